@@ -1,6 +1,9 @@
 package types
 
-import "html/template"
+import (
+	"html/template"
+	"strconv"
+)
 
 // Site represents the site-level configuration and context
 type Site struct {
@@ -103,7 +106,7 @@ type CompileError struct {
 
 func (e *CompileError) Error() string {
 	if e.Line > 0 {
-		return e.File + ":" + string(rune(e.Line)) + ":" + string(rune(e.Column)) + ": " + e.Message
+		return e.File + ":" + strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column) + ": " + e.Message
 	}
 	return e.File + ": " + e.Message
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -53,12 +54,15 @@ func main() {
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, jwtManager, oauthManager)
-	sitesHandler := handlers.NewSitesHandler(db, servingClient, cfg.DefaultPageSize)
+	sitesHandler := handlers.NewSitesHandler(db, servingClient, storageClient, cfg.DefaultPageSize)
 	aliasesHandler := handlers.NewAliasesHandler(db, servingClient)
 	versionsHandler := handlers.NewVersionsHandler(db, storageClient, servingClient, cfg.DefaultPageSize)
-	buildHandler := handlers.NewBuildHandler(db, llmClient, managerClient)
+	buildHandler := handlers.NewBuildHandler(db, llmClient, managerClient, storageClient, cfg.MaxAttachments, cfg.MaxAttachmentBytes)
 	wsHandler := handlers.NewWebSocketHandler(wsHub)
 
+	// Pin what sites serve now, so storage retention never expires it
+	go versionsHandler.PinDeployedVersions(context.Background())
+
 	// Setup router
 	r := mux.NewRouter()
 
@@ -90,6 +94,9 @@ func main() {
 	api.HandleFunc("/sites/{fqdn}", sitesHandler.DeleteSite).Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/sites/{fqdn}/enable", sitesHandler.EnableSite).Methods("POST", "OPTIONS")
 	api.HandleFunc("/sites/{fqdn}/disable", sitesHandler.DisableSite).Methods("POST", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/usage", sitesHandler.GetSiteUsage).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/export", sitesHandler.ExportSite).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/import", sitesHandler.ImportSite).Methods("POST", "OPTIONS")
 
 	// Aliases
 	api.HandleFunc("/sites/{fqdn}/aliases", aliasesHandler.ListAliases).Methods("GET", "OPTIONS")
@@ -99,8 +106,16 @@ func main() {
 	// Versions
 	api.HandleFunc("/sites/{fqdn}/versions", versionsHandler.ListVersions).Methods("GET", "OPTIONS")
 	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/deploy", versionsHandler.DeployVersion).Methods("POST", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/approve", versionsHandler.ApproveVersion).Methods("POST", "OPTIONS")
 	api.HandleFunc("/sites/{fqdn}/versions/{version_id}", versionsHandler.DeleteVersion).Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/download", versionsHandler.DownloadVersion).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/manifest", versionsHandler.GetVersionManifest).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/logs", versionsHandler.GetVersionLogs).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/ancestry", versionsHandler.GetVersionAncestry).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/children", versionsHandler.GetVersionChildren).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/diff/{other_version_id}", versionsHandler.DiffVersions).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/tree", versionsHandler.GetVersionTree).Methods("GET", "OPTIONS")
+	api.HandleFunc("/sites/{fqdn}/versions/{version_id}/files/{path:.+}", versionsHandler.GetVersionFile).Methods("GET", "OPTIONS")
 
 	// Build (chat interface)
 	api.HandleFunc("/sites/{fqdn}/build", buildHandler.Build).Methods("POST", "OPTIONS")
@@ -211,6 +226,10 @@ func runMigrations(db *database.DB) error {
 			CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_token ON password_reset_tokens(token);
 			CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
 		`},
+		{6, `
+			ALTER TABLE versions ADD COLUMN IF NOT EXISTS draft BOOLEAN NOT NULL DEFAULT false;
+			ALTER TABLE versions ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+		`},
 	}
 
 	for _, m := range migrationFiles {
@@ -3,11 +3,15 @@ package clients
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 )
 
+// ErrOverQuota is returned when the manager refuses a job because the site is out of storage
+var ErrOverQuota = errors.New("site is over its storage quota")
+
 type ManagerClient struct {
 	baseURL    string
 	httpClient *http.Client
@@ -37,6 +41,9 @@ func (c *ManagerClient) EnqueueJob(req ManagerJobRequest) (*ManagerJobResponse,
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusInsufficientStorage {
+		return nil, ErrOverQuota
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		return nil, fmt.Errorf("failed to enqueue job: status %d", resp.StatusCode)
 	}
@@ -76,6 +83,10 @@ type ManagerJobRequest struct {
 	BaseBuildID     string            `json:"base_build_id"`
 	RequestedAction string            `json:"requested_action"`
 	UserText        string            `json:"user_text"`
+	TargetVersion   string            `json:"target_version,omitempty"`
+	DryRun          bool              `json:"dry_run,omitempty"` // build a preview-only draft
+	UploadID        string            `json:"upload_id,omitempty"`
+	Attachments     []string          `json:"attachments,omitempty"`
 	Metadata        map[string]string `json:"metadata,omitempty"`
 }
 
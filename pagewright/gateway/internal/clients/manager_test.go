@@ -0,0 +1,20 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestManagerClientOverQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "site is over its storage quota", http.StatusInsufficientStorage)
+	}))
+	defer server.Close()
+
+	_, err := NewManagerClient(server.URL).EnqueueJob(ManagerJobRequest{SiteID: "site-1"})
+	if !errors.Is(err, ErrOverQuota) {
+		t.Errorf("expected ErrOverQuota, got %v", err)
+	}
+}
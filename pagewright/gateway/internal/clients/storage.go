@@ -1,16 +1,35 @@
 package clients
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrNotFound is returned when storage has no such document
+	ErrNotFound = errors.New("not found in storage")
+
+	// ErrInvalidQuery is returned when storage rejects a listing's parameters, e.g. a stale cursor
+	ErrInvalidQuery = errors.New("invalid query")
+
+	// ErrInvalidBundle is returned when storage rejects an import as truncated or malformed
+	ErrInvalidBundle = errors.New("invalid site bundle")
+)
+
 type StorageClient struct {
 	baseURL    string
 	httpClient *http.Client
+
+	// transferClient moves whole site bundles, which take as long as they take
+	transferClient *http.Client
 }
 
 func NewStorageClient(baseURL string) *StorageClient {
@@ -19,6 +38,7 @@ func NewStorageClient(baseURL string) *StorageClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		transferClient: &http.Client{},
 	}
 }
 
@@ -39,9 +59,136 @@ func (c *StorageClient) FetchArtifact(siteID, versionID string) ([]byte, error)
 	return io.ReadAll(resp.Body)
 }
 
-// ListVersions retrieves all versions for a site from storage service
-func (c *StorageClient) ListVersions(siteID string) ([]StorageVersion, error) {
+// FetchManifest returns the build manifest the worker stored with a version
+func (c *StorageClient) FetchManifest(siteID, versionID string) (json.RawMessage, error) {
+	return c.fetchDocument(siteID, versionID, "manifest")
+}
+
+// FetchExecutionLog returns the agent's execution log for a version
+func (c *StorageClient) FetchExecutionLog(siteID, versionID string) (json.RawMessage, error) {
+	return c.fetchDocument(siteID, versionID, "logs")
+}
+
+// FetchAncestry returns a version followed by its parent, grandparent and so on to the root
+func (c *StorageClient) FetchAncestry(siteID, versionID string) (json.RawMessage, error) {
+	return c.fetchJSON(fmt.Sprintf("/sites/%s/versions/%s/ancestry", siteID, versionID), "ancestry")
+}
+
+// FetchChildren returns the versions built directly from a version
+func (c *StorageClient) FetchChildren(siteID, versionID string) (json.RawMessage, error) {
+	return c.fetchJSON(fmt.Sprintf("/sites/%s/versions/%s/children", siteID, versionID), "children")
+}
+
+// FetchDiff returns the files changed between two versions, with unified diffs for text files
+func (c *StorageClient) FetchDiff(siteID, fromVersionID, toVersionID string) (json.RawMessage, error) {
+	query := url.Values{"from": {fromVersionID}, "to": {toVersionID}}
+	return c.fetchJSON(fmt.Sprintf("/sites/%s/diff?%s", siteID, query.Encode()), "diff")
+}
+
+// FetchTree lists the files, directories and symlinks of a version
+func (c *StorageClient) FetchTree(siteID, versionID string) (json.RawMessage, error) {
+	return c.fetchJSON(fmt.Sprintf("/sites/%s/artifacts/%s/tree", siteID, versionID), "tree")
+}
+
+// FetchFile reads one file of a version without downloading the whole artifact
+func (c *StorageClient) FetchFile(siteID, versionID, filePath string) (*StorageFile, error) {
+	segments := strings.Split(strings.Trim(filePath, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	path := fmt.Sprintf("/sites/%s/artifacts/%s/files/%s", siteID, versionID, strings.Join(segments, "/"))
+
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch file: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return &StorageFile{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+	}, nil
+}
+
+// FetchUsage returns the bytes a site and its owner store, with the quotas that apply
+func (c *StorageClient) FetchUsage(siteID string) (json.RawMessage, error) {
+	return c.fetchJSON(fmt.Sprintf("/sites/%s/usage", siteID), "usage")
+}
+
+// SetSiteOwner tells storage which user owns a site, so the owner's sites share one quota
+func (c *StorageClient) SetSiteOwner(siteID, ownerID string) error {
+	url := fmt.Sprintf("%s/sites/%s/owner", c.baseURL, siteID)
+
+	body, err := json.Marshal(map[string]string{"owner_id": ownerID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal owner: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create owner request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to set site owner: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to set site owner: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *StorageClient) fetchDocument(siteID, versionID, name string) (json.RawMessage, error) {
+	return c.fetchJSON(fmt.Sprintf("/sites/%s/artifacts/%s/%s", siteID, versionID, name), name)
+}
+
+// fetchJSON returns a JSON response from storage unchanged
+func (c *StorageClient) fetchJSON(path, what string) (json.RawMessage, error) {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", what, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("failed to fetch %s: invalid JSON", what)
+	}
+	return json.RawMessage(data), nil
+}
+
+// ListVersions retrieves a page of a site's versions from storage, newest first
+func (c *StorageClient) ListVersions(siteID string, query VersionQuery) (*StorageVersionPage, error) {
 	url := fmt.Sprintf("%s/sites/%s/versions", c.baseURL, siteID)
+	if params := query.values(); len(params) > 0 {
+		url += "?" + params.Encode()
+	}
 
 	resp, err := c.httpClient.Get(url)
 	if err != nil {
@@ -49,24 +196,27 @@ func (c *StorageClient) ListVersions(siteID string) ([]StorageVersion, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, fmt.Errorf("%w: status %d", ErrInvalidQuery, resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to list versions: status %d", resp.StatusCode)
 	}
 
-	var result struct {
-		Versions []StorageVersion `json:"versions"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	var page StorageVersionPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		return nil, fmt.Errorf("failed to decode versions response: %w", err)
 	}
+	if page.Versions == nil {
+		page.Versions = []StorageVersion{}
+	}
 
-	return result.Versions, nil
+	return &page, nil
 }
 
 // DeleteVersion deletes a version from storage
 func (c *StorageClient) DeleteVersion(siteID, versionID string) error {
-	url := fmt.Sprintf("%s/artifacts/%s/%s", c.baseURL, siteID, versionID)
+	url := fmt.Sprintf("%s/sites/%s/artifacts/%s", c.baseURL, siteID, versionID)
 
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
@@ -86,8 +236,176 @@ func (c *StorageClient) DeleteVersion(siteID, versionID string) error {
 	return nil
 }
 
+// DeleteSite deletes every version and file stored for a site
+func (c *StorageClient) DeleteSite(siteID string) error {
+	url := fmt.Sprintf("%s/sites/%s", c.baseURL, siteID)
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete site: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to delete site: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SetPinned pins or unpins a version; storage's retention sweep never deletes pinned versions
+func (c *StorageClient) SetPinned(siteID, versionID string, pinned bool) error {
+	url := fmt.Sprintf("%s/sites/%s/artifacts/%s/pin", c.baseURL, siteID, versionID)
+
+	method := http.MethodPut
+	if !pinned {
+		method = http.MethodDelete
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create pin request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to pin version: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: version %s", ErrNotFound, versionID)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to pin version: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// UploadAttachment stores a file uploaded with a build request under the upload ID
+func (c *StorageClient) UploadAttachment(siteID, uploadID, name string, reader io.Reader) error {
+	url := fmt.Sprintf("%s/sites/%s/attachments/%s/%s", c.baseURL, siteID, uploadID, name)
+
+	req, err := http.NewRequest(http.MethodPut, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload attachment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload attachment: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ExportSite streams the bundle of everything storage holds for a site. It returns
+// ErrNotFound if storage has nothing for the site yet.
+func (c *StorageClient) ExportSite(siteID string) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/sites/%s/export", c.baseURL, siteID)
+
+	resp, err := c.transferClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export site: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: site %s", ErrNotFound, siteID)
+		}
+		return nil, fmt.Errorf("failed to export site: status %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// ImportSite streams a bundle made by ExportSite into storage as siteID and returns what
+// storage imported
+func (c *StorageClient) ImportSite(siteID string, bundle io.Reader) (json.RawMessage, error) {
+	url := fmt.Sprintf("%s/sites/%s/import", c.baseURL, siteID)
+
+	resp, err := c.transferClient.Post(url, "application/x-tar", bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import site: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusBadRequest:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBundle, strings.TrimSpace(string(message)))
+	case http.StatusRequestEntityTooLarge, http.StatusInsufficientStorage:
+		return nil, ErrOverQuota
+	default:
+		return nil, fmt.Errorf("failed to import site: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import summary: %w", err)
+	}
+	return json.RawMessage(data), nil
+}
+
+// VersionQuery filters and pages a version listing. Zero values are left out.
+type VersionQuery struct {
+	Limit  int
+	Cursor string
+	Status string
+	Action string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (q VersionQuery) values() url.Values {
+	params := url.Values{}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	for name, value := range map[string]string{"cursor": q.Cursor, "status": q.Status, "action": q.Action} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	if !q.Since.IsZero() {
+		params.Set("since", q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		params.Set("until", q.Until.UTC().Format(time.RFC3339))
+	}
+	return params
+}
+
+// StorageVersionPage is one page of versions; NextCursor is empty on the last page
+type StorageVersionPage struct {
+	Versions   []StorageVersion `json:"versions"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
 type StorageVersion struct {
-	BuildID   string    `json:"build_id"`
-	Timestamp time.Time `json:"timestamp"`
-	Size      int64     `json:"size"`
+	BuildID       string    `json:"build_id"`
+	ParentBuildID string    `json:"parent_build_id,omitempty"` // the version it was built from
+	Timestamp     time.Time `json:"timestamp"`
+	Size          int64     `json:"size"`
+	Draft         bool      `json:"draft,omitempty"` // unapproved dry-run version, set by the gateway
+}
+
+// StorageFile is one file read from a stored version
+type StorageFile struct {
+	Data        []byte
+	ContentType string
+	ETag        string // the file's SHA-256, quoted
 }
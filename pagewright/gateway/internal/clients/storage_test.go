@@ -0,0 +1,243 @@
+package clients
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageClientDeleteAndPinPaths(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewStorageClient(server.URL)
+	if err := client.DeleteVersion("site-1", "v1"); err != nil {
+		t.Fatalf("DeleteVersion: %v", err)
+	}
+	if err := client.SetPinned("site-1", "v2", true); err != nil {
+		t.Fatalf("SetPinned: %v", err)
+	}
+	if err := client.SetPinned("site-1", "v1", false); err != nil {
+		t.Fatalf("SetPinned: %v", err)
+	}
+	if err := client.DeleteSite("site-1"); err != nil {
+		t.Fatalf("DeleteSite: %v", err)
+	}
+	if err := client.SetSiteOwner("site-2", "user-1"); err != nil {
+		t.Fatalf("SetSiteOwner: %v", err)
+	}
+
+	want := []string{
+		"DELETE /sites/site-1/artifacts/v1",
+		"PUT /sites/site-1/artifacts/v2/pin",
+		"DELETE /sites/site-1/artifacts/v1/pin",
+		"DELETE /sites/site-1",
+		"PUT /sites/site-2/owner",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("requests = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStorageClientPinMissingVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewStorageClient(server.URL)
+	if err := client.SetPinned("site-1", "v1", true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SetPinned error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStorageClientFetchDocuments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sites/site-1/artifacts/v1/manifest":
+			w.Write([]byte(`{"changes_summary":"Added a menu page"}`))
+		case "/sites/site-1/artifacts/v1/logs":
+			w.Write([]byte(`{"content":"agent output"}`))
+		case "/sites/site-1/diff":
+			w.Write([]byte(`{"from":"` + r.URL.Query().Get("from") + `","to":"` + r.URL.Query().Get("to") + `"}`))
+		case "/sites/site-1/artifacts/v1/tree":
+			w.Write([]byte(`{"files":[{"path":"content/index.md","type":"file"}],"count":1}`))
+		case "/sites/site-1/artifacts/v1/files/content/my page.md":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Header().Set("ETag", `"abc"`)
+			w.Write([]byte("# My page\n"))
+		case "/sites/site-1/usage":
+			w.Write([]byte(`{"site_id":"site-1","bytes":2048,"quota_bytes":0,"over_quota":false}`))
+		case "/sites/site-1/versions/v1/children":
+			w.Write([]byte(`{"children":[{"build_id":"v2","parent_build_id":"v1"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewStorageClient(server.URL)
+	manifest, err := client.FetchManifest("site-1", "v1")
+	if err != nil {
+		t.Fatalf("FetchManifest: %v", err)
+	}
+	if string(manifest) != `{"changes_summary":"Added a menu page"}` {
+		t.Errorf("manifest = %s", manifest)
+	}
+	logs, err := client.FetchExecutionLog("site-1", "v1")
+	if err != nil {
+		t.Fatalf("FetchExecutionLog: %v", err)
+	}
+	if string(logs) != `{"content":"agent output"}` {
+		t.Errorf("logs = %s", logs)
+	}
+
+	usage, err := client.FetchUsage("site-1")
+	if err != nil {
+		t.Fatalf("FetchUsage: %v", err)
+	}
+	if string(usage) != `{"site_id":"site-1","bytes":2048,"quota_bytes":0,"over_quota":false}` {
+		t.Errorf("usage = %s", usage)
+	}
+
+	children, err := client.FetchChildren("site-1", "v1")
+	if err != nil {
+		t.Fatalf("FetchChildren: %v", err)
+	}
+	if string(children) != `{"children":[{"build_id":"v2","parent_build_id":"v1"}]}` {
+		t.Errorf("children = %s", children)
+	}
+
+	diff, err := client.FetchDiff("site-1", "v1", "v 2")
+	if err != nil {
+		t.Fatalf("FetchDiff: %v", err)
+	}
+	if string(diff) != `{"from":"v1","to":"v 2"}` {
+		t.Errorf("diff = %s", diff)
+	}
+
+	tree, err := client.FetchTree("site-1", "v1")
+	if err != nil {
+		t.Fatalf("FetchTree: %v", err)
+	}
+	if string(tree) != `{"files":[{"path":"content/index.md","type":"file"}],"count":1}` {
+		t.Errorf("tree = %s", tree)
+	}
+
+	file, err := client.FetchFile("site-1", "v1", "content/my page.md")
+	if err != nil {
+		t.Fatalf("FetchFile: %v", err)
+	}
+	if string(file.Data) != "# My page\n" || file.ContentType != "text/markdown; charset=utf-8" || file.ETag != `"abc"` {
+		t.Errorf("file = %+v", file)
+	}
+	if _, err := client.FetchFile("site-1", "v1", "missing.md"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := client.FetchAncestry("site-1", "v9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := client.FetchManifest("site-1", "v2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStorageClientListVersions(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "stale" {
+			http.Error(w, "Invalid query", http.StatusBadRequest)
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		w.Write([]byte(`{"versions":[{"build_id":"v2"}],"next_cursor":"next"}`))
+	}))
+	defer server.Close()
+
+	client := NewStorageClient(server.URL)
+	page, err := client.ListVersions("site-1", VersionQuery{
+		Limit:  20,
+		Cursor: "abc",
+		Status: "success",
+		Since:  time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("ListVersions: %v", err)
+	}
+	if gotQuery != "cursor=abc&limit=20&since=2026-03-01T00%3A00%3A00Z&status=success" {
+		t.Errorf("query = %s", gotQuery)
+	}
+	if len(page.Versions) != 1 || page.Versions[0].BuildID != "v2" || page.NextCursor != "next" {
+		t.Errorf("page = %+v", page)
+	}
+
+	if _, err := client.ListVersions("site-1", VersionQuery{Cursor: "stale"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery, got %v", err)
+	}
+}
+
+func TestStorageClientExportImport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /sites/site-1/export":
+			w.Write([]byte("bundle"))
+		case "POST /sites/site-2/import":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "bundle" {
+				http.Error(w, "Failed to import site: invalid bundle", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"site_id":"site-2","versions":1}`))
+		case "POST /sites/site-3/import":
+			w.WriteHeader(http.StatusInsufficientStorage)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewStorageClient(server.URL)
+	bundle, err := client.ExportSite("site-1")
+	if err != nil {
+		t.Fatalf("ExportSite: %v", err)
+	}
+	data, _ := io.ReadAll(bundle)
+	bundle.Close()
+	if string(data) != "bundle" {
+		t.Errorf("bundle = %q", data)
+	}
+
+	if _, err := client.ExportSite("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	summary, err := client.ImportSite("site-2", strings.NewReader("bundle"))
+	if err != nil {
+		t.Fatalf("ImportSite: %v", err)
+	}
+	if string(summary) != `{"site_id":"site-2","versions":1}` {
+		t.Errorf("summary = %s", summary)
+	}
+
+	if _, err := client.ImportSite("site-2", strings.NewReader("garbage")); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("expected ErrInvalidBundle, got %v", err)
+	}
+	if _, err := client.ImportSite("site-3", strings.NewReader("bundle")); !errors.Is(err, ErrOverQuota) {
+		t.Errorf("expected ErrOverQuota, got %v", err)
+	}
+}
@@ -33,6 +33,10 @@ type Config struct {
 
 	// Pagination
 	DefaultPageSize int
+
+	// Files uploaded with build requests
+	MaxAttachments     int
+	MaxAttachmentBytes int64
 }
 
 func LoadConfig() *Config {
@@ -50,6 +54,8 @@ func LoadConfig() *Config {
 		LLMKey:             getEnv("PAGEWRIGHT_LLM_KEY", ""),
 		LLMURL:             getEnv("PAGEWRIGHT_LLM_URL", "https://api.openai.com/v1"),
 		DefaultPageSize:    getEnvInt("PAGEWRIGHT_DEFAULT_PAGE_SIZE", 25),
+		MaxAttachments:     getEnvInt("PAGEWRIGHT_MAX_ATTACHMENTS", 10),
+		MaxAttachmentBytes: int64(getEnvInt("PAGEWRIGHT_MAX_ATTACHMENT_BYTES", 10*1024*1024)),
 	}
 }
 
@@ -78,6 +78,21 @@ func (db *DB) GetUserSites(userID string, limit, offset int) ([]types.Site, int,
 	return sites, totalCount, nil
 }
 
+// GetDeployedSites returns every site that serves a live or preview version
+func (db *DB) GetDeployedSites() ([]types.Site, error) {
+	var sites []types.Site
+	query := `
+		SELECT id, fqdn, user_id, template_id, live_version_id, preview_version_id, enabled, created_at, updated_at
+		FROM sites WHERE live_version_id IS NOT NULL OR preview_version_id IS NOT NULL
+	`
+
+	if err := db.Select(&sites, query); err != nil {
+		return nil, fmt.Errorf("failed to get deployed sites: %w", err)
+	}
+
+	return sites, nil
+}
+
 func (db *DB) UpdateSiteEnabled(fqdn string, enabled bool) error {
 	query := `UPDATE sites SET enabled = $1, updated_at = $2 WHERE fqdn = $3`
 	_, err := db.Exec(query, enabled, time.Now(), fqdn)
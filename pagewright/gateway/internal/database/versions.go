@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/types"
@@ -9,21 +10,22 @@ import (
 
 // Version operations
 
-func (db *DB) CreateVersion(siteID, buildID, status string) (*types.Version, error) {
+func (db *DB) CreateVersion(siteID, buildID, status string, draft bool) (*types.Version, error) {
 	version := &types.Version{
 		ID:      uuid.New().String(),
 		SiteID:  siteID,
 		BuildID: buildID,
 		Status:  status,
+		Draft:   draft,
 	}
 
 	query := `
-		INSERT INTO versions (id, site_id, build_id, status)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO versions (id, site_id, build_id, status, draft)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING created_at
 	`
 
-	err := db.QueryRow(query, version.ID, version.SiteID, version.BuildID, version.Status).Scan(&version.CreatedAt)
+	err := db.QueryRow(query, version.ID, version.SiteID, version.BuildID, version.Status, version.Draft).Scan(&version.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create version: %w", err)
 	}
@@ -34,7 +36,7 @@ func (db *DB) CreateVersion(siteID, buildID, status string) (*types.Version, err
 func (db *DB) GetSiteVersions(siteID string, limit, offset int) ([]types.Version, int, error) {
 	var versions []types.Version
 	query := `
-		SELECT id, site_id, build_id, status, created_at
+		SELECT id, site_id, build_id, status, draft, approved_at, created_at
 		FROM versions WHERE site_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
@@ -56,6 +58,82 @@ func (db *DB) GetSiteVersions(siteID string, limit, offset int) ([]types.Version
 	return versions, totalCount, nil
 }
 
+// GetAllSiteVersions returns every version record of a site, oldest first
+func (db *DB) GetAllSiteVersions(siteID string) ([]types.Version, error) {
+	var versions []types.Version
+	query := `
+		SELECT id, site_id, build_id, status, draft, approved_at, created_at
+		FROM versions WHERE site_id = $1
+		ORDER BY created_at
+	`
+
+	if err := db.Select(&versions, query, siteID); err != nil {
+		return nil, fmt.Errorf("failed to get site versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// ImportVersion adds a version record from a site export, keeping its dates
+func (db *DB) ImportVersion(siteID string, exported types.ExportedVersion) error {
+	query := `
+		INSERT INTO versions (id, site_id, build_id, status, draft, approved_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := db.Exec(query, uuid.New().String(), siteID, exported.BuildID, exported.Status, exported.Draft, exported.ApprovedAt, exported.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to import version: %w", err)
+	}
+
+	return nil
+}
+
+// GetVersion returns the version record for a build, or nil if the gateway has none
+func (db *DB) GetVersion(siteID, buildID string) (*types.Version, error) {
+	version := &types.Version{}
+	query := `
+		SELECT id, site_id, build_id, status, draft, approved_at, created_at
+		FROM versions WHERE site_id = $1 AND build_id = $2
+	`
+
+	err := db.Get(version, query, siteID, buildID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+
+	return version, nil
+}
+
+// GetUnapprovedDrafts returns the build IDs of a site's drafts that have not been approved
+func (db *DB) GetUnapprovedDrafts(siteID string) (map[string]bool, error) {
+	var buildIDs []string
+	query := `SELECT build_id FROM versions WHERE site_id = $1 AND draft AND approved_at IS NULL`
+
+	if err := db.Select(&buildIDs, query, siteID); err != nil {
+		return nil, fmt.Errorf("failed to get draft versions: %w", err)
+	}
+
+	drafts := make(map[string]bool, len(buildIDs))
+	for _, id := range buildIDs {
+		drafts[id] = true
+	}
+	return drafts, nil
+}
+
+// ApproveVersion allows a draft to be deployed live
+func (db *DB) ApproveVersion(siteID, buildID string) error {
+	query := `UPDATE versions SET approved_at = NOW() WHERE site_id = $1 AND build_id = $2 AND approved_at IS NULL`
+	_, err := db.Exec(query, siteID, buildID)
+	if err != nil {
+		return fmt.Errorf("failed to approve version: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) UpdateVersionStatus(buildID, status string) error {
 	query := `UPDATE versions SET status = $1 WHERE build_id = $2`
 	_, err := db.Exec(query, status, buildID)
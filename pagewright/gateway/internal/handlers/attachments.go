@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/types"
+	"github.com/google/uuid"
+)
+
+// errAttachmentTooLarge is returned for uploads over the size limit
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+// maxAttachmentNameLength matches the limit storage enforces
+const maxAttachmentNameLength = 128
+
+// parseBuildRequest reads a build request sent as JSON or, when files are attached, as
+// multipart/form-data with "message", "conversation_id", "dry_run" and one "files" part per file
+func (h *BuildHandler) parseBuildRequest(w http.ResponseWriter, r *http.Request) (types.BuildRequest, []*multipart.FileHeader, error) {
+	var req types.BuildRequest
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, nil, fmt.Errorf("invalid request body")
+		}
+		return req, nil, nil
+	}
+
+	// Room for every file at the size limit plus the form fields
+	maxBody := int64(h.maxAttachments)*h.maxAttachmentBytes + 1<<20
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return req, nil, errAttachmentTooLarge
+		}
+		return req, nil, fmt.Errorf("invalid multipart body")
+	}
+
+	req.Message = r.FormValue("message")
+	if id := r.FormValue("conversation_id"); id != "" {
+		req.ConversationID = &id
+	}
+	req.DryRun, _ = strconv.ParseBool(r.FormValue("dry_run"))
+
+	files := r.MultipartForm.File["files"]
+	if len(files) > h.maxAttachments {
+		return req, nil, fmt.Errorf("at most %d files can be attached", h.maxAttachments)
+	}
+	for _, file := range files {
+		if file.Size > h.maxAttachmentBytes {
+			return req, nil, errAttachmentTooLarge
+		}
+	}
+
+	return req, files, nil
+}
+
+// storeAttachments uploads files to storage under uploadID, creating one if empty.
+// It returns the upload ID and the stored file names, which avoid those already taken.
+func (h *BuildHandler) storeAttachments(siteID, uploadID string, taken []string, files []*multipart.FileHeader) (string, []string, error) {
+	if len(files) == 0 {
+		return uploadID, nil, nil
+	}
+	if uploadID == "" {
+		uploadID = uuid.New().String()
+	}
+
+	used := make(map[string]bool, len(taken)+len(files))
+	for _, name := range taken {
+		used[name] = true
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		name := uniqueName(attachmentName(file.Filename), used)
+		used[name] = true
+
+		f, err := file.Open()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", file.Filename, err)
+		}
+		err = h.storageClient.UploadAttachment(siteID, uploadID, name, f)
+		f.Close()
+		if err != nil {
+			return "", nil, err
+		}
+		names = append(names, name)
+	}
+
+	return uploadID, names, nil
+}
+
+// attachmentName turns an uploaded file name into one safe to store and place in the site:
+// the base name with each run of characters other than letters, digits, '.', '-' and '_'
+// replaced by a single '-'
+func attachmentName(filename string) string {
+	base := filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+
+	var b strings.Builder
+	replaced := false
+	for _, r := range base {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+			replaced = false
+		case !replaced:
+			b.WriteByte('-')
+			replaced = true
+		}
+	}
+
+	name := strings.TrimLeft(b.String(), ".-_")
+	if name == "" {
+		name = "attachment"
+	}
+	if len(name) > maxAttachmentNameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = name[:maxAttachmentNameLength-len(ext)] + ext
+	}
+	return name
+}
+
+// uniqueName appends -2, -3, ... before the extension until name is not in used
+func uniqueName(name string, used map[string]bool) string {
+	if !used[name] {
+		return name
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		suffix := "-" + strconv.Itoa(i)
+		candidate := stem
+		if len(candidate)+len(suffix)+len(ext) > maxAttachmentNameLength {
+			candidate = candidate[:maxAttachmentNameLength-len(suffix)-len(ext)]
+		}
+		candidate += suffix + ext
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+// withAttachments lists the attached files after the message so the LLM knows what
+// "this logo" refers to
+func withAttachments(message string, names []string) string {
+	if len(names) == 0 {
+		return message
+	}
+	return fmt.Sprintf("%s\n\nAttached files: %s", message, strings.Join(names, ", "))
+}
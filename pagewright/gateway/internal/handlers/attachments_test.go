@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/clients"
+)
+
+// multipartBuild builds a multipart build request with the given files
+func multipartBuild(t *testing.T, message string, files map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("message", message); err != nil {
+		t.Fatalf("failed to write field: %v", err)
+	}
+	for name, content := range files {
+		part, err := mw.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/sites/example.com/build", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestAttachmentName(t *testing.T) {
+	tests := map[string]string{
+		"logo.png":              "logo.png",
+		"Menu 2024 (final).pdf": "Menu-2024-final-.pdf",
+		"../../etc/passwd":      "passwd",
+		`C:\Users\me\logo.png`:  "logo.png",
+		".htaccess":             "htaccess",
+		"...":                   "attachment",
+	}
+	for input, expected := range tests {
+		if got := attachmentName(input); got != expected {
+			t.Errorf("attachmentName(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+
+	long := attachmentName(strings.Repeat("a", 300) + ".png")
+	if len(long) != maxAttachmentNameLength || !strings.HasSuffix(long, ".png") {
+		t.Errorf("expected a %d character name ending in .png, got %q", maxAttachmentNameLength, long)
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	used := map[string]bool{"logo.png": true, "logo-2.png": true}
+	if got := uniqueName("logo.png", used); got != "logo-3.png" {
+		t.Errorf("expected logo-3.png, got %s", got)
+	}
+	if got := uniqueName("menu.pdf", used); got != "menu.pdf" {
+		t.Errorf("expected menu.pdf, got %s", got)
+	}
+}
+
+func TestWithAttachments(t *testing.T) {
+	if got := withAttachments("Use this logo", nil); got != "Use this logo" {
+		t.Errorf("expected message unchanged, got %q", got)
+	}
+	expected := "Use this logo\n\nAttached files: logo.png, menu.pdf"
+	if got := withAttachments("Use this logo", []string{"logo.png", "menu.pdf"}); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestParseBuildRequestJSON(t *testing.T) {
+	h := &BuildHandler{maxAttachments: 2, maxAttachmentBytes: 1024}
+	req := httptest.NewRequest("POST", "/sites/example.com/build", strings.NewReader(`{"message":"Add a page"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	parsed, files, err := h.parseBuildRequest(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Message != "Add a page" || len(files) != 0 {
+		t.Errorf("unexpected request: %+v, %d files", parsed, len(files))
+	}
+}
+
+func TestParseBuildRequestMultipart(t *testing.T) {
+	h := &BuildHandler{maxAttachments: 2, maxAttachmentBytes: 1024}
+
+	parsed, files, err := h.parseBuildRequest(httptest.NewRecorder(), multipartBuild(t, "Use this logo", map[string]string{"logo.png": "png"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Message != "Use this logo" || parsed.DryRun {
+		t.Errorf("unexpected request: %+v", parsed)
+	}
+	if len(files) != 1 || files[0].Filename != "logo.png" {
+		t.Errorf("expected logo.png, got %v", files)
+	}
+
+	_, _, err = h.parseBuildRequest(httptest.NewRecorder(), multipartBuild(t, "Too many", map[string]string{"a.png": "a", "b.png": "b", "c.png": "c"}))
+	if err == nil || !strings.Contains(err.Error(), "at most 2 files") {
+		t.Errorf("expected file count error, got %v", err)
+	}
+
+	_, _, err = h.parseBuildRequest(httptest.NewRecorder(), multipartBuild(t, "Too big", map[string]string{"big.pdf": strings.Repeat("x", 2048)}))
+	if !errors.Is(err, errAttachmentTooLarge) {
+		t.Errorf("expected errAttachmentTooLarge, got %v", err)
+	}
+}
+
+func TestParseBuildRequestDryRun(t *testing.T) {
+	h := &BuildHandler{maxAttachments: 2, maxAttachmentBytes: 1024}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("message", "Show me first")
+	mw.WriteField("dry_run", "true")
+	mw.Close()
+	req := httptest.NewRequest("POST", "/sites/example.com/build", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	parsed, _, err := h.parseBuildRequest(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !parsed.DryRun {
+		t.Error("expected dry_run to be set")
+	}
+}
+
+func TestStoreAttachments(t *testing.T) {
+	var mu sync.Mutex
+	uploaded := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploaded[r.URL.Path] = string(data)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	h := &BuildHandler{storageClient: clients.NewStorageClient(server.URL), maxAttachments: 5, maxAttachmentBytes: 1024}
+	_, files, err := h.parseBuildRequest(httptest.NewRecorder(), multipartBuild(t, "Use this logo", map[string]string{"logo.png": "png"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// logo.png is taken by an earlier upload in the same conversation
+	uploadID, names, err := h.storeAttachments("site-1", "upload-1", []string{"logo.png"}, files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uploadID != "upload-1" || len(names) != 1 || names[0] != "logo-2.png" {
+		t.Errorf("unexpected upload %s: %v", uploadID, names)
+	}
+	if got := uploaded["/sites/site-1/attachments/upload-1/logo-2.png"]; got != "png" {
+		t.Errorf("expected file content in storage, got %q", got)
+	}
+
+	uploadID, names, err = h.storeAttachments("site-1", "", nil, nil)
+	if err != nil || uploadID != "" || len(names) != 0 {
+		t.Errorf("expected no upload without files, got %q %v %v", uploadID, names, err)
+	}
+}
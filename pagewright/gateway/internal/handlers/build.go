@@ -1,7 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/clients"
@@ -13,16 +16,22 @@ import (
 )
 
 type BuildHandler struct {
-	db            *database.DB
-	llmClient     *clients.LLMClient
-	managerClient *clients.ManagerClient
+	db                 *database.DB
+	llmClient          *clients.LLMClient
+	managerClient      *clients.ManagerClient
+	storageClient      *clients.StorageClient
+	maxAttachments     int
+	maxAttachmentBytes int64
 }
 
-func NewBuildHandler(db *database.DB, llmClient *clients.LLMClient, managerClient *clients.ManagerClient) *BuildHandler {
+func NewBuildHandler(db *database.DB, llmClient *clients.LLMClient, managerClient *clients.ManagerClient, storageClient *clients.StorageClient, maxAttachments int, maxAttachmentBytes int64) *BuildHandler {
 	return &BuildHandler{
-		db:            db,
-		llmClient:     llmClient,
-		managerClient: managerClient,
+		db:                 db,
+		llmClient:          llmClient,
+		managerClient:      managerClient,
+		storageClient:      storageClient,
+		maxAttachments:     maxAttachments,
+		maxAttachmentBytes: maxAttachmentBytes,
 	}
 }
 
@@ -34,6 +43,9 @@ type conversationContext struct {
 	UserID          string
 	SiteID          string
 	OriginalMessage string
+	UploadID        string
+	Attachments     []string
+	DryRun          bool
 }
 
 // Build handles build requests with OpenAI clarification loop
@@ -42,9 +54,13 @@ func (h *BuildHandler) Build(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	fqdn := vars["fqdn"]
 
-	var req types.BuildRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
+	req, files, err := h.parseBuildRequest(w, r)
+	if errors.Is(err, errAttachmentTooLarge) {
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("each file must be at most %d bytes", h.maxAttachmentBytes))
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -66,12 +82,18 @@ func (h *BuildHandler) Build(w http.ResponseWriter, r *http.Request) {
 
 	// Check if this is a clarification response
 	if req.ConversationID != nil {
-		h.handleClarification(w, r, site, req)
+		h.handleClarification(w, r, site, req, files)
+		return
+	}
+
+	uploadID, attachments, err := h.storeAttachments(site.ID, "", nil, files)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to store attachments")
 		return
 	}
 
 	// Initial request - evaluate if clear
-	evaluation, err := h.llmClient.EvaluateRequest(req.Message)
+	evaluation, err := h.llmClient.EvaluateRequest(withAttachments(req.Message, attachments))
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to evaluate request")
 		return
@@ -84,20 +106,24 @@ func (h *BuildHandler) Build(w http.ResponseWriter, r *http.Request) {
 			UserID:          user.UserID,
 			SiteID:          site.ID,
 			OriginalMessage: req.Message,
+			UploadID:        uploadID,
+			Attachments:     attachments,
+			DryRun:          req.DryRun,
 		}
 
 		respondJSON(w, types.BuildResponse{
 			Question:       &evaluation.Question,
 			ConversationID: &conversationID,
+			Attachments:    attachments,
 		})
 		return
 	}
 
 	// Request is clear - generate instructions and enqueue job
-	h.enqueueJob(w, site, req.Message, "")
+	h.enqueueJob(w, site, req.Message, "", uploadID, attachments, req.DryRun)
 }
 
-func (h *BuildHandler) handleClarification(w http.ResponseWriter, r *http.Request, site *types.Site, req types.BuildRequest) {
+func (h *BuildHandler) handleClarification(w http.ResponseWriter, r *http.Request, site *types.Site, req types.BuildRequest, files []*multipart.FileHeader) {
 	// Get conversation context
 	ctx, exists := conversationStore[*req.ConversationID]
 	if !exists {
@@ -112,16 +138,23 @@ func (h *BuildHandler) handleClarification(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Files sent with the answer join those from the original request
+	uploadID, added, err := h.storeAttachments(site.ID, ctx.UploadID, ctx.Attachments, files)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to store attachments")
+		return
+	}
+
 	// Clean up conversation
 	delete(conversationStore, *req.ConversationID)
 
 	// Generate instructions with clarification and enqueue job
-	h.enqueueJob(w, site, ctx.OriginalMessage, req.Message)
+	h.enqueueJob(w, site, ctx.OriginalMessage, req.Message, uploadID, append(ctx.Attachments, added...), ctx.DryRun || req.DryRun)
 }
 
-func (h *BuildHandler) enqueueJob(w http.ResponseWriter, site *types.Site, originalMessage, clarification string) {
+func (h *BuildHandler) enqueueJob(w http.ResponseWriter, site *types.Site, originalMessage, clarification, uploadID string, attachments []string, dryRun bool) {
 	// Generate job instructions using LLM
-	instructions, err := h.llmClient.GenerateJobInstructions(originalMessage, clarification)
+	instructions, err := h.llmClient.GenerateJobInstructions(withAttachments(originalMessage, attachments), clarification)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to generate instructions")
 		return
@@ -133,28 +166,47 @@ func (h *BuildHandler) enqueueJob(w http.ResponseWriter, site *types.Site, origi
 		baseBuildID = *site.LiveVersionID
 	}
 
+	// The gateway picks the version ID so the version record matches the build in storage
+	versionID := uuid.New().String()
+
 	// Enqueue job in manager
 	jobReq := clients.ManagerJobRequest{
 		SiteID:          site.ID,
 		BaseBuildID:     baseBuildID,
+		TargetVersion:   versionID,
+		DryRun:          dryRun,
 		RequestedAction: "edit",
 		UserText:        instructions,
+		UploadID:        uploadID,
+		Attachments:     attachments,
 		Metadata: map[string]string{
 			"original_message": originalMessage,
 			"fqdn":             site.FQDN,
 		},
 	}
 
+	// Sites created before quotas were tracked get their owner recorded here
+	if err := h.storageClient.SetSiteOwner(site.ID, site.UserID); err != nil {
+		log.Printf("Warning: failed to record owner of site %s: %v", site.ID, err)
+	}
+
 	jobResp, err := h.managerClient.EnqueueJob(jobReq)
+	if errors.Is(err, clients.ErrOverQuota) {
+		respondError(w, http.StatusInsufficientStorage, "site is over its storage quota; delete old versions to free space")
+		return
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to enqueue job")
 		return
 	}
 
 	// Create version record in database
-	h.db.CreateVersion(site.ID, jobResp.JobID, "pending")
+	h.db.CreateVersion(site.ID, versionID, "pending", dryRun)
 
 	respondJSON(w, types.BuildResponse{
-		JobID: &jobResp.JobID,
+		JobID:       &jobResp.JobID,
+		VersionID:   &versionID,
+		Draft:       dryRun,
+		Attachments: attachments,
 	})
 }
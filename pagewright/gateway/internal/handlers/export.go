@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/clients"
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/middleware"
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/types"
+	"github.com/gorilla/mux"
+)
+
+// A site export is a tar: site.json with the gateway's records of the site, followed by
+// the entries of the storage bundle with its versions, unchanged.
+
+// siteExportVersion is the site.json layout this gateway writes and the newest it reads
+const siteExportVersion = 1
+
+// maxSiteJSONBytes bounds site.json, which lists every version record
+const maxSiteJSONBytes = 16 << 20
+
+// ExportSite streams a site export: settings, aliases, version records and everything
+// storage holds for the site
+func (h *SitesHandler) ExportSite(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUserFromContext(r)
+	fqdn := mux.Vars(r)["fqdn"]
+
+	site, err := h.db.GetSiteByFQDN(fqdn)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get site")
+		return
+	}
+
+	if site == nil {
+		respondError(w, http.StatusNotFound, "site not found")
+		return
+	}
+
+	if site.UserID != user.UserID {
+		respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	aliases, err := h.db.GetSiteAliases(site.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get aliases")
+		return
+	}
+
+	versions, err := h.db.GetAllSiteVersions(site.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get versions")
+		return
+	}
+
+	// A site that never built has nothing in storage; its export is site.json alone
+	bundle, err := h.storageClient.ExportSite(site.ID)
+	if err != nil && !errors.Is(err, clients.ErrNotFound) {
+		respondError(w, http.StatusInternalServerError, "failed to export site versions")
+		return
+	}
+	if bundle != nil {
+		defer bundle.Close()
+	}
+
+	// Every version passes through, so the export outlasts the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to lift write deadline for export of %s: %v", fqdn, err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.export.tar", fqdn))
+	if err := writeSiteExport(w, newSiteExport(site, aliases, versions), bundle); err != nil {
+		// The response has started; the truncated tar fails to import
+		log.Printf("Warning: export of site %s failed: %v", fqdn, err)
+	}
+}
+
+// ImportSite creates the site {fqdn} from a site export, owned by the caller. It restores
+// the versions, version records and aliases, and deploys the versions that were live and
+// in preview. Aliases another site already uses are skipped.
+func (h *SitesHandler) ImportSite(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUserFromContext(r)
+	fqdn := mux.Vars(r)["fqdn"]
+
+	if err := http.NewResponseController(w).SetReadDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to lift read deadline for import of %s: %v", fqdn, err)
+	}
+
+	tr := tar.NewReader(r.Body)
+	export, err := readSiteExport(tr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid site export")
+		return
+	}
+
+	existing, err := h.db.GetSiteByFQDN(fqdn)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get site")
+		return
+	}
+	if existing != nil {
+		respondError(w, http.StatusConflict, "site already exists")
+		return
+	}
+
+	site, err := h.db.CreateSite(user.UserID, fqdn, export.TemplateID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create site")
+		return
+	}
+
+	// Set before the import, so imported versions count toward the owner's quota
+	if err := h.storageClient.SetSiteOwner(site.ID, user.UserID); err != nil {
+		log.Printf("Warning: failed to record owner of site %s: %v", site.ID, err)
+	}
+
+	summary, err := h.importVersions(site.ID, tr)
+	if err != nil {
+		if cleanupErr := h.storageClient.DeleteSite(site.ID); cleanupErr != nil {
+			log.Printf("Warning: failed to delete storage for site %s: %v", site.ID, cleanupErr)
+		}
+		if cleanupErr := h.db.DeleteSite(fqdn); cleanupErr != nil {
+			log.Printf("Warning: failed to remove partially imported site %s: %v", fqdn, cleanupErr)
+		}
+		switch {
+		case errors.Is(err, clients.ErrInvalidBundle):
+			respondError(w, http.StatusBadRequest, "invalid site export")
+		case errors.Is(err, clients.ErrOverQuota):
+			respondError(w, http.StatusInsufficientStorage, "site is over its storage quota")
+		default:
+			log.Printf("Warning: failed to import versions of site %s: %v", fqdn, err)
+			respondError(w, http.StatusInternalServerError, "failed to import site versions")
+		}
+		return
+	}
+
+	for _, version := range export.Versions {
+		if err := h.db.ImportVersion(site.ID, version); err != nil {
+			log.Printf("Warning: failed to import version %s of site %s: %v", version.BuildID, fqdn, err)
+		}
+	}
+
+	h.deployImported(site, export)
+	skipped := h.importAliases(site, export.Aliases)
+
+	if !export.Enabled {
+		if err := h.servingClient.DisableSite(fqdn); err != nil {
+			log.Printf("Warning: failed to disable imported site %s: %v", fqdn, err)
+		} else if err := h.db.UpdateSiteEnabled(fqdn, false); err != nil {
+			log.Printf("Warning: failed to disable imported site %s: %v", fqdn, err)
+		}
+	}
+
+	if updated, err := h.db.GetSiteByFQDN(fqdn); err == nil && updated != nil {
+		site = updated
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respondJSON(w, types.ImportSiteResponse{
+		Site:           site,
+		Storage:        summary,
+		SkippedAliases: skipped,
+	})
+}
+
+// importVersions streams the storage bundle that follows site.json into storage. An
+// export of a site that never built has none.
+func (h *SitesHandler) importVersions(siteID string, tr *tar.Reader) (json.RawMessage, error) {
+	first, err := tr.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", clients.ErrInvalidBundle, err)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(copyEntries(tar.NewWriter(pw), tr, first))
+	}()
+
+	summary, err := h.storageClient.ImportSite(siteID, pr)
+	// Unblocks the copy if storage stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	return summary, err
+}
+
+// deployImported deploys the versions the exported site served, preview first, and
+// records the ones that deployed
+func (h *SitesHandler) deployImported(site *types.Site, export *types.SiteExport) {
+	var live, preview *string
+	if id := export.PreviewVersionID; id != nil {
+		if err := h.servingClient.DeployArtifact(site.FQDN, site.ID, *id); err != nil {
+			log.Printf("Warning: failed to deploy preview of imported site %s: %v", site.FQDN, err)
+		} else if err := h.servingClient.ActivatePreview(site.FQDN, *id); err != nil {
+			log.Printf("Warning: failed to activate preview of imported site %s: %v", site.FQDN, err)
+		} else {
+			preview = id
+		}
+	}
+	if id := export.LiveVersionID; id != nil {
+		if err := h.servingClient.DeployArtifact(site.FQDN, site.ID, *id); err != nil {
+			log.Printf("Warning: failed to deploy imported site %s: %v", site.FQDN, err)
+		} else if err := h.servingClient.ActivateVersion(site.FQDN, *id); err != nil {
+			log.Printf("Warning: failed to activate imported site %s: %v", site.FQDN, err)
+		} else {
+			live = id
+		}
+	}
+
+	if live == nil && preview == nil {
+		return
+	}
+	if err := h.db.UpdateSiteVersions(site.FQDN, live, preview); err != nil {
+		log.Printf("Warning: failed to record deployed versions of %s: %v", site.FQDN, err)
+	}
+}
+
+// importAliases adds the exported aliases and returns those another site already has
+func (h *SitesHandler) importAliases(site *types.Site, aliases []string) []string {
+	var added, skipped []string
+	for _, alias := range aliases {
+		if _, err := h.db.CreateAlias(site.ID, alias); err != nil {
+			skipped = append(skipped, alias)
+			continue
+		}
+		added = append(added, alias)
+	}
+
+	if len(added) > 0 {
+		if err := h.servingClient.UpdateAliases(site.FQDN, added); err != nil {
+			log.Printf("Warning: failed to update serving aliases of %s: %v", site.FQDN, err)
+		}
+	}
+	return skipped
+}
+
+func newSiteExport(site *types.Site, aliases []types.SiteAlias, versions []types.Version) *types.SiteExport {
+	export := &types.SiteExport{
+		Format:           types.SiteExportFormat,
+		Version:          siteExportVersion,
+		FQDN:             site.FQDN,
+		TemplateID:       site.TemplateID,
+		Enabled:          site.Enabled,
+		LiveVersionID:    site.LiveVersionID,
+		PreviewVersionID: site.PreviewVersionID,
+		Aliases:          make([]string, len(aliases)),
+		Versions:         make([]types.ExportedVersion, len(versions)),
+		ExportedAt:       time.Now().UTC(),
+	}
+	for i, alias := range aliases {
+		export.Aliases[i] = alias.Alias
+	}
+	for i, version := range versions {
+		export.Versions[i] = types.ExportedVersion{
+			BuildID:    version.BuildID,
+			Status:     version.Status,
+			Draft:      version.Draft,
+			ApprovedAt: version.ApprovedAt,
+			CreatedAt:  version.CreatedAt,
+		}
+	}
+	return export
+}
+
+// writeSiteExport writes site.json, then the entries of the storage bundle if there is one
+func writeSiteExport(w io.Writer, export *types.SiteExport, bundle io.Reader) error {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal site.json: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "site.json",
+		Size:     int64(len(data)),
+		Mode:     0644,
+		ModTime:  export.ExportedAt,
+		Format:   tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write site.json: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write site.json: %w", err)
+	}
+
+	if bundle != nil {
+		tr := tar.NewReader(bundle)
+		first, err := tr.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read storage bundle: %w", err)
+		}
+		return copyEntries(tw, tr, first)
+	}
+	return tw.Close()
+}
+
+// readSiteExport reads site.json, which must be the first entry
+func readSiteExport(tr *tar.Reader) (*types.SiteExport, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site export: %w", err)
+	}
+	if header.Name != "site.json" {
+		return nil, fmt.Errorf("site export starts with %q instead of site.json", header.Name)
+	}
+	if header.Size > maxSiteJSONBytes {
+		return nil, fmt.Errorf("site.json is larger than %d bytes", maxSiteJSONBytes)
+	}
+
+	var export types.SiteExport
+	if err := json.NewDecoder(tr).Decode(&export); err != nil {
+		return nil, fmt.Errorf("failed to parse site.json: %w", err)
+	}
+	if export.Format != types.SiteExportFormat || export.Version < 1 || export.Version > siteExportVersion {
+		return nil, fmt.Errorf("unsupported site export: format %q version %d", export.Format, export.Version)
+	}
+	if export.TemplateID == "" {
+		return nil, errors.New("site.json has no template_id")
+	}
+	return &export, nil
+}
+
+// copyEntries copies first and every entry after it from tr to tw, then closes tw
+func copyEntries(tw *tar.Writer, tr *tar.Reader, first *tar.Header) error {
+	for header := first; ; {
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", header.Name, err)
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", header.Name, err)
+		}
+
+		var err error
+		header, err = tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+	}
+	return tw.Close()
+}
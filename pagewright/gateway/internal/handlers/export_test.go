@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/types"
+)
+
+// storageBundle builds a stand-in for the bundle storage exports
+func storageBundle(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"bundle.json", "versions/build-1/artifact"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSiteExportRoundTrip(t *testing.T) {
+	live := "build-1"
+	approved := time.Now().UTC().Truncate(time.Second)
+	site := &types.Site{ID: "site-1", FQDN: "example.com", TemplateID: "blog", Enabled: true, LiveVersionID: &live}
+	aliases := []types.SiteAlias{{Alias: "www.example.com"}}
+	versions := []types.Version{{ID: "v-1", SiteID: "site-1", BuildID: "build-1", Status: "success", Draft: true, ApprovedAt: &approved}}
+
+	bundle := storageBundle(t, map[string]string{
+		"bundle.json":               `{"format":"pagewright-site-bundle"}`,
+		"versions/build-1/artifact": "archive bytes",
+	})
+
+	var buf bytes.Buffer
+	if err := writeSiteExport(&buf, newSiteExport(site, aliases, versions), bytes.NewReader(bundle)); err != nil {
+		t.Fatalf("writeSiteExport: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	export, err := readSiteExport(tr)
+	if err != nil {
+		t.Fatalf("readSiteExport: %v", err)
+	}
+	if export.FQDN != "example.com" || export.TemplateID != "blog" || *export.LiveVersionID != "build-1" {
+		t.Errorf("unexpected export: %+v", export)
+	}
+	if len(export.Aliases) != 1 || export.Aliases[0] != "www.example.com" {
+		t.Errorf("aliases = %v", export.Aliases)
+	}
+	if len(export.Versions) != 1 || !export.Versions[0].Draft || !export.Versions[0].ApprovedAt.Equal(approved) {
+		t.Errorf("versions = %+v", export.Versions)
+	}
+
+	// The storage bundle follows unchanged
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading export: %v", err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "versions/build-1/artifact" {
+			data, _ := io.ReadAll(tr)
+			if string(data) != "archive bytes" {
+				t.Errorf("artifact = %q", data)
+			}
+		}
+	}
+	if len(names) != 2 || names[0] != "bundle.json" || names[1] != "versions/build-1/artifact" {
+		t.Errorf("entries = %v", names)
+	}
+}
+
+func TestSiteExportWithoutVersions(t *testing.T) {
+	site := &types.Site{FQDN: "example.com", TemplateID: "blog"}
+
+	var buf bytes.Buffer
+	if err := writeSiteExport(&buf, newSiteExport(site, nil, nil), nil); err != nil {
+		t.Fatalf("writeSiteExport: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	if _, err := readSiteExport(tr); err != nil {
+		t.Fatalf("readSiteExport: %v", err)
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("expected only site.json, got %v", err)
+	}
+}
+
+func TestReadSiteExportRejects(t *testing.T) {
+	siteJSON := func(export *types.SiteExport) []byte {
+		var buf bytes.Buffer
+		if err := writeSiteExport(&buf, export, nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := map[string][]byte{
+		"not a tar":        []byte("garbage"),
+		"storage bundle":   storageBundle(t, map[string]string{"bundle.json": `{}`}),
+		"other format":     siteJSON(&types.SiteExport{Format: "other", Version: 1, TemplateID: "blog"}),
+		"newer version":    siteJSON(&types.SiteExport{Format: types.SiteExportFormat, Version: siteExportVersion + 1, TemplateID: "blog"}),
+		"without template": siteJSON(&types.SiteExport{Format: types.SiteExportFormat, Version: 1}),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readSiteExport(tar.NewReader(bytes.NewReader(data))); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -15,13 +16,15 @@ import (
 type SitesHandler struct {
 	db              *database.DB
 	servingClient   *clients.ServingClient
+	storageClient   *clients.StorageClient
 	defaultPageSize int
 }
 
-func NewSitesHandler(db *database.DB, servingClient *clients.ServingClient, defaultPageSize int) *SitesHandler {
+func NewSitesHandler(db *database.DB, servingClient *clients.ServingClient, storageClient *clients.StorageClient, defaultPageSize int) *SitesHandler {
 	return &SitesHandler{
 		db:              db,
 		servingClient:   servingClient,
+		storageClient:   storageClient,
 		defaultPageSize: defaultPageSize,
 	}
 }
@@ -48,6 +51,11 @@ func (h *SitesHandler) CreateSite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Storage counts the site toward the owner's quota; builds retry this if it fails
+	if err := h.storageClient.SetSiteOwner(site.ID, user.UserID); err != nil {
+		log.Printf("Warning: failed to record owner of site %s: %v", site.ID, err)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	respondJSON(w, site)
 }
@@ -111,6 +119,36 @@ func (h *SitesHandler) GetSite(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, site)
 }
 
+// GetSiteUsage returns the storage a site uses and the quotas that apply to it
+func (h *SitesHandler) GetSiteUsage(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUserFromContext(r)
+	fqdn := mux.Vars(r)["fqdn"]
+
+	site, err := h.db.GetSiteByFQDN(fqdn)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get site")
+		return
+	}
+
+	if site == nil {
+		respondError(w, http.StatusNotFound, "site not found")
+		return
+	}
+
+	if site.UserID != user.UserID {
+		respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	usage, err := h.storageClient.FetchUsage(site.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get storage usage")
+		return
+	}
+
+	respondJSON(w, usage)
+}
+
 // DeleteSite deletes a site
 func (h *SitesHandler) DeleteSite(w http.ResponseWriter, r *http.Request) {
 	user, _ := middleware.GetUserFromContext(r)
@@ -139,6 +177,11 @@ func (h *SitesHandler) DeleteSite(w http.ResponseWriter, r *http.Request) {
 		// In production, consider using a background job for cleanup
 	}
 
+	// Delete stored versions; same best-effort policy as serving
+	if err := h.storageClient.DeleteSite(site.ID); err != nil {
+		log.Printf("Warning: failed to delete storage for site %s: %v", site.ID, err)
+	}
+
 	// Delete from database
 	if err := h.db.DeleteSite(fqdn); err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to delete site")
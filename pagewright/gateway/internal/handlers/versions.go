@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"path"
 	"strconv"
+	"time"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/clients"
 	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/database"
@@ -28,7 +34,10 @@ func NewVersionsHandler(db *database.DB, storageClient *clients.StorageClient, s
 	}
 }
 
-// ListVersions lists all versions for a site (from storage service)
+// ListVersions lists the versions of a site, newest first (from storage service). Filters:
+// status, action and an RFC 3339 since/until range. Pages are numbered (page, page_size), or
+// walked with limit and the next_cursor of the previous page, which storage serves from its
+// index without listing everything.
 func (h *VersionsHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	user, _ := middleware.GetUserFromContext(r)
 	vars := mux.Vars(r)
@@ -45,20 +54,67 @@ func (h *VersionsHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	params := r.URL.Query()
+	query := clients.VersionQuery{
+		Status: params.Get("status"),
+		Action: params.Get("action"),
+	}
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := params.Get(name); value != "" {
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				respondError(w, http.StatusBadRequest, name+" must be an RFC 3339 time")
+				return
+			}
+		}
+	}
+
+	cursorMode := params.Has("limit") || params.Has("cursor")
+	if cursorMode {
+		query.Cursor = params.Get("cursor")
+		query.Limit, _ = strconv.Atoi(params.Get("limit"))
+		if query.Limit < 1 || query.Limit > 100 {
+			query.Limit = h.defaultPageSize
+		}
+	}
+
 	// Fetch versions from storage service
-	versions, err := h.storageClient.ListVersions(site.ID)
+	result, err := h.storageClient.ListVersions(site.ID, query)
 	if err != nil {
+		if errors.Is(err, clients.ErrInvalidQuery) {
+			respondError(w, http.StatusBadRequest, "invalid cursor or filter")
+			return
+		}
 		respondError(w, http.StatusInternalServerError, "failed to list versions")
 		return
 	}
+	versions := result.Versions
+
+	// Flag drafts so clients only offer them for preview
+	drafts, err := h.db.GetUnapprovedDrafts(site.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list versions")
+		return
+	}
+	for i := range versions {
+		versions[i].Draft = drafts[versions[i].BuildID]
+	}
+
+	if cursorMode {
+		respondJSON(w, types.CursorResponse{
+			Data:       versions,
+			Limit:      query.Limit,
+			NextCursor: result.NextCursor,
+		})
+		return
+	}
 
 	// Apply pagination
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page, _ := strconv.Atoi(params.Get("page"))
 	if page < 1 {
 		page = 1
 	}
 
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	pageSize, _ := strconv.Atoi(params.Get("page_size"))
 	if pageSize < 1 || pageSize > 100 {
 		pageSize = h.defaultPageSize
 	}
@@ -113,12 +169,38 @@ func (h *VersionsHandler) DeployVersion(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Drafts from dry runs can only be previewed until they are approved
+	if req.Target == "live" {
+		version, err := h.db.GetVersion(site.ID, versionID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to get version")
+			return
+		}
+		if version != nil && !version.Deployable() {
+			respondError(w, http.StatusConflict, "version is a draft; approve it before deploying live")
+			return
+		}
+	}
+
+	// Pin the version before it is served, so storage retention can never delete it
+	if err := h.storageClient.SetPinned(site.ID, versionID, true); err != nil {
+		log.Printf("Failed to pin version %s of site %s: %v", versionID, site.ID, err)
+		respondError(w, http.StatusInternalServerError, "failed to pin version")
+		return
+	}
+
 	// Deploy artifact to serving infrastructure
 	if err := h.servingClient.DeployArtifact(fqdn, site.ID, versionID); err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to deploy artifact")
 		return
 	}
 
+	// The version this deploy replaces, and the one the other target serves
+	previous, other := site.PreviewVersionID, site.LiveVersionID
+	if req.Target == "live" {
+		previous, other = site.LiveVersionID, site.PreviewVersionID
+	}
+
 	// Activate the version
 	if req.Target == "live" {
 		if err := h.servingClient.ActivateVersion(fqdn, versionID); err != nil {
@@ -136,6 +218,8 @@ func (h *VersionsHandler) DeployVersion(w http.ResponseWriter, r *http.Request)
 		h.db.UpdateSiteVersions(fqdn, nil, &versionID)
 	}
 
+	h.unpinReplaced(site.ID, versionID, previous, other)
+
 	respondJSON(w, map[string]string{
 		"status":     "deployed",
 		"target":     req.Target,
@@ -143,6 +227,106 @@ func (h *VersionsHandler) DeployVersion(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// unpinReplaced unpins the version a deploy replaced, unless the other target still serves
+// it. A version left pinned only costs space, so failures are logged.
+func (h *VersionsHandler) unpinReplaced(siteID, versionID string, previous, other *string) {
+	if previous == nil || *previous == versionID || (other != nil && *other == *previous) {
+		return
+	}
+	if err := h.storageClient.SetPinned(siteID, *previous, false); err != nil {
+		log.Printf("Warning: failed to unpin version %s of site %s: %v", *previous, siteID, err)
+	}
+}
+
+// PinDeployedVersions pins the live and preview versions of every site, including those
+// deployed before deploys pinned them, so turning on storage retention cannot expire what a
+// site serves. It retries until every pin is set or ctx is cancelled.
+func (h *VersionsHandler) PinDeployedVersions(ctx context.Context) {
+	delay := time.Second
+	for {
+		err := h.pinDeployedVersions()
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to pin deployed versions, retrying in %s: %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 5*time.Minute)
+	}
+}
+
+func (h *VersionsHandler) pinDeployedVersions() error {
+	sites, err := h.db.GetDeployedSites()
+	if err != nil {
+		return err
+	}
+
+	var failed error
+	for _, site := range sites {
+		for _, versionID := range []*string{site.LiveVersionID, site.PreviewVersionID} {
+			if versionID == nil {
+				continue
+			}
+			err := h.storageClient.SetPinned(site.ID, *versionID, true)
+			if errors.Is(err, clients.ErrNotFound) {
+				log.Printf("Version %s of site %s is not in storage, not pinning it", *versionID, site.ID)
+				continue
+			}
+			if err != nil {
+				failed = fmt.Errorf("failed to pin version %s of site %s: %w", *versionID, site.ID, err)
+			}
+		}
+	}
+	return failed
+}
+
+// ApproveVersion approves a draft version so it can be deployed live
+func (h *VersionsHandler) ApproveVersion(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUserFromContext(r)
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	versionID := vars["version_id"]
+
+	site, err := h.db.GetSiteByFQDN(fqdn)
+	if err != nil || site == nil {
+		respondError(w, http.StatusNotFound, "site not found")
+		return
+	}
+
+	if site.UserID != user.UserID {
+		respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	version, err := h.db.GetVersion(site.ID, versionID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to get version")
+		return
+	}
+	if version == nil {
+		respondError(w, http.StatusNotFound, "version not found")
+		return
+	}
+	if !version.Draft {
+		respondError(w, http.StatusBadRequest, "version is not a draft")
+		return
+	}
+
+	if err := h.db.ApproveVersion(site.ID, versionID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to approve version")
+		return
+	}
+
+	respondJSON(w, map[string]string{
+		"status":     "approved",
+		"version_id": versionID,
+	})
+}
+
 // DeleteVersion deletes a version
 func (h *VersionsHandler) DeleteVersion(w http.ResponseWriter, r *http.Request) {
 	user, _ := middleware.GetUserFromContext(r)
@@ -180,6 +364,111 @@ func (h *VersionsHandler) DeleteVersion(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetVersionManifest returns the build manifest of a version: prompt, summary, changed files and checks
+func (h *VersionsHandler) GetVersionManifest(w http.ResponseWriter, r *http.Request) {
+	h.versionJSON(w, r, h.storageClient.FetchManifest)
+}
+
+// GetVersionLogs returns the agent's execution log for a version
+func (h *VersionsHandler) GetVersionLogs(w http.ResponseWriter, r *http.Request) {
+	h.versionJSON(w, r, h.storageClient.FetchExecutionLog)
+}
+
+// GetVersionAncestry returns a version and its ancestors, up to the first version of the site
+func (h *VersionsHandler) GetVersionAncestry(w http.ResponseWriter, r *http.Request) {
+	h.versionJSON(w, r, h.storageClient.FetchAncestry)
+}
+
+// GetVersionChildren returns the versions built from a version; more than one means a branch
+func (h *VersionsHandler) GetVersionChildren(w http.ResponseWriter, r *http.Request) {
+	h.versionJSON(w, r, h.storageClient.FetchChildren)
+}
+
+// DiffVersions returns the files changed from version_id to other_version_id
+func (h *VersionsHandler) DiffVersions(w http.ResponseWriter, r *http.Request) {
+	otherVersionID := mux.Vars(r)["other_version_id"]
+	h.versionJSON(w, r, func(siteID, versionID string) (json.RawMessage, error) {
+		return h.storageClient.FetchDiff(siteID, versionID, otherVersionID)
+	})
+}
+
+// GetVersionTree lists the files of a version, for browsing its sources
+func (h *VersionsHandler) GetVersionTree(w http.ResponseWriter, r *http.Request) {
+	h.versionJSON(w, r, h.storageClient.FetchTree)
+}
+
+// GetVersionFile returns one file of a version, e.g. a page's markdown for a preview
+func (h *VersionsHandler) GetVersionFile(w http.ResponseWriter, r *http.Request) {
+	user, _ := middleware.GetUserFromContext(r)
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	versionID := vars["version_id"]
+
+	site, err := h.db.GetSiteByFQDN(fqdn)
+	if err != nil || site == nil {
+		respondError(w, http.StatusNotFound, "site not found")
+		return
+	}
+
+	if site.UserID != user.UserID {
+		respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	file, err := h.storageClient.FetchFile(site.ID, versionID, vars["path"])
+	if err != nil {
+		if errors.Is(err, clients.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "file not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to fetch file")
+		return
+	}
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if file.ETag != "" {
+		w.Header().Set("ETag", file.ETag)
+	}
+	// Served as a download so HTML and SVG from a site never run on the dashboard origin
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(path.Base(vars["path"])))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(file.Data)
+}
+
+func (h *VersionsHandler) versionJSON(w http.ResponseWriter, r *http.Request, fetch func(siteID, versionID string) (json.RawMessage, error)) {
+	user, _ := middleware.GetUserFromContext(r)
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	versionID := vars["version_id"]
+
+	site, err := h.db.GetSiteByFQDN(fqdn)
+	if err != nil || site == nil {
+		respondError(w, http.StatusNotFound, "site not found")
+		return
+	}
+
+	if site.UserID != user.UserID {
+		respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	data, err := fetch(site.ID, versionID)
+	if err != nil {
+		if errors.Is(err, clients.ErrNotFound) {
+			respondError(w, http.StatusNotFound, "version not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to fetch version details")
+		return
+	}
+
+	respondJSON(w, data)
+}
+
 // DownloadVersion downloads a version artifact
 func (h *VersionsHandler) DownloadVersion(w http.ResponseWriter, r *http.Request) {
 	user, _ := middleware.GetUserFromContext(r)
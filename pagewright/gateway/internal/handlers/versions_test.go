@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/gateway/internal/types"
+)
+
+func TestVersionDeployable(t *testing.T) {
+	approved := time.Now()
+	tests := []struct {
+		name     string
+		version  types.Version
+		expected bool
+	}{
+		{"regular version", types.Version{}, true},
+		{"unapproved draft", types.Version{Draft: true}, false},
+		{"approved draft", types.Version{Draft: true, ApprovedAt: &approved}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.version.Deployable(); got != tt.expected {
+				t.Errorf("expected Deployable() = %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
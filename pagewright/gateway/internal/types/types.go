@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Database Models
 
@@ -33,11 +36,18 @@ type SiteAlias struct {
 }
 
 type Version struct {
-	ID        string    `db:"id" json:"id"`
-	SiteID    string    `db:"site_id" json:"site_id"`
-	BuildID   string    `db:"build_id" json:"build_id"`
-	Status    string    `db:"status" json:"status"` // pending, success, failed
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ID         string     `db:"id" json:"id"`
+	SiteID     string     `db:"site_id" json:"site_id"`
+	BuildID    string     `db:"build_id" json:"build_id"`
+	Status     string     `db:"status" json:"status"` // pending, success, failed
+	Draft      bool       `db:"draft" json:"draft"`   // dry run: preview only until approved
+	ApprovedAt *time.Time `db:"approved_at" json:"approved_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Deployable reports whether the version may go live: drafts need approval first
+func (v *Version) Deployable() bool {
+	return !v.Draft || v.ApprovedAt != nil
 }
 
 type PasswordResetToken struct {
@@ -101,12 +111,49 @@ type DeployVersionRequest struct {
 type BuildRequest struct {
 	Message        string  `json:"message"`
 	ConversationID *string `json:"conversation_id,omitempty"` // For follow-up clarifications
+	DryRun         bool    `json:"dry_run,omitempty"`         // Build a draft that can only be previewed until approved
 }
 
 type BuildResponse struct {
-	JobID          *string `json:"job_id,omitempty"`          // Set when job is queued
-	Question       *string `json:"question,omitempty"`        // Set when clarification needed
-	ConversationID *string `json:"conversation_id,omitempty"` // For follow-up
+	JobID          *string  `json:"job_id,omitempty"`          // Set when job is queued
+	VersionID      *string  `json:"version_id,omitempty"`      // Version the job will create
+	Draft          bool     `json:"draft,omitempty"`           // The version is a dry-run draft
+	Attachments    []string `json:"attachments,omitempty"`     // Stored names of uploaded files
+	Question       *string  `json:"question,omitempty"`        // Set when clarification needed
+	ConversationID *string  `json:"conversation_id,omitempty"` // For follow-up
+}
+
+// SiteExportFormat identifies site.json, the first entry of a site export
+const SiteExportFormat = "pagewright-site-export"
+
+// SiteExport is what the gateway knows about an exported site. The storage bundle with
+// its versions follows it in the same tar.
+type SiteExport struct {
+	Format           string            `json:"format"`
+	Version          int               `json:"version"`
+	FQDN             string            `json:"fqdn"`
+	TemplateID       string            `json:"template_id"`
+	Enabled          bool              `json:"enabled"`
+	LiveVersionID    *string           `json:"live_version_id,omitempty"`
+	PreviewVersionID *string           `json:"preview_version_id,omitempty"`
+	Aliases          []string          `json:"aliases"`
+	Versions         []ExportedVersion `json:"versions"`
+	ExportedAt       time.Time         `json:"exported_at"`
+}
+
+// ExportedVersion is a version record without the IDs of the installation it came from
+type ExportedVersion struct {
+	BuildID    string     `json:"build_id"`
+	Status     string     `json:"status"`
+	Draft      bool       `json:"draft,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ImportSiteResponse struct {
+	Site           *Site           `json:"site"`
+	Storage        json.RawMessage `json:"storage,omitempty"`         // What storage imported
+	SkippedAliases []string        `json:"skipped_aliases,omitempty"` // Aliases another site already uses
 }
 
 // WebSocket Types
@@ -114,7 +161,7 @@ type BuildResponse struct {
 type JobStatusUpdate struct {
 	JobID     string    `json:"job_id"`
 	SiteID    string    `json:"site_id"`
-	Status    string    `json:"status"` // queued, running, success, failed
+	Status    string    `json:"status"` // queued, running, success, failed, no_changes
 	BuildID   *string   `json:"build_id,omitempty"`
 	Message   string    `json:"message,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
@@ -133,6 +180,13 @@ type PaginatedResponse struct {
 	TotalPages int         `json:"total_pages"`
 }
 
+// CursorResponse is a page of a listing that is walked with next_cursor rather than page numbers
+type CursorResponse struct {
+	Data       interface{} `json:"data"`
+	Limit      int         `json:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
 // External Service Types (for communication with other microservices)
 
 type ManagerJobRequest struct {
@@ -0,0 +1,2 @@
+ALTER TABLE versions DROP COLUMN IF EXISTS approved_at;
+ALTER TABLE versions DROP COLUMN IF EXISTS draft;
@@ -0,0 +1,2 @@
+ALTER TABLE versions ADD COLUMN IF NOT EXISTS draft BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE versions ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
@@ -16,6 +16,7 @@ import (
 	lockRedis "github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/lock/redis"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/queue"
 	queueRedis "github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/queue/redis"
+	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/quota"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/spawner"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/spawner/docker"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/spawner/kubernetes"
@@ -72,8 +73,14 @@ func main() {
 		managerURL = envURL
 	}
 
+	// Refuse jobs for sites over their storage quota
+	var quotaChecker quota.Checker
+	if cfg.StorageURL != "" {
+		quotaChecker = quota.NewStorageChecker(cfg.StorageURL)
+	}
+
 	// Create API handler
-	handler := api.NewHandler(queueBackend, lockMgr, workerSpawner, cfg.LockTTL, managerURL)
+	handler := api.NewHandler(queueBackend, lockMgr, workerSpawner, quotaChecker, cfg.LockTTL, managerURL)
 	router := handler.SetupRoutes()
 
 	// Create HTTP server
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -13,12 +14,6 @@ import (
 )
 
 func main() {
-	// Get job from environment
-	jobJSON := os.Getenv("PAGEWRIGHT_JOB")
-	if jobJSON == "" {
-		log.Fatal("PAGEWRIGHT_JOB environment variable not set")
-	}
-
 	managerURL := os.Getenv("PAGEWRIGHT_MANAGER_URL")
 	if managerURL == "" {
 		log.Fatal("PAGEWRIGHT_MANAGER_URL environment variable not set")
@@ -29,10 +24,9 @@ func main() {
 		log.Fatal("PAGEWRIGHT_WORKER_ID environment variable not set")
 	}
 
-	// Parse job
-	var job types.Job
-	if err := json.Unmarshal([]byte(jobJSON), &job); err != nil {
-		log.Fatalf("Failed to parse job: %v", err)
+	job, err := loadJob(managerURL)
+	if err != nil {
+		log.Fatalf("Failed to load job: %v", err)
 	}
 
 	log.Printf("Worker %s starting for job %s", workerID, job.JobID)
@@ -65,6 +59,60 @@ func main() {
 	log.Println("Callback sent successfully, worker exiting")
 }
 
+// loadJob reads the job from PAGEWRIGHT_JOB_FILE, claims it from the manager with
+// PAGEWRIGHT_JOB_ID and PAGEWRIGHT_CLAIM_TOKEN, or falls back to the legacy PAGEWRIGHT_JOB
+func loadJob(managerURL string) (*types.Job, error) {
+	var data []byte
+	switch {
+	case os.Getenv("PAGEWRIGHT_JOB_FILE") != "":
+		b, err := os.ReadFile(os.Getenv("PAGEWRIGHT_JOB_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job file: %w", err)
+		}
+		data = b
+	case os.Getenv("PAGEWRIGHT_CLAIM_TOKEN") != "":
+		b, err := claimJob(managerURL, os.Getenv("PAGEWRIGHT_JOB_ID"), os.Getenv("PAGEWRIGHT_CLAIM_TOKEN"))
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	case os.Getenv("PAGEWRIGHT_JOB") != "":
+		data = []byte(os.Getenv("PAGEWRIGHT_JOB"))
+	default:
+		return nil, fmt.Errorf("no job source: set PAGEWRIGHT_JOB_FILE or PAGEWRIGHT_JOB_ID and PAGEWRIGHT_CLAIM_TOKEN")
+	}
+
+	var job types.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job: %w", err)
+	}
+	return &job, nil
+}
+
+func claimJob(managerURL, jobID, token string) ([]byte, error) {
+	if jobID == "" {
+		return nil, fmt.Errorf("PAGEWRIGHT_JOB_ID environment variable not set")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/jobs/%s/claim", managerURL, jobID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create claim request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("claim failed with status: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
 func sendCallback(managerURL, jobID string, update types.JobStatusUpdate) error {
 	url := fmt.Sprintf("%s/jobs/%s/status", managerURL, jobID)
 
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
+	"github.com/gorilla/mux"
+)
+
+// newClaimToken returns a random one-time token for a worker to claim its job
+func newClaimToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate claim token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashClaimToken is what the manager stores, so a leaked job record cannot be used to claim
+func hashClaimToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClaimJob hands the full job payload to the worker spawned for it. The worker authenticates
+// with the one-time token from its spawner (Authorization: Bearer <token>); the payload can
+// only be claimed once, while the job is running.
+func (h *Handler) ClaimJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["job_id"]
+
+	if jobID == "" {
+		http.Error(w, "job_id is required", http.StatusBadRequest)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "claim token is required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	job, err := h.queue.GetJob(ctx, jobID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Job not found: %v", err), http.StatusNotFound)
+		return
+	}
+
+	expected := job.ClaimTokenHash
+	if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(hashClaimToken(token))) != 1 {
+		http.Error(w, "invalid claim token", http.StatusForbidden)
+		return
+	}
+
+	if job.Status != types.JobStatusRunning {
+		http.Error(w, fmt.Sprintf("Job is %s and cannot be claimed", job.Status), http.StatusConflict)
+		return
+	}
+
+	// The claim time is stored with the claim, not in the job, which CreateJob may still be
+	// saving while the worker claims it
+	now := time.Now().UTC()
+	claimed, err := h.queue.MarkClaimed(ctx, jobID, now)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to claim job: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, "job has already been claimed", http.StatusConflict)
+		return
+	}
+	job.ClaimedAt = &now
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(job)
+}
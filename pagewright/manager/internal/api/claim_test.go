@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]types.Job
+	claimed map[string]time.Time
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{jobs: map[string]types.Job{}, claimed: map[string]time.Time{}}
+}
+
+func (q *memoryQueue) Push(ctx context.Context, job *types.Job) error {
+	return q.UpdateJob(ctx, job)
+}
+
+func (q *memoryQueue) Pop(ctx context.Context) (*types.Job, error) {
+	return nil, nil
+}
+
+func (q *memoryQueue) GetJob(ctx context.Context, jobID string) (*types.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+	if at, ok := q.claimed[jobID]; ok {
+		job.ClaimedAt = &at
+	}
+	return &job, nil
+}
+
+func (q *memoryQueue) UpdateJob(ctx context.Context, job *types.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.JobID] = *job
+	return nil
+}
+
+func (q *memoryQueue) MarkClaimed(ctx context.Context, jobID string, at time.Time) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.claimed[jobID]; ok {
+		return false, nil
+	}
+	q.claimed[jobID] = at
+	return true, nil
+}
+
+func (q *memoryQueue) Close() error { return nil }
+
+type noopLock struct{}
+
+func (noopLock) Acquire(ctx context.Context, siteID string, ttl time.Duration) (string, int64, error) {
+	return "lock-token", 1, nil
+}
+func (noopLock) Renew(ctx context.Context, siteID, token string, ttl time.Duration) error { return nil }
+func (noopLock) Release(ctx context.Context, siteID, token string) error                  { return nil }
+func (noopLock) Close() error                                                             { return nil }
+
+// recordingSpawner keeps the claim token the handler passes to the worker
+type recordingSpawner struct {
+	claimToken string
+	onSpawn    func(jobID, claimToken string) // Runs before Spawn returns, like a fast worker
+}
+
+func (s *recordingSpawner) Spawn(ctx context.Context, job *types.Job, managerURL, claimToken string) (string, error) {
+	s.claimToken = claimToken
+	if s.onSpawn != nil {
+		s.onSpawn(job.JobID, claimToken)
+	}
+	return "worker-1", nil
+}
+
+func (s *recordingSpawner) Close() error { return nil }
+
+// createJob posts a job and returns its ID and the token given to the spawner
+func createJob(t *testing.T, h *Handler, s *recordingSpawner) (string, string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"site_id":"site-1","prompt":"Add a contact page"}`))
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var job types.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	require.NotEmpty(t, s.claimToken)
+	return job.JobID, s.claimToken
+}
+
+func claim(h *Handler, jobID, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/jobs/"+jobID+"/claim", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestClaimJobOnce(t *testing.T) {
+	q := newMemoryQueue()
+	s := &recordingSpawner{}
+	h := NewHandler(q, noopLock{}, s, nil, time.Minute, "http://manager:8081")
+
+	jobID, token := createJob(t, h, s)
+
+	stored, err := q.GetJob(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Equal(t, hashClaimToken(token), stored.ClaimTokenHash)
+	assert.NotContains(t, stored.ClaimTokenHash, token)
+
+	rec := claim(h, jobID, token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var job types.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, "Add a contact page", job.Prompt)
+	assert.Equal(t, "lock-token", job.LockToken)
+	assert.NotNil(t, job.ClaimedAt)
+
+	// The token is single use
+	assert.Equal(t, http.StatusConflict, claim(h, jobID, token).Code)
+}
+
+func TestClaimJobRejectsBadTokens(t *testing.T) {
+	q := newMemoryQueue()
+	s := &recordingSpawner{}
+	h := NewHandler(q, noopLock{}, s, nil, time.Minute, "http://manager:8081")
+
+	jobID, token := createJob(t, h, s)
+
+	assert.Equal(t, http.StatusUnauthorized, claim(h, jobID, "").Code)
+	assert.Equal(t, http.StatusForbidden, claim(h, jobID, "wrong").Code)
+	assert.Equal(t, http.StatusNotFound, claim(h, "missing", token).Code)
+
+	// A failed attempt does not use up the token
+	assert.Equal(t, http.StatusOK, claim(h, jobID, token).Code)
+}
+
+func TestClaimJobRequiresRunningJob(t *testing.T) {
+	q := newMemoryQueue()
+	s := &recordingSpawner{}
+	h := NewHandler(q, noopLock{}, s, nil, time.Minute, "http://manager:8081")
+
+	jobID, token := createJob(t, h, s)
+	job, err := q.GetJob(context.Background(), jobID)
+	require.NoError(t, err)
+	job.Status = types.JobStatusFailed
+	require.NoError(t, q.UpdateJob(context.Background(), job))
+
+	assert.Equal(t, http.StatusConflict, claim(h, jobID, token).Code)
+}
+
+func TestClaimBeforeSpawnReturnsIsKept(t *testing.T) {
+	q := newMemoryQueue()
+	s := &recordingSpawner{}
+	h := NewHandler(q, noopLock{}, s, nil, time.Minute, "http://manager:8081")
+	s.onSpawn = func(jobID, claimToken string) {
+		assert.Equal(t, http.StatusOK, claim(h, jobID, claimToken).Code)
+	}
+
+	jobID, _ := createJob(t, h, s)
+
+	// Saving the worker ID after the claim keeps the claim time
+	stored, err := q.GetJob(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Equal(t, "worker-1", stored.WorkerID)
+	assert.NotNil(t, stored.ClaimedAt)
+}
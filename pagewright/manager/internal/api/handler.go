@@ -8,6 +8,7 @@ import (
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/lock"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/queue"
+	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/quota"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/spawner"
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
 	"github.com/google/uuid"
@@ -18,15 +19,17 @@ type Handler struct {
 	queue      queue.Backend
 	lockMgr    lock.Manager
 	spawner    spawner.Spawner
+	quota      quota.Checker // nil when quotas are not checked
 	lockTTL    time.Duration
 	managerURL string
 }
 
-func NewHandler(q queue.Backend, l lock.Manager, s spawner.Spawner, lockTTL time.Duration, managerURL string) *Handler {
+func NewHandler(q queue.Backend, l lock.Manager, s spawner.Spawner, c quota.Checker, lockTTL time.Duration, managerURL string) *Handler {
 	return &Handler{
 		queue:      q,
 		lockMgr:    l,
 		spawner:    s,
+		quota:      c,
 		lockTTL:    lockTTL,
 		managerURL: managerURL,
 	}
@@ -41,6 +44,7 @@ func (h *Handler) SetupRoutes() *mux.Router {
 	// Job endpoints
 	r.HandleFunc("/jobs", h.CreateJob).Methods("POST")
 	r.HandleFunc("/jobs/{job_id}", h.GetJob).Methods("GET")
+	r.HandleFunc("/jobs/{job_id}/claim", h.ClaimJob).Methods("GET")
 	r.HandleFunc("/jobs/{job_id}/status", h.UpdateJobStatus).Methods("POST")
 	r.HandleFunc("/jobs/{job_id}/result", h.JobResult).Methods("POST")
 
@@ -67,6 +71,23 @@ func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "site_id and prompt are required", http.StatusBadRequest)
 		return
 	}
+	if len(req.Attachments) > 0 && req.UploadID == "" {
+		http.Error(w, "upload_id is required with attachments", http.StatusBadRequest)
+		return
+	}
+
+	// A job on a site out of storage would fail when the worker uploads its version.
+	// If storage cannot tell, the job goes ahead and the upload is checked anyway.
+	if h.quota != nil {
+		over, err := h.quota.OverQuota(r.Context(), req.SiteID)
+		if err != nil {
+			fmt.Printf("Warning: Failed to check storage quota for site %s: %v\n", req.SiteID, err)
+		}
+		if over {
+			http.Error(w, "site is over its storage quota", http.StatusInsufficientStorage)
+			return
+		}
+	}
 
 	// Generate job ID and target version if not provided
 	jobID := uuid.New().String()
@@ -81,11 +102,22 @@ func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		Prompt:        req.Prompt,
 		SourceVersion: req.SourceVersion,
 		TargetVersion: req.TargetVersion,
+		UploadID:      req.UploadID,
+		Attachments:   req.Attachments,
+		DryRun:        req.DryRun,
 		Status:        types.JobStatusPending,
 		CreatedAt:     time.Now().UTC(),
 		UpdatedAt:     time.Now().UTC(),
 	}
 
+	// The worker fetches the job payload with this token instead of receiving it from the spawner
+	claimToken, err := newClaimToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create claim token: %v", err), http.StatusInternalServerError)
+		return
+	}
+	job.ClaimTokenHash = hashClaimToken(claimToken)
+
 	// Try to acquire lock
 	ctx := r.Context()
 	token, fencingToken, err := h.lockMgr.Acquire(ctx, req.SiteID, h.lockTTL)
@@ -114,7 +146,7 @@ func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Spawn worker
-	workerID, err := h.spawner.Spawn(ctx, job, h.managerURL)
+	workerID, err := h.spawner.Spawn(ctx, job, h.managerURL, claimToken)
 	if err != nil {
 		// Update job as failed
 		job.Status = types.JobStatusFailed
@@ -129,6 +161,10 @@ func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The worker may already have reported status; save the worker ID on the current copy
+	if current, err := h.queue.GetJob(ctx, job.JobID); err == nil {
+		job = current
+	}
 	job.WorkerID = workerID
 	h.queue.UpdateJob(ctx, job)
 
@@ -191,8 +227,8 @@ func (h *Handler) UpdateJobStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Release lock if job is completed or failed
-	if update.Status == types.JobStatusCompleted || update.Status == types.JobStatusFailed {
+	// Release lock if job has finished
+	if update.Status == types.JobStatusCompleted || update.Status == types.JobStatusFailed || update.Status == types.JobStatusNoChanges {
 		if job.LockToken != "" {
 			if err := h.lockMgr.Release(ctx, job.SiteID, job.LockToken); err != nil {
 				fmt.Printf("Warning: Failed to release lock for site %s: %v\n", job.SiteID, err)
@@ -220,6 +256,7 @@ func (h *Handler) JobResult(w http.ResponseWriter, r *http.Request) {
 		Result        string `json:"result"`
 		ErrorMessage  string `json:"error_message,omitempty"`
 		ManifestPath  string `json:"manifest_path,omitempty"`
+		LimitExceeded string `json:"limit_exceeded,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
@@ -237,12 +274,19 @@ func (h *Handler) JobResult(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update job with worker result
-	if result.Status == "completed" {
+	switch types.JobStatus(result.Status) {
+	case types.JobStatusCompleted:
 		job.Status = types.JobStatusCompleted
 		job.Result = result.Result
-	} else {
+	case types.JobStatusNoChanges:
+		// The agent changed nothing, so no target version was created
+		job.Status = types.JobStatusNoChanges
+		job.Result = result.Result
+		job.TargetVersion = ""
+	default:
 		job.Status = types.JobStatusFailed
 		job.ErrorMessage = result.ErrorMessage
+		job.LimitExceeded = result.LimitExceeded
 	}
 	job.UpdatedAt = time.Now().UTC()
 
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateJobWithAttachments(t *testing.T) {
+	q := newMemoryQueue()
+	s := &recordingSpawner{}
+	h := NewHandler(q, noopLock{}, s, nil, time.Minute, "http://manager:8081")
+
+	body := `{"site_id":"site-1","prompt":"Use logo.png in the header","upload_id":"upload-1","attachments":["logo.png"]}`
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("POST", "/jobs", strings.NewReader(body)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var created types.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+
+	stored, err := q.GetJob(context.Background(), created.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, "upload-1", stored.UploadID)
+	assert.Equal(t, []string{"logo.png"}, stored.Attachments)
+}
+
+func TestCreateJobAttachmentsNeedUploadID(t *testing.T) {
+	h := NewHandler(newMemoryQueue(), noopLock{}, &recordingSpawner{}, nil, time.Minute, "http://manager:8081")
+
+	body := `{"site_id":"site-1","prompt":"Use logo.png","attachments":["logo.png"]}`
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("POST", "/jobs", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestJobResultRecordsLimit(t *testing.T) {
+	q := newMemoryQueue()
+	h := NewHandler(q, noopLock{}, &recordingSpawner{}, nil, time.Minute, "http://manager:8081")
+	require.NoError(t, q.Push(context.Background(), &types.Job{JobID: "job-1", SiteID: "site-1", Status: types.JobStatusRunning}))
+
+	body := `{"job_id":"job-1","status":"failed","error_message":"agent exceeded files_touched limit","limit_exceeded":"files_touched"}`
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("POST", "/jobs/job-1/result", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("GET", "/jobs/job-1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var job types.Job
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, types.JobStatusFailed, job.Status)
+	assert.Equal(t, "files_touched", job.LimitExceeded)
+}
+
+type fixedQuota struct {
+	over bool
+	err  error
+}
+
+func (f fixedQuota) OverQuota(ctx context.Context, siteID string) (bool, error) {
+	return f.over, f.err
+}
+
+func TestCreateJobOverQuota(t *testing.T) {
+	q := newMemoryQueue()
+	s := &recordingSpawner{}
+	h := NewHandler(q, noopLock{}, s, fixedQuota{over: true}, time.Minute, "http://manager:8081")
+
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"site_id":"site-1","prompt":"Add a page"}`)))
+
+	assert.Equal(t, http.StatusInsufficientStorage, rec.Code)
+	assert.Empty(t, q.jobs)
+}
+
+func TestCreateJobQuotaUnknown(t *testing.T) {
+	h := NewHandler(newMemoryQueue(), noopLock{}, &recordingSpawner{}, fixedQuota{err: assert.AnError}, time.Minute, "http://manager:8081")
+
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"site_id":"site-1","prompt":"Add a page"}`)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
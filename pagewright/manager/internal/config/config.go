@@ -17,6 +17,7 @@ type Config struct {
 	LockRenewInterval time.Duration
 	WorkerImage       string
 	WorkerTimeout     time.Duration
+	StorageURL        string // checked for quotas before a job is accepted; empty skips the check
 }
 
 func LoadConfig() *Config {
@@ -31,6 +32,7 @@ func LoadConfig() *Config {
 		LockRenewInterval: getEnvDuration("PAGEWRIGHT_LOCK_RENEW_INTERVAL", 1*time.Minute),
 		WorkerImage:       getEnv("PAGEWRIGHT_WORKER_IMAGE", "pagewright-worker:latest"),
 		WorkerTimeout:     getEnvDuration("PAGEWRIGHT_WORKER_TIMEOUT", 30*time.Minute),
+		StorageURL:        getEnv("PAGEWRIGHT_STORAGE_URL", ""),
 	}
 }
 
@@ -21,6 +21,7 @@ func TestLoadConfigDefaults(t *testing.T) {
 	assert.Equal(t, 0, cfg.RedisDB)
 	assert.Equal(t, 5*time.Minute, cfg.LockTTL)
 	assert.Equal(t, "pagewright-worker:latest", cfg.WorkerImage)
+	assert.Equal(t, "", cfg.StorageURL)
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
@@ -32,6 +33,7 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	os.Setenv("PAGEWRIGHT_REDIS_DB", "1")
 	os.Setenv("PAGEWRIGHT_LOCK_TTL", "10m")
 	os.Setenv("PAGEWRIGHT_WORKER_IMAGE", "custom-worker:v1")
+	os.Setenv("PAGEWRIGHT_STORAGE_URL", "http://storage:8080")
 	defer os.Clearenv()
 
 	cfg := LoadConfig()
@@ -44,4 +46,5 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	assert.Equal(t, 1, cfg.RedisDB)
 	assert.Equal(t, 10*time.Minute, cfg.LockTTL)
 	assert.Equal(t, "custom-worker:v1", cfg.WorkerImage)
+	assert.Equal(t, "http://storage:8080", cfg.StorageURL)
 }
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"time"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
 )
@@ -20,6 +21,12 @@ type Backend interface {
 	// UpdateJob updates a job's status and metadata
 	UpdateJob(ctx context.Context, job *types.Job) error
 
+	// MarkClaimed atomically records that a job's payload was handed to a worker at the
+	// given time. It returns false if the job was already claimed. The claim is kept apart
+	// from the job, and GetJob reports its time as ClaimedAt, so saving a stale copy of the
+	// job with UpdateJob cannot lose it.
+	MarkClaimed(ctx context.Context, jobID string, at time.Time) (bool, error)
+
 	// Close closes the backend connection
 	Close() error
 }
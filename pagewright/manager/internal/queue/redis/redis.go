@@ -13,6 +13,7 @@ import (
 const (
 	queueKey     = "pagewright:queue"
 	jobKeyPrefix = "pagewright:job:"
+	claimPrefix  = "pagewright:claim:"
 )
 
 type RedisBackend struct {
@@ -79,19 +80,28 @@ func (r *RedisBackend) Pop(ctx context.Context) (*types.Job, error) {
 
 func (r *RedisBackend) GetJob(ctx context.Context, jobID string) (*types.Job, error) {
 	jobKey := jobKeyPrefix + jobID
-	jobData, err := r.client.Get(ctx, jobKey).Result()
+	values, err := r.client.MGet(ctx, jobKey, claimPrefix+jobID).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return nil, fmt.Errorf("job not found: %s", jobID)
-		}
 		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
 
+	jobData, ok := values[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+
 	var job types.Job
 	if err := json.Unmarshal([]byte(jobData), &job); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
 	}
 
+	// The claim key holds the claim time
+	if claimedAt, ok := values[1].(string); ok {
+		if at, err := time.Parse(time.RFC3339, claimedAt); err == nil {
+			job.ClaimedAt = &at
+		}
+	}
+
 	return &job, nil
 }
 
@@ -109,6 +119,14 @@ func (r *RedisBackend) UpdateJob(ctx context.Context, job *types.Job) error {
 	return nil
 }
 
+func (r *RedisBackend) MarkClaimed(ctx context.Context, jobID string, at time.Time) (bool, error) {
+	claimed, err := r.client.SetNX(ctx, claimPrefix+jobID, at.UTC().Format(time.RFC3339Nano), 24*time.Hour).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark job claimed: %w", err)
+	}
+	return claimed, nil
+}
+
 func (r *RedisBackend) Close() error {
 	return r.client.Close()
 }
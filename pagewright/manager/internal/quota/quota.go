@@ -0,0 +1,55 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Checker reports whether a site has used up its storage quota
+type Checker interface {
+	OverQuota(ctx context.Context, siteID string) (bool, error)
+}
+
+// StorageChecker asks the storage service, which tracks usage per site and per owner
+type StorageChecker struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewStorageChecker(baseURL string) *StorageChecker {
+	return &StorageChecker{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *StorageChecker) OverQuota(ctx context.Context, siteID string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/sites/%s/usage", c.baseURL, url.PathEscape(siteID)), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create usage request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch storage usage: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to fetch storage usage: status %d", resp.StatusCode)
+	}
+
+	var usage struct {
+		OverQuota bool `json:"over_quota"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		return false, fmt.Errorf("failed to decode storage usage: %w", err)
+	}
+	return usage.OverQuota, nil
+}
@@ -0,0 +1,38 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sites/full-site/usage":
+			w.Write([]byte(`{"site_id":"full-site","bytes":100,"quota_bytes":100,"over_quota":true}`))
+		case "/sites/site-1/usage":
+			w.Write([]byte(`{"site_id":"site-1","bytes":10,"quota_bytes":100,"over_quota":false}`))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewStorageChecker(server.URL)
+
+	over, err := checker.OverQuota(context.Background(), "full-site")
+	require.NoError(t, err)
+	assert.True(t, over)
+
+	over, err = checker.OverQuota(context.Background(), "site-1")
+	require.NoError(t, err)
+	assert.False(t, over)
+
+	_, err = checker.OverQuota(context.Background(), "other")
+	assert.Error(t, err)
+}
@@ -2,7 +2,6 @@ package docker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
@@ -19,28 +18,24 @@ func NewDockerSpawner(image string) *DockerSpawner {
 	}
 }
 
-func (d *DockerSpawner) Spawn(ctx context.Context, job *types.Job, managerURL string) (string, error) {
+func (d *DockerSpawner) Spawn(ctx context.Context, job *types.Job, managerURL, claimToken string) (string, error) {
 	workerID := uuid.New().String()
 
-	// Marshal job to JSON
-	jobJSON, err := json.Marshal(job)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal job: %w", err)
-	}
-
+	// The job payload is not passed to the container: it would show up in docker inspect.
+	// The worker claims it from the manager with the one-time token instead.
 	// For PoC, we'll just log the spawn request
 	// In production, this would use Docker API to spawn a container
 	fmt.Printf("DOCKER SPAWN: Would spawn container:\n")
 	fmt.Printf("  Image: %s\n", d.image)
 	fmt.Printf("  Worker ID: %s\n", workerID)
-	fmt.Printf("  Job: %s\n", string(jobJSON))
 	fmt.Printf("  Manager URL: %s\n", managerURL)
-	fmt.Printf("  Env: PAGEWRIGHT_JOB=%s\n", string(jobJSON))
+	fmt.Printf("  Env: PAGEWRIGHT_JOB_ID=%s\n", job.JobID)
+	fmt.Printf("  Env: PAGEWRIGHT_CLAIM_TOKEN=<%d-char one-time token>\n", len(claimToken))
 	fmt.Printf("  Env: PAGEWRIGHT_MANAGER_URL=%s\n", managerURL)
 	fmt.Printf("  Env: PAGEWRIGHT_WORKER_ID=%s\n", workerID)
 
 	// TODO: Actually spawn docker container:
-	// docker run -e PAGEWRIGHT_JOB=<json> -e PAGEWRIGHT_MANAGER_URL=<url> -e PAGEWRIGHT_WORKER_ID=<id> <image>
+	// docker run -e PAGEWRIGHT_JOB_ID=<id> -e PAGEWRIGHT_CLAIM_TOKEN=<token> -e PAGEWRIGHT_MANAGER_URL=<url> -e PAGEWRIGHT_WORKER_ID=<id> <image>
 
 	return workerID, nil
 }
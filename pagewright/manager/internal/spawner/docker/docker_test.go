@@ -30,7 +30,7 @@ func TestDockerSpawner_Spawn(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	workerID, err := spawner.Spawn(ctx, job, "http://manager:8081")
+	workerID, err := spawner.Spawn(ctx, job, "http://manager:8081", "claim-token")
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, workerID)
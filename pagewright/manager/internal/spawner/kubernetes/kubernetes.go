@@ -2,7 +2,6 @@ package kubernetes
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/manager/internal/types"
@@ -21,29 +20,27 @@ func NewKubernetesSpawner(image, namespace string) *KubernetesSpawner {
 	}
 }
 
-func (k *KubernetesSpawner) Spawn(ctx context.Context, job *types.Job, managerURL string) (string, error) {
+func (k *KubernetesSpawner) Spawn(ctx context.Context, job *types.Job, managerURL, claimToken string) (string, error) {
 	workerID := uuid.New().String()
 
-	// Marshal job to JSON
-	jobJSON, err := json.Marshal(job)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal job: %w", err)
-	}
-
+	// The job payload is not put in the pod spec; the worker claims it from the manager
+	// with the one-time token, which is stored in a Secret rather than a plain env value.
 	// For PoC, we'll just log the spawn request
 	// In production, this would use Kubernetes API to create a Job or Pod
 	fmt.Printf("KUBERNETES SPAWN: Would create Job:\n")
 	fmt.Printf("  Namespace: %s\n", k.namespace)
 	fmt.Printf("  Image: %s\n", k.image)
 	fmt.Printf("  Worker ID: %s\n", workerID)
-	fmt.Printf("  Job: %s\n", string(jobJSON))
 	fmt.Printf("  Manager URL: %s\n", managerURL)
-	fmt.Printf("  Env: PAGEWRIGHT_JOB=%s\n", string(jobJSON))
+	fmt.Printf("  Secret: pagewright-claim-%s (token=<%d-char one-time token>)\n", workerID, len(claimToken))
+	fmt.Printf("  Env: PAGEWRIGHT_JOB_ID=%s\n", job.JobID)
+	fmt.Printf("  Env: PAGEWRIGHT_CLAIM_TOKEN from secret pagewright-claim-%s\n", workerID)
 	fmt.Printf("  Env: PAGEWRIGHT_MANAGER_URL=%s\n", managerURL)
 	fmt.Printf("  Env: PAGEWRIGHT_WORKER_ID=%s\n", workerID)
 
 	// TODO: Actually create Kubernetes Job:
-	// kubectl run pagewright-worker-<id> --image=<image> --env=PAGEWRIGHT_JOB=<json> ...
+	// kubectl create secret generic pagewright-claim-<id> --from-literal=token=<token>
+	// kubectl run pagewright-worker-<id> --image=<image> --env=PAGEWRIGHT_JOB_ID=<job_id> ...
 
 	return workerID, nil
 }
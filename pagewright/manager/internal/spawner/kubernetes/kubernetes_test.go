@@ -31,7 +31,7 @@ func TestKubernetesSpawner_Spawn(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	workerID, err := spawner.Spawn(ctx, job, "http://manager:8081")
+	workerID, err := spawner.Spawn(ctx, job, "http://manager:8081", "claim-token")
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, workerID)
@@ -8,8 +8,9 @@ import (
 
 // Spawner defines the interface for worker spawners
 type Spawner interface {
-	// Spawn creates and starts a worker container
-	Spawn(ctx context.Context, job *types.Job, managerURL string) (workerID string, err error)
+	// Spawn creates and starts a worker container. The worker receives only the job ID and
+	// a one-time claimToken, and fetches the job payload from GET /jobs/{job_id}/claim.
+	Spawn(ctx context.Context, job *types.Job, managerURL, claimToken string) (workerID string, err error)
 
 	// Close closes the spawner
 	Close() error
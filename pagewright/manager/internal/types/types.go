@@ -10,6 +10,7 @@ const (
 	JobStatusRunning   JobStatus = "running"
 	JobStatusCompleted JobStatus = "completed"
 	JobStatusFailed    JobStatus = "failed"
+	JobStatusNoChanges JobStatus = "no_changes"
 )
 
 // Job represents a work request
@@ -19,6 +20,9 @@ type Job struct {
 	Prompt        string    `json:"prompt"`
 	SourceVersion string    `json:"source_version,omitempty"`
 	TargetVersion string    `json:"target_version,omitempty"`
+	UploadID      string    `json:"upload_id,omitempty"`   // storage upload holding the attachments
+	Attachments   []string  `json:"attachments,omitempty"` // file names the prompt refers to
+	DryRun        bool      `json:"dry_run,omitempty"`     // build a draft that can only be previewed
 	Status        JobStatus `json:"status"`
 	LockToken     string    `json:"lock_token,omitempty"`
 	FencingToken  int64     `json:"fencing_token,omitempty"`
@@ -27,6 +31,11 @@ type Job struct {
 	WorkerID      string    `json:"worker_id,omitempty"`
 	Result        string    `json:"result,omitempty"`
 	ErrorMessage  string    `json:"error_message,omitempty"`
+	LimitExceeded string    `json:"limit_exceeded,omitempty"` // resource limit that stopped the agent
+
+	// SHA-256 of the one-time token the worker presents to claim the job payload
+	ClaimTokenHash string     `json:"claim_token_hash,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
 }
 
 // JobRequest represents an incoming job request
@@ -35,6 +44,13 @@ type JobRequest struct {
 	Prompt        string `json:"prompt"`
 	SourceVersion string `json:"source_version,omitempty"`
 	TargetVersion string `json:"target_version,omitempty"`
+
+	// Files uploaded with the request, stored in storage under upload ID
+	UploadID    string   `json:"upload_id,omitempty"`
+	Attachments []string `json:"attachments,omitempty"`
+
+	// DryRun builds the version as a draft that can only be activated as preview
+	DryRun bool `json:"dry_run,omitempty"`
 }
 
 // JobStatusUpdate represents a status update from a worker
@@ -2,6 +2,7 @@ package artifact
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -10,6 +11,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 type Manager struct {
@@ -179,13 +182,13 @@ func (m *Manager) unpack(archivePath, destDir string) error {
 	}
 	defer file.Close()
 
-	gzr, err := gzip.NewReader(file)
+	archive, err := openArchive(file)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return err
 	}
-	defer gzr.Close()
+	defer archive.Close()
 
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(archive)
 
 	for {
 		header, err := tr.Next()
@@ -230,6 +233,29 @@ func (m *Manager) unpack(archivePath, destDir string) error {
 	return nil
 }
 
+// openArchive decompresses a tar.gz or tar.zst stream, telling them apart by their magic bytes
+func openArchive(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if len(magic) == 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd {
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	gzr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return gzr, nil
+}
+
 func (m *Manager) extractDomain(fqdn string) string {
 	// Extract domain from FQDN
 	// blog.example.com -> example.com
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -71,6 +72,37 @@ func TestDeployArtifact(t *testing.T) {
 	assert.Equal(t, "<html>test</html>", string(content))
 }
 
+func TestDeployZstdArtifact(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := &Manager{
+		wwwRoot: tmpDir,
+	}
+
+	// Re-encode the test artifact as tar.zst
+	gzFile, err := os.Open(createTestArtifact(t, tmpDir))
+	require.NoError(t, err)
+	defer gzFile.Close()
+	gzReader, err := gzip.NewReader(gzFile)
+	require.NoError(t, err)
+
+	artifactPath := filepath.Join(tmpDir, "artifact.tar.zst")
+	zstFile, err := os.Create(artifactPath)
+	require.NoError(t, err)
+	zstWriter, err := zstd.NewWriter(zstFile)
+	require.NoError(t, err)
+	_, err = io.Copy(zstWriter, gzReader)
+	require.NoError(t, err)
+	require.NoError(t, zstWriter.Close())
+	require.NoError(t, zstFile.Close())
+
+	err = mgr.DeployArtifact("blog.example.com", "v1", artifactPath)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(mgr.GetArtifactPath("blog.example.com", "v1"), "public", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<html>test</html>", string(content))
+}
+
 func TestActivateVersion(t *testing.T) {
 	tmpDir := t.TempDir()
 	mgr := &Manager{
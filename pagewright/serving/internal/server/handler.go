@@ -67,8 +67,8 @@ func (h *Handler) DeployArtifact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Download artifact from storage
-	tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("artifact-%s-%s.tar.gz", req.SiteID, req.Version))
+	// Download artifact from storage; it may be tar.gz or tar.zst
+	tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("artifact-%s-%s", req.SiteID, req.Version))
 	defer os.Remove(tmpFile)
 
 	if err := h.storageCli.FetchArtifact(req.SiteID, req.Version, tmpFile); err != nil {
@@ -23,11 +23,18 @@ func NewClient(baseURL string) *Client {
 	}
 }
 
-// FetchArtifact downloads an artifact to the specified destination
+// FetchArtifact downloads an artifact to the specified destination. zstd is preferred, as
+// it is smaller to transfer; storage transcodes versions it holds as tar.gz.
 func (c *Client) FetchArtifact(siteID, versionID, destPath string) error {
 	url := fmt.Sprintf("%s/artifacts/%s/%s", c.baseURL, siteID, versionID)
 
-	resp, err := c.httpClient.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", "zstd, gzip;q=0.5")
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to fetch artifact: %w", err)
 	}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/backends"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/config"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/replica"
+)
+
+const (
+	exitSuccess = 0
+	exitError   = 1
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run compares the primary backend with the replica and repairs the replica, printing
+// what differed as JSON. It reads the same environment as the server.
+func run() int {
+	dryRun := flag.Bool("dry-run", false, "Report drift without repairing it")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	if cfg.ReplicaBackend == "" {
+		log.Println("Error: PAGEWRIGHT_REPLICA_BACKEND environment variable is required")
+		return exitError
+	}
+
+	primary, err := backends.Primary(cfg)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return exitError
+	}
+	secondary, err := backends.Secondary(cfg)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return exitError
+	}
+
+	report, err := replica.Reconcile(primary, secondary, *dryRun)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return exitError
+	}
+	return exitSuccess
+}
@@ -11,30 +11,65 @@ import (
 	"time"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/api"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/backends"
 	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/config"
-	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
-	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/quota"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/retention"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/replica"
 )
 
 func main() {
 	cfg := config.LoadConfig()
 
 	// Initialize storage backend
-	var backend storage.Backend
-	var err error
+	backend, err := backends.Primary(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
 
-	switch cfg.StorageBackend {
-	case "nfs":
-		backend, err = nfs.NewNFSBackend(cfg.NFSBasePath)
+	// Mirror writes to the replica in the background
+	replicaCtx, stopReplication := context.WithCancel(context.Background())
+	defer stopReplication()
+	secondary, err := backends.Secondary(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize replica storage: %v", err)
+	}
+	if secondary != nil {
+		journal, err := replica.OpenJournal(cfg.ReplicationLogPath)
 		if err != nil {
-			log.Fatalf("Failed to initialize NFS backend: %v", err)
+			log.Fatalf("Failed to open replication log: %v", err)
 		}
-	default:
-		log.Fatalf("Unsupported storage backend: %s", cfg.StorageBackend)
+		replicated := replica.New(backend, secondary, journal)
+		go replicated.Run(replicaCtx)
+		backend = replicated
+		log.Printf("Replicating to %s backend", cfg.ReplicaBackend)
+	}
+
+	// Track usage per site and owner; every write below goes through the tracker
+	usageCtx, stopUsage := context.WithCancel(context.Background())
+	defer stopUsage()
+	tracker := quota.NewTracker(backend)
+	if cfg.UsageRecomputeHours > 0 {
+		go tracker.Run(usageCtx, time.Duration(cfg.UsageRecomputeHours)*time.Hour)
+	}
+	backend = tracker
+
+	// Expire old versions, prune unreferenced blobs and drop idle uploads in the background
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	sweeper := retention.NewSweeper(backend, retention.Policy{
+		KeepLast:   cfg.RetentionKeepLast,
+		KeepWithin: time.Duration(cfg.RetentionKeepDays) * 24 * time.Hour,
+	}, time.Duration(cfg.BlobGraceMinutes)*time.Minute, time.Duration(cfg.UploadSessionTTLHours)*time.Hour)
+	if cfg.RetentionIntervalMinutes > 0 {
+		go sweeper.Run(sweepCtx, time.Duration(cfg.RetentionIntervalMinutes)*time.Minute)
 	}
 
 	// Create API handler
-	handler := api.NewHandler(backend)
+	handler := api.NewHandler(backend, quota.Limits{
+		SiteBytes:  int64(cfg.SiteQuotaMB) << 20,
+		OwnerBytes: int64(cfg.OwnerQuotaMB) << 20,
+	})
 	router := handler.SetupRoutes()
 
 	// Create HTTP server
@@ -60,6 +95,9 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopSweeper()
+	stopUsage()
+	stopReplication()
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
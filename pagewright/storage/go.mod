@@ -4,6 +4,7 @@ go 1.22
 
 require (
 	github.com/gorilla/mux v1.8.1
+	github.com/klauspost/compress v1.17.9
 	github.com/stretchr/testify v1.8.4
 )
 
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// DigestHeader carries the hex SHA-256 of an artifact stream, as a header or trailer
+const DigestHeader = "X-Content-SHA256"
+
+// InstanceDigestHeader is the RFC 3230 digest header, "sha-256=<base64>"
+const InstanceDigestHeader = "Digest"
+
+// ErrDigestMismatch is returned when an uploaded artifact does not match its declared digest
+var ErrDigestMismatch = errors.New("artifact digest mismatch")
+
+// digestReader hashes a stream as it is read and checks the digest once the stream ends.
+// A mismatch is reported in place of io.EOF, so the backend discards the partial write.
+type digestReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected func() string
+}
+
+func newDigestReader(r io.Reader, expected func() string) *digestReader {
+	return &digestReader{
+		r:        r,
+		hash:     sha256.New(),
+		expected: expected,
+	}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if expected := d.expected(); expected != "" && expected != d.Sum() {
+			return n, fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, expected, d.Sum())
+		}
+	}
+
+	return n, err
+}
+
+// Sum returns the hex SHA-256 of the bytes read so far
+func (d *digestReader) Sum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// parseInstanceDigest returns the hex SHA-256 from an RFC 3230 Digest header value.
+// Other algorithms are ignored; an empty string means no usable digest was sent.
+func parseInstanceDigest(value string) (string, error) {
+	for _, part := range strings.Split(value, ",") {
+		algorithm, encoded, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.EqualFold(algorithm, "sha-256") {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sum) != sha256.Size {
+			return "", fmt.Errorf("invalid sha-256 digest: %q", encoded)
+		}
+		return hex.EncodeToString(sum), nil
+	}
+	return "", nil
+}
+
+// formatInstanceDigest renders a hex SHA-256 as an RFC 3230 Digest header value
+func formatInstanceDigest(hexSum string) string {
+	sum, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
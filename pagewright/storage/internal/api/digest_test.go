@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/integrity"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/quota"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func setupDigestServer(t *testing.T) (*httptest.Server, *nfs.NFSBackend) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewHandler(backend, quota.Limits{}).SetupRoutes())
+	t.Cleanup(server.Close)
+	return server, backend
+}
+
+func TestStoreArtifactDigestHeader(t *testing.T) {
+	server, backend := setupDigestServer(t)
+	content := []byte("test artifact content")
+
+	req, err := http.NewRequest("PUT", server.URL+"/sites/test-site/artifacts/build-123", bytes.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Set(DigestHeader, sha256Hex(content))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	reader, err := backend.FetchArtifact("test-site", "build-123")
+	require.NoError(t, err)
+	reader.Close()
+}
+
+func TestStoreArtifactInstanceDigest(t *testing.T) {
+	server, backend := setupDigestServer(t)
+	content := []byte("test artifact content")
+	sum := sha256.Sum256(content)
+	other := sha256.Sum256([]byte("something else"))
+
+	for _, tc := range []struct {
+		digest string
+		status int
+	}{
+		{"md5=ignored, sha-256=" + base64.StdEncoding.EncodeToString(other[:]), http.StatusBadRequest},
+		{"sha-256=not-base64", http.StatusBadRequest},
+		{"SHA-256=" + base64.StdEncoding.EncodeToString(sum[:]), http.StatusCreated},
+	} {
+		req, err := http.NewRequest("PUT", server.URL+"/sites/test-site/artifacts/build-123", bytes.NewReader(content))
+		require.NoError(t, err)
+		req.Header.Set(InstanceDigestHeader, tc.digest)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tc.status, resp.StatusCode, tc.digest)
+	}
+
+	info, err := backend.ArtifactInfo("test-site", "build-123")
+	require.NoError(t, err)
+	assert.Equal(t, sha256Hex(content), info.SHA256)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "text/plain; charset=utf-8", info.ContentType)
+}
+
+func TestStoreArtifactDigestTrailerMismatch(t *testing.T) {
+	server, backend := setupDigestServer(t)
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("PUT", server.URL+"/sites/test-site/artifacts/build-123", pr)
+	require.NoError(t, err)
+	req.Trailer = http.Header{DigestHeader: nil}
+
+	go func() {
+		pw.Write([]byte("streamed artifact content"))
+		req.Trailer.Set(DigestHeader, sha256Hex([]byte("something else")))
+		pw.Close()
+	}()
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The mismatched upload must not be committed
+	_, err = backend.FetchArtifact("test-site", "build-123")
+	assert.Error(t, err)
+}
+
+func TestFetchArtifactDigestHeader(t *testing.T) {
+	server, backend := setupDigestServer(t)
+	content := []byte("test artifact content")
+	require.NoError(t, backend.StoreArtifact("test-site", "build-123", bytes.NewReader(content)))
+
+	resp, err := http.Get(server.URL + "/sites/test-site/artifacts/build-123")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, body)
+	assert.Equal(t, `"`+sha256Hex(content)+`"`, resp.Header.Get("ETag"))
+	sum := sha256.Sum256(content)
+	assert.Equal(t, "sha-256="+base64.StdEncoding.EncodeToString(sum[:]), resp.Header.Get(InstanceDigestHeader))
+	assert.Empty(t, resp.Trailer.Get(DigestHeader))
+}
+
+func TestVerifyArtifacts(t *testing.T) {
+	server, backend := setupDigestServer(t)
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader([]byte("first artifact"))))
+	require.NoError(t, backend.StoreArtifact("test-site", "build-2", bytes.NewReader([]byte("second artifact"))))
+
+	resp, err := http.Post(server.URL+"/admin/verify?site_id=test-site", "", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var report integrity.Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, 2, report.Checked)
+	assert.Empty(t, report.Corrupted)
+}
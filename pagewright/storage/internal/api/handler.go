@@ -2,22 +2,42 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/bundle"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/diff"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/integrity"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/quota"
 	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
 	backend storage.Backend
+	usage   *quota.Tracker
+	limits  quota.Limits
 }
 
-func NewHandler(backend storage.Backend) *Handler {
+// NewHandler serves backend. A backend that is not a quota tracker already is wrapped in
+// one, so the writes made through the handler keep the usage counters current.
+func NewHandler(backend storage.Backend, limits quota.Limits) *Handler {
+	tracker, ok := backend.(*quota.Tracker)
+	if !ok {
+		tracker = quota.NewTracker(backend)
+	}
 	return &Handler{
-		backend: backend,
+		backend: tracker,
+		usage:   tracker,
+		limits:  limits,
 	}
 }
 
@@ -27,17 +47,66 @@ func (h *Handler) SetupRoutes() *mux.Router {
 	// Health check
 	r.HandleFunc("/health", h.HealthCheck).Methods("GET")
 
-	// Artifact endpoints
-	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}", h.StoreArtifact).Methods("PUT")
-	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}", h.FetchArtifact).Methods("GET")
+	// Artifact endpoints. The worker, serving and gateway clients address versions as
+	// /artifacts/{site_id}/{build_id}, so uploads, downloads and documents answer on both paths.
+	for _, prefix := range []string{"/sites/{site_id}/artifacts/{build_id}", "/artifacts/{site_id}/{build_id}"} {
+		r.HandleFunc(prefix, h.StoreArtifact).Methods("PUT")
+		r.HandleFunc(prefix, h.FetchArtifact).Methods("GET")
+		r.HandleFunc(prefix+"/manifest", h.StoreManifest).Methods("POST", "PUT")
+		r.HandleFunc(prefix+"/manifest", h.FetchManifest).Methods("GET")
+		r.HandleFunc(prefix+"/logs", h.StoreExecutionLog).Methods("POST", "PUT")
+		r.HandleFunc(prefix+"/logs", h.FetchExecutionLog).Methods("GET")
+	}
+	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}", h.DeleteArtifact).Methods("DELETE")
+	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}/upload-sessions", h.CreateUploadSession).Methods("POST")
+	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}/tree", h.ListFiles).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}/files/{path:.+}", h.FetchFile).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}/pin", h.PinArtifact).Methods("PUT")
+	r.HandleFunc("/sites/{site_id}/artifacts/{build_id}/pin", h.UnpinArtifact).Methods("DELETE")
+	r.HandleFunc("/sites/{site_id}", h.DeleteSite).Methods("DELETE")
+	r.HandleFunc("/sites/{site_id}/owner", h.SetOwner).Methods("PUT")
+	r.HandleFunc("/sites/{site_id}/usage", h.GetUsage).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/export", h.ExportSite).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/import", h.ImportSite).Methods("POST")
+
+	// Resumable artifact uploads
+	r.HandleFunc("/upload-sessions/{session_id}", h.GetUploadSession).Methods("GET")
+	r.HandleFunc("/upload-sessions/{session_id}", h.AbortUploadSession).Methods("DELETE")
+	r.HandleFunc("/upload-sessions/{session_id}/chunks/{index}", h.PutUploadChunk).Methods("PUT")
+	r.HandleFunc("/upload-sessions/{session_id}/commit", h.CommitUploadSession).Methods("POST")
 
 	// Log and version endpoints
 	r.HandleFunc("/sites/{site_id}/logs", h.WriteLog).Methods("POST")
 	r.HandleFunc("/sites/{site_id}/versions", h.ListVersions).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/versions/{build_id}/ancestry", h.VersionAncestry).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/versions/{build_id}/children", h.VersionChildren).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/diff", h.DiffVersions).Methods("GET")
+
+	// Agent conversation history
+	r.HandleFunc("/sites/{site_id}/session", h.AppendSessionTurn).Methods("POST")
+	r.HandleFunc("/sites/{site_id}/session", h.ListSessionTurns).Methods("GET")
+
+	// Files uploaded with build requests
+	r.HandleFunc("/sites/{site_id}/attachments/{upload_id}", h.ListAttachments).Methods("GET")
+	r.HandleFunc("/sites/{site_id}/attachments/{upload_id}/{name}", h.StoreAttachment).Methods("PUT")
+	r.HandleFunc("/sites/{site_id}/attachments/{upload_id}/{name}", h.FetchAttachment).Methods("GET")
+
+	// Maintenance
+	r.HandleFunc("/admin/verify", h.VerifyArtifacts).Methods("POST")
+	r.HandleFunc("/admin/usage/recompute", h.RecomputeUsage).Methods("POST")
 
 	return r
 }
 
+// maxAttachmentBytes bounds a single uploaded file
+const maxAttachmentBytes = 25 << 20
+
+// maxDocumentBytes bounds a version manifest or execution log
+const maxDocumentBytes = 16 << 20
+
+// maxChunkBytes bounds one chunk of a resumable upload
+const maxChunkBytes = 64 << 20
+
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -56,8 +125,47 @@ func (h *Handler) StoreArtifact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read the request body (multipart or direct stream)
-	if err := h.backend.StoreArtifact(siteID, buildID, r.Body); err != nil {
+	instanceDigest, err := parseInstanceDigest(r.Header.Get(InstanceDigestHeader))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s header: %v", InstanceDigestHeader, err), http.StatusBadRequest)
+		return
+	}
+
+	var upload io.Reader = r.Body
+	if h.limits.Enabled() {
+		usage, err := h.usage.Usage(siteID, h.limits)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to compute storage usage: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := usage.Check(r.ContentLength); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to store artifact: %v", err), quotaStatus(err))
+			return
+		}
+		upload = usage.LimitReader(r.Body)
+	}
+
+	// Stream the request body to the backend, verifying the client digest if one is sent.
+	// Streaming clients send the digest as a trailer, which is only available after EOF.
+	body := newDigestReader(upload, func() string {
+		if digest := r.Header.Get(DigestHeader); digest != "" {
+			return digest
+		}
+		if instanceDigest != "" {
+			return instanceDigest
+		}
+		return r.Trailer.Get(DigestHeader)
+	})
+
+	if err := h.backend.StoreArtifact(siteID, buildID, body); err != nil {
+		if errors.Is(err, ErrDigestMismatch) {
+			http.Error(w, fmt.Sprintf("Failed to store artifact: %v", err), http.StatusBadRequest)
+			return
+		}
+		if status := quotaStatus(err); status != 0 {
+			http.Error(w, fmt.Sprintf("Failed to store artifact: %v", err), status)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to store artifact: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -67,6 +175,7 @@ func (h *Handler) StoreArtifact(w http.ResponseWriter, r *http.Request) {
 		"message":  "Artifact stored successfully",
 		"site_id":  siteID,
 		"build_id": buildID,
+		"sha256":   body.Sum(),
 	})
 }
 
@@ -80,29 +189,774 @@ func (h *Handler) FetchArtifact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reader, err := h.backend.FetchArtifact(siteID, buildID)
+	info, err := h.backend.ArtifactInfo(siteID, buildID)
 	if err != nil {
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			http.Error(w, fmt.Sprintf("Failed to fetch artifact: %v", err), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to fetch artifact: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Archives can be served in either codec; raw uploads are served as stored
+	format := info.Format
+	if storage.IsArchive(info.Format) {
+		w.Header().Set("Vary", "Accept-Encoding")
+		if format, err = negotiateFormat(r, info.Format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A transcoded archive is rebuilt from the manifest. Full archives stored before
+	// manifests have none and are always served as stored.
+	var manifest *storage.ArtifactManifest
+	if format != info.Format {
+		manifest, err = h.backend.ReadManifest(siteID, buildID)
+		if errors.Is(err, storage.ErrNoManifest) {
+			format, manifest = info.Format, nil
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch artifact: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Validators come from the manifest, so a compressor upgrade changes neither. Artifacts
+	// stored before manifests get none. A rebuilt archive is equivalent, not byte for byte
+	// stable, across upgrades, so its ETag is weak.
+	if info.ContentHash != "" {
+		etag := `"` + info.ContentHash + `"`
+		if storage.IsArchive(info.Format) {
+			etag = `W/"` + info.ContentHash + "." + format + `"`
+		}
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	var reader io.ReadCloser
+	if manifest != nil {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(storage.WriteArchive(pw, manifest, h.backend, format))
+		}()
+		reader = pr
+	} else if reader, err = h.backend.FetchArtifact(siteID, buildID); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to fetch artifact: %v", err), http.StatusNotFound)
 		return
 	}
 	defer reader.Close()
 
 	// Set headers for file download
-	w.Header().Set("Content-Type", "application/gzip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.tar.gz", siteID, buildID))
+	contentType := info.ContentType
+	if manifest != nil {
+		contentType = storage.ArchiveContentType(format)
+	} else if contentType == "" {
+		contentType = "application/gzip"
+	}
+	extension := storage.FormatTarGz
+	if format == storage.FormatTarZst {
+		extension = storage.FormatTarZst
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.%s", siteID, buildID, extension))
 
-	// Stream the file
-	if _, err := io.Copy(w, reader); err != nil {
+	// An artifact served in its stored format is rebuilt the same way every time, so the
+	// digest recorded at upload goes in a header. A transcoded archive, or one stored before
+	// digests were recorded, is hashed on the way out and its digest sent as a trailer.
+	if manifest == nil && info.SHA256 != "" {
+		w.Header().Set(InstanceDigestHeader, formatInstanceDigest(info.SHA256))
+		if _, err := io.Copy(w, reader); err != nil {
+			// Can't send error at this point, just log it
+			fmt.Printf("Error streaming artifact: %v\n", err)
+		}
+		return
+	}
+
+	w.Header().Set("Trailer", DigestHeader)
+	body := newDigestReader(reader, func() string { return "" })
+	if _, err := io.Copy(w, body); err != nil {
 		// Can't send error at this point, just log it
 		fmt.Printf("Error streaming artifact: %v\n", err)
+		return
+	}
+
+	w.Header().Set(DigestHeader, body.Sum())
+}
+
+// CreateUploadSession starts a resumable upload of the artifact of build_id
+func (h *Handler) CreateUploadSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.backend.CreateUploadSession(siteID, buildID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create upload session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// GetUploadSession returns a session with the chunks received so far, so a client that lost
+// its connection knows which ones to send again
+func (h *Handler) GetUploadSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !storage.ValidUploadSessionID(sessionID) {
+		http.Error(w, "a valid session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.backend.GetUploadSession(sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadSessionNotFound) {
+			http.Error(w, fmt.Sprintf("Failed to fetch upload session: %v", err), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to fetch upload session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// PutUploadChunk stores one numbered chunk. A digest header, if sent, is checked.
+func (h *Handler) PutUploadChunk(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["session_id"]
+	index, err := strconv.Atoi(vars["index"])
+	if !storage.ValidUploadSessionID(sessionID) || err != nil || index < 0 || index >= storage.MaxUploadChunks {
+		http.Error(w, fmt.Sprintf("a valid session_id and a chunk index below %d are required", storage.MaxUploadChunks), http.StatusBadRequest)
+		return
+	}
+
+	instanceDigest, err := parseInstanceDigest(r.Header.Get(InstanceDigestHeader))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s header: %v", InstanceDigestHeader, err), http.StatusBadRequest)
+		return
+	}
+	body := newDigestReader(http.MaxBytesReader(w, r.Body, maxChunkBytes), func() string {
+		if digest := r.Header.Get(DigestHeader); digest != "" {
+			return digest
+		}
+		return instanceDigest
+	})
+
+	if err := h.backend.PutUploadChunk(sessionID, index, body); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, fmt.Sprintf("Chunk exceeds %d bytes", maxChunkBytes), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, storage.ErrUploadSessionNotFound):
+			http.Error(w, fmt.Sprintf("Failed to store chunk: %v", err), http.StatusNotFound)
+		case errors.Is(err, ErrDigestMismatch):
+			http.Error(w, fmt.Sprintf("Failed to store chunk: %v", err), http.StatusBadRequest)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to store chunk: %v", err), http.StatusInternalServerError)
+		}
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": sessionID,
+		"index":      index,
+		"sha256":     body.Sum(),
+	})
+}
+
+// CommitRequest names the digest the joined chunks must have
+type CommitRequest struct {
+	SHA256 string `json:"sha256"`
+}
+
+// CommitUploadSession stores the joined chunks as the artifact. The expected SHA-256 comes
+// from the body or from a digest header.
+func (h *Handler) CommitUploadSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !storage.ValidUploadSessionID(sessionID) {
+		http.Error(w, "a valid session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var req CommitRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.SHA256 == "" {
+		req.SHA256 = r.Header.Get(DigestHeader)
+	}
+	if req.SHA256 == "" {
+		digest, err := parseInstanceDigest(r.Header.Get(InstanceDigestHeader))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s header: %v", InstanceDigestHeader, err), http.StatusBadRequest)
+			return
+		}
+		req.SHA256 = digest
+	}
+	req.SHA256 = strings.ToLower(req.SHA256)
+	if !storage.ValidBlobHash(req.SHA256) {
+		http.Error(w, "the expected sha256 of the artifact is required", http.StatusBadRequest)
+		return
+	}
+
+	if h.limits.Enabled() {
+		if status, err := h.checkUploadQuota(sessionID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to commit upload: %v", err), status)
+			return
+		}
+	}
+
+	session, err := h.backend.CommitUploadSession(sessionID, req.SHA256)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUploadSessionNotFound):
+			http.Error(w, fmt.Sprintf("Failed to commit upload: %v", err), http.StatusNotFound)
+		case errors.Is(err, storage.ErrUploadIncomplete):
+			http.Error(w, fmt.Sprintf("Failed to commit upload: %v", err), http.StatusConflict)
+		case errors.Is(err, storage.ErrUploadDigestMismatch):
+			http.Error(w, fmt.Sprintf("Failed to commit upload: %v", err), http.StatusBadRequest)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to commit upload: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":  "Artifact stored successfully",
+		"site_id":  session.SiteID,
+		"build_id": session.BuildID,
+		"sha256":   req.SHA256,
+	})
+}
+
+// checkUploadQuota checks that the chunks of a session fit the site's quota
+func (h *Handler) checkUploadQuota(sessionID string) (int, error) {
+	session, err := h.backend.GetUploadSession(sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadSessionNotFound) {
+			return http.StatusNotFound, err
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	usage, err := h.usage.Usage(session.SiteID, h.limits)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to compute storage usage: %w", err)
+	}
+
+	var size int64
+	for _, chunk := range session.Chunks {
+		size += chunk.Size
+	}
+	if err := usage.Check(size); err != nil {
+		return quotaStatus(err), err
+	}
+	return 0, nil
+}
+
+// quotaStatus maps quota errors to their status: 413 when the upload alone is larger than
+// the quota, 507 when it does not fit what is left. It returns 0 for other errors.
+func quotaStatus(err error) int {
+	switch {
+	case errors.Is(err, quota.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, quota.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	}
+	return 0
+}
+
+// SetOwnerRequest names the user a site belongs to
+type SetOwnerRequest struct {
+	OwnerID string `json:"owner_id"`
+}
+
+// SetOwner records the owner of a site, whose sites then share the owner quota
+func (h *Handler) SetOwner(w http.ResponseWriter, r *http.Request) {
+	siteID := mux.Vars(r)["site_id"]
+
+	var req SetOwnerRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if siteID == "" || req.OwnerID == "" {
+		http.Error(w, "site_id and owner_id are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.backend.SetOwner(siteID, req.OwnerID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to set owner: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUsage returns the bytes stored by a site and its owner, with the quotas that apply
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	siteID := mux.Vars(r)["site_id"]
+
+	usage, err := h.usage.Usage(siteID, h.limits)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compute storage usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// AbortUploadSession discards a session and its chunks
+func (h *Handler) AbortUploadSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !storage.ValidUploadSessionID(sessionID) {
+		http.Error(w, "a valid session_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.backend.AbortUploadSession(sessionID); err != nil {
+		if errors.Is(err, storage.ErrUploadSessionNotFound) {
+			http.Error(w, fmt.Sprintf("Failed to abort upload: %v", err), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to abort upload: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (weak comparison)
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ListFiles returns the entries of a version from its file index
+func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	files, ok := h.openFiles(w, siteID, buildID, "list files")
+	if !ok {
+		return
+	}
+
+	entries := files.Entries()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"site_id":  siteID,
+		"build_id": buildID,
+		"files":    entries,
+		"count":    len(entries),
+	})
+}
+
+// FetchFile streams one file of a version without rebuilding the archive. Symlinks are
+// followed; directories are not files.
+func (h *Handler) FetchFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+	name := vars["path"]
+
+	if siteID == "" || buildID == "" || name == "" {
+		http.Error(w, "site_id, build_id and path are required", http.StatusBadRequest)
+		return
+	}
+
+	files, ok := h.openFiles(w, siteID, buildID, "fetch file")
+	if !ok {
+		return
+	}
+
+	file, err := files.Resolve(name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch file: %v", err), http.StatusNotFound)
+		return
+	}
+
+	etag := `"` + file.SHA256 + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set(InstanceDigestHeader, formatInstanceDigest(file.SHA256))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	reader, err := files.Open(file)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch file: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", fileContentType(file.Path))
+	w.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+	if _, err := io.Copy(w, reader); err != nil {
+		// Can't send error at this point, just log it
+		fmt.Printf("Error streaming file: %v\n", err)
+	}
+}
+
+// openFiles loads the file index of a version, answering the request itself on failure
+func (h *Handler) openFiles(w http.ResponseWriter, siteID, buildID, action string) (*storage.VersionFiles, bool) {
+	files, err := storage.OpenVersionFiles(h.backend, siteID, buildID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrArtifactNotFound):
+			http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusNotFound)
+		case errors.Is(err, storage.ErrNotArchive):
+			http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return files, true
+}
+
+// fileContentType picks a Content-Type from the file extension. Site sources are mostly
+// markdown, which the standard table does not know.
+func fileContentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return "text/markdown; charset=utf-8"
+	case ".yaml", ".yml":
+		return "application/yaml"
+	case ".toml":
+		return "application/toml"
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+func (h *Handler) DeleteArtifact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.backend.DeleteArtifact(siteID, buildID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrArtifactNotFound):
+			http.Error(w, fmt.Sprintf("Failed to delete artifact: %v", err), http.StatusNotFound)
+		case errors.Is(err, storage.ErrPinned):
+			http.Error(w, fmt.Sprintf("Failed to delete artifact: %v", err), http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to delete artifact: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) PinArtifact(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, true)
+}
+
+func (h *Handler) UnpinArtifact(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, false)
+}
+
+func (h *Handler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.backend.SetPinned(siteID, buildID, pinned); err != nil {
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			http.Error(w, fmt.Sprintf("Failed to pin artifact: %v", err), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to pin artifact: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) DeleteSite(w http.ResponseWriter, r *http.Request) {
+	siteID := mux.Vars(r)["site_id"]
+
+	if siteID == "" {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.backend.DeleteSite(siteID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete site: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportSite streams a bundle of everything stored for a site; see package bundle
+func (h *Handler) ExportSite(w http.ResponseWriter, r *http.Request) {
+	siteID := mux.Vars(r)["site_id"]
+
+	if siteID == "" {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+
+	sites, err := h.backend.ListSites()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list sites: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !slices.Contains(sites, siteID) {
+		http.Error(w, fmt.Sprintf("Failed to export site: site %s not found", siteID), http.StatusNotFound)
+		return
+	}
+
+	// A bundle holds every version, so it outlasts the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		fmt.Printf("Warning: failed to lift write deadline for export: %v\n", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.bundle.tar", siteID))
+	if err := bundle.Export(h.backend, siteID, w); err != nil {
+		// Can't send error at this point, just log it; the truncated tar fails to import
+		fmt.Printf("Error exporting site %s: %v\n", siteID, err)
+	}
+}
+
+// ImportSite stores a bundle made by ExportSite as site_id, which must have no versions yet.
+// Artifacts count toward the site and owner quotas.
+func (h *Handler) ImportSite(w http.ResponseWriter, r *http.Request) {
+	siteID := mux.Vars(r)["site_id"]
+
+	if siteID == "" {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := http.NewResponseController(w).SetReadDeadline(time.Time{}); err != nil {
+		fmt.Printf("Warning: failed to lift read deadline for import: %v\n", err)
+	}
+
+	var reserve func(size int64) error
+	if h.limits.Enabled() {
+		usage, err := h.usage.Usage(siteID, h.limits)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to compute storage usage: %v", err), http.StatusInternalServerError)
+			return
+		}
+		reserve = func(size int64) error {
+			if err := usage.Check(size); err != nil {
+				return err
+			}
+			usage.Bytes += size
+			usage.OwnerBytes += size
+			return nil
+		}
+	}
+
+	summary, err := bundle.Import(h.backend, siteID, r.Body, reserve)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, bundle.ErrInvalidBundle):
+			status = http.StatusBadRequest
+		case errors.Is(err, bundle.ErrSiteNotEmpty):
+			status = http.StatusConflict
+		case quotaStatus(err) != 0:
+			status = quotaStatus(err)
+		}
+		http.Error(w, fmt.Sprintf("Failed to import site: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
+
+func (h *Handler) StoreManifest(w http.ResponseWriter, r *http.Request) {
+	h.storeDocument(w, r, storage.DocumentManifest)
+}
+
+func (h *Handler) FetchManifest(w http.ResponseWriter, r *http.Request) {
+	h.fetchDocument(w, r, storage.DocumentManifest)
+}
+
+func (h *Handler) StoreExecutionLog(w http.ResponseWriter, r *http.Request) {
+	h.storeDocument(w, r, storage.DocumentLogs)
+}
+
+func (h *Handler) FetchExecutionLog(w http.ResponseWriter, r *http.Request) {
+	h.fetchDocument(w, r, storage.DocumentLogs)
+}
+
+// storeDocument stores a JSON object next to an existing artifact. The worker uploads the
+// artifact first, so a document for a missing version is rejected.
+func (h *Handler) storeDocument(w http.ResponseWriter, r *http.Request, name string) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDocumentBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Document exceeds %d bytes", maxDocumentBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to read %s: %v", name, err), http.StatusBadRequest)
+		return
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s: must be a JSON object", name), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.backend.ArtifactInfo(siteID, buildID); err != nil {
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			http.Error(w, fmt.Sprintf("Failed to store %s: %v", name, err), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to store %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.backend.StoreDocument(siteID, buildID, name, data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+
+	// The build manifest names the version the build started from
+	if name == storage.DocumentManifest {
+		var manifest struct {
+			BaseBuildID string    `json:"base_build_id"`
+			CreatedAt   time.Time `json:"created_at"`
+		}
+		// Already checked to be an object; a field of the wrong type is left unset
+		json.Unmarshal(data, &manifest)
+		if manifest.CreatedAt.IsZero() {
+			manifest.CreatedAt = time.Now().UTC()
+		}
+		link := &storage.LineageLink{BuildID: buildID, ParentBuildID: manifest.BaseBuildID, CreatedAt: manifest.CreatedAt}
+		if err := h.backend.RecordLineage(siteID, link); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to record lineage: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":  fmt.Sprintf("Stored %s successfully", name),
+		"site_id":  siteID,
+		"build_id": buildID,
+	})
+}
+
+func (h *Handler) fetchDocument(w http.ResponseWriter, r *http.Request, name string) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.backend.FetchDocument(siteID, buildID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrDocumentNotFound) {
+			http.Error(w, fmt.Sprintf("Failed to fetch %s: %v", name, err), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to fetch %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// VerifyArtifacts checks artifact blobs against their manifests and reports the corrupted ones,
+// for a single site if site_id is given and for all sites otherwise
+func (h *Handler) VerifyArtifacts(w http.ResponseWriter, r *http.Request) {
+	report, err := integrity.Verify(h.backend, r.URL.Query().Get("site_id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to verify artifacts: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RecomputeUsage rebuilds the usage counters from what the backend stores, repairing
+// drift such as writes made by another storage instance
+func (h *Handler) RecomputeUsage(w http.ResponseWriter, r *http.Request) {
+	if err := h.usage.Recompute(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to recompute storage usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 type LogRequest struct {
-	BuildID  string            `json:"build_id"`
-	Action   string            `json:"action"`
-	Status   string            `json:"status"`
-	Metadata map[string]string `json:"metadata,omitempty"`
+	BuildID       string            `json:"build_id"`
+	ParentBuildID string            `json:"parent_build_id,omitempty"` // recorded in the version lineage
+	Action        string            `json:"action"`
+	Status        string            `json:"status"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
 }
 
 func (h *Handler) WriteLog(w http.ResponseWriter, r *http.Request) {
@@ -139,6 +993,14 @@ func (h *Handler) WriteLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.ParentBuildID != "" {
+		link := &storage.LineageLink{BuildID: req.BuildID, ParentBuildID: req.ParentBuildID, CreatedAt: entry.Timestamp}
+		if err := h.backend.RecordLineage(siteID, link); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to record lineage: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message":   "Log entry written successfully",
@@ -148,6 +1010,11 @@ func (h *Handler) WriteLog(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// maxVersionPage bounds ?limit= on version listings
+const maxVersionPage = 1000
+
+// ListVersions returns a page of versions, newest first. Optional parameters: limit and
+// cursor for paging, status and action filters, and an RFC 3339 since/until range.
 func (h *Handler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	siteID := vars["site_id"]
@@ -157,16 +1024,321 @@ func (h *Handler) ListVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	versions, err := h.backend.ListVersions(siteID)
+	query, err := parseVersionQuery(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.backend.ListVersions(siteID, query)
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to list versions: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Only the versions on this page are looked up, not the site's whole lineage
+	for _, version := range page.Versions {
+		link, err := h.backend.GetLineage(siteID, version.BuildID)
+		if errors.Is(err, storage.ErrLineageNotFound) {
+			continue
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list versions: %v", err), http.StatusInternalServerError)
+			return
+		}
+		version.ParentBuildID = link.ParentBuildID
+	}
+
+	response := map[string]interface{}{
+		"site_id":  siteID,
+		"versions": page.Versions,
+		"count":    len(page.Versions),
+	}
+	if page.NextCursor != "" {
+		response["next_cursor"] = page.NextCursor
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func parseVersionQuery(r *http.Request) (storage.VersionQuery, error) {
+	params := r.URL.Query()
+	query := storage.VersionQuery{
+		Cursor: params.Get("cursor"),
+		Status: params.Get("status"),
+		Action: params.Get("action"),
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxVersionPage {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxVersionPage)
+		}
+		query.Limit = limit
+	}
+
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("%s must be an RFC 3339 time: %w", name, err)
+		}
+		*target = t
+	}
+
+	return query, nil
+}
+
+// VersionAncestry returns a version followed by its parent, grandparent and so on to the root
+func (h *Handler) VersionAncestry(w http.ResponseWriter, r *http.Request) {
+	h.lineageListing(w, r, "ancestry", (*storage.Lineage).Ancestry)
+}
+
+// VersionChildren returns the versions built directly from a version, oldest first
+func (h *Handler) VersionChildren(w http.ResponseWriter, r *http.Request) {
+	h.lineageListing(w, r, "children", (*storage.Lineage).Children)
+}
+
+func (h *Handler) lineageListing(w http.ResponseWriter, r *http.Request, key string, list func(*storage.Lineage, string) []*storage.LineageLink) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	buildID := vars["build_id"]
+
+	if siteID == "" || buildID == "" {
+		http.Error(w, "site_id and build_id are required", http.StatusBadRequest)
+		return
+	}
+
+	links, err := h.backend.ListLineage(siteID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read lineage: %v", err), http.StatusInternalServerError)
+		return
+	}
+	buildIDs, err := h.backend.ListArtifacts(siteID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read lineage: %v", err), http.StatusInternalServerError)
+		return
+	}
+	stored := make(map[string]bool, len(buildIDs))
+	for _, id := range buildIDs {
+		stored[id] = true
+	}
+
+	lineage := storage.NewLineage(links)
+	if !lineage.Has(buildID) && !stored[buildID] {
+		http.Error(w, fmt.Sprintf("Version not found: %s/%s", siteID, buildID), http.StatusNotFound)
+		return
+	}
+
+	nodes := []*storage.LineageNode{}
+	for _, link := range list(lineage, buildID) {
+		nodes = append(nodes, &storage.LineageNode{LineageLink: *link, Deleted: !stored[link.BuildID]})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"site_id":  siteID,
-		"versions": versions,
-		"count":    len(versions),
+		"build_id": buildID,
+		key:        nodes,
+	})
+}
+
+// DiffVersions lists the files added, removed and modified between ?from= and ?to=, with
+// unified diffs for text files
+func (h *Handler) DiffVersions(w http.ResponseWriter, r *http.Request) {
+	siteID := mux.Vars(r)["site_id"]
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+
+	if siteID == "" || from == "" || to == "" {
+		http.Error(w, "site_id, from and to are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := diff.Versions(h.backend, siteID, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrArtifactNotFound):
+			http.Error(w, fmt.Sprintf("Failed to diff versions: %v", err), http.StatusNotFound)
+		case errors.Is(err, storage.ErrNotArchive):
+			http.Error(w, fmt.Sprintf("Failed to diff versions: %v", err), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to diff versions: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) AppendSessionTurn(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+
+	if siteID == "" {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+
+	var turn storage.SessionTurn
+	if err := json.NewDecoder(r.Body).Decode(&turn); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if turn.JobID == "" || turn.Prompt == "" || turn.Status == "" {
+		http.Error(w, "job_id, prompt, and status are required", http.StatusBadRequest)
+		return
+	}
+
+	if turn.Timestamp.IsZero() {
+		turn.Timestamp = time.Now().UTC()
+	}
+
+	if err := h.backend.AppendSessionTurn(siteID, &turn); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to append session turn: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Session turn recorded successfully",
+		"site_id":   siteID,
+		"job_id":    turn.JobID,
+		"timestamp": turn.Timestamp.Format(time.RFC3339),
+	})
+}
+
+func (h *Handler) ListSessionTurns(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+
+	if siteID == "" {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	turns, err := h.backend.ListSessionTurns(siteID, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list session turns: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"site_id": siteID,
+		"turns":   turns,
+		"count":   len(turns),
+	})
+}
+
+func (h *Handler) StoreAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	uploadID := vars["upload_id"]
+	name := vars["name"]
+
+	if siteID == "" || !storage.ValidAttachmentName(uploadID) || !storage.ValidAttachmentName(name) {
+		http.Error(w, "site_id, a valid upload_id and a valid file name are required", http.StatusBadRequest)
+		return
+	}
+
+	body := newDigestReader(http.MaxBytesReader(w, r.Body, maxAttachmentBytes), func() string {
+		return r.Header.Get(DigestHeader)
+	})
+
+	if err := h.backend.StoreAttachment(siteID, uploadID, name, body); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			http.Error(w, fmt.Sprintf("Attachment exceeds %d bytes", maxAttachmentBytes), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, ErrDigestMismatch):
+			http.Error(w, fmt.Sprintf("Failed to store attachment: %v", err), http.StatusBadRequest)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to store attachment: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":   "Attachment stored successfully",
+		"site_id":   siteID,
+		"upload_id": uploadID,
+		"name":      name,
+		"sha256":    body.Sum(),
+	})
+}
+
+func (h *Handler) FetchAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	uploadID := vars["upload_id"]
+	name := vars["name"]
+
+	if siteID == "" || !storage.ValidAttachmentName(uploadID) || !storage.ValidAttachmentName(name) {
+		http.Error(w, "site_id, a valid upload_id and a valid file name are required", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := h.backend.FetchAttachment(siteID, uploadID, name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, storage.ErrAttachmentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, fmt.Sprintf("Failed to fetch attachment: %v", err), status)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", name))
+	if _, err := io.Copy(w, reader); err != nil {
+		// Can't send error at this point, just log it
+		fmt.Printf("Error streaming attachment: %v\n", err)
+	}
+}
+
+func (h *Handler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	siteID := vars["site_id"]
+	uploadID := vars["upload_id"]
+
+	if siteID == "" || !storage.ValidAttachmentName(uploadID) {
+		http.Error(w, "site_id and a valid upload_id are required", http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := h.backend.ListAttachments(siteID, uploadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list attachments: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"site_id":     siteID,
+		"upload_id":   uploadID,
+		"attachments": attachments,
+		"count":       len(attachments),
 	})
 }
@@ -1,17 +1,25 @@
 package api
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/bundle"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/quota"
 	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockBackend is a mock implementation of storage.Backend
@@ -32,22 +40,211 @@ func (m *MockBackend) FetchArtifact(siteID, buildID string) (io.ReadCloser, erro
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+func (m *MockBackend) ArtifactInfo(siteID, buildID string) (*storage.ArtifactInfo, error) {
+	args := m.Called(siteID, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.ArtifactInfo), args.Error(1)
+}
+
+func (m *MockBackend) ListArtifacts(siteID string) ([]string, error) {
+	args := m.Called(siteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBackend) StoreDocument(siteID, buildID, name string, data []byte) error {
+	args := m.Called(siteID, buildID, name, data)
+	return args.Error(0)
+}
+
+func (m *MockBackend) FetchDocument(siteID, buildID, name string) ([]byte, error) {
+	args := m.Called(siteID, buildID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockBackend) RecordLineage(siteID string, link *storage.LineageLink) error {
+	args := m.Called(siteID, link)
+	return args.Error(0)
+}
+
+func (m *MockBackend) GetLineage(siteID, buildID string) (*storage.LineageLink, error) {
+	args := m.Called(siteID, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.LineageLink), args.Error(1)
+}
+
+func (m *MockBackend) ListLineage(siteID string) ([]*storage.LineageLink, error) {
+	args := m.Called(siteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*storage.LineageLink), args.Error(1)
+}
+
+func (m *MockBackend) ReadManifest(siteID, buildID string) (*storage.ArtifactManifest, error) {
+	args := m.Called(siteID, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.ArtifactManifest), args.Error(1)
+}
+
+func (m *MockBackend) OpenBlob(hash string) (io.ReadCloser, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
 func (m *MockBackend) WriteLogEntry(siteID string, entry *storage.LogEntry) error {
 	args := m.Called(siteID, entry)
 	return args.Error(0)
 }
 
-func (m *MockBackend) ListVersions(siteID string) ([]*storage.Version, error) {
+func (m *MockBackend) ListVersions(siteID string, query storage.VersionQuery) (*storage.VersionPage, error) {
+	args := m.Called(siteID, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.VersionPage), args.Error(1)
+}
+
+func (m *MockBackend) AppendSessionTurn(siteID string, turn *storage.SessionTurn) error {
+	args := m.Called(siteID, turn)
+	return args.Error(0)
+}
+
+func (m *MockBackend) ListSessionTurns(siteID string, limit int) ([]*storage.SessionTurn, error) {
+	args := m.Called(siteID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*storage.SessionTurn), args.Error(1)
+}
+
+func (m *MockBackend) StoreAttachment(siteID, uploadID, name string, reader io.Reader) error {
+	args := m.Called(siteID, uploadID, name, reader)
+	return args.Error(0)
+}
+
+func (m *MockBackend) FetchAttachment(siteID, uploadID, name string) (io.ReadCloser, error) {
+	args := m.Called(siteID, uploadID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockBackend) ListAttachments(siteID, uploadID string) ([]*storage.Attachment, error) {
+	args := m.Called(siteID, uploadID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*storage.Attachment), args.Error(1)
+}
+
+func (m *MockBackend) DeleteArtifact(siteID, buildID string) error {
+	args := m.Called(siteID, buildID)
+	return args.Error(0)
+}
+
+func (m *MockBackend) DeleteSite(siteID string) error {
+	args := m.Called(siteID)
+	return args.Error(0)
+}
+
+func (m *MockBackend) SetPinned(siteID, buildID string, pinned bool) error {
+	args := m.Called(siteID, buildID, pinned)
+	return args.Error(0)
+}
+
+func (m *MockBackend) ListPinned(siteID string) ([]string, error) {
+	args := m.Called(siteID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBackend) ListSites() ([]string, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBackend) SetOwner(siteID, ownerID string) error {
+	args := m.Called(siteID, ownerID)
+	return args.Error(0)
+}
+
+func (m *MockBackend) GetOwner(siteID string) (string, error) {
 	args := m.Called(siteID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockBackend) PruneBlobs(grace time.Duration) (int, error) {
+	args := m.Called(grace)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockBackend) CreateUploadSession(siteID, buildID string) (*storage.UploadSession, error) {
+	args := m.Called(siteID, buildID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.UploadSession), args.Error(1)
+}
+
+func (m *MockBackend) GetUploadSession(sessionID string) (*storage.UploadSession, error) {
+	args := m.Called(sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.UploadSession), args.Error(1)
+}
+
+func (m *MockBackend) PutUploadChunk(sessionID string, index int, reader io.Reader) error {
+	args := m.Called(sessionID, index, reader)
+	// Drain the reader like a real backend, so digest checks run
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return err
+	}
+	return args.Error(0)
+}
+
+func (m *MockBackend) CommitUploadSession(sessionID, sha256 string) (*storage.UploadSession, error) {
+	args := m.Called(sessionID, sha256)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*storage.Version), args.Error(1)
+	return args.Get(0).(*storage.UploadSession), args.Error(1)
+}
+
+func (m *MockBackend) AbortUploadSession(sessionID string) error {
+	args := m.Called(sessionID)
+	return args.Error(0)
+}
+
+func (m *MockBackend) PruneUploadSessions(maxAge time.Duration) (int, error) {
+	args := m.Called(maxAge)
+	return args.Int(0), args.Error(1)
 }
 
 func TestHealthCheck(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	req := httptest.NewRequest("GET", "/health", nil)
@@ -66,7 +263,7 @@ func TestHealthCheck(t *testing.T) {
 
 func TestStoreArtifact(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	content := []byte("test artifact")
@@ -90,7 +287,7 @@ func TestStoreArtifact(t *testing.T) {
 
 func TestStoreArtifactError(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	content := []byte("test artifact")
@@ -107,12 +304,18 @@ func TestStoreArtifactError(t *testing.T) {
 
 func TestFetchArtifact(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	content := []byte("test artifact content")
 	mockReader := io.NopCloser(bytes.NewReader(content))
 
+	mockBackend.On("ArtifactInfo", "test-site", "build-123").Return(&storage.ArtifactInfo{
+		Size:        int64(len(content)),
+		SHA256:      sha256Hex(content),
+		ContentHash: sha256Hex(content),
+		ContentType: "application/gzip",
+	}, nil)
 	mockBackend.On("FetchArtifact", "test-site", "build-123").Return(mockReader, nil)
 
 	req := httptest.NewRequest("GET", "/sites/test-site/artifacts/build-123", nil)
@@ -123,17 +326,99 @@ func TestFetchArtifact(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
 	assert.Contains(t, w.Header().Get("Content-Disposition"), "test-site-build-123.tar.gz")
+	assert.Equal(t, `"`+sha256Hex(content)+`"`, w.Header().Get("ETag"))
 	assert.Equal(t, content, w.Body.Bytes())
 
 	mockBackend.AssertExpectations(t)
 }
 
+func TestFetchArtifactTranscodes(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	router := NewHandler(backend, quota.Limits{}).SetupRoutes()
+
+	var archive bytes.Buffer
+	gzw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gzw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "index.html", Mode: 0644, Size: 5, Typeflag: tar.TypeReg}))
+	_, err = tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader(archive.Bytes())))
+
+	fetch := func(query, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/sites/test-site/artifacts/build-1"+query, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	stored := fetch("", "zstd, gzip")
+	require.Equal(t, http.StatusOK, stored.Code)
+	assert.Equal(t, "application/gzip", stored.Header().Get("Content-Type"))
+	// Served as stored, the archive is rebuilt byte for byte and its digest is known upfront
+	assert.Equal(t, formatInstanceDigest(sha256Hex(stored.Body.Bytes())), stored.Header().Get(InstanceDigestHeader))
+	assert.Empty(t, stored.Result().Trailer.Get(DigestHeader))
+	info, err := backend.ArtifactInfo("test-site", "build-1")
+	require.NoError(t, err)
+	assert.Equal(t, `W/"`+info.ContentHash+`.tar.gz"`, stored.Header().Get("ETag"))
+
+	for _, w := range []*httptest.ResponseRecorder{fetch("", "zstd"), fetch("?format=tar.zst", "")} {
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zstd", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "test-site-build-1.tar.zst")
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Empty(t, w.Header().Get(InstanceDigestHeader))
+		assert.NotEqual(t, stored.Header().Get("ETag"), w.Header().Get("ETag"))
+		assert.Equal(t, sha256Hex(w.Body.Bytes()), w.Result().Trailer.Get(DigestHeader))
+
+		// The transcoded archive stores back as the same files
+		require.NoError(t, backend.StoreArtifact("test-site", "build-2", bytes.NewReader(w.Body.Bytes())))
+		manifest, err := backend.ReadManifest("test-site", "build-2")
+		require.NoError(t, err)
+		assert.Equal(t, storage.FormatTarZst, manifest.Format)
+		require.Len(t, manifest.Files, 1)
+		assert.Equal(t, sha256Hex([]byte("hello")), manifest.Files[0].SHA256)
+		// and keeps the validators of the original, whatever the compressor wrote
+		assert.Equal(t, info.ContentHash, manifest.ContentHash())
+	}
+
+	assert.Equal(t, http.StatusBadRequest, fetch("?format=zip", "").Code)
+}
+
+func TestFetchArtifactNotModified(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("ArtifactInfo", "test-site", "build-123").Return(&storage.ArtifactInfo{Size: 3, SHA256: "abc123", ContentHash: "abc123"}, nil)
+
+	for _, ifNoneMatch := range []string{`"abc123"`, `"other", W/"abc123"`, "*"} {
+		req := httptest.NewRequest("GET", "/sites/test-site/artifacts/build-123", nil)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code, ifNoneMatch)
+		assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+		assert.Empty(t, w.Body.Bytes())
+	}
+
+	// The body is never read for a matching validator
+	mockBackend.AssertNotCalled(t, "FetchArtifact", "test-site", "build-123")
+}
+
 func TestFetchArtifactNotFound(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
-	mockBackend.On("FetchArtifact", "test-site", "build-123").Return(nil, assert.AnError)
+	mockBackend.On("ArtifactInfo", "test-site", "build-123").Return(nil, storage.ErrArtifactNotFound)
 
 	req := httptest.NewRequest("GET", "/sites/test-site/artifacts/build-123", nil)
 	w := httptest.NewRecorder()
@@ -146,7 +431,7 @@ func TestFetchArtifactNotFound(t *testing.T) {
 
 func TestWriteLog(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	logReq := LogRequest{
@@ -180,7 +465,7 @@ func TestWriteLog(t *testing.T) {
 
 func TestWriteLogMissingFields(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	logReq := LogRequest{
@@ -200,7 +485,7 @@ func TestWriteLogMissingFields(t *testing.T) {
 
 func TestListVersions(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
 	versions := []*storage.Version{
@@ -218,7 +503,9 @@ func TestListVersions(t *testing.T) {
 		},
 	}
 
-	mockBackend.On("ListVersions", "test-site").Return(versions, nil)
+	mockBackend.On("ListVersions", "test-site", storage.VersionQuery{}).Return(&storage.VersionPage{Versions: versions}, nil)
+	mockBackend.On("GetLineage", "test-site", "build-3").Return(&storage.LineageLink{BuildID: "build-3", ParentBuildID: "build-2"}, nil)
+	mockBackend.On("GetLineage", "test-site", "build-2").Return(nil, storage.ErrLineageNotFound)
 
 	req := httptest.NewRequest("GET", "/sites/test-site/versions", nil)
 	w := httptest.NewRecorder()
@@ -232,16 +519,17 @@ func TestListVersions(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "test-site", response["site_id"])
 	assert.Equal(t, float64(2), response["count"])
+	assert.Equal(t, "build-2", response["versions"].([]interface{})[0].(map[string]interface{})["parent_build_id"])
 
 	mockBackend.AssertExpectations(t)
 }
 
 func TestListVersionsEmpty(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
-	mockBackend.On("ListVersions", "test-site").Return([]*storage.Version{}, nil)
+	mockBackend.On("ListVersions", "test-site", storage.VersionQuery{}).Return(&storage.VersionPage{Versions: []*storage.Version{}}, nil)
 
 	req := httptest.NewRequest("GET", "/sites/test-site/versions", nil)
 	w := httptest.NewRecorder()
@@ -260,10 +548,10 @@ func TestListVersionsEmpty(t *testing.T) {
 
 func TestListVersionsError(t *testing.T) {
 	mockBackend := new(MockBackend)
-	handler := NewHandler(mockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
 	router := handler.SetupRoutes()
 
-	mockBackend.On("ListVersions", "test-site").Return(nil, assert.AnError)
+	mockBackend.On("ListVersions", "test-site", storage.VersionQuery{}).Return(nil, assert.AnError)
 
 	req := httptest.NewRequest("GET", "/sites/test-site/versions", nil)
 	w := httptest.NewRecorder()
@@ -273,3 +561,767 @@ func TestListVersionsError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	mockBackend.AssertExpectations(t)
 }
+
+func TestListVersionsQuery(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	query := storage.VersionQuery{
+		Limit:  10,
+		Cursor: "abc",
+		Status: "success",
+		Action: "build",
+		Since:  time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Until:  time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	mockBackend.On("ListVersions", "test-site", query).Return(&storage.VersionPage{
+		Versions:   []*storage.Version{{BuildID: "build-1"}},
+		NextCursor: "def",
+	}, nil)
+	mockBackend.On("GetLineage", "test-site", "build-1").Return(nil, storage.ErrLineageNotFound)
+	mockBackend.On("ListVersions", "test-site", storage.VersionQuery{Cursor: "stale"}).Return(nil, storage.ErrInvalidCursor)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET",
+		"/sites/test-site/versions?limit=10&cursor=abc&status=success&action=build&since=2026-03-01T00:00:00Z&until=2026-04-01T00:00:00Z", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "def", response["next_cursor"])
+	assert.Equal(t, float64(1), response["count"])
+
+	for _, target := range []string{
+		"/sites/test-site/versions?limit=0",
+		"/sites/test-site/versions?limit=5000",
+		"/sites/test-site/versions?since=yesterday",
+		"/sites/test-site/versions?cursor=stale",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+}
+
+func TestAppendSessionTurn(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("AppendSessionTurn", "test-site", mock.MatchedBy(func(turn *storage.SessionTurn) bool {
+		return turn.JobID == "job-1" && !turn.Timestamp.IsZero() && turn.Diff != ""
+	})).Return(nil)
+
+	body, _ := json.Marshal(storage.SessionTurn{
+		JobID:   "job-1",
+		Status:  "completed",
+		Prompt:  "Make the button blue",
+		Summary: "Changed the CTA colour",
+		Diff:    "--- a/content/home/index.md\n+++ b/content/home/index.md\n",
+	})
+	req := httptest.NewRequest("POST", "/sites/test-site/session", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestAppendSessionTurnMissingFields(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	body, _ := json.Marshal(storage.SessionTurn{JobID: "job-1"})
+	req := httptest.NewRequest("POST", "/sites/test-site/session", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListSessionTurns(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	turns := []*storage.SessionTurn{
+		{JobID: "job-1", Status: "completed", Prompt: "Add a hero"},
+		{JobID: "job-2", Status: "completed", Prompt: "Make it blue"},
+	}
+	mockBackend.On("ListSessionTurns", "test-site", 2).Return(turns, nil)
+
+	req := httptest.NewRequest("GET", "/sites/test-site/session?limit=2", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), response["count"])
+
+	mockBackend.AssertExpectations(t)
+}
+
+func TestListSessionTurnsInvalidLimit(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/sites/test-site/session?limit=-1", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStoreAttachment(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	var stored []byte
+	mockBackend.On("StoreAttachment", "test-site", "upload-1", "logo.png", mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored, _ = io.ReadAll(args.Get(3).(io.Reader))
+		}).Return(nil)
+
+	req := httptest.NewRequest("PUT", "/sites/test-site/attachments/upload-1/logo.png", bytes.NewReader([]byte("png data")))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []byte("png data"), stored)
+
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response["sha256"], 64)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestStoreAttachmentRejectsUnsafeNames(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	for _, path := range []string{
+		"/sites/test-site/attachments/upload-1/.htaccess",
+		"/sites/test-site/attachments/upload-1/..",
+		"/sites/test-site/attachments/upload-1/a%2Fb.png",
+		"/sites/test-site/attachments/.hidden/logo.png",
+	} {
+		req := httptest.NewRequest("PUT", path, bytes.NewReader([]byte("data")))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.NotEqual(t, http.StatusCreated, w.Code, path)
+	}
+	mockBackend.AssertNotCalled(t, "StoreAttachment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestFetchAttachment(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("FetchAttachment", "test-site", "upload-1", "menu.pdf").
+		Return(io.NopCloser(bytes.NewReader([]byte("%PDF"))), nil)
+
+	req := httptest.NewRequest("GET", "/sites/test-site/attachments/upload-1/menu.pdf", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "%PDF", w.Body.String())
+	mockBackend.AssertExpectations(t)
+}
+
+func TestListAttachments(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	attachments := []*storage.Attachment{{Name: "logo.png", Size: 8}, {Name: "menu.pdf", Size: 4}}
+	mockBackend.On("ListAttachments", "test-site", "upload-1").Return(attachments, nil)
+
+	req := httptest.NewRequest("GET", "/sites/test-site/attachments/upload-1", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Attachments []*storage.Attachment `json:"attachments"`
+		Count       int                   `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, "menu.pdf", response.Attachments[1].Name)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestDeleteArtifact(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Deleted", nil, http.StatusNoContent},
+		{"Not found", fmt.Errorf("%w: test-site/build-123", storage.ErrArtifactNotFound), http.StatusNotFound},
+		{"Pinned", fmt.Errorf("%w: test-site/build-123", storage.ErrPinned), http.StatusConflict},
+		{"Backend error", assert.AnError, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBackend := new(MockBackend)
+			router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+			mockBackend.On("DeleteArtifact", "test-site", "build-123").Return(tt.err)
+
+			req := httptest.NewRequest("DELETE", "/sites/test-site/artifacts/build-123", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			mockBackend.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPinArtifact(t *testing.T) {
+	mockBackend := new(MockBackend)
+	router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+	mockBackend.On("SetPinned", "test-site", "build-123", true).Return(nil)
+	mockBackend.On("SetPinned", "test-site", "build-123", false).Return(nil)
+	mockBackend.On("SetPinned", "test-site", "missing", true).Return(fmt.Errorf("%w: test-site/missing", storage.ErrArtifactNotFound))
+
+	for _, tc := range []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{"PUT", "/sites/test-site/artifacts/build-123/pin", http.StatusNoContent},
+		{"DELETE", "/sites/test-site/artifacts/build-123/pin", http.StatusNoContent},
+		{"PUT", "/sites/test-site/artifacts/missing/pin", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.wantStatus, w.Code, tc.method+" "+tc.path)
+	}
+	mockBackend.AssertExpectations(t)
+}
+
+func TestDeleteSite(t *testing.T) {
+	mockBackend := new(MockBackend)
+	router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+	mockBackend.On("DeleteSite", "test-site").Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/sites/test-site", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestStoreManifest(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	manifest := []byte(`{"build_id":"build-123","base_build_id":"build-100","files_changed":["index.md"]}`)
+	mockBackend.On("ArtifactInfo", "test-site", "build-123").Return(&storage.ArtifactInfo{}, nil)
+	mockBackend.On("StoreDocument", "test-site", "build-123", storage.DocumentManifest, manifest).Return(nil)
+	mockBackend.On("RecordLineage", "test-site", mock.MatchedBy(func(link *storage.LineageLink) bool {
+		return link.BuildID == "build-123" && link.ParentBuildID == "build-100"
+	})).Return(nil)
+
+	// The worker client's path
+	req := httptest.NewRequest("POST", "/artifacts/test-site/build-123/manifest", bytes.NewReader(manifest))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestStoreExecutionLogValidation(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("ArtifactInfo", "test-site", "missing").Return(nil, storage.ErrArtifactNotFound)
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/sites/test-site/artifacts/build-123/logs", `["not", "an", "object"]`, http.StatusBadRequest},
+		{"/sites/test-site/artifacts/build-123/logs", `{"content":`, http.StatusBadRequest},
+		{"/artifacts/test-site/missing/logs", `{"content":"log"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, bytes.NewReader([]byte(tt.body)))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code, tt.body)
+	}
+	mockBackend.AssertNotCalled(t, "StoreDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestFetchDocuments(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("FetchDocument", "test-site", "build-123", storage.DocumentLogs).Return([]byte(`{"content":"agent output"}`), nil)
+	mockBackend.On("FetchDocument", "test-site", "build-123", storage.DocumentManifest).Return(nil, storage.ErrDocumentNotFound)
+
+	req := httptest.NewRequest("GET", "/sites/test-site/artifacts/build-123/logs", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"content":"agent output"}`, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/artifacts/test-site/build-123/manifest", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestVersionLineage(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	base := time.Now().UTC()
+	// v1 -> v2 -> v3, then a rollback to v2 and a new build v4 from it
+	mockBackend.On("ListLineage", "test-site").Return([]*storage.LineageLink{
+		{BuildID: "v1", CreatedAt: base},
+		{BuildID: "v2", ParentBuildID: "v1", CreatedAt: base.Add(time.Minute)},
+		{BuildID: "v3", ParentBuildID: "v2", CreatedAt: base.Add(2 * time.Minute)},
+		{BuildID: "v4", ParentBuildID: "v2", CreatedAt: base.Add(3 * time.Minute)},
+	}, nil)
+	mockBackend.On("ListArtifacts", "test-site").Return([]string{"v2", "v3", "v4"}, nil)
+
+	get := func(path string) (int, map[string]json.RawMessage) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var response map[string]json.RawMessage
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	code, response := get("/sites/test-site/versions/v4/ancestry")
+	require.Equal(t, http.StatusOK, code)
+	var ancestry []storage.LineageNode
+	require.NoError(t, json.Unmarshal(response["ancestry"], &ancestry))
+	require.Len(t, ancestry, 3)
+	assert.Equal(t, []string{"v4", "v2", "v1"}, []string{ancestry[0].BuildID, ancestry[1].BuildID, ancestry[2].BuildID})
+	assert.True(t, ancestry[2].Deleted)
+
+	code, response = get("/sites/test-site/versions/v2/children")
+	require.Equal(t, http.StatusOK, code)
+	var children []storage.LineageNode
+	require.NoError(t, json.Unmarshal(response["children"], &children))
+	require.Len(t, children, 2)
+	assert.Equal(t, "v3", children[0].BuildID)
+	assert.Equal(t, "v4", children[1].BuildID)
+
+	code, response = get("/sites/test-site/versions/v3/children")
+	require.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, "[]", string(response["children"]))
+
+	code, _ = get("/sites/test-site/versions/missing/ancestry")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestWriteLogRecordsParent(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("WriteLogEntry", "test-site", mock.AnythingOfType("*storage.LogEntry")).Return(nil)
+	mockBackend.On("RecordLineage", "test-site", mock.MatchedBy(func(link *storage.LineageLink) bool {
+		return link.BuildID == "build-2" && link.ParentBuildID == "build-1" && !link.CreatedAt.IsZero()
+	})).Return(nil)
+
+	body, _ := json.Marshal(LogRequest{BuildID: "build-2", ParentBuildID: "build-1", Action: "build", Status: "success"})
+	req := httptest.NewRequest("POST", "/sites/test-site/logs", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestDiffVersions(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	mockBackend.On("ReadManifest", "test-site", "v1").Return(&storage.ArtifactManifest{
+		Format: storage.FormatTarGz,
+		Files:  []*storage.ArtifactFile{{Path: "index.md", Type: storage.EntryFile, Mode: 0644, Size: 2, SHA256: "aaa"}},
+	}, nil)
+	mockBackend.On("ReadManifest", "test-site", "v2").Return(&storage.ArtifactManifest{
+		Format: storage.FormatTarGz,
+		Files:  []*storage.ArtifactFile{{Path: "index.md", Type: storage.EntryFile, Mode: 0644, Size: 2, SHA256: "bbb"}},
+	}, nil)
+	mockBackend.On("ReadManifest", "test-site", "missing").Return(nil, storage.ErrArtifactNotFound)
+	mockBackend.On("OpenBlob", "aaa").Return(io.NopCloser(bytes.NewReader([]byte("a\n"))), nil)
+	mockBackend.On("OpenBlob", "bbb").Return(io.NopCloser(bytes.NewReader([]byte("b\n"))), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/diff?from=v1&to=v2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"modified":1`)
+	assert.Contains(t, w.Body.String(), `-a\n+b\n`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/diff?from=v1", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/diff?from=v1&to=missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFetchFileAndTree(t *testing.T) {
+	mockBackend := new(MockBackend)
+	handler := NewHandler(mockBackend, quota.Limits{})
+	router := handler.SetupRoutes()
+
+	hash := strings.Repeat("a", 64)
+	mockBackend.On("ReadManifest", "test-site", "v1").Return(&storage.ArtifactManifest{
+		Format: storage.FormatTarGz,
+		Files: []*storage.ArtifactFile{
+			{Path: "content", Type: storage.EntryDir, Mode: 0755},
+			{Path: "content/index.md", Type: storage.EntryFile, Mode: 0644, Size: 7, SHA256: hash},
+			{Path: "home.md", Type: storage.EntrySymlink, Mode: 0777, Link: "content/index.md"},
+		},
+	}, nil)
+	mockBackend.On("ReadManifest", "test-site", "missing").Return(nil, storage.ErrArtifactNotFound)
+	mockBackend.On("OpenBlob", hash).Return(io.NopCloser(bytes.NewReader([]byte("# Home\n"))), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/artifacts/v1/tree", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":3`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/artifacts/v1/files/home.md", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# Home\n", w.Body.String())
+	assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"`+hash+`"`, w.Header().Get("ETag"))
+
+	req := httptest.NewRequest("GET", "/sites/test-site/artifacts/v1/files/content/index.md", nil)
+	req.Header.Set("If-None-Match", `"`+hash+`"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/artifacts/v1/files/content", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/test-site/artifacts/missing/tree", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUploadSession(t *testing.T) {
+	sessionID := "0123456789abcdef0123456789abcdef"
+	session := &storage.UploadSession{SessionID: sessionID, SiteID: "test-site", BuildID: "build-123"}
+	chunk := []byte("chunk zero")
+	digest := sha256Hex(chunk)
+
+	t.Run("create", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("CreateUploadSession", "test-site", "build-123").Return(session, nil)
+
+		req := httptest.NewRequest("POST", "/sites/test-site/artifacts/build-123/upload-sessions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response storage.UploadSession
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, sessionID, response.SessionID)
+		mockBackend.AssertExpectations(t)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("GetUploadSession", sessionID).Return(session, nil)
+		mockBackend.On("GetUploadSession", "ffffffffffffffffffffffffffffffff").Return(nil, storage.ErrUploadSessionNotFound)
+
+		for path, code := range map[string]int{
+			"/upload-sessions/" + sessionID:                     http.StatusOK,
+			"/upload-sessions/ffffffffffffffffffffffffffffffff": http.StatusNotFound,
+			"/upload-sessions/not-a-session":                    http.StatusBadRequest,
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			assert.Equal(t, code, w.Code, path)
+		}
+	})
+
+	t.Run("put chunk", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("PutUploadChunk", sessionID, 0, mock.Anything).Return(nil)
+
+		req := httptest.NewRequest("PUT", "/upload-sessions/"+sessionID+"/chunks/0", bytes.NewReader(chunk))
+		req.Header.Set(DigestHeader, digest)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, digest, response["sha256"])
+		assert.Equal(t, float64(0), response["index"])
+		mockBackend.AssertExpectations(t)
+	})
+
+	t.Run("put chunk with wrong digest", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("PutUploadChunk", sessionID, 1, mock.Anything).Return(nil)
+
+		req := httptest.NewRequest("PUT", "/upload-sessions/"+sessionID+"/chunks/1", bytes.NewReader(chunk))
+		req.Header.Set(DigestHeader, sha256Hex([]byte("other")))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("put chunk out of range", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+
+		for _, index := range []string{"-1", fmt.Sprint(storage.MaxUploadChunks), "x"} {
+			req := httptest.NewRequest("PUT", "/upload-sessions/"+sessionID+"/chunks/"+index, bytes.NewReader(chunk))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, index)
+		}
+		mockBackend.AssertNotCalled(t, "PutUploadChunk", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("CommitUploadSession", sessionID, digest).Return(session, nil)
+
+		body, _ := json.Marshal(CommitRequest{SHA256: digest})
+		req := httptest.NewRequest("POST", "/upload-sessions/"+sessionID+"/commit", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "test-site", response["site_id"])
+		assert.Equal(t, "build-123", response["build_id"])
+		assert.Equal(t, digest, response["sha256"])
+		mockBackend.AssertExpectations(t)
+	})
+
+	t.Run("commit with digest header", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("CommitUploadSession", sessionID, digest).Return(session, nil)
+
+		req := httptest.NewRequest("POST", "/upload-sessions/"+sessionID+"/commit", nil)
+		req.Header.Set(DigestHeader, strings.ToUpper(digest))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockBackend.AssertExpectations(t)
+	})
+
+	t.Run("commit errors", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("CommitUploadSession", sessionID, digest).Return(nil, storage.ErrUploadIncomplete).Once()
+		mockBackend.On("CommitUploadSession", sessionID, digest).Return(nil, storage.ErrUploadDigestMismatch).Once()
+		mockBackend.On("CommitUploadSession", sessionID, digest).Return(nil, storage.ErrUploadSessionNotFound).Once()
+
+		for _, code := range []int{http.StatusConflict, http.StatusBadRequest, http.StatusNotFound} {
+			req := httptest.NewRequest("POST", "/upload-sessions/"+sessionID+"/commit", nil)
+			req.Header.Set(DigestHeader, digest)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, code, w.Code)
+		}
+
+		// No digest at all
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/upload-sessions/"+sessionID+"/commit", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockBackend.AssertExpectations(t)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		mockBackend := new(MockBackend)
+		router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+		mockBackend.On("AbortUploadSession", sessionID).Return(nil).Once()
+		mockBackend.On("AbortUploadSession", sessionID).Return(storage.ErrUploadSessionNotFound).Once()
+
+		for _, code := range []int{http.StatusNoContent, http.StatusNotFound} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("DELETE", "/upload-sessions/"+sessionID, nil))
+			assert.Equal(t, code, w.Code)
+		}
+		mockBackend.AssertExpectations(t)
+	})
+}
+
+func TestSetOwner(t *testing.T) {
+	mockBackend := new(MockBackend)
+	router := NewHandler(mockBackend, quota.Limits{}).SetupRoutes()
+	mockBackend.On("SetOwner", "test-site", "user-1").Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/sites/test-site/owner", strings.NewReader(`{"owner_id":"user-1"}`)))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/sites/test-site/owner", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockBackend.AssertExpectations(t)
+}
+
+func TestStoreArtifactQuota(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	router := NewHandler(backend, quota.Limits{SiteBytes: 100, OwnerBytes: 150}).SetupRoutes()
+
+	put := func(siteID, buildID string, size int, declared bool) int {
+		var body io.Reader = bytes.NewReader(bytes.Repeat([]byte("x"), size))
+		if !declared {
+			body = io.MultiReader(body) // Hides the length, like a chunked upload
+		}
+		req := httptest.NewRequest("PUT", "/sites/"+siteID+"/artifacts/"+buildID, body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.NoError(t, backend.SetOwner("site-a", "user-1"))
+	require.NoError(t, backend.SetOwner("site-b", "user-1"))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, put("site-a", "too-large", 101, true))
+	assert.Equal(t, http.StatusCreated, put("site-a", "build-1", 60, true))
+	assert.Equal(t, http.StatusInsufficientStorage, put("site-a", "build-2", 50, true))
+	assert.Equal(t, http.StatusInsufficientStorage, put("site-a", "build-2", 50, false))
+
+	// site-b has room of its own, but the owner has only 90 bytes left
+	assert.Equal(t, http.StatusCreated, put("site-b", "build-1", 80, false))
+	assert.Equal(t, http.StatusInsufficientStorage, put("site-b", "build-2", 20, true))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/site-b/usage", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var usage quota.Usage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Equal(t, quota.Usage{
+		SiteID:          "site-b",
+		Bytes:           80,
+		Versions:        1,
+		QuotaBytes:      100,
+		OwnerID:         "user-1",
+		OwnerBytes:      140,
+		OwnerQuotaBytes: 150,
+	}, usage)
+
+	// Resumable uploads are checked when committed
+	session, err := backend.CreateUploadSession("site-a", "build-3")
+	require.NoError(t, err)
+	chunk := bytes.Repeat([]byte("x"), 50)
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 0, bytes.NewReader(chunk)))
+	req := httptest.NewRequest("POST", "/upload-sessions/"+session.SessionID+"/commit", nil)
+	req.Header.Set(DigestHeader, sha256Hex(chunk))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+
+	// Refused uploads store nothing
+	buildIDs, err := backend.ListArtifacts("site-a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1"}, buildIDs)
+
+	// Writes that bypass the handler are counted once the usage is recomputed
+	require.NoError(t, backend.DeleteArtifact("site-b", "build-1"))
+	assert.Equal(t, http.StatusInsufficientStorage, put("site-b", "build-2", 20, true))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/usage/recompute", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusCreated, put("site-b", "build-2", 20, true))
+}
+
+func TestExportImportSite(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	router := NewHandler(backend, quota.Limits{SiteBytes: 100}).SetupRoutes()
+
+	require.NoError(t, backend.StoreArtifact("site-a", "build-1", bytes.NewReader(bytes.Repeat([]byte("x"), 60))))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/missing/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/sites/site-a/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-tar", w.Header().Get("Content-Type"))
+	exported := w.Body.Bytes()
+
+	importSite := func(siteID string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/sites/"+siteID+"/import", bytes.NewReader(body)))
+		return w
+	}
+
+	w = importSite("site-b", exported)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var summary bundle.Summary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, "site-a", summary.FromSiteID)
+	assert.Equal(t, 1, summary.Versions)
+
+	assert.Equal(t, http.StatusConflict, importSite("site-b", exported).Code)
+	assert.Equal(t, http.StatusBadRequest, importSite("site-c", []byte("not a tar")).Code)
+
+	// Imported artifacts count toward the quota
+	router = NewHandler(backend, quota.Limits{SiteBytes: 50}).SetupRoutes()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, importSite("site-f", exported).Code)
+	buildIDs, err := backend.ListArtifacts("site-f")
+	require.NoError(t, err)
+	assert.Empty(t, buildIDs)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// negotiateFormat picks the archive format to serve an archive stored as stored: the format
+// query parameter if given, else the codec the client weighs highest in Accept-Encoding.
+// Ties, and clients that accept neither codec, get the stored format, which needs no
+// transcoding.
+func negotiateFormat(r *http.Request, stored string) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if !storage.IsArchive(format) {
+			return "", fmt.Errorf("unsupported format %q: use %s or %s", format, storage.FormatTarGz, storage.FormatTarZst)
+		}
+		return format, nil
+	}
+
+	other := storage.FormatTarZst
+	if stored == storage.FormatTarZst {
+		other = storage.FormatTarGz
+	}
+
+	weights := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+	if formatWeight(weights, other) > formatWeight(weights, stored) {
+		return other, nil
+	}
+	return stored, nil
+}
+
+// parseAcceptEncoding returns the q-value of each coding in an Accept-Encoding header
+func parseAcceptEncoding(header string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				weight = parsed
+			}
+		}
+		weights[coding] = weight
+	}
+	return weights
+}
+
+func formatWeight(weights map[string]float64, format string) float64 {
+	codings := []string{"gzip", "x-gzip"}
+	if format == storage.FormatTarZst {
+		codings = []string{"zstd"}
+	}
+
+	for _, coding := range codings {
+		if weight, ok := weights[coding]; ok {
+			return weight
+		}
+	}
+	return weights["*"]
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		acceptEncoding string
+		stored         string
+		expected       string
+	}{
+		{"no preference", "", "", storage.FormatTarGz, storage.FormatTarGz},
+		{"zstd only", "", "zstd", storage.FormatTarGz, storage.FormatTarZst},
+		{"gzip only", "", "gzip", storage.FormatTarZst, storage.FormatTarGz},
+		{"tie keeps stored", "", "zstd, gzip", storage.FormatTarGz, storage.FormatTarGz},
+		{"q-values", "", "zstd, gzip;q=0.5", storage.FormatTarGz, storage.FormatTarZst},
+		{"refused coding", "", "gzip;q=0, *", storage.FormatTarGz, storage.FormatTarZst},
+		{"wildcard", "", "*", storage.FormatTarZst, storage.FormatTarZst},
+		{"neither accepted", "", "br, identity", storage.FormatTarZst, storage.FormatTarZst},
+		{"query wins", "?format=tar.zst", "gzip", storage.FormatTarGz, storage.FormatTarZst},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/artifacts/site/build"+tt.query, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			format, err := negotiateFormat(req, tt.stored)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+
+	_, err := negotiateFormat(httptest.NewRequest("GET", "/artifacts/site/build?format=zip", nil), storage.FormatTarGz)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+// Package backends opens the storage backends named in the configuration
+package backends
+
+import (
+	"fmt"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/config"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/s3"
+)
+
+// Primary opens the backend the service stores to
+func Primary(cfg *config.Config) (storage.Backend, error) {
+	return open(cfg.StorageBackend, cfg.NFSBasePath, s3.Config{
+		Endpoint:        cfg.S3Endpoint,
+		Bucket:          cfg.S3Bucket,
+		Region:          cfg.S3Region,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+	})
+}
+
+// Secondary opens the replica backend, or returns nil if replication is off
+func Secondary(cfg *config.Config) (storage.Backend, error) {
+	if cfg.ReplicaBackend == "" {
+		return nil, nil
+	}
+	return open(cfg.ReplicaBackend, cfg.ReplicaNFSBasePath, s3.Config{
+		Endpoint:        cfg.ReplicaS3Endpoint,
+		Bucket:          cfg.ReplicaS3Bucket,
+		Region:          cfg.ReplicaS3Region,
+		AccessKeyID:     cfg.ReplicaS3AccessKeyID,
+		SecretAccessKey: cfg.ReplicaS3SecretAccessKey,
+	})
+}
+
+func open(kind, nfsBasePath string, s3Config s3.Config) (storage.Backend, error) {
+	switch kind {
+	case "nfs":
+		backend, err := nfs.NewNFSBackend(nfsBasePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize NFS backend: %w", err)
+		}
+		return backend, nil
+	case "s3":
+		backend, err := s3.NewS3Backend(s3Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 backend: %w", err)
+		}
+		return backend, nil
+	}
+	return nil, fmt.Errorf("unsupported storage backend: %s", kind)
+}
@@ -0,0 +1,436 @@
+// Package bundle exports everything storage holds for a site into one tar stream and
+// imports it again, under the same or another site ID and on any installation.
+//
+// A bundle is an uncompressed tar; artifacts are compressed already. Entries:
+//
+//	bundle.json                        # Header, always first
+//	versions/{build_id}/artifact       # the artifact as served
+//	versions/{build_id}/manifest.json  # build manifest document, if any
+//	versions/{build_id}/logs.json      # execution log document, if any
+//	log.json                           # every log entry, oldest first
+//	lineage.json                       # lineage links
+//	session.json                       # agent conversation turns
+//
+// Attachments are not exported: they are build inputs and cannot be listed.
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+var (
+	// ErrInvalidBundle is returned for a stream that is not a complete bundle this version reads
+	ErrInvalidBundle = errors.New("invalid bundle")
+
+	// ErrSiteNotEmpty is returned when importing into a site that already has versions
+	ErrSiteNotEmpty = errors.New("site already has versions")
+)
+
+// Format and Version identify the bundle layout; Import reads versions up to Version
+const (
+	Format  = "pagewright-site-bundle"
+	Version = 1
+)
+
+// maxJSONBytes bounds a JSON entry; log, lineage and session files of large sites included
+const maxJSONBytes = 64 << 20
+
+// documentNames are the version documents a bundle carries
+var documentNames = []string{storage.DocumentManifest, storage.DocumentLogs}
+
+// Header is bundle.json
+type Header struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	SiteID     string          `json:"site_id"` // Site the bundle was exported from
+	ExportedAt time.Time       `json:"exported_at"`
+	Builds     []*BuildSummary `json:"builds"`
+	Pinned     []string        `json:"pinned"`
+}
+
+// BuildSummary lists one version of the bundle, so an import can tell a truncated bundle
+// from a complete one and check each artifact
+type BuildSummary struct {
+	BuildID     string `json:"build_id"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentHash string `json:"content_hash,omitempty"` // Checked in place of SHA256 when set
+}
+
+// Summary reports what Import stored
+type Summary struct {
+	SiteID     string `json:"site_id"`
+	FromSiteID string `json:"from_site_id"`
+	Versions   int    `json:"versions"`
+	Documents  int    `json:"documents"`
+	LogEntries int    `json:"log_entries"`
+	Lineage    int    `json:"lineage"`
+	Turns      int    `json:"turns"`
+	Pinned     int    `json:"pinned"`
+}
+
+// Export writes the bundle of siteID to w. Artifacts are streamed from the backend one at
+// a time, so the bundle is never held in memory.
+func Export(backend storage.Backend, siteID string, w io.Writer) error {
+	builds, err := backend.ListArtifacts(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	pinned, err := backend.ListPinned(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list pinned versions: %w", err)
+	}
+
+	now := time.Now().UTC()
+	header := &Header{
+		Format:     Format,
+		Version:    Version,
+		SiteID:     siteID,
+		ExportedAt: now,
+		Builds:     make([]*BuildSummary, 0, len(builds)),
+		Pinned:     pinned,
+	}
+	for _, buildID := range builds {
+		info, err := backend.ArtifactInfo(siteID, buildID)
+		if err != nil {
+			return fmt.Errorf("failed to read artifact %s: %w", buildID, err)
+		}
+		header.Builds = append(header.Builds, &BuildSummary{BuildID: buildID, Size: info.Size, SHA256: info.SHA256, ContentHash: info.ContentHash})
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeJSON(tw, "bundle.json", header, now); err != nil {
+		return err
+	}
+
+	for _, build := range header.Builds {
+		if err := writeArtifact(tw, backend, siteID, build, now); err != nil {
+			return err
+		}
+		for _, name := range documentNames {
+			data, err := backend.FetchDocument(siteID, build.BuildID, name)
+			if errors.Is(err, storage.ErrDocumentNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to fetch %s of %s: %w", name, build.BuildID, err)
+			}
+			if err := writeFile(tw, versionEntry(build.BuildID, name+".json"), data, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	page, err := backend.ListVersions(siteID, storage.VersionQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to list versions: %w", err)
+	}
+	entries := make([]*storage.LogEntry, 0, len(page.Versions))
+	for i := len(page.Versions) - 1; i >= 0; i-- {
+		entries = append(entries, storage.LogFromVersion(siteID, page.Versions[i]))
+	}
+	if err := writeJSON(tw, "log.json", entries, now); err != nil {
+		return err
+	}
+
+	links, err := backend.ListLineage(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list lineage: %w", err)
+	}
+	if err := writeJSON(tw, "lineage.json", links, now); err != nil {
+		return err
+	}
+
+	turns, err := backend.ListSessionTurns(siteID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list session turns: %w", err)
+	}
+	if err := writeJSON(tw, "session.json", turns, now); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return nil
+}
+
+func versionEntry(buildID, name string) string {
+	return "versions/" + buildID + "/" + name
+}
+
+func writeArtifact(tw *tar.Writer, backend storage.Backend, siteID string, build *BuildSummary, modTime time.Time) error {
+	reader, err := backend.FetchArtifact(siteID, build.BuildID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch artifact %s: %w", build.BuildID, err)
+	}
+	defer reader.Close()
+
+	// A rebuilt archive need not match the size recorded at upload, and tar needs the
+	// length up front
+	tmpFile, err := os.CreateTemp("", "pagewright-bundle-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size, err := io.Copy(tmpFile, reader)
+	if err != nil {
+		return fmt.Errorf("failed to read artifact %s: %w", build.BuildID, err)
+	}
+
+	if err := tw.WriteHeader(fileHeader(versionEntry(build.BuildID, "artifact"), size, modTime)); err != nil {
+		return fmt.Errorf("failed to write bundle entry: %w", err)
+	}
+	if _, err := io.Copy(tw, io.NewSectionReader(tmpFile, 0, size)); err != nil {
+		return fmt.Errorf("failed to write artifact %s: %w", build.BuildID, err)
+	}
+	return nil
+}
+
+func writeJSON(tw *tar.Writer, name string, value any, modTime time.Time) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return writeFile(tw, name, data, modTime)
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(fileHeader(name, int64(len(data)), modTime)); err != nil {
+		return fmt.Errorf("failed to write bundle entry: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func fileHeader(name string, size int64, modTime time.Time) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+}
+
+// Import stores a bundle read from r as siteID, which must have no versions yet. reserve
+// is called with the size of each artifact before it is stored and may refuse it, e.g.
+// for quota. If the import fails, whatever it stored is deleted again.
+func Import(backend storage.Backend, siteID string, r io.Reader, reserve func(size int64) error) (*Summary, error) {
+	existing, err := backend.ListArtifacts(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	page, err := backend.ListVersions(siteID, storage.VersionQuery{Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	if len(existing) > 0 || len(page.Versions) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSiteNotEmpty, siteID)
+	}
+	owner, err := backend.GetOwner(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owner: %w", err)
+	}
+
+	summary, err := importBundle(backend, siteID, tar.NewReader(r), reserve)
+	if err != nil {
+		// The site held nothing but possibly its owner, so removing it undoes the import
+		cleanupErr := backend.DeleteSite(siteID)
+		if cleanupErr == nil && owner != "" {
+			cleanupErr = backend.SetOwner(siteID, owner)
+		}
+		if cleanupErr != nil {
+			return nil, fmt.Errorf("%w (and failed to remove the partial import: %v)", err, cleanupErr)
+		}
+		return nil, err
+	}
+	return summary, nil
+}
+
+func importBundle(backend storage.Backend, siteID string, tr *tar.Reader, reserve func(size int64) error) (*Summary, error) {
+	var header Header
+	entry, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if entry.Name != "bundle.json" {
+		return nil, fmt.Errorf("%w: starts with %q instead of bundle.json", ErrInvalidBundle, entry.Name)
+	}
+	if err := readJSON(tr, entry, &header); err != nil {
+		return nil, err
+	}
+	if header.Format != Format || header.Version < 1 || header.Version > Version {
+		return nil, fmt.Errorf("%w: format %q version %d", ErrInvalidBundle, header.Format, header.Version)
+	}
+
+	builds := make(map[string]*BuildSummary, len(header.Builds))
+	for _, build := range header.Builds {
+		if !validBuildID(build.BuildID) {
+			return nil, fmt.Errorf("%w: build ID %q", ErrInvalidBundle, build.BuildID)
+		}
+		builds[build.BuildID] = build
+	}
+
+	summary := &Summary{SiteID: siteID, FromSiteID: header.SiteID}
+	stored := make(map[string]bool, len(builds))
+	for {
+		entry, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if entry.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		switch dir, name := path.Split(entry.Name); {
+		case strings.HasPrefix(dir, "versions/"):
+			buildID := strings.TrimSuffix(strings.TrimPrefix(dir, "versions/"), "/")
+			build, ok := builds[buildID]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s is not listed in bundle.json", ErrInvalidBundle, entry.Name)
+			}
+			if name == "artifact" {
+				if err := importArtifact(backend, siteID, build, tr, entry, reserve); err != nil {
+					return nil, err
+				}
+				stored[buildID] = true
+				summary.Versions++
+				continue
+			}
+			document := strings.TrimSuffix(name, ".json")
+			if !slices.Contains(documentNames, document) {
+				continue // Written by a newer version
+			}
+			var data json.RawMessage
+			if err := readJSON(tr, entry, &data); err != nil {
+				return nil, err
+			}
+			if err := backend.StoreDocument(siteID, buildID, document, data); err != nil {
+				return nil, fmt.Errorf("failed to store %s of %s: %w", document, buildID, err)
+			}
+			summary.Documents++
+
+		case entry.Name == "log.json":
+			var entries []*storage.LogEntry
+			if err := readJSON(tr, entry, &entries); err != nil {
+				return nil, err
+			}
+			for _, logEntry := range entries {
+				logEntry.SiteID = siteID
+				if err := backend.WriteLogEntry(siteID, logEntry); err != nil {
+					return nil, fmt.Errorf("failed to write log entry: %w", err)
+				}
+			}
+			summary.LogEntries = len(entries)
+
+		case entry.Name == "lineage.json":
+			var links []*storage.LineageLink
+			if err := readJSON(tr, entry, &links); err != nil {
+				return nil, err
+			}
+			for _, link := range links {
+				if err := backend.RecordLineage(siteID, link); err != nil {
+					return nil, fmt.Errorf("failed to record lineage: %w", err)
+				}
+			}
+			summary.Lineage = len(links)
+
+		case entry.Name == "session.json":
+			var turns []*storage.SessionTurn
+			if err := readJSON(tr, entry, &turns); err != nil {
+				return nil, err
+			}
+			for _, turn := range turns {
+				if err := backend.AppendSessionTurn(siteID, turn); err != nil {
+					return nil, fmt.Errorf("failed to append session turn: %w", err)
+				}
+			}
+			summary.Turns = len(turns)
+		}
+	}
+
+	for buildID := range builds {
+		if !stored[buildID] {
+			return nil, fmt.Errorf("%w: artifact of %s is missing", ErrInvalidBundle, buildID)
+		}
+	}
+
+	for _, buildID := range header.Pinned {
+		if !stored[buildID] {
+			continue
+		}
+		if err := backend.SetPinned(siteID, buildID, true); err != nil {
+			return nil, fmt.Errorf("failed to pin %s: %w", buildID, err)
+		}
+		summary.Pinned++
+	}
+
+	return summary, nil
+}
+
+// importArtifact stores one artifact and checks it against the digest bundle.json lists
+func importArtifact(backend storage.Backend, siteID string, build *BuildSummary, tr *tar.Reader, entry *tar.Header, reserve func(size int64) error) error {
+	if reserve != nil {
+		if err := reserve(entry.Size); err != nil {
+			return err
+		}
+	}
+	if err := backend.StoreArtifact(siteID, build.BuildID, tr); err != nil {
+		return fmt.Errorf("failed to store artifact %s: %w", build.BuildID, err)
+	}
+
+	if build.SHA256 == "" && build.ContentHash == "" {
+		return nil // Exported from an artifact stored before digests were recorded
+	}
+	info, err := backend.ArtifactInfo(siteID, build.BuildID)
+	if err != nil {
+		return fmt.Errorf("failed to read artifact %s: %w", build.BuildID, err)
+	}
+	// The content hash survives the archive being rebuilt on export; the digest only
+	// matches while the compressor writes the same bytes it did at upload
+	if build.ContentHash != "" {
+		if info.ContentHash != build.ContentHash {
+			return fmt.Errorf("%w: artifact of %s has content hash %s, expected %s", ErrInvalidBundle, build.BuildID, info.ContentHash, build.ContentHash)
+		}
+		return nil
+	}
+	if info.SHA256 != build.SHA256 {
+		return fmt.Errorf("%w: artifact of %s has digest %s, expected %s", ErrInvalidBundle, build.BuildID, info.SHA256, build.SHA256)
+	}
+	return nil
+}
+
+func readJSON(tr *tar.Reader, entry *tar.Header, value any) error {
+	if entry.Size > maxJSONBytes {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidBundle, entry.Name, maxJSONBytes)
+	}
+	if err := json.NewDecoder(tr).Decode(value); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidBundle, entry.Name, err)
+	}
+	return nil
+}
+
+// validBuildID keeps build IDs from a bundle to a single path segment
+func validBuildID(buildID string) bool {
+	return buildID != "" && buildID != "." && buildID != ".." && !strings.ContainsAny(buildID, `/\`)
+}
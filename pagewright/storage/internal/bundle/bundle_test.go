@@ -0,0 +1,200 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// siteArchive returns a gzip site archive, which storage splits into blobs and rebuilds
+func siteArchive(t *testing.T, html string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "index.html", Mode: 0644, Size: int64(len(html))}))
+	_, err := tw.Write([]byte(html))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func exportedSite(t *testing.T) (storage.Backend, []byte) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, buildID := range []string{"build-1", "build-2"} {
+		require.NoError(t, backend.StoreArtifact("site", buildID, bytes.NewReader([]byte("artifact "+buildID))))
+		require.NoError(t, backend.WriteLogEntry("site", &storage.LogEntry{
+			Timestamp: now.Add(time.Duration(i) * time.Minute),
+			BuildID:   buildID,
+			SiteID:    "site",
+			Action:    "build",
+			Status:    "success",
+			Metadata:  map[string]string{"prompt": "make it blue"},
+		}))
+	}
+	require.NoError(t, backend.StoreArtifact("site", "build-3", bytes.NewReader(siteArchive(t, "<h1>blue</h1>"))))
+	require.NoError(t, backend.StoreDocument("site", "build-2", storage.DocumentManifest, []byte(`{"changes_summary":"blue"}`)))
+	require.NoError(t, backend.RecordLineage("site", &storage.LineageLink{BuildID: "build-2", ParentBuildID: "build-1", CreatedAt: now}))
+	require.NoError(t, backend.AppendSessionTurn("site", &storage.SessionTurn{Timestamp: now, JobID: "job-1", Prompt: "make it blue"}))
+	require.NoError(t, backend.SetPinned("site", "build-2", true))
+	require.NoError(t, backend.SetOwner("site", "user-1"))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(backend, "site", &buf))
+	return backend, buf.Bytes()
+}
+
+func TestExportImport(t *testing.T) {
+	source, data := exportedSite(t)
+
+	// The header comes first and lists every version
+	tr := tar.NewReader(bytes.NewReader(data))
+	first, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "bundle.json", first.Name)
+
+	target, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, target.SetOwner("copy", "user-2"))
+
+	summary, err := Import(target, "copy", bytes.NewReader(data), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &Summary{
+		SiteID:     "copy",
+		FromSiteID: "site",
+		Versions:   3,
+		Documents:  1,
+		LogEntries: 2,
+		Lineage:    1,
+		Turns:      1,
+		Pinned:     1,
+	}, summary)
+
+	reader, err := target.FetchArtifact("copy", "build-1")
+	require.NoError(t, err)
+	artifact, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "artifact build-1", string(artifact))
+
+	exported, err := source.ArtifactInfo("site", "build-3")
+	require.NoError(t, err)
+	imported, err := target.ArtifactInfo("copy", "build-3")
+	require.NoError(t, err)
+	assert.Equal(t, exported.SHA256, imported.SHA256)
+
+	document, err := target.FetchDocument("copy", "build-2", storage.DocumentManifest)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"changes_summary":"blue"}`, string(document))
+
+	page, err := target.ListVersions("copy", storage.VersionQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Versions, 2)
+	assert.Equal(t, "build-2", page.Versions[0].BuildID)
+	assert.Equal(t, "make it blue", page.Versions[1].Metadata["prompt"])
+
+	links, err := target.ListLineage("copy")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "build-1", links[0].ParentBuildID)
+
+	turns, err := target.ListSessionTurns("copy", 0)
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+
+	pinned, err := target.ListPinned("copy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-2"}, pinned)
+
+	// The owner is the target's, not the exported site's
+	owner, err := target.GetOwner("copy")
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", owner)
+
+	// A second import into the same site is refused
+	_, err = Import(target, "copy", bytes.NewReader(data), nil)
+	assert.ErrorIs(t, err, ErrSiteNotEmpty)
+}
+
+func TestImportRejectsBrokenBundles(t *testing.T) {
+	_, data := exportedSite(t)
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"truncated": data[:len(data)/2],
+		"not a bundle": func() []byte {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			require.NoError(t, writeFile(tw, "site.tar.gz", []byte("x"), time.Now()))
+			require.NoError(t, tw.Close())
+			return buf.Bytes()
+		}(),
+		"newer version": func() []byte {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			require.NoError(t, writeJSON(tw, "bundle.json", &Header{Format: Format, Version: Version + 1}, time.Now()))
+			require.NoError(t, tw.Close())
+			return buf.Bytes()
+		}(),
+		"unsafe build ID": func() []byte {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			header := &Header{Format: Format, Version: Version, Builds: []*BuildSummary{{BuildID: ".."}}}
+			require.NoError(t, writeJSON(tw, "bundle.json", header, time.Now()))
+			require.NoError(t, tw.Close())
+			return buf.Bytes()
+		}(),
+	}
+
+	for name, bundle := range tests {
+		t.Run(name, func(t *testing.T) {
+			target, err := nfs.NewNFSBackend(t.TempDir())
+			require.NoError(t, err)
+			require.NoError(t, target.SetOwner("copy", "user-2"))
+
+			_, err = Import(target, "copy", bytes.NewReader(bundle), nil)
+			assert.ErrorIs(t, err, ErrInvalidBundle)
+
+			// Nothing of a failed import is left, but the owner is
+			builds, err := target.ListArtifacts("copy")
+			require.NoError(t, err)
+			assert.Empty(t, builds)
+			owner, err := target.GetOwner("copy")
+			require.NoError(t, err)
+			assert.Equal(t, "user-2", owner)
+		})
+	}
+}
+
+func TestImportReserve(t *testing.T) {
+	_, data := exportedSite(t)
+	target, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	errFull := errors.New("full")
+	reserved := 0
+	_, err = Import(target, "copy", bytes.NewReader(data), func(size int64) error {
+		if reserved == 1 {
+			return errFull
+		}
+		reserved++
+		return nil
+	})
+	assert.ErrorIs(t, err, errFull)
+
+	builds, err := target.ListArtifacts("copy")
+	require.NoError(t, err)
+	assert.Empty(t, builds)
+}
@@ -9,6 +9,39 @@ type Config struct {
 	Port           int
 	StorageBackend string
 	NFSBasePath    string
+
+	// S3-compatible object storage, addressed path-style
+	S3Endpoint        string
+	S3Bucket          string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	// Retention sweep; with both rules at 0 versions never expire but unreferenced blobs are still pruned
+	RetentionKeepLast        int
+	RetentionKeepDays        int
+	RetentionIntervalMinutes int
+	BlobGraceMinutes         int
+
+	// Upload sessions idle this long are removed by the sweep
+	UploadSessionTTLHours int
+
+	// Storage quotas in MiB; 0 is unlimited
+	SiteQuotaMB  int
+	OwnerQuotaMB int
+
+	// Usage counters are rebuilt from the backend this often, repairing drift; 0 never does
+	UsageRecomputeHours int
+
+	// Secondary backend mirrored from the primary; an empty ReplicaBackend disables replication
+	ReplicaBackend           string
+	ReplicaNFSBasePath       string
+	ReplicaS3Endpoint        string
+	ReplicaS3Bucket          string
+	ReplicaS3Region          string
+	ReplicaS3AccessKeyID     string
+	ReplicaS3SecretAccessKey string
+	ReplicationLogPath       string
 }
 
 func LoadConfig() *Config {
@@ -16,6 +49,33 @@ func LoadConfig() *Config {
 		Port:           getEnvInt("PAGEWRIGHT_PORT", 8080),
 		StorageBackend: getEnv("PAGEWRIGHT_STORAGE_BACKEND", "nfs"),
 		NFSBasePath:    getEnv("PAGEWRIGHT_NFS_BASE_PATH", "/nfs"),
+
+		S3Endpoint:        getEnv("PAGEWRIGHT_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Bucket:          getEnv("PAGEWRIGHT_S3_BUCKET", ""),
+		S3Region:          getEnv("PAGEWRIGHT_S3_REGION", "us-east-1"),
+		S3AccessKeyID:     getEnv("PAGEWRIGHT_S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("PAGEWRIGHT_S3_SECRET_ACCESS_KEY", ""),
+
+		RetentionKeepLast:        getEnvInt("PAGEWRIGHT_RETENTION_KEEP_LAST", 0),
+		RetentionKeepDays:        getEnvInt("PAGEWRIGHT_RETENTION_KEEP_DAYS", 0),
+		RetentionIntervalMinutes: getEnvInt("PAGEWRIGHT_RETENTION_INTERVAL_MINUTES", 60),
+		BlobGraceMinutes:         getEnvInt("PAGEWRIGHT_BLOB_GRACE_MINUTES", 60),
+
+		UploadSessionTTLHours: getEnvInt("PAGEWRIGHT_UPLOAD_SESSION_TTL_HOURS", 24),
+
+		SiteQuotaMB:  getEnvInt("PAGEWRIGHT_SITE_QUOTA_MB", 0),
+		OwnerQuotaMB: getEnvInt("PAGEWRIGHT_OWNER_QUOTA_MB", 0),
+
+		UsageRecomputeHours: getEnvInt("PAGEWRIGHT_USAGE_RECOMPUTE_HOURS", 24),
+
+		ReplicaBackend:           getEnv("PAGEWRIGHT_REPLICA_BACKEND", ""),
+		ReplicaNFSBasePath:       getEnv("PAGEWRIGHT_REPLICA_NFS_BASE_PATH", "/nfs-replica"),
+		ReplicaS3Endpoint:        getEnv("PAGEWRIGHT_REPLICA_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		ReplicaS3Bucket:          getEnv("PAGEWRIGHT_REPLICA_S3_BUCKET", ""),
+		ReplicaS3Region:          getEnv("PAGEWRIGHT_REPLICA_S3_REGION", "us-east-1"),
+		ReplicaS3AccessKeyID:     getEnv("PAGEWRIGHT_REPLICA_S3_ACCESS_KEY_ID", ""),
+		ReplicaS3SecretAccessKey: getEnv("PAGEWRIGHT_REPLICA_S3_SECRET_ACCESS_KEY", ""),
+		ReplicationLogPath:       getEnv("PAGEWRIGHT_REPLICATION_LOG_PATH", "/var/lib/pagewright/replication"),
 	}
 }
 
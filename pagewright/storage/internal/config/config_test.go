@@ -16,6 +16,22 @@ func TestLoadConfigDefaults(t *testing.T) {
 	assert.Equal(t, 8080, cfg.Port)
 	assert.Equal(t, "nfs", cfg.StorageBackend)
 	assert.Equal(t, "/nfs", cfg.NFSBasePath)
+	assert.Equal(t, "https://s3.amazonaws.com", cfg.S3Endpoint)
+	assert.Equal(t, "", cfg.S3Bucket)
+	assert.Equal(t, "us-east-1", cfg.S3Region)
+	assert.Equal(t, 0, cfg.RetentionKeepLast)
+	assert.Equal(t, 0, cfg.RetentionKeepDays)
+	assert.Equal(t, 60, cfg.RetentionIntervalMinutes)
+	assert.Equal(t, 60, cfg.BlobGraceMinutes)
+	assert.Equal(t, 24, cfg.UploadSessionTTLHours)
+	assert.Equal(t, 0, cfg.SiteQuotaMB)
+	assert.Equal(t, 0, cfg.OwnerQuotaMB)
+	assert.Equal(t, 24, cfg.UsageRecomputeHours)
+	assert.Equal(t, "", cfg.ReplicaBackend)
+	assert.Equal(t, "/nfs-replica", cfg.ReplicaNFSBasePath)
+	assert.Equal(t, "https://s3.amazonaws.com", cfg.ReplicaS3Endpoint)
+	assert.Equal(t, "us-east-1", cfg.ReplicaS3Region)
+	assert.Equal(t, "/var/lib/pagewright/replication", cfg.ReplicationLogPath)
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
@@ -23,6 +39,27 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	os.Setenv("PAGEWRIGHT_PORT", "9090")
 	os.Setenv("PAGEWRIGHT_STORAGE_BACKEND", "s3")
 	os.Setenv("PAGEWRIGHT_NFS_BASE_PATH", "/custom/path")
+	os.Setenv("PAGEWRIGHT_S3_ENDPOINT", "http://minio:9000")
+	os.Setenv("PAGEWRIGHT_S3_BUCKET", "pagewright")
+	os.Setenv("PAGEWRIGHT_S3_REGION", "eu-west-1")
+	os.Setenv("PAGEWRIGHT_S3_ACCESS_KEY_ID", "key")
+	os.Setenv("PAGEWRIGHT_S3_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("PAGEWRIGHT_RETENTION_KEEP_LAST", "20")
+	os.Setenv("PAGEWRIGHT_RETENTION_KEEP_DAYS", "30")
+	os.Setenv("PAGEWRIGHT_RETENTION_INTERVAL_MINUTES", "15")
+	os.Setenv("PAGEWRIGHT_BLOB_GRACE_MINUTES", "120")
+	os.Setenv("PAGEWRIGHT_UPLOAD_SESSION_TTL_HOURS", "6")
+	os.Setenv("PAGEWRIGHT_SITE_QUOTA_MB", "500")
+	os.Setenv("PAGEWRIGHT_OWNER_QUOTA_MB", "2048")
+	os.Setenv("PAGEWRIGHT_USAGE_RECOMPUTE_HOURS", "12")
+	os.Setenv("PAGEWRIGHT_REPLICA_BACKEND", "s3")
+	os.Setenv("PAGEWRIGHT_REPLICA_NFS_BASE_PATH", "/mnt/replica")
+	os.Setenv("PAGEWRIGHT_REPLICA_S3_ENDPOINT", "http://minio-dr:9000")
+	os.Setenv("PAGEWRIGHT_REPLICA_S3_BUCKET", "pagewright-dr")
+	os.Setenv("PAGEWRIGHT_REPLICA_S3_REGION", "eu-central-1")
+	os.Setenv("PAGEWRIGHT_REPLICA_S3_ACCESS_KEY_ID", "dr-key")
+	os.Setenv("PAGEWRIGHT_REPLICA_S3_SECRET_ACCESS_KEY", "dr-secret")
+	os.Setenv("PAGEWRIGHT_REPLICATION_LOG_PATH", "/data/replication")
 	defer os.Clearenv()
 
 	cfg := LoadConfig()
@@ -30,6 +67,27 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	assert.Equal(t, 9090, cfg.Port)
 	assert.Equal(t, "s3", cfg.StorageBackend)
 	assert.Equal(t, "/custom/path", cfg.NFSBasePath)
+	assert.Equal(t, "http://minio:9000", cfg.S3Endpoint)
+	assert.Equal(t, "pagewright", cfg.S3Bucket)
+	assert.Equal(t, "eu-west-1", cfg.S3Region)
+	assert.Equal(t, "key", cfg.S3AccessKeyID)
+	assert.Equal(t, "secret", cfg.S3SecretAccessKey)
+	assert.Equal(t, 20, cfg.RetentionKeepLast)
+	assert.Equal(t, 30, cfg.RetentionKeepDays)
+	assert.Equal(t, 15, cfg.RetentionIntervalMinutes)
+	assert.Equal(t, 120, cfg.BlobGraceMinutes)
+	assert.Equal(t, 6, cfg.UploadSessionTTLHours)
+	assert.Equal(t, 500, cfg.SiteQuotaMB)
+	assert.Equal(t, 2048, cfg.OwnerQuotaMB)
+	assert.Equal(t, 12, cfg.UsageRecomputeHours)
+	assert.Equal(t, "s3", cfg.ReplicaBackend)
+	assert.Equal(t, "/mnt/replica", cfg.ReplicaNFSBasePath)
+	assert.Equal(t, "http://minio-dr:9000", cfg.ReplicaS3Endpoint)
+	assert.Equal(t, "pagewright-dr", cfg.ReplicaS3Bucket)
+	assert.Equal(t, "eu-central-1", cfg.ReplicaS3Region)
+	assert.Equal(t, "dr-key", cfg.ReplicaS3AccessKeyID)
+	assert.Equal(t, "dr-secret", cfg.ReplicaS3SecretAccessKey)
+	assert.Equal(t, "/data/replication", cfg.ReplicationLogPath)
 }
 
 func TestGetEnvInt(t *testing.T) {
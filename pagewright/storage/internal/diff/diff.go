@@ -0,0 +1,186 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// File change statuses
+const (
+	StatusAdded    = "added"
+	StatusRemoved  = "removed"
+	StatusModified = "modified"
+)
+
+const (
+	// maxFileBytes is the largest file that gets a text diff
+	maxFileBytes = 1 << 20
+
+	// maxTotalBytes bounds the diffs of one response; later files are listed without a diff
+	maxTotalBytes = 4 << 20
+)
+
+// FileChange is one path that differs between two versions
+type FileChange struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"` // added, removed, modified
+	OldSize int64  `json:"old_size"`
+	NewSize int64  `json:"new_size"`
+	Binary  bool   `json:"binary,omitempty"`
+	Diff    string `json:"diff,omitempty"` // unified diff, for text files
+}
+
+// Result lists the files that differ between two versions, sorted by path
+type Result struct {
+	SiteID    string        `json:"site_id"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Added     int           `json:"added"`
+	Removed   int           `json:"removed"`
+	Modified  int           `json:"modified"`
+	Files     []*FileChange `json:"files"`
+	Truncated bool          `json:"truncated,omitempty"` // some text diffs were left out to bound the response
+}
+
+// version is a stored version's files by path and the index holding their content
+type version struct {
+	files map[string]*storage.ArtifactFile
+	index *storage.VersionFiles
+}
+
+// Versions compares two versions of a site file by file. Unchanged files are found from
+// the manifests alone; only changed text files are read to produce a diff.
+func Versions(backend storage.Backend, siteID, from, to string) (*Result, error) {
+	old, err := load(backend, siteID, from)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := load(backend, siteID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(old.files)+len(cur.files))
+	for path := range old.files {
+		paths = append(paths, path)
+	}
+	for path := range cur.files {
+		if _, ok := old.files[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	result := &Result{SiteID: siteID, From: from, To: to, Files: []*FileChange{}}
+	budget := maxTotalBytes
+	for _, path := range paths {
+		a, b := old.files[path], cur.files[path]
+		change := &FileChange{Path: path}
+		switch {
+		case b == nil:
+			change.Status = StatusRemoved
+			result.Removed++
+		case a == nil:
+			change.Status = StatusAdded
+			result.Added++
+		case sameEntry(a, b):
+			continue
+		default:
+			change.Status = StatusModified
+			result.Modified++
+		}
+		if a != nil {
+			change.OldSize = a.Size
+		}
+		if b != nil {
+			change.NewSize = b.Size
+		}
+		result.Files = append(result.Files, change)
+
+		if !needsDiff(a, b) {
+			continue
+		}
+		if budget <= 0 {
+			result.Truncated = true
+			continue
+		}
+		if err := fillDiff(change, old, cur, a, b); err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", path, err)
+		}
+		budget -= len(change.Diff)
+	}
+
+	return result, nil
+}
+
+// load returns the files of a version, directories left out
+func load(backend storage.Backend, siteID, buildID string) (*version, error) {
+	index, err := storage.OpenVersionFiles(backend, siteID, buildID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &version{files: make(map[string]*storage.ArtifactFile), index: index}
+	for _, file := range index.Entries() {
+		if file.Type != storage.EntryDir {
+			v.files[file.Path] = file
+		}
+	}
+	return v, nil
+}
+
+func sameEntry(a, b *storage.ArtifactFile) bool {
+	return a.Type == b.Type && a.SHA256 == b.SHA256 && a.Link == b.Link && a.Mode == b.Mode
+}
+
+// needsDiff reports whether the change has content to show: a regular file on at least
+// one side whose bytes differ
+func needsDiff(a, b *storage.ArtifactFile) bool {
+	if (a == nil || a.Type != storage.EntryFile) && (b == nil || b.Type != storage.EntryFile) {
+		return false
+	}
+	return a == nil || b == nil || a.SHA256 != b.SHA256
+}
+
+func fillDiff(change *FileChange, old, cur *version, a, b *storage.ArtifactFile) error {
+	if (a != nil && a.Size > maxFileBytes) || (b != nil && b.Size > maxFileBytes) {
+		change.Diff = fmt.Sprintf("--- a/%s\n+++ b/%s\n@@ file too large to diff (%d -> %d bytes) @@\n",
+			change.Path, change.Path, change.OldSize, change.NewSize)
+		return nil
+	}
+
+	before, err := readFile(old.index, a)
+	if err != nil {
+		return err
+	}
+	after, err := readFile(cur.index, b)
+	if err != nil {
+		return err
+	}
+
+	if isBinary(before) || isBinary(after) {
+		change.Binary = true
+		return nil
+	}
+	change.Diff = unifiedDiff(change.Path, before, after, a == nil || a.Type != storage.EntryFile, b == nil || b.Type != storage.EntryFile)
+	return nil
+}
+
+// readFile returns a regular file's content; other entries read as empty
+func readFile(index *storage.VersionFiles, file *storage.ArtifactFile) ([]byte, error) {
+	reader, err := index.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) != -1
+}
@@ -0,0 +1,121 @@
+package diff
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBackend(t *testing.T) (*nfs.NFSBackend, string) {
+	tmpDir := t.TempDir()
+	backend, err := nfs.NewNFSBackend(tmpDir)
+	require.NoError(t, err)
+	return backend, tmpDir
+}
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, name := range names {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  time.Unix(0, 0),
+		}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func TestVersions(t *testing.T) {
+	backend, _ := setupBackend(t)
+
+	require.NoError(t, backend.StoreArtifact("test-site", "v1", bytes.NewReader(buildArchive(t, map[string]string{
+		"index.md":   "# Home\n\nWelcome.\n",
+		"about.md":   "# About\n",
+		"logo.png":   "\x89PNG\x00old",
+		"old-faq.md": "# FAQ\n",
+	}))))
+	require.NoError(t, backend.StoreArtifact("test-site", "v2", bytes.NewReader(buildArchive(t, map[string]string{
+		"index.md": "# Home\n\nWelcome back.\n",
+		"about.md": "# About\n",
+		"logo.png": "\x89PNG\x00new",
+		"menu.md":  "# Menu\n",
+	}))))
+
+	result, err := Versions(backend, "test-site", "v1", "v2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, 2, result.Modified)
+	require.Len(t, result.Files, 4)
+
+	byPath := make(map[string]*FileChange)
+	for _, change := range result.Files {
+		byPath[change.Path] = change
+	}
+	assert.NotContains(t, byPath, "about.md")
+
+	assert.Equal(t, StatusModified, byPath["index.md"].Status)
+	assert.Equal(t, "--- a/index.md\n+++ b/index.md\n@@ -1,3 +1,3 @@\n # Home\n \n-Welcome.\n+Welcome back.\n", byPath["index.md"].Diff)
+
+	assert.Equal(t, StatusAdded, byPath["menu.md"].Status)
+	assert.True(t, strings.HasPrefix(byPath["menu.md"].Diff, "--- /dev/null\n+++ b/menu.md\n"))
+
+	assert.Equal(t, StatusRemoved, byPath["old-faq.md"].Status)
+	assert.Contains(t, byPath["old-faq.md"].Diff, "+++ /dev/null\n")
+
+	assert.True(t, byPath["logo.png"].Binary)
+	assert.Empty(t, byPath["logo.png"].Diff)
+}
+
+func TestVersionsLegacyArchive(t *testing.T) {
+	backend, tmpDir := setupBackend(t)
+
+	artifactDir := filepath.Join(tmpDir, "sites", "test-site", "artifacts")
+	require.NoError(t, os.MkdirAll(artifactDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(artifactDir, "old.tar.gz"), buildArchive(t, map[string]string{"index.md": "a\n"}), 0644))
+	require.NoError(t, backend.StoreArtifact("test-site", "new", bytes.NewReader(buildArchive(t, map[string]string{"index.md": "b\n"}))))
+
+	result, err := Versions(backend, "test-site", "old", "new")
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	assert.Equal(t, "--- a/index.md\n+++ b/index.md\n@@ -1,1 +1,1 @@\n-a\n+b\n", result.Files[0].Diff)
+}
+
+func TestVersionsErrors(t *testing.T) {
+	backend, _ := setupBackend(t)
+	require.NoError(t, backend.StoreArtifact("test-site", "raw", bytes.NewReader([]byte("not an archive"))))
+	require.NoError(t, backend.StoreArtifact("test-site", "v1", bytes.NewReader(buildArchive(t, map[string]string{"index.md": "a\n"}))))
+
+	_, err := Versions(backend, "test-site", "v1", "raw")
+	assert.ErrorIs(t, err, storage.ErrNotArchive)
+
+	_, err = Versions(backend, "test-site", "v1", "missing")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+}
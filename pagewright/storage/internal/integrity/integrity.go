@@ -0,0 +1,129 @@
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Corruption describes an artifact whose stored content no longer matches its manifest
+type Corruption struct {
+	SiteID  string `json:"site_id"`
+	BuildID string `json:"build_id"`
+	Error   string `json:"error"`
+}
+
+// Report summarizes one verification scrub
+type Report struct {
+	Sites      int          `json:"sites"`
+	Checked    int          `json:"checked"`
+	Unverified int          `json:"unverified"` // Stored as full archives, before manifests
+	Corrupted  []Corruption `json:"corrupted"`
+}
+
+// blobResult is the outcome of hashing one blob, shared by every artifact referencing it
+type blobResult struct {
+	size int64
+	err  error
+}
+
+// Verify checks every artifact of siteID (all sites if empty) against its manifest: each
+// blob it references must exist and hash to its content-address key, and each file entry
+// must match its blob's size. Archives are never rebuilt, so the scrub does not depend on
+// what the compressor writes. Blobs shared between artifacts are hashed once.
+func Verify(backend storage.Backend, siteID string) (*Report, error) {
+	report := &Report{Corrupted: []Corruption{}}
+
+	sites := []string{siteID}
+	if siteID == "" {
+		var err error
+		sites, err = backend.ListSites()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sites: %w", err)
+		}
+	}
+
+	blobs := make(map[string]*blobResult)
+	for _, site := range sites {
+		buildIDs, err := backend.ListArtifacts(site)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list artifacts of %s: %w", site, err)
+		}
+		report.Sites++
+
+		for _, buildID := range buildIDs {
+			manifest, err := backend.ReadManifest(site, buildID)
+			if errors.Is(err, storage.ErrNoManifest) {
+				report.Unverified++
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifest of %s/%s: %w", site, buildID, err)
+			}
+
+			report.Checked++
+			if err := verifyManifest(backend, manifest, blobs); err != nil {
+				report.Corrupted = append(report.Corrupted, Corruption{SiteID: site, BuildID: buildID, Error: err.Error()})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func verifyManifest(backend storage.Backend, manifest *storage.ArtifactManifest, blobs map[string]*blobResult) error {
+	for _, hash := range manifest.Blobs() {
+		result, ok := blobs[hash]
+		if !ok {
+			result = verifyBlob(backend, hash)
+			blobs[hash] = result
+		}
+		if result.err != nil {
+			return result.err
+		}
+	}
+
+	if manifest.Format == storage.FormatRaw {
+		if size := blobs[manifest.Blob].size; size != manifest.Size {
+			return fmt.Errorf("size mismatch: expected %d, got %d", manifest.Size, size)
+		}
+		return nil
+	}
+
+	for _, file := range manifest.Files {
+		if file.Type != storage.EntryFile {
+			continue
+		}
+		if file.SHA256 == "" {
+			return fmt.Errorf("file %s has no blob", file.Path)
+		}
+		if size := blobs[file.SHA256].size; size != file.Size {
+			return fmt.Errorf("size mismatch for %s: expected %d, got %d", file.Path, file.Size, size)
+		}
+	}
+	return nil
+}
+
+// verifyBlob re-hashes a blob and compares the digest with its key
+func verifyBlob(backend storage.Backend, hash string) *blobResult {
+	reader, err := backend.OpenBlob(hash)
+	if err != nil {
+		return &blobResult{err: fmt.Errorf("failed to open blob %s: %w", hash, err)}
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, reader)
+	if err != nil {
+		return &blobResult{err: fmt.Errorf("failed to read blob %s: %w", hash, err)}
+	}
+
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != hash {
+		return &blobResult{err: fmt.Errorf("digest mismatch: blob %s hashes to %s", hash, sum)}
+	}
+	return &blobResult{size: size}
+}
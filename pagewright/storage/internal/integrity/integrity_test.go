@@ -0,0 +1,114 @@
+package integrity
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend, err := nfs.NewNFSBackend(tmpDir)
+	require.NoError(t, err)
+
+	require.NoError(t, backend.StoreArtifact("site-a", "build-1", bytes.NewReader([]byte("healthy artifact"))))
+	require.NoError(t, backend.StoreArtifact("site-b", "build-1", bytes.NewReader([]byte("artifact to corrupt"))))
+	legacyDir := filepath.Join(tmpDir, "sites", "site-b", "artifacts")
+	require.NoError(t, os.WriteFile(filepath.Join(legacyDir, "old.tar.gz"), []byte("full archive"), 0644))
+
+	info, err := backend.ArtifactInfo("site-b", "build-1")
+	require.NoError(t, err)
+	corruptBlob(t, tmpDir, info.SHA256, "bit rot")
+
+	report, err := Verify(backend, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Sites)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 1, report.Unverified)
+	require.Len(t, report.Corrupted, 1)
+	assert.Equal(t, "site-b", report.Corrupted[0].SiteID)
+	assert.Equal(t, "build-1", report.Corrupted[0].BuildID)
+	assert.Contains(t, report.Corrupted[0].Error, "digest mismatch")
+
+	report, err = Verify(backend, "site-a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Checked)
+	assert.Empty(t, report.Corrupted)
+}
+
+func TestVerifyArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+	backend, err := nfs.NewNFSBackend(tmpDir)
+	require.NoError(t, err)
+
+	archive := buildArchive(t, map[string]string{"index.html": "hello", "about.html": "about us"})
+	require.NoError(t, backend.StoreArtifact("site", "build-1", bytes.NewReader(archive)))
+	require.NoError(t, backend.StoreArtifact("site", "build-2", bytes.NewReader(archive)))
+	require.NoError(t, backend.StoreArtifact("site", "build-3", bytes.NewReader(buildArchive(t, map[string]string{"index.html": "hello"}))))
+
+	report, err := Verify(backend, "site")
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Checked)
+	assert.Empty(t, report.Corrupted)
+
+	// A missing blob fails every version that lists it, and only those
+	require.NoError(t, os.Remove(blobPath(tmpDir, sha256Hex("about us"))))
+
+	report, err = Verify(backend, "site")
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Checked)
+	require.Len(t, report.Corrupted, 2)
+	assert.Equal(t, "build-1", report.Corrupted[0].BuildID)
+	assert.Equal(t, "build-2", report.Corrupted[1].BuildID)
+	assert.Contains(t, report.Corrupted[0].Error, "failed to open blob")
+
+	// A blob that no longer hashes to its key is caught without rebuilding the archive
+	corruptBlob(t, tmpDir, sha256Hex("hello"), "jello")
+
+	report, err = Verify(backend, "site")
+	require.NoError(t, err)
+	require.Len(t, report.Corrupted, 3)
+	assert.Equal(t, "build-3", report.Corrupted[2].BuildID)
+	assert.Contains(t, report.Corrupted[2].Error, "digest mismatch")
+}
+
+func blobPath(baseDir, hash string) string {
+	return filepath.Join(baseDir, "blobs", hash[:2], hash)
+}
+
+func corruptBlob(t *testing.T, baseDir, hash, content string) {
+	t.Helper()
+	path := blobPath(baseDir, hash)
+	require.NoError(t, os.Chmod(path, 0644))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,95 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrQuotaExceeded is returned when the site or its owner has too little space left
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+	// ErrTooLarge is returned when an upload alone is larger than the quota
+	ErrTooLarge = errors.New("artifact is larger than the storage quota")
+)
+
+// Limits caps the bytes stored per site and per owner. A zero limit is off.
+type Limits struct {
+	SiteBytes  int64
+	OwnerBytes int64
+}
+
+// Enabled reports whether any limit is set
+func (l Limits) Enabled() bool {
+	return l.SiteBytes > 0 || l.OwnerBytes > 0
+}
+
+// Usage is what a site, and the owner it belongs to, store. Bytes count each version's
+// artifact at the size recorded on upload; files shared between versions are counted every time.
+type Usage struct {
+	SiteID          string `json:"site_id"`
+	Bytes           int64  `json:"bytes"`
+	Versions        int    `json:"versions"`
+	QuotaBytes      int64  `json:"quota_bytes"` // 0 when unlimited
+	OwnerID         string `json:"owner_id,omitempty"`
+	OwnerBytes      int64  `json:"owner_bytes"`
+	OwnerQuotaBytes int64  `json:"owner_quota_bytes"` // 0 when unlimited
+	OverQuota       bool   `json:"over_quota"`        // Nothing more can be stored
+}
+
+// Remaining returns how many more bytes the site may store and the quota that bounds it.
+// The quota is 0 when the site is unlimited.
+func (u *Usage) Remaining() (remaining, quota int64) {
+	if u.QuotaBytes > 0 {
+		remaining, quota = u.QuotaBytes-u.Bytes, u.QuotaBytes
+	}
+	if u.OwnerQuotaBytes > 0 {
+		if left := u.OwnerQuotaBytes - u.OwnerBytes; quota == 0 || left < remaining {
+			remaining, quota = left, u.OwnerQuotaBytes
+		}
+	}
+	return remaining, quota
+}
+
+// Check returns ErrTooLarge or ErrQuotaExceeded if size more bytes do not fit. A negative
+// size is unknown and only fails once nothing fits.
+func (u *Usage) Check(size int64) error {
+	remaining, quota := u.Remaining()
+	switch {
+	case quota == 0:
+		return nil
+	case size > quota:
+		return fmt.Errorf("%w: %d bytes, quota %d", ErrTooLarge, size, quota)
+	case remaining <= 0 || size > remaining:
+		return fmt.Errorf("%w: %d bytes left", ErrQuotaExceeded, max(remaining, 0))
+	}
+	return nil
+}
+
+// LimitReader returns r, failing with a quota error once more bytes are read than fit.
+// Checking the declared size first gives the better error; this catches uploads of
+// unknown size.
+func (u *Usage) LimitReader(r io.Reader) io.Reader {
+	remaining, quota := u.Remaining()
+	if quota == 0 {
+		return r
+	}
+	return &limitReader{reader: r, left: remaining, usage: u}
+}
+
+type limitReader struct {
+	reader io.Reader
+	left   int64
+	read   int64
+	usage  *Usage
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+	if l.read > l.left {
+		return 0, l.usage.Check(l.read)
+	}
+	return n, err
+}
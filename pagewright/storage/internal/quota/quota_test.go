@@ -0,0 +1,43 @@
+package quota
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	usage := &Usage{Bytes: 60, QuotaBytes: 100, OwnerBytes: 100, OwnerQuotaBytes: 200}
+
+	remaining, quota := usage.Remaining()
+	assert.Equal(t, int64(40), remaining)
+	assert.Equal(t, int64(100), quota)
+
+	assert.NoError(t, usage.Check(40))
+	assert.NoError(t, usage.Check(-1))
+	assert.True(t, errors.Is(usage.Check(41), ErrQuotaExceeded))
+	assert.True(t, errors.Is(usage.Check(101), ErrTooLarge))
+
+	// The owner has less left than the site
+	usage.OwnerBytes = 190
+	remaining, quota = usage.Remaining()
+	assert.Equal(t, int64(10), remaining)
+	assert.Equal(t, int64(200), quota)
+}
+
+func TestLimitReader(t *testing.T) {
+	usage := &Usage{Bytes: 60, QuotaBytes: 100}
+
+	data, err := io.ReadAll(usage.LimitReader(bytes.NewReader(make([]byte, 40))))
+	assert.NoError(t, err)
+	assert.Len(t, data, 40)
+
+	_, err = io.ReadAll(usage.LimitReader(bytes.NewReader(make([]byte, 41))))
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+
+	_, err = io.ReadAll((&Usage{QuotaBytes: 100}).LimitReader(bytes.NewReader(make([]byte, 101))))
+	assert.True(t, errors.Is(err, ErrTooLarge))
+}
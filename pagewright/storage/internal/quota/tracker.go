@@ -0,0 +1,360 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Tracker keeps the bytes stored per site and per owner, so checking a quota costs no
+// backend reads. It wraps a backend and updates its counters on every write that changes
+// usage. The counters are loaded by one scan on first use; Recompute scans again to repair
+// drift, such as writes made by another process.
+type Tracker struct {
+	storage.Backend
+
+	recompute sync.Mutex // Serializes scans
+
+	mu         sync.Mutex
+	loaded     bool
+	sites      map[string]*siteCounter
+	owners     map[string]int64
+	stale      map[string]bool // Sites whose counter could not be updated; rescanned on read
+	rescanning map[string]bool // Sites written during a scan, nil when none runs
+}
+
+type siteCounter struct {
+	owner  string
+	bytes  int64
+	builds map[string]int64 // Size of each version
+}
+
+func NewTracker(backend storage.Backend) *Tracker {
+	return &Tracker{
+		Backend: backend,
+		sites:   make(map[string]*siteCounter),
+		owners:  make(map[string]int64),
+		stale:   make(map[string]bool),
+	}
+}
+
+// Usage returns what a site and its owner store, with limits applied
+func (t *Tracker) Usage(siteID string, limits Limits) (*Usage, error) {
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for site := range t.stale {
+		counter, err := scanSite(t.Backend, site)
+		if err != nil {
+			return nil, err
+		}
+		t.replace(site, counter)
+		delete(t.stale, site)
+	}
+
+	usage := &Usage{SiteID: siteID, QuotaBytes: limits.SiteBytes}
+	if counter := t.sites[siteID]; counter != nil {
+		usage.Bytes = counter.bytes
+		usage.Versions = len(counter.builds)
+		usage.OwnerID = counter.owner
+	}
+	if usage.OwnerID != "" {
+		usage.OwnerQuotaBytes = limits.OwnerBytes
+		usage.OwnerBytes = t.owners[usage.OwnerID]
+	}
+
+	remaining, quota := usage.Remaining()
+	usage.OverQuota = quota > 0 && remaining <= 0
+	return usage, nil
+}
+
+// Recompute rebuilds every counter from the backend. Writes made while it scans are
+// tracked as usual and their sites scanned again at the end, so none is lost.
+func (t *Tracker) Recompute() error {
+	t.recompute.Lock()
+	defer t.recompute.Unlock()
+	return t.scan()
+}
+
+// scan rebuilds the counters. Callers hold recompute.
+func (t *Tracker) scan() error {
+	t.mu.Lock()
+	t.rescanning = make(map[string]bool)
+	t.mu.Unlock()
+
+	sites, err := scanAll(t.Backend)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rescanning := t.rescanning
+	t.rescanning = nil
+	if err != nil {
+		return err
+	}
+
+	for site := range rescanning {
+		counter, err := scanSite(t.Backend, site)
+		if err != nil {
+			return err
+		}
+		if counter == nil {
+			delete(sites, site)
+		} else {
+			sites[site] = counter
+		}
+	}
+
+	t.sites = sites
+	t.owners = make(map[string]int64)
+	for _, counter := range sites {
+		if counter.owner != "" {
+			t.owners[counter.owner] += counter.bytes
+		}
+	}
+	t.stale = make(map[string]bool)
+	t.loaded = true
+	return nil
+}
+
+// Run recomputes the counters now and then every interval until ctx is cancelled
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Recompute(); err != nil {
+			log.Printf("Quota recompute failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tracker) StoreArtifact(siteID, buildID string, reader io.Reader) error {
+	if err := t.Backend.StoreArtifact(siteID, buildID, reader); err != nil {
+		return err
+	}
+	t.stored(siteID, buildID)
+	return nil
+}
+
+func (t *Tracker) CommitUploadSession(sessionID, sha256 string) (*storage.UploadSession, error) {
+	session, err := t.Backend.CommitUploadSession(sessionID, sha256)
+	if err != nil {
+		return nil, err
+	}
+	t.stored(session.SiteID, session.BuildID)
+	return session, nil
+}
+
+func (t *Tracker) DeleteArtifact(siteID, buildID string) error {
+	if err := t.Backend.DeleteArtifact(siteID, buildID); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tracking() {
+		return nil
+	}
+	t.touch(siteID)
+	if counter := t.sites[siteID]; counter != nil {
+		t.setBuild(counter, buildID, 0, false)
+	}
+	return nil
+}
+
+func (t *Tracker) DeleteSite(siteID string) error {
+	if err := t.Backend.DeleteSite(siteID); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tracking() {
+		return nil
+	}
+	t.touch(siteID)
+	t.replace(siteID, nil)
+	delete(t.stale, siteID)
+	return nil
+}
+
+func (t *Tracker) SetOwner(siteID, ownerID string) error {
+	if err := t.Backend.SetOwner(siteID, ownerID); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tracking() {
+		return nil
+	}
+	t.touch(siteID)
+	counter := t.counter(siteID)
+	t.addOwner(counter.owner, -counter.bytes)
+	counter.owner = ownerID
+	t.addOwner(counter.owner, counter.bytes)
+	return nil
+}
+
+// stored counts the version just written, replacing any earlier copy of it
+func (t *Tracker) stored(siteID, buildID string) {
+	t.mu.Lock()
+	tracking := t.tracking()
+	t.mu.Unlock()
+	if !tracking {
+		return
+	}
+	info, err := t.Backend.ArtifactInfo(siteID, buildID)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.touch(siteID)
+	if err != nil {
+		log.Printf("Failed to read size of %s/%s, recounting the site: %v", siteID, buildID, err)
+		t.stale[siteID] = true
+		return
+	}
+	t.setBuild(t.counter(siteID), buildID, info.Size, true)
+}
+
+// load scans the backend once, before the counters are first read
+func (t *Tracker) load() error {
+	if t.isLoaded() {
+		return nil // Never waits for a recompute once loaded
+	}
+
+	t.recompute.Lock()
+	defer t.recompute.Unlock()
+	if t.isLoaded() {
+		return nil
+	}
+	return t.scan()
+}
+
+func (t *Tracker) isLoaded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.loaded
+}
+
+// tracking reports whether writes must update the counters: once they are loaded, or
+// while the first scan runs. Before that, the first scan sees every write. Callers hold mu.
+func (t *Tracker) tracking() bool {
+	return t.loaded || t.rescanning != nil
+}
+
+// touch notes a write to siteID for a scan in progress. Callers hold mu.
+func (t *Tracker) touch(siteID string) {
+	if t.rescanning != nil {
+		t.rescanning[siteID] = true
+	}
+}
+
+// counter returns the counter of siteID, creating it. Callers hold mu.
+func (t *Tracker) counter(siteID string) *siteCounter {
+	counter := t.sites[siteID]
+	if counter == nil {
+		counter = &siteCounter{builds: make(map[string]int64)}
+		t.sites[siteID] = counter
+	}
+	return counter
+}
+
+// setBuild records the size of a version, or removes it. Callers hold mu.
+func (t *Tracker) setBuild(counter *siteCounter, buildID string, size int64, exists bool) {
+	delta := -counter.builds[buildID]
+	delete(counter.builds, buildID)
+	if exists {
+		counter.builds[buildID] = size
+		delta += size
+	}
+	counter.bytes += delta
+	t.addOwner(counter.owner, delta)
+}
+
+// replace swaps the counter of siteID, nil removing it. Callers hold mu.
+func (t *Tracker) replace(siteID string, counter *siteCounter) {
+	if old := t.sites[siteID]; old != nil {
+		t.addOwner(old.owner, -old.bytes)
+	}
+	if counter == nil {
+		delete(t.sites, siteID)
+		return
+	}
+	t.sites[siteID] = counter
+	t.addOwner(counter.owner, counter.bytes)
+}
+
+// addOwner adjusts an owner's total. Callers hold mu.
+func (t *Tracker) addOwner(ownerID string, delta int64) {
+	if ownerID == "" {
+		return
+	}
+	t.owners[ownerID] += delta
+	if t.owners[ownerID] == 0 {
+		delete(t.owners, ownerID)
+	}
+}
+
+func scanAll(backend storage.Backend) (map[string]*siteCounter, error) {
+	siteIDs, err := backend.ListSites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sites: %w", err)
+	}
+
+	sites := make(map[string]*siteCounter, len(siteIDs))
+	for _, siteID := range siteIDs {
+		counter, err := scanSite(backend, siteID)
+		if err != nil {
+			return nil, err
+		}
+		if counter != nil {
+			sites[siteID] = counter
+		}
+	}
+	return sites, nil
+}
+
+// scanSite counts the artifacts of a site; nil if it stores nothing and has no owner
+func scanSite(backend storage.Backend, siteID string) (*siteCounter, error) {
+	owner, err := backend.GetOwner(siteID)
+	if err != nil {
+		return nil, err
+	}
+	buildIDs, err := backend.ListArtifacts(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts of %s: %w", siteID, err)
+	}
+
+	counter := &siteCounter{owner: owner, builds: make(map[string]int64, len(buildIDs))}
+	for _, buildID := range buildIDs {
+		info, err := backend.ArtifactInfo(siteID, buildID)
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			continue // Deleted since the listing
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read artifact info of %s/%s: %w", siteID, buildID, err)
+		}
+		counter.builds[buildID] = info.Size
+		counter.bytes += info.Size
+	}
+
+	if owner == "" && len(counter.builds) == 0 {
+		return nil, nil
+	}
+	return counter, nil
+}
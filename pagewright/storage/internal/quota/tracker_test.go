@@ -0,0 +1,119 @@
+package quota
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerUsage(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, backend.StoreArtifact("site-a", "build-1", bytes.NewReader(make([]byte, 40))))
+	require.NoError(t, backend.StoreArtifact("site-a", "build-2", bytes.NewReader(make([]byte, 30))))
+	require.NoError(t, backend.StoreArtifact("site-b", "build-1", bytes.NewReader(make([]byte, 50))))
+	require.NoError(t, backend.StoreArtifact("site-c", "build-1", bytes.NewReader(make([]byte, 1000))))
+	require.NoError(t, backend.SetOwner("site-a", "user-1"))
+	require.NoError(t, backend.SetOwner("site-b", "user-1"))
+	require.NoError(t, backend.SetOwner("site-c", "user-2"))
+
+	tracker := NewTracker(backend)
+	usage, err := tracker.Usage("site-a", Limits{SiteBytes: 100, OwnerBytes: 120})
+	require.NoError(t, err)
+	assert.Equal(t, int64(70), usage.Bytes)
+	assert.Equal(t, 2, usage.Versions)
+	assert.Equal(t, "user-1", usage.OwnerID)
+	assert.Equal(t, int64(120), usage.OwnerBytes)
+	assert.True(t, usage.OverQuota)
+
+	// Without an owner only the site limit applies
+	usage, err = tracker.Usage("site-d", Limits{SiteBytes: 100, OwnerBytes: 120})
+	require.NoError(t, err)
+	assert.Empty(t, usage.OwnerID)
+	assert.Zero(t, usage.OwnerQuotaBytes)
+	assert.False(t, usage.OverQuota)
+
+	usage, err = tracker.Usage("site-c", Limits{})
+	require.NoError(t, err)
+	assert.False(t, usage.OverQuota)
+	assert.NoError(t, usage.Check(1<<30))
+}
+
+func TestTrackerFollowsWrites(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, backend.StoreArtifact("site-a", "build-1", bytes.NewReader(make([]byte, 40))))
+
+	tracker := NewTracker(backend)
+	usage := func(siteID string) *Usage {
+		t.Helper()
+		usage, err := tracker.Usage(siteID, Limits{})
+		require.NoError(t, err)
+		return usage
+	}
+	assert.Equal(t, int64(40), usage("site-a").Bytes)
+
+	require.NoError(t, tracker.SetOwner("site-a", "user-1"))
+	require.NoError(t, tracker.SetOwner("site-b", "user-1"))
+	require.NoError(t, tracker.StoreArtifact("site-a", "build-2", bytes.NewReader(make([]byte, 30))))
+	require.NoError(t, tracker.StoreArtifact("site-b", "build-1", bytes.NewReader(make([]byte, 50))))
+	assert.Equal(t, int64(70), usage("site-a").Bytes)
+	assert.Equal(t, int64(120), usage("site-a").OwnerBytes)
+
+	// Storing a version again replaces its size
+	require.NoError(t, tracker.StoreArtifact("site-a", "build-2", bytes.NewReader(make([]byte, 10))))
+	assert.Equal(t, int64(50), usage("site-a").Bytes)
+
+	session, err := tracker.CreateUploadSession("site-a", "build-3")
+	require.NoError(t, err)
+	chunk := make([]byte, 25)
+	require.NoError(t, tracker.PutUploadChunk(session.SessionID, 0, bytes.NewReader(chunk)))
+	sum := sha256.Sum256(chunk)
+	_, err = tracker.CommitUploadSession(session.SessionID, hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	assert.Equal(t, 3, usage("site-a").Versions)
+	assert.Equal(t, int64(75), usage("site-a").Bytes)
+
+	require.NoError(t, tracker.DeleteArtifact("site-a", "build-1"))
+	assert.Equal(t, int64(35), usage("site-a").Bytes)
+	assert.Equal(t, int64(85), usage("site-b").OwnerBytes)
+
+	// Moving a site moves its bytes to the new owner
+	require.NoError(t, tracker.SetOwner("site-b", "user-2"))
+	assert.Equal(t, int64(35), usage("site-a").OwnerBytes)
+	assert.Equal(t, int64(50), usage("site-b").OwnerBytes)
+
+	require.NoError(t, tracker.DeleteSite("site-b"))
+	assert.Zero(t, usage("site-b").Bytes)
+	assert.Empty(t, usage("site-b").OwnerID)
+}
+
+func TestTrackerRecompute(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, backend.StoreArtifact("site-a", "build-1", bytes.NewReader(make([]byte, 40))))
+
+	tracker := NewTracker(backend)
+	usage, err := tracker.Usage("site-a", Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), usage.Bytes)
+
+	// Reads come from the counters, so writes that bypass the tracker go unseen
+	require.NoError(t, backend.StoreArtifact("site-a", "build-2", bytes.NewReader(make([]byte, 30))))
+	usage, err = tracker.Usage("site-a", Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), usage.Bytes)
+
+	// until a recompute repairs them
+	require.NoError(t, tracker.Recompute())
+	usage, err = tracker.Usage("site-a", Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(70), usage.Bytes)
+	assert.Equal(t, 2, usage.Versions)
+}
@@ -0,0 +1,192 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Policy decides which versions of a site to keep. A version is kept if any rule keeps it:
+// it is among the KeepLast newest, it is younger than KeepWithin, or it is pinned.
+// A zero rule is off; with both rules off nothing expires.
+type Policy struct {
+	KeepLast   int
+	KeepWithin time.Duration
+}
+
+// Enabled reports whether the policy ever expires versions
+func (p Policy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepWithin > 0
+}
+
+// Expired returns the build IDs the policy would delete, oldest first. created dates each
+// stored version by its upload; a version with no known date is never expired.
+func (p Policy) Expired(created map[string]time.Time, pinned []string, now time.Time) []string {
+	if !p.Enabled() {
+		return nil
+	}
+
+	buildIDs := make([]string, 0, len(created))
+	for buildID := range created {
+		buildIDs = append(buildIDs, buildID)
+	}
+	// Newest first; ties broken by ID so the result is stable
+	sort.Slice(buildIDs, func(i, j int) bool {
+		ti, tj := created[buildIDs[i]], created[buildIDs[j]]
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return buildIDs[i] > buildIDs[j]
+	})
+
+	isPinned := make(map[string]bool, len(pinned))
+	for _, buildID := range pinned {
+		isPinned[buildID] = true
+	}
+
+	var expired []string
+	for i := len(buildIDs) - 1; i >= 0; i-- {
+		buildID := buildIDs[i]
+		switch {
+		case p.KeepLast > 0 && i < p.KeepLast:
+		case p.KeepWithin > 0 && now.Sub(created[buildID]) < p.KeepWithin:
+		case isPinned[buildID]:
+		case created[buildID].IsZero():
+		default:
+			expired = append(expired, buildID)
+		}
+	}
+
+	return expired
+}
+
+// Report summarizes one sweep
+type Report struct {
+	Sites           int `json:"sites"`
+	DeletedVersions int `json:"deleted_versions"`
+	PrunedBlobs     int `json:"pruned_blobs"`
+	ExpiredUploads  int `json:"expired_uploads"`
+}
+
+// Sweeper applies a retention policy to every site and removes blobs left unreferenced
+// and upload sessions left idle
+type Sweeper struct {
+	backend   storage.Backend
+	policy    Policy
+	blobGrace time.Duration
+	uploadTTL time.Duration
+	now       func() time.Time
+}
+
+func NewSweeper(backend storage.Backend, policy Policy, blobGrace, uploadTTL time.Duration) *Sweeper {
+	return &Sweeper{
+		backend:   backend,
+		policy:    policy,
+		blobGrace: blobGrace,
+		uploadTTL: uploadTTL,
+		now:       time.Now,
+	}
+}
+
+// Sweep deletes expired versions of all sites, then prunes unreferenced blobs and idle
+// upload sessions. Blobs are pruned even with the policy off, to reclaim space from
+// versions deleted through the API.
+func (s *Sweeper) Sweep() (*Report, error) {
+	report := &Report{}
+
+	if s.policy.Enabled() {
+		sites, err := s.backend.ListSites()
+		if err != nil {
+			return report, fmt.Errorf("failed to list sites: %w", err)
+		}
+
+		for _, siteID := range sites {
+			deleted, err := s.sweepSite(siteID)
+			report.DeletedVersions += deleted
+			if err != nil {
+				return report, err
+			}
+			report.Sites++
+		}
+	}
+
+	pruned, err := s.backend.PruneBlobs(s.blobGrace)
+	report.PrunedBlobs = pruned
+	if err != nil {
+		return report, err
+	}
+
+	expired, err := s.backend.PruneUploadSessions(s.uploadTTL)
+	report.ExpiredUploads = expired
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// sweepSite expires versions of a site. Versions are what the site stores, dated by their
+// upload: builds write no log entries, so the version index cannot be relied on here.
+func (s *Sweeper) sweepSite(siteID string) (int, error) {
+	buildIDs, err := s.backend.ListArtifacts(siteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list artifacts of %s: %w", siteID, err)
+	}
+	created := make(map[string]time.Time, len(buildIDs))
+	for _, buildID := range buildIDs {
+		info, err := s.backend.ArtifactInfo(siteID, buildID)
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			continue // Deleted since the listing
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read artifact info of %s/%s: %w", siteID, buildID, err)
+		}
+		created[buildID] = info.UploadedAt
+	}
+	pinned, err := s.backend.ListPinned(siteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pinned versions of %s: %w", siteID, err)
+	}
+
+	deleted := 0
+	for _, buildID := range s.policy.Expired(created, pinned, s.now()) {
+		err := s.backend.DeleteArtifact(siteID, buildID)
+		// Pinned since the listing, or removed concurrently
+		if errors.Is(err, storage.ErrPinned) || errors.Is(err, storage.ErrArtifactNotFound) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s/%s: %w", siteID, buildID, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Sweep()
+			if err != nil {
+				log.Printf("Retention sweep failed: %v", err)
+			}
+			if report.DeletedVersions > 0 || report.PrunedBlobs > 0 || report.ExpiredUploads > 0 {
+				log.Printf("Retention sweep: deleted %d versions across %d sites, pruned %d blobs, expired %d uploads",
+					report.DeletedVersions, report.Sites, report.PrunedBlobs, report.ExpiredUploads)
+			}
+		}
+	}
+}
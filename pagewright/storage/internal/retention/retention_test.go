@@ -0,0 +1,106 @@
+package retention
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func versionsAt(now time.Time, ages ...time.Duration) map[string]time.Time {
+	created := make(map[string]time.Time, len(ages))
+	for i, age := range ages {
+		created["build-"+string(rune('a'+i))] = now.Add(-age)
+	}
+	return created
+}
+
+func TestPolicyExpired(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	// build-a is the newest, build-e the oldest
+	versions := versionsAt(now, 1*day, 2*day, 10*day, 20*day, 30*day)
+
+	tests := []struct {
+		name   string
+		policy Policy
+		pinned []string
+		want   []string
+	}{
+		{"Disabled", Policy{}, nil, nil},
+		{"Keep last", Policy{KeepLast: 2}, nil, []string{"build-e", "build-d", "build-c"}},
+		{"Keep within", Policy{KeepWithin: 15 * day}, nil, []string{"build-e", "build-d"}},
+		{"Either rule keeps", Policy{KeepLast: 4, KeepWithin: 5 * day}, nil, []string{"build-e"}},
+		{"Pinned", Policy{KeepLast: 1}, []string{"build-d"}, []string{"build-e", "build-c", "build-b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Expired(versions, tt.pinned, now))
+		})
+	}
+}
+
+func TestPolicyKeepsUndatedVersions(t *testing.T) {
+	now := time.Now()
+	created := map[string]time.Time{"new": now.Add(-time.Hour), "old": now.Add(-48 * time.Hour), "unknown": {}}
+
+	assert.Equal(t, []string{"old"}, Policy{KeepLast: 1}.Expired(created, nil, now))
+}
+
+func TestSweep(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	// Builds store artifacts and documents only, never log entries
+	for _, buildID := range []string{"build-1", "build-2", "build-3"} {
+		archive := []byte("artifact " + buildID)
+		require.NoError(t, backend.StoreArtifact("test-site", buildID, bytes.NewReader(archive)))
+		time.Sleep(10 * time.Millisecond) // Distinct upload times
+	}
+	require.NoError(t, backend.SetPinned("test-site", "build-1", true))
+
+	sweeper := NewSweeper(backend, Policy{KeepLast: 1}, -time.Minute, time.Hour)
+	report, err := sweeper.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, &Report{Sites: 1, DeletedVersions: 1, PrunedBlobs: 1}, report)
+
+	buildIDs, err := backend.ListArtifacts("test-site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1", "build-3"}, buildIDs)
+
+	_, err = backend.FetchArtifact("test-site", "build-2")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+
+	// Keep within dates versions by upload too
+	sweeper = NewSweeper(backend, Policy{KeepWithin: time.Hour}, time.Hour, time.Hour)
+	sweeper.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	require.NoError(t, backend.SetPinned("test-site", "build-1", false))
+	report, err = sweeper.Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.DeletedVersions)
+}
+
+func TestSweepExpiresIdleUploads(t *testing.T) {
+	backend, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	session, err := backend.CreateUploadSession("test-site", "build-1")
+	require.NoError(t, err)
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 0, bytes.NewReader([]byte("chunk"))))
+
+	report, err := NewSweeper(backend, Policy{}, time.Hour, time.Hour).Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.ExpiredUploads)
+
+	report, err = NewSweeper(backend, Policy{}, time.Hour, -time.Minute).Sweep()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.ExpiredUploads)
+
+	_, err = backend.GetUploadSession(session.SessionID)
+	assert.ErrorIs(t, err, storage.ErrUploadSessionNotFound)
+}
@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Artifact formats recorded in a manifest
+const (
+	// FormatTarGz artifacts are split into one blob per file and rebuilt on download
+	FormatTarGz = "tar.gz"
+	// FormatTarZst artifacts are split the same way and rebuilt with zstd
+	FormatTarZst = "tar.zst"
+	// FormatRaw artifacts are not archives and are kept as a single blob
+	FormatRaw = "raw"
+)
+
+// IsArchive reports whether format is a tar archive, which can be served in either codec
+func IsArchive(format string) bool {
+	return format == FormatTarGz || format == FormatTarZst
+}
+
+// ArchiveContentType returns the media type of an archive format
+func ArchiveContentType(format string) string {
+	if format == FormatTarZst {
+		return "application/zstd"
+	}
+	return "application/gzip"
+}
+
+// Entry types in an artifact manifest
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+)
+
+// BlobStore holds file contents keyed by their SHA-256, each stored once
+type BlobStore interface {
+	// PutBlob stores the content read from r and returns its hex SHA-256 and size.
+	// Content that is already stored is not written again.
+	PutBlob(r io.Reader) (string, int64, error)
+
+	// OpenBlob opens the blob with the given hex SHA-256
+	OpenBlob(hash string) (io.ReadCloser, error)
+}
+
+// ArtifactManifest describes a stored version as the list of its files and their blobs.
+// It doubles as the version's integrity sidecar: the size, digest and content type of the
+// artifact as it is served, recorded at upload time.
+type ArtifactManifest struct {
+	SiteID      string          `json:"site_id"`
+	BuildID     string          `json:"build_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Format      string          `json:"format"`
+	Size        int64           `json:"size"`         // Total bytes of file content
+	ArchiveSize int64           `json:"archive_size"` // Bytes of the artifact as served at upload time
+	SHA256      string          `json:"sha256"`       // Digest of the artifact as served at upload time
+	ContentType string          `json:"content_type"`
+	Blob        string          `json:"blob,omitempty"` // Whole upload, for raw artifacts
+	Files       []*ArtifactFile `json:"files,omitempty"`
+}
+
+// ArtifactInfo is the integrity metadata of a stored artifact
+type ArtifactInfo struct {
+	Format      string    `json:"format"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256,omitempty"`       // Empty for artifacts stored before digests were recorded
+	ContentHash string    `json:"content_hash,omitempty"` // Empty for artifacts stored before manifests
+	ContentType string    `json:"content_type"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// Info returns the manifest's integrity metadata
+func (m *ArtifactManifest) Info() *ArtifactInfo {
+	return &ArtifactInfo{
+		Format:      m.Format,
+		Size:        m.ArchiveSize,
+		SHA256:      m.SHA256,
+		ContentHash: m.ContentHash(),
+		ContentType: m.ContentType,
+		UploadedAt:  m.CreatedAt,
+	}
+}
+
+// ContentHash identifies what a version holds, whatever codec it is served in. Rebuilt
+// archives depend on the compressor, so their bytes may change with a toolchain upgrade;
+// this hash does not. It is the blob hash of a raw artifact and the SHA-256 of the file
+// list of an archive, which names every file's blob.
+func (m *ArtifactManifest) ContentHash() string {
+	if m.Format == FormatRaw {
+		return m.Blob
+	}
+
+	hasher := sha256.New()
+	// Encoding a slice of structs cannot fail
+	json.NewEncoder(hasher).Encode(m.Files)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// ArtifactFile is one entry of an archive; regular files point at a blob
+type ArtifactFile struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+	Mode    int64  `json:"mode"`
+	Size    int64  `json:"size,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Link    string `json:"link,omitempty"`
+	ModTime int64  `json:"mtime,omitempty"` // Unix seconds; omitted for the epoch used by deterministic archives
+}
+
+var blobHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidBlobHash reports whether hash is a lowercase hex SHA-256
+func ValidBlobHash(hash string) bool {
+	return blobHashPattern.MatchString(hash)
+}
+
+// SplitArtifact reads an uploaded artifact and stores its content in blobs. A gzip or
+// zstd archive gets one blob per regular file; anything else is kept as a single raw blob.
+// The reader is always consumed to EOF, so errors it reports at the end of the stream
+// (such as a digest mismatch) are returned.
+func SplitArtifact(r io.Reader, blobs BlobStore) (*ArtifactManifest, error) {
+	br := bufio.NewReader(r)
+
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	format := detectFormat(head)
+	if format == FormatRaw {
+		contentType := http.DetectContentType(head)
+		hash, size, err := blobs.PutBlob(br)
+		if err != nil {
+			return nil, err
+		}
+		// Served as uploaded, so the blob hash is the artifact digest
+		return &ArtifactManifest{
+			Format:      FormatRaw,
+			Size:        size,
+			ArchiveSize: size,
+			SHA256:      hash,
+			ContentType: contentType,
+			Blob:        hash,
+		}, nil
+	}
+
+	archive, err := openArchive(br, format)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	manifest := &ArtifactManifest{Format: format, ContentType: ArchiveContentType(format)}
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		file, err := archiveEntry(header)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			continue
+		}
+
+		if file.Type == EntryFile {
+			hash, size, err := blobs.PutBlob(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to store %s: %w", file.Path, err)
+			}
+			file.SHA256 = hash
+			file.Size = size
+			manifest.Size += size
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	// Drain the tar padding and anything after the archive so end-of-stream errors surface
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, fmt.Errorf("failed to read artifact: %w", err)
+	}
+
+	// Record the digest of the rebuilt archive, which is what downloads return. For
+	// deterministic uploads it equals the digest of the upload.
+	hasher := sha256.New()
+	counter := &countingWriter{w: hasher}
+	if err := WriteArtifact(counter, manifest, blobs); err != nil {
+		return nil, fmt.Errorf("failed to digest artifact: %w", err)
+	}
+	manifest.ArchiveSize = counter.n
+	manifest.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+	return manifest, nil
+}
+
+// detectFormat tells archives from raw uploads by their magic bytes
+func detectFormat(head []byte) string {
+	switch {
+	case len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b:
+		return FormatTarGz
+	case len(head) >= 4 && head[0] == 0x28 && head[1] == 0xb5 && head[2] == 0x2f && head[3] == 0xfd:
+		return FormatTarZst
+	default:
+		return FormatRaw
+	}
+}
+
+func openArchive(r io.Reader, format string) (io.ReadCloser, error) {
+	if format == FormatTarZst {
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	return gz, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// archiveEntry converts a tar header to a manifest entry. Entry types that a site
+// archive never needs (devices, fifos, hard links) are skipped.
+func archiveEntry(header *tar.Header) (*ArtifactFile, error) {
+	name := strings.TrimSuffix(header.Name, "/")
+	clean := path.Clean(name)
+	if name == "" || clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, fmt.Errorf("invalid path in artifact: %q", header.Name)
+	}
+
+	file := &ArtifactFile{
+		Path: clean,
+		Mode: header.Mode,
+	}
+	if !header.ModTime.IsZero() && header.ModTime.Unix() != 0 {
+		file.ModTime = header.ModTime.Unix()
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		file.Type = EntryFile
+	case tar.TypeDir:
+		file.Type = EntryDir
+	case tar.TypeSymlink:
+		file.Type = EntrySymlink
+		file.Link = header.Linkname
+	default:
+		return nil, nil
+	}
+
+	return file, nil
+}
+
+// WriteArtifact rebuilds the artifact described by manifest from its blobs. Archives are
+// written the way the worker packs them (sorted PAX entries, fixed owners, gzip header
+// without name or time, single-threaded zstd), so a deterministic upload downloads byte
+// for byte.
+func WriteArtifact(w io.Writer, manifest *ArtifactManifest, blobs BlobReader) error {
+	if manifest.Format == FormatRaw {
+		return copyBlob(w, blobs, manifest.Blob)
+	}
+	return WriteArchive(w, manifest, blobs, manifest.Format)
+}
+
+// WriteArchive rebuilds an archive manifest in the given archive format, which may differ
+// from the one it was uploaded in
+func WriteArchive(w io.Writer, manifest *ArtifactManifest, blobs BlobReader, format string) error {
+	if !IsArchive(manifest.Format) {
+		return fmt.Errorf("artifact is not an archive: %s", manifest.Format)
+	}
+
+	cw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(cw)
+	for _, file := range manifest.Files {
+		header := &tar.Header{
+			Name:    file.Path,
+			Mode:    file.Mode,
+			ModTime: time.Unix(file.ModTime, 0).UTC(),
+			Format:  tar.FormatPAX,
+		}
+
+		switch file.Type {
+		case EntryDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case EntrySymlink:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = file.Link
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = file.Size
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if err := copyBlob(tw, blobs, file.SHA256); err != nil {
+				return fmt.Errorf("failed to write %s: %w", file.Path, err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("failed to close %s writer: %w", format, err)
+	}
+
+	return nil
+}
+
+func newArchiveWriter(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case FormatTarGz:
+		gzw, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		gzw.Header = gzip.Header{OS: 255}
+		return gzw, nil
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %q", format)
+	}
+}
+
+func copyBlob(w io.Writer, blobs BlobReader, hash string) error {
+	reader, err := blobs.OpenBlob(hash)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// Blobs returns the distinct blob hashes a manifest references
+func (m *ArtifactManifest) Blobs() []string {
+	if m.Format == FormatRaw {
+		return []string{m.Blob}
+	}
+
+	seen := make(map[string]bool)
+	var hashes []string
+	for _, file := range m.Files {
+		if file.SHA256 != "" && !seen[file.SHA256] {
+			seen[file.SHA256] = true
+			hashes = append(hashes, file.SHA256)
+		}
+	}
+	return hashes
+}
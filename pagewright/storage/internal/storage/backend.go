@@ -1,10 +1,38 @@
 package storage
 
 import (
+	"errors"
 	"io"
+	"regexp"
 	"time"
 )
 
+var (
+	// ErrArtifactNotFound is returned when a version has no stored artifact
+	ErrArtifactNotFound = errors.New("artifact not found")
+
+	// ErrPinned is returned when deleting a pinned version
+	ErrPinned = errors.New("version is pinned")
+
+	// ErrNoManifest is returned for versions stored as a full archive, before manifests
+	ErrNoManifest = errors.New("version has no manifest")
+
+	// ErrDocumentNotFound is returned when a version has no document of the requested kind
+	ErrDocumentNotFound = errors.New("document not found")
+
+	// ErrLineageNotFound is returned when no lineage link was recorded for a version
+	ErrLineageNotFound = errors.New("lineage not found")
+
+	// ErrAttachmentNotFound is returned when an upload has no file of the requested name
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// Documents the worker uploads next to each artifact
+const (
+	DocumentManifest = "manifest" // build manifest: prompt, summary, changed files, checks
+	DocumentLogs     = "logs"     // execution log of the agent run
+)
+
 // Backend defines the interface for storage backends
 type Backend interface {
 	// StoreArtifact stores an artifact tar.gz file
@@ -13,11 +41,99 @@ type Backend interface {
 	// FetchArtifact retrieves an artifact and returns a reader
 	FetchArtifact(siteID, buildID string) (io.ReadCloser, error)
 
+	// ArtifactInfo returns the size, digest, content type and upload time of an artifact
+	ArtifactInfo(siteID, buildID string) (*ArtifactInfo, error)
+
+	// ListArtifacts returns the build IDs with a stored artifact, sorted
+	ListArtifacts(siteID string) ([]string, error)
+
+	// CreateUploadSession starts a resumable upload of a version's artifact
+	CreateUploadSession(siteID, buildID string) (*UploadSession, error)
+
+	// GetUploadSession returns an upload session and the chunks received so far
+	GetUploadSession(sessionID string) (*UploadSession, error)
+
+	// PutUploadChunk stores one chunk of an upload, replacing an earlier copy of it
+	PutUploadChunk(sessionID string, index int, reader io.Reader) error
+
+	// CommitUploadSession joins the chunks in order, checks them against the hex SHA-256,
+	// stores the result as the session's artifact and removes the session
+	CommitUploadSession(sessionID, sha256 string) (*UploadSession, error)
+
+	// AbortUploadSession discards an upload session and its chunks
+	AbortUploadSession(sessionID string) error
+
+	// PruneUploadSessions removes sessions that received nothing for longer than maxAge.
+	// It returns the number of sessions removed.
+	PruneUploadSessions(maxAge time.Duration) (int, error)
+
+	// ReadManifest returns the file manifest of a version; ErrNoManifest for full archives
+	ReadManifest(siteID, buildID string) (*ArtifactManifest, error)
+
+	// OpenBlob opens a file blob referenced by a manifest
+	OpenBlob(hash string) (io.ReadCloser, error)
+
+	// StoreDocument stores a JSON document of a version, replacing any earlier one
+	StoreDocument(siteID, buildID, name string, data []byte) error
+
+	// FetchDocument returns a JSON document of a version
+	FetchDocument(siteID, buildID, name string) ([]byte, error)
+
 	// WriteLogEntry writes a log entry for a site
 	WriteLogEntry(siteID string, entry *LogEntry) error
 
-	// ListVersions lists all versions for a site, sorted by timestamp
-	ListVersions(siteID string) ([]*Version, error)
+	// ListVersions returns a page of a site's versions, newest first, from an index kept by
+	// WriteLogEntry. An empty query returns every version.
+	ListVersions(siteID string, query VersionQuery) (*VersionPage, error)
+
+	// RecordLineage stores which version a build started from, replacing any earlier link
+	RecordLineage(siteID string, link *LineageLink) error
+
+	// GetLineage returns the lineage link of one version
+	GetLineage(siteID, buildID string) (*LineageLink, error)
+
+	// ListLineage returns every lineage link of a site
+	ListLineage(siteID string) ([]*LineageLink, error)
+
+	// AppendSessionTurn records one agent turn in the site's conversation history
+	AppendSessionTurn(siteID string, turn *SessionTurn) error
+
+	// ListSessionTurns returns the most recent limit turns for a site, oldest first (0 for all)
+	ListSessionTurns(siteID string, limit int) ([]*SessionTurn, error)
+
+	// StoreAttachment stores a file uploaded with a build request under its upload ID
+	StoreAttachment(siteID, uploadID, name string, reader io.Reader) error
+
+	// FetchAttachment retrieves an uploaded file
+	FetchAttachment(siteID, uploadID, name string) (io.ReadCloser, error)
+
+	// ListAttachments lists the files of an upload, sorted by name
+	ListAttachments(siteID, uploadID string) ([]*Attachment, error)
+
+	// DeleteArtifact removes a version's artifact and log entries. Pinned versions are refused.
+	DeleteArtifact(siteID, buildID string) error
+
+	// DeleteSite removes everything stored for a site, pinned versions included
+	DeleteSite(siteID string) error
+
+	// SetPinned pins or unpins a version; pinned versions are never deleted
+	SetPinned(siteID, buildID string, pinned bool) error
+
+	// ListPinned returns the pinned build IDs of a site
+	ListPinned(siteID string) ([]string, error)
+
+	// SetOwner records which user owns a site, for per-owner quotas
+	SetOwner(siteID, ownerID string) error
+
+	// GetOwner returns the owner of a site, or "" if none was recorded
+	GetOwner(siteID string) (string, error)
+
+	// ListSites returns the IDs of all sites with stored data
+	ListSites() ([]string, error)
+
+	// PruneBlobs deletes blobs that no manifest references and that are older than grace,
+	// so uploads in progress keep theirs. It returns the number of blobs removed.
+	PruneBlobs(grace time.Duration) (int, error)
 }
 
 // LogEntry represents a log entry
@@ -32,9 +148,37 @@ type LogEntry struct {
 
 // Version represents a site version
 type Version struct {
-	BuildID   string            `json:"build_id"`
-	Timestamp time.Time         `json:"timestamp"`
-	Action    string            `json:"action"`
-	Status    string            `json:"status"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	BuildID       string            `json:"build_id"`
+	ParentBuildID string            `json:"parent_build_id,omitempty"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Action        string            `json:"action"`
+	Status        string            `json:"status"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// SessionTurn is one prompt and its outcome in a site's conversation with the agent
+type SessionTurn struct {
+	Timestamp    time.Time `json:"timestamp"`
+	JobID        string    `json:"job_id"`
+	BuildID      string    `json:"build_id,omitempty"`
+	BaseBuildID  string    `json:"base_build_id,omitempty"`
+	Status       string    `json:"status"`
+	Prompt       string    `json:"prompt"`
+	Summary      string    `json:"summary,omitempty"`
+	FilesChanged []string  `json:"files_changed,omitempty"`
+	Diff         string    `json:"diff,omitempty"`
+}
+
+// Attachment is a file a user uploaded with a build request, e.g. a logo or a PDF menu
+type Attachment struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+var attachmentNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidAttachmentName reports whether name is safe to use as a file name: no path separators,
+// no leading dot and at most 128 characters. Upload IDs follow the same rule.
+func ValidAttachmentName(name string) bool {
+	return attachmentNamePattern.MatchString(name)
 }
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNotArchive is returned when a version is a single raw upload rather than a site archive
+	ErrNotArchive = errors.New("version is not a site archive")
+
+	// ErrFileNotFound is returned when a version has no regular file at a path
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// maxLinkHops bounds how many symlinks are followed when resolving a path
+const maxLinkHops = 8
+
+// BlobReader reads file content by hash
+type BlobReader interface {
+	OpenBlob(hash string) (io.ReadCloser, error)
+}
+
+// VersionFiles is the file index of one stored site archive: its manifest entries by
+// path and the store holding their content
+type VersionFiles struct {
+	Manifest *ArtifactManifest
+	entries  map[string]*ArtifactFile
+	blobs    BlobReader
+}
+
+// OpenVersionFiles loads the file index of a version from its manifest. Full archives
+// stored before manifests are split in memory.
+func OpenVersionFiles(backend Backend, siteID, buildID string) (*VersionFiles, error) {
+	manifest, err := backend.ReadManifest(siteID, buildID)
+	var blobs BlobReader = backend
+	if errors.Is(err, ErrNoManifest) {
+		manifest, blobs, err = splitInMemory(backend, siteID, buildID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !IsArchive(manifest.Format) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrNotArchive, siteID, buildID)
+	}
+
+	v := &VersionFiles{
+		Manifest: manifest,
+		entries:  make(map[string]*ArtifactFile, len(manifest.Files)),
+		blobs:    blobs,
+	}
+	for _, file := range manifest.Files {
+		v.entries[file.Path] = file
+	}
+	return v, nil
+}
+
+func splitInMemory(backend Backend, siteID, buildID string) (*ArtifactManifest, BlobReader, error) {
+	reader, err := backend.FetchArtifact(siteID, buildID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	blobs := memoryBlobs{}
+	manifest, err := SplitArtifact(reader, blobs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, blobs, nil
+}
+
+// Entries returns the files, directories and symlinks of the version, sorted by path
+func (v *VersionFiles) Entries() []*ArtifactFile {
+	entries := make([]*ArtifactFile, 0, len(v.entries))
+	for _, file := range v.entries {
+		entries = append(entries, file)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Entry returns the entry at name as stored, or nil
+func (v *VersionFiles) Entry(name string) *ArtifactFile {
+	return v.entries[name]
+}
+
+// Resolve returns the regular file at name, following symlinks that stay inside the
+// archive. Directories, dangling links and paths outside the archive are ErrFileNotFound.
+func (v *VersionFiles) Resolve(name string) (*ArtifactFile, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	for hops := 0; hops <= maxLinkHops; hops++ {
+		file, ok := v.entries[name]
+		if !ok {
+			break
+		}
+		switch file.Type {
+		case EntryFile:
+			return file, nil
+		case EntrySymlink:
+			if path.IsAbs(file.Link) {
+				return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+			}
+			name = path.Join(path.Dir(name), file.Link)
+			if name == ".." || strings.HasPrefix(name, "../") {
+				return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+			}
+			continue
+		}
+		break
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFileNotFound, name)
+}
+
+// Open opens the content of a regular file; other entries read as empty
+func (v *VersionFiles) Open(file *ArtifactFile) (io.ReadCloser, error) {
+	if file == nil || file.Type != EntryFile {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return v.blobs.OpenBlob(file.SHA256)
+}
+
+// memoryBlobs holds the files of a full archive while it is read
+type memoryBlobs map[string][]byte
+
+func (m memoryBlobs) PutBlob(r io.Reader) (string, int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read blob: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	m[hash] = data
+	return hash, int64(len(data)), nil
+}
+
+func (m memoryBlobs) OpenBlob(hash string) (io.ReadCloser, error) {
+	data, ok := m[hash]
+	if !ok {
+		return nil, fmt.Errorf("blob not found: %s", hash)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
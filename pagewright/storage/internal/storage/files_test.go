@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionFilesResolve(t *testing.T) {
+	files := &VersionFiles{entries: map[string]*ArtifactFile{}}
+	for _, file := range []*ArtifactFile{
+		{Path: "content", Type: EntryDir},
+		{Path: "content/index.md", Type: EntryFile, SHA256: "aaa"},
+		{Path: "content/home.md", Type: EntrySymlink, Link: "index.md"},
+		{Path: "latest", Type: EntrySymlink, Link: "content/home.md"},
+		{Path: "escape", Type: EntrySymlink, Link: "../outside.md"},
+		{Path: "loop", Type: EntrySymlink, Link: "loop"},
+	} {
+		files.entries[file.Path] = file
+	}
+
+	file, err := files.Resolve("/content/index.md")
+	require.NoError(t, err)
+	assert.Equal(t, "aaa", file.SHA256)
+
+	file, err = files.Resolve("latest")
+	require.NoError(t, err)
+	assert.Equal(t, "content/index.md", file.Path)
+
+	for _, name := range []string{"content", "missing.md", "escape", "loop", "../content/index.md"} {
+		_, err := files.Resolve(name)
+		assert.ErrorIs(t, err, ErrFileNotFound, name)
+	}
+}
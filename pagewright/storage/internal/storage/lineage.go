@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// LineageLink records which version a build started from. Root versions have no parent.
+// Links outlive the versions they describe, so branches stay connected after a delete.
+type LineageLink struct {
+	BuildID       string    `json:"build_id"`
+	ParentBuildID string    `json:"parent_build_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LineageNode is a version in an ancestry or children listing
+type LineageNode struct {
+	LineageLink
+	Deleted bool `json:"deleted,omitempty"` // the version's artifact no longer exists
+}
+
+// Lineage is the version graph of one site
+type Lineage struct {
+	links    map[string]*LineageLink
+	children map[string][]*LineageLink
+}
+
+// NewLineage indexes a site's links by build and by parent
+func NewLineage(links []*LineageLink) *Lineage {
+	l := &Lineage{
+		links:    make(map[string]*LineageLink, len(links)),
+		children: make(map[string][]*LineageLink),
+	}
+	for _, link := range links {
+		l.links[link.BuildID] = link
+	}
+	for _, link := range l.links {
+		if link.ParentBuildID != "" {
+			l.children[link.ParentBuildID] = append(l.children[link.ParentBuildID], link)
+		}
+	}
+	for _, children := range l.children {
+		sort.Slice(children, func(i, j int) bool {
+			if !children[i].CreatedAt.Equal(children[j].CreatedAt) {
+				return children[i].CreatedAt.Before(children[j].CreatedAt)
+			}
+			return children[i].BuildID < children[j].BuildID
+		})
+	}
+	return l
+}
+
+// Has reports whether the graph knows buildID, as a version or as a parent
+func (l *Lineage) Has(buildID string) bool {
+	_, ok := l.links[buildID]
+	return ok || len(l.children[buildID]) > 0
+}
+
+// Parent returns the parent of buildID, or "" for roots and unknown builds
+func (l *Lineage) Parent(buildID string) string {
+	if link, ok := l.links[buildID]; ok {
+		return link.ParentBuildID
+	}
+	return ""
+}
+
+// Ancestry returns buildID followed by its parent, grandparent and so on up to the root.
+// A parent with no link of its own (recorded before lineage was) ends the chain.
+func (l *Lineage) Ancestry(buildID string) []*LineageLink {
+	var chain []*LineageLink
+	seen := make(map[string]bool)
+	for buildID != "" && !seen[buildID] {
+		seen[buildID] = true
+		link, ok := l.links[buildID]
+		if !ok {
+			link = &LineageLink{BuildID: buildID}
+		}
+		chain = append(chain, link)
+		buildID = link.ParentBuildID
+	}
+	return chain
+}
+
+// Children returns the versions built directly from buildID, oldest first. More than one
+// child means the history branched, e.g. after a rollback.
+func (l *Lineage) Children(buildID string) []*LineageLink {
+	return l.children[buildID]
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineageAncestryStopsAtUnknownParentAndCycles(t *testing.T) {
+	base := time.Now().UTC()
+	lineage := NewLineage([]*LineageLink{
+		{BuildID: "v2", ParentBuildID: "v1", CreatedAt: base},
+		{BuildID: "v3", ParentBuildID: "v2", CreatedAt: base.Add(time.Minute)},
+		{BuildID: "a", ParentBuildID: "b"},
+		{BuildID: "b", ParentBuildID: "a"},
+	})
+
+	// v1 was built before lineage was recorded: it ends the chain without a parent
+	chain := lineage.Ancestry("v3")
+	assert.Len(t, chain, 3)
+	assert.Equal(t, "v1", chain[2].BuildID)
+	assert.Empty(t, chain[2].ParentBuildID)
+
+	assert.Len(t, lineage.Ancestry("a"), 2)
+
+	assert.True(t, lineage.Has("v1"))
+	assert.False(t, lineage.Has("v9"))
+	assert.Equal(t, "v2", lineage.Parent("v3"))
+	assert.Empty(t, lineage.Parent("v1"))
+	assert.Len(t, lineage.Children("v1"), 1)
+	assert.Empty(t, lineage.Children("v3"))
+}
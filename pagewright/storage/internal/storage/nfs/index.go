@@ -0,0 +1,326 @@
+package nfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// The version index is an append-only file of one JSON version per line, in the order the
+// log entries were written. Listings read it backwards, so the newest page costs the same
+// however long the history is. Log entry files stay the source of truth: a missing index
+// is rebuilt from them, and deleting a version rewrites the index without it.
+
+// indexChunkSize is how much of the index is read at a time when walking it backwards
+const indexChunkSize = 64 << 10
+
+func (n *NFSBackend) versionIndexPath(siteID string) string {
+	return filepath.Join(n.basePath, "sites", siteID, "versions.idx")
+}
+
+// ensureVersionIndex builds the index of a site from its log entries if there is none.
+// Callers hold indexMu.
+func (n *NFSBackend) ensureVersionIndex(siteID string) error {
+	indexPath := n.versionIndexPath(siteID)
+	if _, err := os.Stat(indexPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat version index: %w", err)
+	}
+
+	versions, err := n.readLogVersions(siteID)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Timestamp.Before(versions[j].Timestamp)
+	})
+
+	var buf bytes.Buffer
+	for _, version := range versions {
+		line, err := json.Marshal(version)
+		if err != nil {
+			return fmt.Errorf("failed to marshal version: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return fmt.Errorf("failed to create site directory: %w", err)
+	}
+	return atomicWriteBytes(indexPath, buf.Bytes())
+}
+
+// readLogVersions reads every log entry file of a site
+func (n *NFSBackend) readLogVersions(siteID string) ([]*storage.Version, error) {
+	logDir := filepath.Join(n.basePath, "sites", siteID, "logs")
+
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*storage.Version{}, nil
+		}
+		return nil, fmt.Errorf("failed to read log directory: %w", err)
+	}
+
+	versions := make([]*storage.Version, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(logDir, entry.Name()))
+		if err != nil {
+			continue // Skip unreadable files
+		}
+
+		var logEntry storage.LogEntry
+		if err := json.Unmarshal(data, &logEntry); err != nil {
+			continue // Skip malformed files
+		}
+		versions = append(versions, storage.VersionFromLog(&logEntry))
+	}
+
+	return versions, nil
+}
+
+// appendVersionIndex adds a version to the end of the index. Callers hold indexMu.
+func (n *NFSBackend) appendVersionIndex(siteID string, version *storage.Version) error {
+	line, err := json.Marshal(version)
+	if err != nil {
+		return fmt.Errorf("failed to marshal version: %w", err)
+	}
+
+	file, err := os.OpenFile(n.versionIndexPath(siteID), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open version index: %w", err)
+	}
+	defer file.Close()
+
+	// A crash mid-append can leave a partial last line. Ending it first keeps this line
+	// whole; readers skip the partial one as malformed.
+	terminated, err := endsWithNewline(file)
+	if err != nil {
+		return err
+	}
+	if !terminated {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	// One write per line, so a reader never sees half of one
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to version index: %w", err)
+	}
+	return file.Sync()
+}
+
+// endsWithNewline reports whether file is empty or its last byte is a newline
+func endsWithNewline(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat version index: %w", err)
+	}
+	if info.Size() == 0 {
+		return true, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, fmt.Errorf("failed to read version index: %w", err)
+	}
+	return last[0] == '\n', nil
+}
+
+// removeFromVersionIndex rewrites the index without the versions of buildID.
+// Callers hold indexMu.
+func (n *NFSBackend) removeFromVersionIndex(siteID, buildID string) error {
+	indexPath := n.versionIndexPath(siteID)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read version index: %w", err)
+	}
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		var version storage.Version
+		if json.Unmarshal(line, &version) == nil && version.BuildID == buildID {
+			continue
+		}
+		buf.Write(line)
+	}
+
+	if buf.Len() == len(data) {
+		return nil
+	}
+	return atomicWriteBytes(indexPath, buf.Bytes())
+}
+
+// indexCursor is a position in the version index: the offset of the last line returned,
+// and that version's time and build ID in case the index was rewritten since
+type indexCursor struct {
+	offset    int64
+	timestamp time.Time
+	buildID   string
+}
+
+func (c indexCursor) encode() string {
+	return storage.EncodeCursor(fmt.Sprintf("%d:%d:%s", c.offset, c.timestamp.UnixNano(), c.buildID))
+}
+
+func decodeIndexCursor(cursor string) (indexCursor, error) {
+	position, err := storage.DecodeCursor(cursor)
+	if err != nil {
+		return indexCursor{}, err
+	}
+	parts := strings.SplitN(position, ":", 3)
+	if len(parts) != 3 {
+		return indexCursor{}, fmt.Errorf("%w: %q", storage.ErrInvalidCursor, cursor)
+	}
+	offset, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || offset < 0 {
+		return indexCursor{}, fmt.Errorf("%w: %q", storage.ErrInvalidCursor, cursor)
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return indexCursor{}, fmt.Errorf("%w: %q", storage.ErrInvalidCursor, cursor)
+	}
+	return indexCursor{offset: offset, timestamp: time.Unix(0, nanos).UTC(), buildID: parts[2]}, nil
+}
+
+// queryVersionIndex walks the index from the newest line back, stopping once the page is
+// full or the lines are older than query.Since
+func queryVersionIndex(file *os.File, size int64, query storage.VersionQuery) (*storage.VersionPage, error) {
+	page := &storage.VersionPage{Versions: []*storage.Version{}}
+
+	end := size
+	resumed := true
+	var from indexCursor
+	if query.Cursor != "" {
+		var err error
+		from, err = decodeIndexCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if version, ok := versionAt(file, size, from.offset); ok && version.BuildID == from.buildID {
+			end = from.offset
+		} else {
+			// The index was rewritten since: skip ahead to the cursor's version, or to
+			// the first one older than it if that version is gone
+			resumed = false
+		}
+	}
+
+	var last indexCursor
+	err := walkBackwards(file, end, func(offset int64, line []byte) bool {
+		var version storage.Version
+		if json.Unmarshal(line, &version) != nil {
+			return true // Skip a malformed line
+		}
+		if !resumed {
+			if version.BuildID == from.buildID && version.Timestamp.Equal(from.timestamp) {
+				resumed = true
+				return true
+			}
+			if !version.Timestamp.Before(from.timestamp) {
+				return true
+			}
+			resumed = true
+		}
+		if !query.Since.IsZero() && version.Timestamp.Before(query.Since) {
+			return false
+		}
+		if !query.Matches(&version) {
+			return true
+		}
+		if query.Limit > 0 && len(page.Versions) == query.Limit {
+			page.NextCursor = last.encode()
+			return false
+		}
+		page.Versions = append(page.Versions, &version)
+		last = indexCursor{offset: offset, timestamp: version.Timestamp, buildID: version.BuildID}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// versionAt reads the line starting at offset
+func versionAt(file *os.File, size, offset int64) (*storage.Version, bool) {
+	if offset >= size {
+		return nil, false
+	}
+	if offset > 0 {
+		// A line start follows a newline
+		prev := make([]byte, 1)
+		if _, err := file.ReadAt(prev, offset-1); err != nil || prev[0] != '\n' {
+			return nil, false
+		}
+	}
+
+	line, err := bufio.NewReader(io.NewSectionReader(file, offset, size-offset)).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false
+	}
+	var version storage.Version
+	if json.Unmarshal(line, &version) != nil {
+		return nil, false
+	}
+	return &version, true
+}
+
+// walkBackwards calls fn with each complete line before end and its offset, last line
+// first, until fn returns false
+func walkBackwards(file *os.File, end int64, fn func(offset int64, line []byte) bool) error {
+	var tail []byte // start of the line that continues into the chunk read before
+	pos := end
+	for pos > 0 {
+		size := int64(indexChunkSize)
+		if size > pos {
+			size = pos
+		}
+		pos -= size
+
+		chunk := make([]byte, size, size+int64(len(tail)))
+		if _, err := file.ReadAt(chunk, pos); err != nil {
+			return fmt.Errorf("failed to read version index: %w", err)
+		}
+		chunk = append(chunk, tail...)
+
+		// Every line in the chunk except the first, which may start in an earlier chunk
+		for {
+			i := bytes.LastIndexByte(chunk[:len(chunk)-1], '\n')
+			if i < 0 {
+				break
+			}
+			line := chunk[i+1:]
+			if len(bytes.TrimSpace(line)) > 0 && !fn(pos+int64(i)+1, line) {
+				return nil
+			}
+			chunk = chunk[:i+1]
+		}
+		tail = chunk
+	}
+
+	if len(bytes.TrimSpace(tail)) > 0 {
+		fn(0, tail)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package nfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeVersions(t *testing.T, backend *NFSBackend, count int, note string) time.Time {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		status := "success"
+		if i%3 == 2 {
+			status = "failed"
+		}
+		require.NoError(t, backend.WriteLogEntry("test-site", &storage.LogEntry{
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			BuildID:   fmt.Sprintf("build-%03d", i),
+			SiteID:    "test-site",
+			Action:    "build",
+			Status:    status,
+			Metadata:  map[string]string{"note": note},
+		}))
+	}
+	return base
+}
+
+func buildIDs(versions []*storage.Version) []string {
+	ids := make([]string, len(versions))
+	for i, version := range versions {
+		ids[i] = version.BuildID
+	}
+	return ids
+}
+
+func TestListVersionsPages(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+	// Large enough entries that pages cross index chunks
+	writeVersions(t, backend, 120, strings.Repeat("x", 2000))
+
+	var all []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 20)
+		page, err := backend.ListVersions("test-site", storage.VersionQuery{Limit: 25, Cursor: cursor})
+		require.NoError(t, err)
+		all = append(all, buildIDs(page.Versions)...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	require.Len(t, all, 120)
+	assert.Equal(t, "build-119", all[0])
+	assert.Equal(t, "build-000", all[119])
+}
+
+func TestListVersionsFilters(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+	base := writeVersions(t, backend, 9, "")
+
+	page, err := backend.ListVersions("test-site", storage.VersionQuery{Status: "failed"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-008", "build-005", "build-002"}, buildIDs(page.Versions))
+
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{
+		Since: base.Add(3 * time.Minute),
+		Until: base.Add(6 * time.Minute),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-005", "build-004", "build-003"}, buildIDs(page.Versions))
+
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{Action: "deploy"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Versions)
+
+	_, err = backend.ListVersions("test-site", storage.VersionQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestListVersionsCursorAfterDelete(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+	writeVersions(t, backend, 6, "")
+
+	page, err := backend.ListVersions("test-site", storage.VersionQuery{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-005", "build-004"}, buildIDs(page.Versions))
+
+	// Rewriting the index moves every line; the cursor still resumes after build-004
+	require.NoError(t, backend.DeleteArtifact("test-site", "build-000"))
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-003", "build-002"}, buildIDs(page.Versions))
+
+	// Deleting the cursor's own version resumes at the next older one
+	require.NoError(t, backend.DeleteArtifact("test-site", "build-002"))
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-001"}, buildIDs(page.Versions))
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestListVersionsRebuildsIndex(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+	writeVersions(t, backend, 3, "")
+
+	// Sites written before the index have log entries only
+	indexPath := filepath.Join(tmpDir, "sites", "test-site", "versions.idx")
+	require.NoError(t, os.Remove(indexPath))
+
+	page, err := backend.ListVersions("test-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-002", "build-001", "build-000"}, buildIDs(page.Versions))
+	assert.FileExists(t, indexPath)
+
+	require.NoError(t, os.Remove(indexPath))
+	require.NoError(t, backend.WriteLogEntry("test-site", &storage.LogEntry{
+		Timestamp: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		BuildID:   "build-new",
+		SiteID:    "test-site",
+		Action:    "build",
+		Status:    "success",
+	}))
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-new", "build-002", "build-001", "build-000"}, buildIDs(page.Versions))
+}
+
+func TestWriteLogEntryDropsIndexOnFailedAppend(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+	writeVersions(t, backend, 2, "")
+
+	// An index that cannot be appended to: the entry is still stored and listed
+	indexPath := filepath.Join(tmpDir, "sites", "test-site", "versions.idx")
+	require.NoError(t, os.Remove(indexPath))
+	require.NoError(t, os.Mkdir(indexPath, 0755))
+
+	require.NoError(t, backend.WriteLogEntry("test-site", &storage.LogEntry{
+		Timestamp: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		BuildID:   "build-new",
+		SiteID:    "test-site",
+		Action:    "build",
+		Status:    "success",
+	}))
+	assert.NoDirExists(t, indexPath)
+
+	page, err := backend.ListVersions("test-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-new", "build-001", "build-000"}, buildIDs(page.Versions))
+}
+
+func TestListVersionsSkipsTruncatedLine(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+	writeVersions(t, backend, 2, "")
+
+	// A crash mid-append leaves half a line at the end of the index
+	indexPath := filepath.Join(tmpDir, "sites", "test-site", "versions.idx")
+	file, err := os.OpenFile(indexPath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"build_id":"build-lost","timest`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	page, err := backend.ListVersions("test-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-001", "build-000"}, buildIDs(page.Versions))
+
+	// The next append starts on a line of its own
+	require.NoError(t, backend.WriteLogEntry("test-site", &storage.LogEntry{
+		Timestamp: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		BuildID:   "build-new",
+		SiteID:    "test-site",
+		Action:    "build",
+		Status:    "success",
+	}))
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-new", "build-001", "build-000"}, buildIDs(page.Versions))
+
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{Limit: 1})
+	require.NoError(t, err)
+	page, err = backend.ListVersions("test-site", storage.VersionQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-001", "build-000"}, buildIDs(page.Versions))
+}
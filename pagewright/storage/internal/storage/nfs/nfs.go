@@ -1,19 +1,25 @@
 package nfs
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
 )
 
 type NFSBackend struct {
 	basePath string
+	indexMu  sync.Mutex // serializes version index appends and rewrites
 }
 
 func NewNFSBackend(basePath string) (*NFSBackend, error) {
@@ -27,31 +33,471 @@ func NewNFSBackend(basePath string) (*NFSBackend, error) {
 	}, nil
 }
 
+// StoreArtifact splits the artifact into content-addressed blobs and records the version
+// as a manifest, so files shared between versions are stored once
 func (n *NFSBackend) StoreArtifact(siteID, buildID string, reader io.Reader) error {
 	artifactDir := filepath.Join(n.basePath, "sites", siteID, "artifacts")
 	if err := os.MkdirAll(artifactDir, 0755); err != nil {
 		return fmt.Errorf("failed to create artifact directory: %w", err)
 	}
 
-	artifactPath := filepath.Join(artifactDir, fmt.Sprintf("%s.tar.gz", buildID))
-	return atomicWrite(artifactPath, reader)
+	manifest, err := storage.SplitArtifact(reader, n)
+	if err != nil {
+		return fmt.Errorf("failed to split artifact: %w", err)
+	}
+	manifest.SiteID = siteID
+	manifest.BuildID = buildID
+	manifest.CreatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal artifact manifest: %w", err)
+	}
+	if err := atomicWriteBytes(n.manifestPath(siteID, buildID), data); err != nil {
+		return err
+	}
+
+	// A re-upload supersedes a full archive stored before blobs were introduced
+	if err := os.Remove(n.legacyArtifactPath(siteID, buildID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove legacy artifact: %w", err)
+	}
+
+	return nil
 }
 
+// FetchArtifact rebuilds the tar.gz from the version's manifest while it is read.
+// Versions stored as a full archive are served as they are.
 func (n *NFSBackend) FetchArtifact(siteID, buildID string) (io.ReadCloser, error) {
-	artifactPath := filepath.Join(n.basePath, "sites", siteID, "artifacts", fmt.Sprintf("%s.tar.gz", buildID))
+	manifest, err := n.readManifest(siteID, buildID)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		file, err := os.Open(n.legacyArtifactPath(siteID, buildID))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+			}
+			return nil, fmt.Errorf("failed to open artifact: %w", err)
+		}
+		return file, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(storage.WriteArtifact(pw, manifest, n))
+	}()
+
+	return pr, nil
+}
 
-	file, err := os.Open(artifactPath)
+func (n *NFSBackend) ArtifactInfo(siteID, buildID string) (*storage.ArtifactInfo, error) {
+	manifest, err := n.readManifest(siteID, buildID)
+	if err == nil {
+		return manifest.Info(), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// Full archives stored before manifests have no recorded digest
+	info, err := os.Stat(n.legacyArtifactPath(siteID, buildID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+		}
+		return nil, fmt.Errorf("failed to stat artifact: %w", err)
+	}
+	return &storage.ArtifactInfo{
+		Format:      storage.FormatTarGz,
+		Size:        info.Size(),
+		ContentType: "application/gzip",
+		UploadedAt:  info.ModTime().UTC(),
+	}, nil
+}
+
+func (n *NFSBackend) ListArtifacts(siteID string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(n.basePath, "sites", siteID, "artifacts"))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("artifact not found: %s/%s", siteID, buildID)
+			return []string{}, nil
 		}
-		return nil, fmt.Errorf("failed to open artifact: %w", err)
+		return nil, fmt.Errorf("failed to read artifact directory: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	buildIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		buildID := strings.TrimSuffix(strings.TrimSuffix(name, ".json"), ".tar.gz")
+		if entry.IsDir() || buildID == name || seen[buildID] {
+			continue
+		}
+		seen[buildID] = true
+		buildIDs = append(buildIDs, buildID)
+	}
+	sort.Strings(buildIDs)
+
+	return buildIDs, nil
+}
+
+// PutBlob writes r to a temporary file while hashing it and moves it into place
+// under blobs/{hash[:2]}/{hash}, unless a blob with that hash already exists. An existing
+// blob gets a fresh mtime, so PruneBlobs spares it until the upload writes its manifest.
+func (n *NFSBackend) PutBlob(r io.Reader) (string, int64, error) {
+	blobDir := filepath.Join(n.basePath, "blobs")
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(blobDir, "blob-*.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hasher), r)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	tmpFile.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	blobPath := n.blobPath(hash)
+	now := time.Now()
+	if err := os.Chtimes(blobPath, now, now); err == nil {
+		os.Remove(tmpPath)
+		return hash, size, nil
+	} else if !os.IsNotExist(err) {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to touch blob: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(tmpPath, blobPath); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to rename blob: %w", err)
+	}
+
+	return hash, size, nil
+}
+
+func (n *NFSBackend) OpenBlob(hash string) (io.ReadCloser, error) {
+	if !storage.ValidBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash: %q", hash)
+	}
+
+	file, err := os.Open(n.blobPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("blob not found: %s", hash)
+		}
+		return nil, fmt.Errorf("failed to open blob: %w", err)
 	}
 
 	return file, nil
 }
 
+func (n *NFSBackend) DeleteArtifact(siteID, buildID string) error {
+	pinned, err := n.isPinned(siteID, buildID)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		return fmt.Errorf("%w: %s/%s", storage.ErrPinned, siteID, buildID)
+	}
+
+	found := false
+	for _, path := range []string{n.manifestPath(siteID, buildID), n.legacyArtifactPath(siteID, buildID)} {
+		if err := os.Remove(path); err == nil {
+			found = true
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete artifact: %w", err)
+		}
+	}
+
+	documentDir := n.documentDir(siteID, buildID)
+	if _, err := os.Stat(documentDir); err == nil {
+		found = true
+	}
+	if err := os.RemoveAll(documentDir); err != nil {
+		return fmt.Errorf("failed to delete version documents: %w", err)
+	}
+
+	// Drop the version's log entries too, so it leaves the version list
+	logDir := filepath.Join(n.basePath, "sites", siteID, "logs")
+	entries, err := os.ReadDir(logDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read log directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), "-"+buildID+".json") {
+			continue
+		}
+
+		logPath := filepath.Join(logDir, entry.Name())
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			continue // Removed concurrently
+		}
+		var logEntry storage.LogEntry
+		if json.Unmarshal(data, &logEntry) != nil || logEntry.BuildID != buildID {
+			continue
+		}
+		if err := os.Remove(logPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete log entry: %w", err)
+		}
+		found = true
+	}
+
+	n.indexMu.Lock()
+	err = n.removeFromVersionIndex(siteID, buildID)
+	n.indexMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+	}
+	return nil
+}
+
+func (n *NFSBackend) DeleteSite(siteID string) error {
+	if err := os.RemoveAll(filepath.Join(n.basePath, "sites", siteID)); err != nil {
+		return fmt.Errorf("failed to delete site: %w", err)
+	}
+	return nil
+}
+
+func (n *NFSBackend) SetPinned(siteID, buildID string, pinned bool) error {
+	pinPath := filepath.Join(n.basePath, "sites", siteID, "pins", buildID)
+
+	if !pinned {
+		if err := os.Remove(pinPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to unpin version: %w", err)
+		}
+		return nil
+	}
+
+	if !n.artifactExists(siteID, buildID) {
+		return fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+	}
+	if err := os.MkdirAll(filepath.Dir(pinPath), 0755); err != nil {
+		return fmt.Errorf("failed to create pin directory: %w", err)
+	}
+	return atomicWriteBytes(pinPath, []byte(time.Now().UTC().Format(time.RFC3339)))
+}
+
+func (n *NFSBackend) ListPinned(siteID string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(n.basePath, "sites", siteID, "pins"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read pin directory: %w", err)
+	}
+
+	pinned := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".tmp") {
+			pinned = append(pinned, entry.Name())
+		}
+	}
+	return pinned, nil
+}
+
+func (n *NFSBackend) SetOwner(siteID, ownerID string) error {
+	ownerPath := filepath.Join(n.basePath, "sites", siteID, "owner")
+	if err := os.MkdirAll(filepath.Dir(ownerPath), 0755); err != nil {
+		return fmt.Errorf("failed to create site directory: %w", err)
+	}
+	return atomicWriteBytes(ownerPath, []byte(ownerID))
+}
+
+func (n *NFSBackend) GetOwner(siteID string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(n.basePath, "sites", siteID, "owner"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read site owner: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (n *NFSBackend) ListSites() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(n.basePath, "sites"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read sites directory: %w", err)
+	}
+
+	sites := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			sites = append(sites, entry.Name())
+		}
+	}
+	return sites, nil
+}
+
+func (n *NFSBackend) PruneBlobs(grace time.Duration) (int, error) {
+	// Collect every referenced blob before looking at the blob directory
+	referenced := make(map[string]bool)
+	manifests, err := filepath.Glob(filepath.Join(n.basePath, "sites", "*", "artifacts", "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list manifests: %w", err)
+	}
+	for _, path := range manifests {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Deleted meanwhile
+			}
+			return 0, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		var manifest storage.ArtifactManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			// Keep everything rather than risk deleting blobs of an unreadable version
+			return 0, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+		}
+		for _, hash := range manifest.Blobs() {
+			referenced[hash] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-grace)
+	removed := 0
+	err = filepath.Walk(filepath.Join(n.basePath, "blobs"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || referenced[info.Name()] || info.ModTime().After(cutoff) {
+			return nil
+		}
+		// Leftover temp files of failed uploads go as well
+		if !storage.ValidBlobHash(info.Name()) && !strings.HasSuffix(info.Name(), ".tmp") {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune blobs: %w", err)
+	}
+
+	return removed, nil
+}
+
+func (n *NFSBackend) isPinned(siteID, buildID string) (bool, error) {
+	_, err := os.Stat(filepath.Join(n.basePath, "sites", siteID, "pins", buildID))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check pin: %w", err)
+}
+
+func (n *NFSBackend) artifactExists(siteID, buildID string) bool {
+	for _, path := range []string{n.manifestPath(siteID, buildID), n.legacyArtifactPath(siteID, buildID)} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *NFSBackend) ReadManifest(siteID, buildID string) (*storage.ArtifactManifest, error) {
+	manifest, err := n.readManifest(siteID, buildID)
+	if err == nil || !os.IsNotExist(err) {
+		return manifest, err
+	}
+
+	if _, err := os.Stat(n.legacyArtifactPath(siteID, buildID)); err == nil {
+		return nil, fmt.Errorf("%w: %s/%s", storage.ErrNoManifest, siteID, buildID)
+	}
+	return nil, fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+}
+
+func (n *NFSBackend) readManifest(siteID, buildID string) (*storage.ArtifactManifest, error) {
+	data, err := os.ReadFile(n.manifestPath(siteID, buildID))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest storage.ArtifactManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+func (n *NFSBackend) manifestPath(siteID, buildID string) string {
+	return filepath.Join(n.basePath, "sites", siteID, "artifacts", fmt.Sprintf("%s.json", buildID))
+}
+
+func (n *NFSBackend) legacyArtifactPath(siteID, buildID string) string {
+	return filepath.Join(n.basePath, "sites", siteID, "artifacts", fmt.Sprintf("%s.tar.gz", buildID))
+}
+
+func (n *NFSBackend) blobPath(hash string) string {
+	return filepath.Join(n.basePath, "blobs", hash[:2], hash)
+}
+
+func (n *NFSBackend) StoreDocument(siteID, buildID, name string, data []byte) error {
+	documentDir := n.documentDir(siteID, buildID)
+	if err := os.MkdirAll(documentDir, 0755); err != nil {
+		return fmt.Errorf("failed to create document directory: %w", err)
+	}
+
+	return atomicWriteBytes(filepath.Join(documentDir, name+".json"), data)
+}
+
+func (n *NFSBackend) FetchDocument(siteID, buildID, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(n.documentDir(siteID, buildID), name+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s/%s/%s", storage.ErrDocumentNotFound, siteID, buildID, name)
+		}
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return data, nil
+}
+
+func (n *NFSBackend) documentDir(siteID, buildID string) string {
+	return filepath.Join(n.basePath, "sites", siteID, "versions", buildID)
+}
+
 func (n *NFSBackend) WriteLogEntry(siteID string, entry *storage.LogEntry) error {
+	n.indexMu.Lock()
+	defer n.indexMu.Unlock()
+
+	// Index the entries written so far before adding one, so none is left out
+	if err := n.ensureVersionIndex(siteID); err != nil {
+		return err
+	}
+
 	logDir := filepath.Join(n.basePath, "sites", siteID, "logs")
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %w", err)
@@ -68,55 +514,223 @@ func (n *NFSBackend) WriteLogEntry(siteID string, entry *storage.LogEntry) error
 	}
 
 	// Use atomic write for log entry
-	return atomicWriteBytes(logPath, data)
+	if err := atomicWriteBytes(logPath, data); err != nil {
+		return err
+	}
+
+	if err := n.appendVersionIndex(siteID, storage.VersionFromLog(entry)); err != nil {
+		// The entry is stored; without the index the next listing rebuilds it, entry included
+		if rmErr := os.Remove(n.versionIndexPath(siteID)); rmErr != nil && !os.IsNotExist(rmErr) {
+			return fmt.Errorf("%w; failed to remove version index: %v", err, rmErr)
+		}
+		log.Printf("Dropped version index of %s to rebuild it: %v", siteID, err)
+	}
+	return nil
 }
 
-func (n *NFSBackend) ListVersions(siteID string) ([]*storage.Version, error) {
-	logDir := filepath.Join(n.basePath, "sites", siteID, "logs")
+// ListVersions returns a page of the site's versions, newest first, read from the
+// version index rather than from every log entry
+func (n *NFSBackend) ListVersions(siteID string, query storage.VersionQuery) (*storage.VersionPage, error) {
+	if _, err := os.Stat(filepath.Join(n.basePath, "sites", siteID)); os.IsNotExist(err) {
+		return &storage.VersionPage{Versions: []*storage.Version{}}, nil
+	}
 
-	// Check if directory exists
-	if _, err := os.Stat(logDir); os.IsNotExist(err) {
-		return []*storage.Version{}, nil
+	n.indexMu.Lock()
+	err := n.ensureVersionIndex(siteID)
+	n.indexMu.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
-	entries, err := os.ReadDir(logDir)
+	file, err := os.Open(n.versionIndexPath(siteID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &storage.VersionPage{Versions: []*storage.Version{}}, nil // Site deleted meanwhile
+		}
+		return nil, fmt.Errorf("failed to open version index: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat version index: %w", err)
+	}
+
+	return queryVersionIndex(file, info.Size(), query)
+}
+
+func (n *NFSBackend) RecordLineage(siteID string, link *storage.LineageLink) error {
+	lineageDir := filepath.Join(n.basePath, "sites", siteID, "lineage")
+	if err := os.MkdirAll(lineageDir, 0755); err != nil {
+		return fmt.Errorf("failed to create lineage directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(link, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read log directory: %w", err)
+		return fmt.Errorf("failed to marshal lineage link: %w", err)
 	}
 
-	versions := make([]*storage.Version, 0, len(entries))
+	return atomicWriteBytes(filepath.Join(lineageDir, link.BuildID+".json"), data)
+}
 
+func (n *NFSBackend) GetLineage(siteID, buildID string) (*storage.LineageLink, error) {
+	data, err := os.ReadFile(filepath.Join(n.basePath, "sites", siteID, "lineage", buildID+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s/%s", storage.ErrLineageNotFound, siteID, buildID)
+		}
+		return nil, fmt.Errorf("failed to read lineage link: %w", err)
+	}
+
+	var link storage.LineageLink
+	if err := json.Unmarshal(data, &link); err != nil {
+		return nil, fmt.Errorf("failed to parse lineage link: %w", err)
+	}
+	return &link, nil
+}
+
+func (n *NFSBackend) ListLineage(siteID string) ([]*storage.LineageLink, error) {
+	lineageDir := filepath.Join(n.basePath, "sites", siteID, "lineage")
+
+	entries, err := os.ReadDir(lineageDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*storage.LineageLink{}, nil
+		}
+		return nil, fmt.Errorf("failed to read lineage directory: %w", err)
+	}
+
+	links := make([]*storage.LineageLink, 0, len(entries))
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
 			continue
 		}
 
-		logPath := filepath.Join(logDir, entry.Name())
-		data, err := os.ReadFile(logPath)
+		data, err := os.ReadFile(filepath.Join(lineageDir, entry.Name()))
+		if err != nil {
+			continue // Removed while listing
+		}
+		var link storage.LineageLink
+		if err := json.Unmarshal(data, &link); err != nil {
+			continue // Skip malformed links
+		}
+		links = append(links, &link)
+	}
+
+	return links, nil
+}
+
+func (n *NFSBackend) AppendSessionTurn(siteID string, turn *storage.SessionTurn) error {
+	sessionDir := filepath.Join(n.basePath, "sites", siteID, "session")
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	// Same naming scheme as log entries: sortable by time, unique per job
+	timestamp := turn.Timestamp.UTC().Format("20060102-150405.000000")
+	turnPath := filepath.Join(sessionDir, fmt.Sprintf("%s-%s.json", timestamp, turn.JobID))
+
+	data, err := json.MarshalIndent(turn, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session turn: %w", err)
+	}
+
+	return atomicWriteBytes(turnPath, data)
+}
+
+func (n *NFSBackend) ListSessionTurns(siteID string, limit int) ([]*storage.SessionTurn, error) {
+	sessionDir := filepath.Join(n.basePath, "sites", siteID, "session")
+
+	entries, err := os.ReadDir(sessionDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*storage.SessionTurn{}, nil
+		}
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	turns := make([]*storage.SessionTurn, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(sessionDir, entry.Name()))
 		if err != nil {
 			continue // Skip unreadable files
 		}
 
-		var logEntry storage.LogEntry
-		if err := json.Unmarshal(data, &logEntry); err != nil {
+		var turn storage.SessionTurn
+		if err := json.Unmarshal(data, &turn); err != nil {
 			continue // Skip malformed files
 		}
-
-		versions = append(versions, &storage.Version{
-			BuildID:   logEntry.BuildID,
-			Timestamp: logEntry.Timestamp,
-			Action:    logEntry.Action,
-			Status:    logEntry.Status,
-			Metadata:  logEntry.Metadata,
-		})
+		turns = append(turns, &turn)
 	}
 
-	// Sort by timestamp descending (newest first)
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i].Timestamp.After(versions[j].Timestamp)
+	// Oldest first, so the history reads as a conversation
+	sort.Slice(turns, func(i, j int) bool {
+		return turns[i].Timestamp.Before(turns[j].Timestamp)
 	})
 
-	return versions, nil
+	if limit > 0 && len(turns) > limit {
+		turns = turns[len(turns)-limit:]
+	}
+
+	return turns, nil
+}
+
+func (n *NFSBackend) StoreAttachment(siteID, uploadID, name string, reader io.Reader) error {
+	uploadDir := filepath.Join(n.basePath, "sites", siteID, "attachments", uploadID)
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+
+	return atomicWrite(filepath.Join(uploadDir, name), reader)
+}
+
+func (n *NFSBackend) FetchAttachment(siteID, uploadID, name string) (io.ReadCloser, error) {
+	attachmentPath := filepath.Join(n.basePath, "sites", siteID, "attachments", uploadID, name)
+
+	file, err := os.Open(attachmentPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s/%s/%s", storage.ErrAttachmentNotFound, siteID, uploadID, name)
+		}
+		return nil, fmt.Errorf("failed to open attachment: %w", err)
+	}
+
+	return file, nil
+}
+
+func (n *NFSBackend) ListAttachments(siteID, uploadID string) ([]*storage.Attachment, error) {
+	uploadDir := filepath.Join(n.basePath, "sites", siteID, "attachments", uploadID)
+
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*storage.Attachment{}, nil
+		}
+		return nil, fmt.Errorf("failed to read attachment directory: %w", err)
+	}
+
+	attachments := make([]*storage.Attachment, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed while listing
+		}
+		attachments = append(attachments, &storage.Attachment{
+			Name: entry.Name(),
+			Size: info.Size(),
+		})
+	}
+
+	// ReadDir already sorts by name
+	return attachments, nil
 }
 
 // atomicWrite writes data from reader to path atomically
@@ -1,14 +1,21 @@
 package nfs
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -39,12 +46,13 @@ func TestStoreArtifact(t *testing.T) {
 	err := backend.StoreArtifact(siteID, buildID, bytes.NewReader(content))
 	assert.NoError(t, err)
 
-	// Verify file exists
-	artifactPath := filepath.Join(backend.basePath, "sites", siteID, "artifacts", buildID+".tar.gz")
-	assert.FileExists(t, artifactPath)
+	// Not a gzip archive, so the upload is kept whole as one blob
+	manifest, err := backend.readManifest(siteID, buildID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.FormatRaw, manifest.Format)
+	assert.Equal(t, int64(len(content)), manifest.Size)
 
-	// Verify content
-	storedContent, err := os.ReadFile(artifactPath)
+	storedContent, err := os.ReadFile(backend.blobPath(manifest.Blob))
 	assert.NoError(t, err)
 	assert.Equal(t, content, storedContent)
 }
@@ -54,21 +62,160 @@ func TestStoreArtifactAtomic(t *testing.T) {
 
 	siteID := "test-site"
 	buildID := "build-123"
-	content := []byte("test artifact content")
 
-	err := backend.StoreArtifact(siteID, buildID, bytes.NewReader(content))
+	err := backend.StoreArtifact(siteID, buildID, bytes.NewReader(buildArchive(t, map[string]string{"index.html": "<h1>Hi</h1>"})))
 	assert.NoError(t, err)
 
 	// Verify no temp files remain
-	artifactDir := filepath.Join(tmpDir, "sites", siteID, "artifacts")
-	entries, err := os.ReadDir(artifactDir)
+	err = filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		assert.NotContains(t, info.Name(), ".tmp", "Temporary file should not exist after atomic write")
+		return nil
+	})
 	assert.NoError(t, err)
+}
 
-	for _, entry := range entries {
-		assert.NotContains(t, entry.Name(), ".tmp", "Temporary file should not exist after atomic write")
+func TestStoreArtifactSplitsFiles(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	v1 := buildArchive(t, map[string]string{
+		"index.html":     "<h1>Home</h1>",
+		"about.html":     "<h1>About</h1>",
+		"assets/app.css": "body { color: black; }",
+	})
+	v2 := buildArchive(t, map[string]string{
+		"index.html":     "<h1>Home, edited</h1>",
+		"about.html":     "<h1>About</h1>",
+		"assets/app.css": "body { color: black; }",
+	})
+	require.NoError(t, backend.StoreArtifact("test-site", "v1", bytes.NewReader(v1)))
+	require.NoError(t, backend.StoreArtifact("test-site", "v2", bytes.NewReader(v2)))
+
+	manifest, err := backend.readManifest("test-site", "v2")
+	require.NoError(t, err)
+	assert.Equal(t, storage.FormatTarGz, manifest.Format)
+	assert.Equal(t, "v2", manifest.BuildID)
+	require.Len(t, manifest.Files, 4)
+	assert.Equal(t, "about.html", manifest.Files[0].Path)
+	assert.Equal(t, storage.EntryDir, manifest.Files[1].Type)
+
+	// Two unchanged files are shared, so four distinct blobs in total
+	assert.Equal(t, 4, countBlobs(t, tmpDir))
+
+	// Downloads are rebuilt byte for byte
+	for buildID, want := range map[string][]byte{"v1": v1, "v2": v2} {
+		reader, err := backend.FetchArtifact("test-site", buildID)
+		require.NoError(t, err)
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		require.NoError(t, err)
+		assert.Equal(t, want, got, buildID)
 	}
 }
 
+func TestStoreZstdArtifact(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	files := map[string]string{
+		"index.html":     "<h1>Home</h1>",
+		"assets/app.css": "body { color: black; }",
+	}
+	gz := buildArchive(t, files)
+	zst := recompressZstd(t, gz)
+	require.NoError(t, backend.StoreArtifact("test-site", "gz", bytes.NewReader(gz)))
+	require.NoError(t, backend.StoreArtifact("test-site", "zst", bytes.NewReader(zst)))
+
+	info, err := backend.ArtifactInfo("test-site", "zst")
+	require.NoError(t, err)
+	assert.Equal(t, storage.FormatTarZst, info.Format)
+	assert.Equal(t, "application/zstd", info.ContentType)
+	sum := sha256.Sum256(zst)
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.SHA256)
+
+	// Both codecs share the same file blobs
+	assert.Equal(t, 2, countBlobs(t, tmpDir))
+
+	reader, err := backend.FetchArtifact("test-site", "zst")
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, zst, got)
+
+	// Either version can be rebuilt in the other codec
+	manifest, err := backend.ReadManifest("test-site", "zst")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, storage.WriteArchive(&buf, manifest, backend, storage.FormatTarGz))
+	assert.Equal(t, gz, buf.Bytes())
+}
+
+func TestStoreArtifactReadErrorKeepsNoVersion(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	archive := buildArchive(t, map[string]string{"index.html": "<h1>Hi</h1>"})
+	reader := io.MultiReader(bytes.NewReader(archive), errReader{errors.New("digest mismatch")})
+
+	err := backend.StoreArtifact("test-site", "build-123", reader)
+	assert.ErrorContains(t, err, "digest mismatch")
+
+	_, err = backend.FetchArtifact("test-site", "build-123")
+	assert.Error(t, err)
+}
+
+func TestStoreArtifactRejectsUnsafePaths(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	archive := buildArchive(t, map[string]string{"../escape.html": "nope"})
+	err := backend.StoreArtifact("test-site", "build-123", bytes.NewReader(archive))
+	assert.ErrorContains(t, err, "invalid path")
+}
+
+func TestFetchLegacyArtifact(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	artifactDir := filepath.Join(tmpDir, "sites", "test-site", "artifacts")
+	require.NoError(t, os.MkdirAll(artifactDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(artifactDir, "old.tar.gz"), []byte("full archive"), 0644))
+
+	reader, err := backend.FetchArtifact("test-site", "old")
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("full archive"), data)
+}
+
+func TestArtifactInfo(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	archive := buildArchive(t, map[string]string{"index.html": "<h1>Home</h1>"})
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader(archive)))
+
+	info, err := backend.ArtifactInfo("test-site", "build-1")
+	require.NoError(t, err)
+	sum := sha256.Sum256(archive)
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.SHA256)
+	assert.Equal(t, int64(len(archive)), info.Size)
+	assert.Equal(t, "application/gzip", info.ContentType)
+	assert.False(t, info.UploadedAt.IsZero())
+
+	// Legacy archives have a size but no recorded digest
+	artifactDir := filepath.Join(tmpDir, "sites", "test-site", "artifacts")
+	require.NoError(t, os.WriteFile(filepath.Join(artifactDir, "old.tar.gz"), []byte("full archive"), 0644))
+	info, err = backend.ArtifactInfo("test-site", "old")
+	require.NoError(t, err)
+	assert.Empty(t, info.SHA256)
+	assert.Equal(t, int64(12), info.Size)
+
+	buildIDs, err := backend.ListArtifacts("test-site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1", "old"}, buildIDs)
+
+	_, err = backend.ArtifactInfo("test-site", "missing")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+}
+
 func TestFetchArtifact(t *testing.T) {
 	backend, _ := setupTestBackend(t)
 
@@ -189,8 +336,9 @@ func TestListVersions(t *testing.T) {
 	}
 
 	// List versions
-	versions, err := backend.ListVersions(siteID)
-	assert.NoError(t, err)
+	page, err := backend.ListVersions(siteID, storage.VersionQuery{})
+	require.NoError(t, err)
+	versions := page.Versions
 	assert.Len(t, versions, 3)
 
 	// Verify sorting (newest first)
@@ -202,8 +350,9 @@ func TestListVersions(t *testing.T) {
 func TestListVersionsEmpty(t *testing.T) {
 	backend, _ := setupTestBackend(t)
 
-	versions, err := backend.ListVersions("non-existent-site")
-	assert.NoError(t, err)
+	page, err := backend.ListVersions("non-existent-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	versions := page.Versions
 	assert.Empty(t, versions)
 }
 
@@ -228,9 +377,337 @@ func TestListVersionsWithMetadata(t *testing.T) {
 	err := backend.WriteLogEntry(siteID, entry)
 	require.NoError(t, err)
 
-	versions, err := backend.ListVersions(siteID)
-	assert.NoError(t, err)
+	page, err := backend.ListVersions(siteID, storage.VersionQuery{})
+	require.NoError(t, err)
+	versions := page.Versions
 	require.Len(t, versions, 1)
 
 	assert.Equal(t, metadata, versions[0].Metadata)
 }
+
+func TestSessionTurns(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	siteID := "test-site"
+	base := time.Now().UTC()
+
+	// Appended out of order; listing sorts oldest first
+	for _, tc := range []struct {
+		jobID  string
+		offset time.Duration
+	}{
+		{"job-2", 2 * time.Minute},
+		{"job-1", 1 * time.Minute},
+		{"job-3", 3 * time.Minute},
+	} {
+		turn := &storage.SessionTurn{
+			Timestamp: base.Add(tc.offset),
+			JobID:     tc.jobID,
+			Status:    "completed",
+			Prompt:    "prompt for " + tc.jobID,
+		}
+		require.NoError(t, backend.AppendSessionTurn(siteID, turn))
+	}
+
+	files, err := os.ReadDir(filepath.Join(tmpDir, "sites", siteID, "session"))
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	turns, err := backend.ListSessionTurns(siteID, 0)
+	require.NoError(t, err)
+	require.Len(t, turns, 3)
+	assert.Equal(t, "job-1", turns[0].JobID)
+	assert.Equal(t, "job-3", turns[2].JobID)
+
+	turns, err = backend.ListSessionTurns(siteID, 2)
+	require.NoError(t, err)
+	require.Len(t, turns, 2)
+	assert.Equal(t, "job-2", turns[0].JobID)
+	assert.Equal(t, "job-3", turns[1].JobID)
+}
+
+func TestListSessionTurnsEmpty(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	turns, err := backend.ListSessionTurns("no-site", 5)
+	assert.NoError(t, err)
+	assert.Empty(t, turns)
+}
+
+func TestAttachments(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	require.NoError(t, backend.StoreAttachment("test-site", "upload-1", "menu.pdf", bytes.NewReader([]byte("%PDF-1.7"))))
+	require.NoError(t, backend.StoreAttachment("test-site", "upload-1", "logo.png", bytes.NewReader([]byte("png"))))
+
+	assert.FileExists(t, filepath.Join(tmpDir, "sites", "test-site", "attachments", "upload-1", "logo.png"))
+
+	attachments, err := backend.ListAttachments("test-site", "upload-1")
+	require.NoError(t, err)
+	require.Len(t, attachments, 2)
+	assert.Equal(t, &storage.Attachment{Name: "logo.png", Size: 3}, attachments[0])
+	assert.Equal(t, &storage.Attachment{Name: "menu.pdf", Size: 8}, attachments[1])
+
+	reader, err := backend.FetchAttachment("test-site", "upload-1", "menu.pdf")
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("%PDF-1.7"), data)
+
+	_, err = backend.FetchAttachment("test-site", "upload-1", "missing.png")
+	assert.Error(t, err)
+
+	attachments, err = backend.ListAttachments("test-site", "no-upload")
+	assert.NoError(t, err)
+	assert.Empty(t, attachments)
+}
+
+// recompressZstd re-encodes a tar.gz archive as tar.zst the way the worker packs it
+func recompressZstd(t *testing.T, archive []byte) []byte {
+	t.Helper()
+
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf, zstd.WithEncoderConcurrency(1))
+	require.NoError(t, err)
+	_, err = io.Copy(zw, gzr)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// buildArchive packs files the way the worker does: sorted PAX entries with fixed
+// mtimes and a gzip header without name, time or OS
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	entries := make(map[string]bool)
+	for name := range files {
+		entries[name] = false
+		for dir := filepath.Dir(name); dir != "." && dir != ".."; dir = filepath.Dir(dir) {
+			entries[dir] = true
+		}
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gzw, err := gzip.NewWriterLevel(&buf, gzip.DefaultCompression)
+	require.NoError(t, err)
+	gzw.Header = gzip.Header{OS: 255}
+	tw := tar.NewWriter(gzw)
+
+	for _, name := range names {
+		header := &tar.Header{Name: name, ModTime: time.Unix(0, 0).UTC(), Format: tar.FormatPAX}
+		if entries[name] {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			header.Mode = 0755
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Mode = 0644
+			header.Size = int64(len(files[name]))
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		if !entries[name] {
+			_, err := tw.Write([]byte(files[name]))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return buf.Bytes()
+}
+
+func countBlobs(t *testing.T, basePath string) int {
+	t.Helper()
+
+	count := 0
+	err := filepath.Walk(filepath.Join(basePath, "blobs"), func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		if !info.IsDir() {
+			count++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return count
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDocuments(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	require.NoError(t, backend.StoreDocument("test-site", "build-1", storage.DocumentManifest, []byte(`{"v":1}`)))
+	require.NoError(t, backend.StoreDocument("test-site", "build-1", storage.DocumentManifest, []byte(`{"v":2}`)))
+	require.NoError(t, backend.StoreDocument("test-site", "build-1", storage.DocumentLogs, []byte(`{"content":"ok"}`)))
+
+	data, err := backend.FetchDocument("test-site", "build-1", storage.DocumentManifest)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":2}`, string(data))
+	assert.FileExists(t, filepath.Join(tmpDir, "sites", "test-site", "versions", "build-1", "logs.json"))
+
+	_, err = backend.FetchDocument("test-site", "build-2", storage.DocumentLogs)
+	assert.ErrorIs(t, err, storage.ErrDocumentNotFound)
+}
+
+func TestLineage(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	links, err := backend.ListLineage("test-site")
+	require.NoError(t, err)
+	assert.Empty(t, links)
+
+	base := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, backend.RecordLineage("test-site", &storage.LineageLink{BuildID: "v2", ParentBuildID: "v1", CreatedAt: base}))
+	require.NoError(t, backend.RecordLineage("test-site", &storage.LineageLink{BuildID: "v3", ParentBuildID: "v2", CreatedAt: base}))
+	require.NoError(t, backend.RecordLineage("test-site", &storage.LineageLink{BuildID: "v3", ParentBuildID: "v1", CreatedAt: base}))
+
+	links, err = backend.ListLineage("test-site")
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	assert.Equal(t, &storage.LineageLink{BuildID: "v3", ParentBuildID: "v1", CreatedAt: base}, links[1])
+
+	link, err := backend.GetLineage("test-site", "v3")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", link.ParentBuildID)
+
+	_, err = backend.GetLineage("test-site", "v1")
+	assert.ErrorIs(t, err, storage.ErrLineageNotFound)
+}
+
+func TestDeleteArtifact(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader(buildArchive(t, map[string]string{"index.html": "v1"}))))
+	require.NoError(t, backend.StoreArtifact("test-site", "build-2", bytes.NewReader(buildArchive(t, map[string]string{"index.html": "v2"}))))
+	for _, buildID := range []string{"build-1", "build-2"} {
+		require.NoError(t, backend.WriteLogEntry("test-site", &storage.LogEntry{
+			Timestamp: time.Now().UTC(),
+			BuildID:   buildID,
+			SiteID:    "test-site",
+			Action:    "build",
+			Status:    "success",
+		}))
+	}
+
+	require.NoError(t, backend.StoreDocument("test-site", "build-1", storage.DocumentManifest, []byte(`{"build_id":"build-1"}`)))
+
+	require.NoError(t, backend.DeleteArtifact("test-site", "build-1"))
+
+	_, err := backend.FetchArtifact("test-site", "build-1")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+	_, err = backend.FetchDocument("test-site", "build-1", storage.DocumentManifest)
+	assert.ErrorIs(t, err, storage.ErrDocumentNotFound)
+	page, err := backend.ListVersions("test-site", storage.VersionQuery{})
+	require.NoError(t, err)
+	versions := page.Versions
+	require.Len(t, versions, 1)
+	assert.Equal(t, "build-2", versions[0].BuildID)
+
+	err = backend.DeleteArtifact("test-site", "build-1")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+}
+
+func TestPinnedArtifactIsNotDeleted(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader([]byte("content"))))
+	require.NoError(t, backend.SetPinned("test-site", "build-1", true))
+
+	pinned, err := backend.ListPinned("test-site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1"}, pinned)
+
+	err = backend.DeleteArtifact("test-site", "build-1")
+	assert.ErrorIs(t, err, storage.ErrPinned)
+
+	require.NoError(t, backend.SetPinned("test-site", "build-1", false))
+	assert.NoError(t, backend.DeleteArtifact("test-site", "build-1"))
+
+	err = backend.SetPinned("test-site", "missing", true)
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+}
+
+func TestDeleteSite(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader([]byte("content"))))
+	require.NoError(t, backend.SetPinned("test-site", "build-1", true))
+	require.NoError(t, backend.StoreArtifact("other-site", "build-1", bytes.NewReader([]byte("other"))))
+
+	require.NoError(t, backend.DeleteSite("test-site"))
+	assert.NoDirExists(t, filepath.Join(tmpDir, "sites", "test-site"))
+
+	sites, err := backend.ListSites()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other-site"}, sites)
+
+	// Deleting again is a no-op
+	assert.NoError(t, backend.DeleteSite("test-site"))
+}
+
+func TestPruneBlobsSparesReusedBlob(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	hash, _, err := backend.PutBlob(bytes.NewReader([]byte("shared")))
+	require.NoError(t, err)
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(backend.blobPath(hash), old, old))
+
+	// An upload reuses the old blob but has not written its manifest yet
+	_, _, err = backend.PutBlob(bytes.NewReader([]byte("shared")))
+	require.NoError(t, err)
+	removed, err := backend.PruneBlobs(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	assert.Equal(t, 1, countBlobs(t, tmpDir))
+
+	// The upload commits and its files are all there
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader(buildArchive(t, map[string]string{"index.html": "shared"}))))
+	manifest, err := backend.ReadManifest("test-site", "build-1")
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 1)
+	assert.Equal(t, hash, manifest.Files[0].SHA256)
+	reader, err := backend.OpenBlob(hash)
+	require.NoError(t, err)
+	reader.Close()
+}
+
+func TestPruneBlobs(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+
+	require.NoError(t, backend.StoreArtifact("test-site", "build-1", bytes.NewReader(buildArchive(t, map[string]string{"index.html": "v1", "about.html": "about"}))))
+	require.NoError(t, backend.StoreArtifact("test-site", "build-2", bytes.NewReader(buildArchive(t, map[string]string{"index.html": "v2", "about.html": "about"}))))
+	require.NoError(t, backend.DeleteArtifact("test-site", "build-1"))
+	assert.Equal(t, 3, countBlobs(t, tmpDir))
+
+	// Within the grace period nothing goes, in case an upload is still writing its manifest
+	removed, err := backend.PruneBlobs(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	removed, err = backend.PruneBlobs(-time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, 2, countBlobs(t, tmpDir))
+
+	reader, err := backend.FetchArtifact("test-site", "build-2")
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,195 @@
+package nfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Upload sessions live outside the sites tree, one directory each:
+//
+//	upload-sessions/{session_id}/session.json
+//	upload-sessions/{session_id}/chunks/{index}
+//	upload-sessions/{session_id}/artifact        # the joined chunks, while committing
+
+func (n *NFSBackend) uploadSessionDir(sessionID string) string {
+	return filepath.Join(n.basePath, "upload-sessions", sessionID)
+}
+
+func (n *NFSBackend) CreateUploadSession(siteID, buildID string) (*storage.UploadSession, error) {
+	sessionID, err := storage.NewUploadSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := n.uploadSessionDir(sessionID)
+	if err := os.MkdirAll(filepath.Join(dir, "chunks"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload session directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	session := &storage.UploadSession{
+		SessionID: sessionID,
+		SiteID:    siteID,
+		BuildID:   buildID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Chunks:    []storage.UploadChunk{},
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal upload session: %w", err)
+	}
+	if err := atomicWriteBytes(filepath.Join(dir, "session.json"), data); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetUploadSession reads the session and lists its chunks; the newest chunk dates UpdatedAt
+func (n *NFSBackend) GetUploadSession(sessionID string) (*storage.UploadSession, error) {
+	dir := n.uploadSessionDir(sessionID)
+	data, err := os.ReadFile(filepath.Join(dir, "session.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrUploadSessionNotFound, sessionID)
+		}
+		return nil, fmt.Errorf("failed to read upload session: %w", err)
+	}
+
+	var session storage.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse upload session: %w", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "chunks"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	session.Chunks = []storage.UploadChunk{}
+	for _, entry := range entries {
+		index, err := strconv.Atoi(entry.Name())
+		if err != nil || entry.Name() != storage.ChunkName(index) {
+			continue // Temporary file of a chunk being written
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Replaced meanwhile
+		}
+		session.Chunks = append(session.Chunks, storage.UploadChunk{Index: index, Size: info.Size()})
+		if modTime := info.ModTime().UTC(); modTime.After(session.UpdatedAt) {
+			session.UpdatedAt = modTime
+		}
+	}
+	sort.Slice(session.Chunks, func(i, j int) bool { return session.Chunks[i].Index < session.Chunks[j].Index })
+
+	return &session, nil
+}
+
+// PutUploadChunk writes the chunk atomically, so a dropped request leaves no partial chunk
+// and the client just sends it again
+func (n *NFSBackend) PutUploadChunk(sessionID string, index int, reader io.Reader) error {
+	chunksDir := filepath.Join(n.uploadSessionDir(sessionID), "chunks")
+	if _, err := os.Stat(chunksDir); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", storage.ErrUploadSessionNotFound, sessionID)
+		}
+		return fmt.Errorf("failed to stat upload session: %w", err)
+	}
+
+	return atomicWrite(filepath.Join(chunksDir, storage.ChunkName(index)), reader)
+}
+
+// CommitUploadSession joins the chunks into one file with atomicWrite, checking the digest
+// on the way, then stores that file as the artifact
+func (n *NFSBackend) CommitUploadSession(sessionID, sha256 string) (*storage.UploadSession, error) {
+	session, err := n.GetUploadSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if missing := session.Missing(); missing >= 0 {
+		return nil, fmt.Errorf("%w: chunk %d", storage.ErrUploadIncomplete, missing)
+	}
+
+	dir := n.uploadSessionDir(sessionID)
+	chunks := storage.JoinChunks(len(session.Chunks), func(index int) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, "chunks", storage.ChunkName(index)))
+	}, sha256)
+	defer chunks.Close()
+
+	artifactPath := filepath.Join(dir, "artifact")
+	if err := atomicWrite(artifactPath, chunks); err != nil {
+		return nil, err
+	}
+
+	artifact, err := os.Open(artifactPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open joined upload: %w", err)
+	}
+	defer artifact.Close()
+
+	if err := n.StoreArtifact(session.SiteID, session.BuildID, artifact); err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	return session, nil
+}
+
+func (n *NFSBackend) AbortUploadSession(sessionID string) error {
+	dir := n.uploadSessionDir(sessionID)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", storage.ErrUploadSessionNotFound, sessionID)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	return nil
+}
+
+func (n *NFSBackend) PruneUploadSessions(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(n.basePath, "upload-sessions"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read upload sessions: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	pruned := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !storage.ValidUploadSessionID(entry.Name()) {
+			continue
+		}
+
+		session, err := n.GetUploadSession(entry.Name())
+		if err != nil {
+			// A session directory without a readable session.json is left from a crash;
+			// age it by the directory instead
+			info, statErr := entry.Info()
+			if statErr != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+		} else if session.UpdatedAt.After(cutoff) {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(n.basePath, "upload-sessions", entry.Name())); err != nil {
+			return pruned, fmt.Errorf("failed to remove upload session: %w", err)
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,97 @@
+package nfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSessionCommit(t *testing.T) {
+	backend, tmpDir := setupTestBackend(t)
+	content := buildArchive(t, map[string]string{"index.html": "chunked", "about.html": "about"})
+	sum := sha256.Sum256(content)
+	half := len(content) / 2
+
+	session, err := backend.CreateUploadSession("test-site", "build-1")
+	require.NoError(t, err)
+
+	// Chunks arrive out of order, and a resent chunk replaces the first copy
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 1, bytes.NewReader(content[half:])))
+	_, err = backend.CommitUploadSession(session.SessionID, hex.EncodeToString(sum[:]))
+	assert.True(t, errors.Is(err, storage.ErrUploadIncomplete))
+
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 0, bytes.NewReader([]byte("partial"))))
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 0, bytes.NewReader(content[:half])))
+
+	current, err := backend.GetUploadSession(session.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.UploadChunk{{Index: 0, Size: int64(half)}, {Index: 1, Size: int64(len(content) - half)}}, current.Chunks)
+
+	committed, err := backend.CommitUploadSession(session.SessionID, hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	assert.Equal(t, "build-1", committed.BuildID)
+
+	reader, err := backend.FetchArtifact("test-site", "build-1")
+	require.NoError(t, err)
+	defer reader.Close()
+	fetched, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, fetched)
+
+	// The session is gone once committed
+	_, err = os.Stat(filepath.Join(tmpDir, "upload-sessions", session.SessionID))
+	assert.True(t, os.IsNotExist(err))
+	_, err = backend.GetUploadSession(session.SessionID)
+	assert.True(t, errors.Is(err, storage.ErrUploadSessionNotFound))
+}
+
+func TestUploadSessionDigestMismatch(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	session, err := backend.CreateUploadSession("test-site", "build-1")
+	require.NoError(t, err)
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 0, bytes.NewReader([]byte("content"))))
+
+	_, err = backend.CommitUploadSession(session.SessionID, hex.EncodeToString(make([]byte, 32)))
+	assert.True(t, errors.Is(err, storage.ErrUploadDigestMismatch))
+
+	// Nothing was stored, and the session is kept for another try
+	_, err = backend.FetchArtifact("test-site", "build-1")
+	assert.Error(t, err)
+	_, err = backend.GetUploadSession(session.SessionID)
+	assert.NoError(t, err)
+}
+
+func TestUploadSessionAbortAndPrune(t *testing.T) {
+	backend, _ := setupTestBackend(t)
+
+	aborted, err := backend.CreateUploadSession("test-site", "build-1")
+	require.NoError(t, err)
+	require.NoError(t, backend.AbortUploadSession(aborted.SessionID))
+	assert.True(t, errors.Is(backend.AbortUploadSession(aborted.SessionID), storage.ErrUploadSessionNotFound))
+	assert.True(t, errors.Is(backend.PutUploadChunk(aborted.SessionID, 0, bytes.NewReader(nil)), storage.ErrUploadSessionNotFound))
+
+	idle, err := backend.CreateUploadSession("test-site", "build-2")
+	require.NoError(t, err)
+	require.NoError(t, backend.PutUploadChunk(idle.SessionID, 0, bytes.NewReader([]byte("chunk"))))
+
+	pruned, err := backend.PruneUploadSessions(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pruned)
+
+	pruned, err = backend.PruneUploadSessions(-time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	_, err = backend.GetUploadSession(idle.SessionID)
+	assert.True(t, errors.Is(err, storage.ErrUploadSessionNotFound))
+}
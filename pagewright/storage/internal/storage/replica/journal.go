@@ -0,0 +1,212 @@
+package replica
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Kinds of replicated operations
+const (
+	OpArtifact       = "artifact"        // copy the artifact of BuildID from the primary
+	OpDocument       = "document"        // copy document Name of BuildID from the primary
+	OpAttachment     = "attachment"      // copy attachment UploadID/Name from the primary
+	OpLogEntry       = "log_entry"       // write LogEntry
+	OpLineage        = "lineage"         // record Lineage
+	OpSessionTurn    = "session_turn"    // append Turn
+	OpDeleteArtifact = "delete_artifact" // delete BuildID
+	OpDeleteSite     = "delete_site"     // delete SiteID
+	OpPin            = "pin"             // set the pin of BuildID to Pinned
+	OpOwner          = "owner"           // set the owner of SiteID to OwnerID
+)
+
+// Op is one write to mirror on the secondary. Artifacts, documents and attachments are
+// read back from the primary when the op is applied, so the journal stays small; the
+// small writes carry their data.
+type Op struct {
+	Seq       int64                `json:"seq"`
+	Kind      string               `json:"kind"`
+	SiteID    string               `json:"site_id"`
+	BuildID   string               `json:"build_id,omitempty"`
+	Name      string               `json:"name,omitempty"`
+	UploadID  string               `json:"upload_id,omitempty"`
+	OwnerID   string               `json:"owner_id,omitempty"`
+	Pinned    bool                 `json:"pinned,omitempty"`
+	LogEntry  *storage.LogEntry    `json:"log_entry,omitempty"`
+	Lineage   *storage.LineageLink `json:"lineage,omitempty"`
+	Turn      *storage.SessionTurn `json:"turn,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+	Attempts  int                  `json:"attempts,omitempty"`
+	LastError string               `json:"last_error,omitempty"`
+}
+
+// Journal is the durable replication log: a directory with one file per pending op,
+// named by sequence number. An op's file is removed once the secondary has it, or moved
+// to the parked subdirectory once it has failed too often.
+type Journal struct {
+	dir  string
+	mu   sync.Mutex
+	next int64
+}
+
+// OpenJournal opens the journal in dir, creating it if needed. Ops left by an earlier
+// run stay pending.
+func OpenJournal(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create replication log directory: %w", err)
+	}
+
+	// Numbering continues after parked ops as well, so a new op never takes a parked op's name
+	j := &Journal{dir: dir, next: 1}
+	for _, d := range []string{dir, j.parkedDir()} {
+		seqs, err := listSeqs(d)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(seqs) > 0 && seqs[len(seqs)-1] >= j.next {
+			j.next = seqs[len(seqs)-1] + 1
+		}
+	}
+	return j, nil
+}
+
+// Append assigns the op its sequence number and writes it durably
+func (j *Journal) Append(op *Op) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	op.Seq = j.next
+	if op.CreatedAt.IsZero() {
+		op.CreatedAt = time.Now().UTC()
+	}
+	if err := j.write(op); err != nil {
+		return err
+	}
+	j.next++
+	return nil
+}
+
+// Pending returns the ops not yet applied, oldest first
+func (j *Journal) Pending() ([]*Op, error) {
+	seqs, err := listSeqs(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]*Op, 0, len(seqs))
+	for _, seq := range seqs {
+		data, err := os.ReadFile(j.path(seq))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Applied meanwhile
+			}
+			return nil, fmt.Errorf("failed to read replication op %d: %w", seq, err)
+		}
+		var op Op
+		if err := json.Unmarshal(data, &op); err != nil {
+			return nil, fmt.Errorf("failed to parse replication op %d: %w", seq, err)
+		}
+		ops = append(ops, &op)
+	}
+	return ops, nil
+}
+
+// Update rewrites an op, e.g. after a failed attempt
+func (j *Journal) Update(op *Op) error {
+	return j.write(op)
+}
+
+// Done removes an applied op
+func (j *Journal) Done(op *Op) error {
+	if err := os.Remove(j.path(op.Seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove replication op %d: %w", op.Seq, err)
+	}
+	return nil
+}
+
+// Park records the op's last attempt and moves it out of the pending ops, so the ops after
+// it can be applied. Parked ops stay on disk for inspection; reconcile repairs what they missed.
+func (j *Journal) Park(op *Op) error {
+	if err := j.write(op); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(j.parkedDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create parked replication op directory: %w", err)
+	}
+	if err := os.Rename(j.path(op.Seq), filepath.Join(j.parkedDir(), filepath.Base(j.path(op.Seq)))); err != nil {
+		return fmt.Errorf("failed to park replication op %d: %w", op.Seq, err)
+	}
+	return nil
+}
+
+func (j *Journal) path(seq int64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d.json", seq))
+}
+
+func (j *Journal) parkedDir() string {
+	return filepath.Join(j.dir, "parked")
+}
+
+// write stores an op with write, fsync and rename, so a crash never leaves half of one
+func (j *Journal) write(op *Op) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication op: %w", err)
+	}
+
+	tmpPath := j.path(op.Seq) + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create replication op: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write replication op: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync replication op: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close replication op: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.path(op.Seq)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to commit replication op: %w", err)
+	}
+	return nil
+}
+
+func listSeqs(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replication log: %w", err)
+	}
+
+	var seqs []int64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue // Temporary file of an interrupted write
+		}
+		seq, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, k int) bool { return seqs[i] < seqs[k] })
+	return seqs, nil
+}
@@ -0,0 +1,62 @@
+package replica
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Append(&Op{Kind: OpArtifact, SiteID: "site", BuildID: "build-1"}))
+	require.NoError(t, journal.Append(&Op{Kind: OpLogEntry, SiteID: "site", LogEntry: &storage.LogEntry{BuildID: "build-1"}}))
+	require.NoError(t, journal.Append(&Op{Kind: OpPin, SiteID: "site", BuildID: "build-1", Pinned: true}))
+
+	ops, err := journal.Pending()
+	require.NoError(t, err)
+	require.Len(t, ops, 3)
+	assert.Equal(t, []int64{1, 2, 3}, []int64{ops[0].Seq, ops[1].Seq, ops[2].Seq})
+	assert.Equal(t, "build-1", ops[1].LogEntry.BuildID)
+	assert.True(t, ops[2].Pinned)
+
+	ops[0].Attempts = 2
+	ops[0].LastError = "secondary down"
+	require.NoError(t, journal.Update(ops[0]))
+	require.NoError(t, journal.Done(ops[1]))
+
+	// A leftover temporary file is not an op
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000009.json.tmp"), []byte("{"), 0644))
+
+	// Reopening keeps the pending ops and continues the numbering
+	journal, err = OpenJournal(dir)
+	require.NoError(t, err)
+	ops, err = journal.Pending()
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	assert.Equal(t, 2, ops[0].Attempts)
+	assert.Equal(t, "secondary down", ops[0].LastError)
+	assert.Equal(t, int64(3), ops[1].Seq)
+
+	op := &Op{Kind: OpOwner, SiteID: "site", OwnerID: "user-1"}
+	require.NoError(t, journal.Append(op))
+	assert.Equal(t, int64(4), op.Seq)
+
+	// A parked op leaves the pending ops but keeps its number taken
+	op.Attempts = 20
+	require.NoError(t, journal.Park(op))
+	assert.FileExists(t, filepath.Join(dir, "parked", "00000000000000000004.json"))
+	journal, err = OpenJournal(dir)
+	require.NoError(t, err)
+	ops, err = journal.Pending()
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	require.NoError(t, journal.Append(op))
+	assert.Equal(t, int64(5), op.Seq)
+}
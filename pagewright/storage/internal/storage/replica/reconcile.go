@@ -0,0 +1,311 @@
+package replica
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// ReconcileReport lists what Reconcile found different on the secondary and repaired,
+// or would repair on a dry run
+type ReconcileReport struct {
+	DryRun           bool     `json:"dry_run"`
+	Sites            int      `json:"sites"`
+	CopiedArtifacts  []string `json:"copied_artifacts"`
+	DeletedArtifacts []string `json:"deleted_artifacts"`
+	CopiedDocuments  int      `json:"copied_documents"`
+	CopiedLogEntries int      `json:"copied_log_entries"`
+	CopiedLineage    int      `json:"copied_lineage"`
+	CopiedTurns      int      `json:"copied_turns"`
+	FixedPins        int      `json:"fixed_pins"`
+	FixedOwners      int      `json:"fixed_owners"`
+	DeletedSites     []string `json:"deleted_sites"`
+}
+
+// Reconcile makes the secondary match the primary, site by site: artifacts missing or
+// with a different digest are copied, extra ones deleted, and documents, log entries,
+// lineage, session turns, pins and owners filled in. Sites only the secondary has are
+// deleted. Attachments are left alone, as uploads cannot be listed; the journal is what
+// replicates them.
+func Reconcile(primary, secondary storage.Backend, dryRun bool) (*ReconcileReport, error) {
+	report := &ReconcileReport{
+		DryRun:           dryRun,
+		CopiedArtifacts:  []string{},
+		DeletedArtifacts: []string{},
+		DeletedSites:     []string{},
+	}
+
+	sites, err := primary.ListSites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary sites: %w", err)
+	}
+	secondarySites, err := secondary.ListSites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secondary sites: %w", err)
+	}
+
+	for _, siteID := range sites {
+		if err := reconcileSite(primary, secondary, siteID, report); err != nil {
+			return report, fmt.Errorf("failed to reconcile site %s: %w", siteID, err)
+		}
+		report.Sites++
+	}
+
+	// An empty primary is more likely a wrong mount or bucket than deleted sites
+	if len(sites) > 0 {
+		for _, siteID := range secondarySites {
+			if slices.Contains(sites, siteID) {
+				continue
+			}
+			report.DeletedSites = append(report.DeletedSites, siteID)
+			if !report.DryRun {
+				if err := secondary.DeleteSite(siteID); err != nil {
+					return report, fmt.Errorf("failed to delete site %s: %w", siteID, err)
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func reconcileSite(primary, secondary storage.Backend, siteID string, report *ReconcileReport) error {
+	builds, err := primary.ListArtifacts(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list primary artifacts: %w", err)
+	}
+	secondaryBuilds, err := secondary.ListArtifacts(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list secondary artifacts: %w", err)
+	}
+	secondaryPinned, err := secondary.ListPinned(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list secondary pins: %w", err)
+	}
+
+	for _, buildID := range secondaryBuilds {
+		if slices.Contains(builds, buildID) {
+			continue
+		}
+		report.DeletedArtifacts = append(report.DeletedArtifacts, siteID+"/"+buildID)
+		if report.DryRun {
+			continue
+		}
+		if slices.Contains(secondaryPinned, buildID) {
+			if err := secondary.SetPinned(siteID, buildID, false); err != nil {
+				return fmt.Errorf("failed to unpin %s: %w", buildID, err)
+			}
+		}
+		if err := secondary.DeleteArtifact(siteID, buildID); err != nil && !errors.Is(err, storage.ErrArtifactNotFound) {
+			return fmt.Errorf("failed to delete %s: %w", buildID, err)
+		}
+	}
+
+	for _, buildID := range builds {
+		if err := reconcileArtifact(primary, secondary, siteID, buildID, report); err != nil {
+			return err
+		}
+	}
+
+	pinned, err := primary.ListPinned(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list primary pins: %w", err)
+	}
+	for _, buildID := range builds {
+		want := slices.Contains(pinned, buildID)
+		if want == slices.Contains(secondaryPinned, buildID) {
+			continue
+		}
+		report.FixedPins++
+		if !report.DryRun {
+			if err := secondary.SetPinned(siteID, buildID, want); err != nil {
+				return fmt.Errorf("failed to pin %s: %w", buildID, err)
+			}
+		}
+	}
+
+	owner, err := primary.GetOwner(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to read primary owner: %w", err)
+	}
+	secondaryOwner, err := secondary.GetOwner(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to read secondary owner: %w", err)
+	}
+	if owner != "" && owner != secondaryOwner {
+		report.FixedOwners++
+		if !report.DryRun {
+			if err := secondary.SetOwner(siteID, owner); err != nil {
+				return fmt.Errorf("failed to set owner: %w", err)
+			}
+		}
+	}
+
+	if err := reconcileLogEntries(primary, secondary, siteID, report); err != nil {
+		return err
+	}
+	if err := reconcileLineage(primary, secondary, siteID, report); err != nil {
+		return err
+	}
+	return reconcileSessionTurns(primary, secondary, siteID, report)
+}
+
+// reconcileArtifact copies an artifact the secondary lacks or holds a different copy of,
+// then the documents stored next to it
+func reconcileArtifact(primary, secondary storage.Backend, siteID, buildID string, report *ReconcileReport) error {
+	info, err := primary.ArtifactInfo(siteID, buildID)
+	if err != nil {
+		return fmt.Errorf("failed to read primary artifact %s: %w", buildID, err)
+	}
+	secondaryInfo, err := secondary.ArtifactInfo(siteID, buildID)
+	if err != nil && !errors.Is(err, storage.ErrArtifactNotFound) {
+		return fmt.Errorf("failed to read secondary artifact %s: %w", buildID, err)
+	}
+
+	// Copies hold the same files whatever codec wrote them; artifacts stored before
+	// manifests fall back to the digest, or the size alone before digests were recorded
+	same := secondaryInfo != nil && secondaryInfo.SHA256 == info.SHA256 && secondaryInfo.Size == info.Size
+	if secondaryInfo != nil && info.ContentHash != "" && secondaryInfo.ContentHash != "" {
+		same = secondaryInfo.ContentHash == info.ContentHash
+	}
+	if !same {
+		report.CopiedArtifacts = append(report.CopiedArtifacts, siteID+"/"+buildID)
+		if !report.DryRun {
+			reader, err := primary.FetchArtifact(siteID, buildID)
+			if err != nil {
+				return fmt.Errorf("failed to fetch %s: %w", buildID, err)
+			}
+			err = secondary.StoreArtifact(siteID, buildID, reader)
+			reader.Close()
+			if err != nil {
+				return fmt.Errorf("failed to copy %s: %w", buildID, err)
+			}
+		}
+	}
+
+	for _, name := range []string{storage.DocumentManifest, storage.DocumentLogs} {
+		data, err := primary.FetchDocument(siteID, buildID, name)
+		if errors.Is(err, storage.ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s document of %s: %w", name, buildID, err)
+		}
+		if existing, err := secondary.FetchDocument(siteID, buildID, name); err == nil && string(existing) == string(data) {
+			continue
+		} else if err != nil && !errors.Is(err, storage.ErrDocumentNotFound) {
+			return fmt.Errorf("failed to read secondary %s document of %s: %w", name, buildID, err)
+		}
+		report.CopiedDocuments++
+		if !report.DryRun {
+			if err := secondary.StoreDocument(siteID, buildID, name, data); err != nil {
+				return fmt.Errorf("failed to copy %s document of %s: %w", name, buildID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reconcileLogEntries writes the log entries the secondary's version listing lacks
+func reconcileLogEntries(primary, secondary storage.Backend, siteID string, report *ReconcileReport) error {
+	versions, err := primary.ListVersions(siteID, storage.VersionQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to list primary versions: %w", err)
+	}
+	secondaryVersions, err := secondary.ListVersions(siteID, storage.VersionQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to list secondary versions: %w", err)
+	}
+
+	have := make(map[string]bool, len(secondaryVersions.Versions))
+	for _, version := range secondaryVersions.Versions {
+		have[versionKey(version)] = true
+	}
+
+	// Oldest first, so the secondary's index keeps the primary's order
+	for i := len(versions.Versions) - 1; i >= 0; i-- {
+		version := versions.Versions[i]
+		if have[versionKey(version)] {
+			continue
+		}
+		report.CopiedLogEntries++
+		if report.DryRun {
+			continue
+		}
+		if err := secondary.WriteLogEntry(siteID, storage.LogFromVersion(siteID, version)); err != nil {
+			return fmt.Errorf("failed to copy log entry of %s: %w", version.BuildID, err)
+		}
+	}
+	return nil
+}
+
+func versionKey(version *storage.Version) string {
+	return fmt.Sprintf("%s/%d/%s/%s", version.BuildID, version.Timestamp.UnixNano(), version.Action, version.Status)
+}
+
+func reconcileLineage(primary, secondary storage.Backend, siteID string, report *ReconcileReport) error {
+	links, err := primary.ListLineage(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list primary lineage: %w", err)
+	}
+	secondaryLinks, err := secondary.ListLineage(siteID)
+	if err != nil {
+		return fmt.Errorf("failed to list secondary lineage: %w", err)
+	}
+
+	have := make(map[string]string, len(secondaryLinks))
+	for _, link := range secondaryLinks {
+		have[link.BuildID] = link.ParentBuildID
+	}
+
+	for _, link := range links {
+		if parent, ok := have[link.BuildID]; ok && parent == link.ParentBuildID {
+			continue
+		}
+		report.CopiedLineage++
+		if !report.DryRun {
+			if err := secondary.RecordLineage(siteID, link); err != nil {
+				return fmt.Errorf("failed to copy lineage of %s: %w", link.BuildID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileSessionTurns appends the turns the secondary lacks. Turns already there are
+// kept in place, so a turn missed in the middle ends up last on the secondary.
+func reconcileSessionTurns(primary, secondary storage.Backend, siteID string, report *ReconcileReport) error {
+	turns, err := primary.ListSessionTurns(siteID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list primary session turns: %w", err)
+	}
+	secondaryTurns, err := secondary.ListSessionTurns(siteID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list secondary session turns: %w", err)
+	}
+
+	have := make(map[string]bool, len(secondaryTurns))
+	for _, turn := range secondaryTurns {
+		have[turnKey(turn)] = true
+	}
+
+	for _, turn := range turns {
+		if have[turnKey(turn)] {
+			continue
+		}
+		report.CopiedTurns++
+		if !report.DryRun {
+			if err := secondary.AppendSessionTurn(siteID, turn); err != nil {
+				return fmt.Errorf("failed to copy session turn of job %s: %w", turn.JobID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func turnKey(turn *storage.SessionTurn) string {
+	return fmt.Sprintf("%d/%s", turn.Timestamp.UnixNano(), turn.JobID)
+}
@@ -0,0 +1,98 @@
+package replica
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	primary, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	secondary, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, buildID := range []string{"build-1", "build-2"} {
+		require.NoError(t, primary.StoreArtifact("site", buildID, bytes.NewReader([]byte("artifact "+buildID))))
+		require.NoError(t, primary.WriteLogEntry("site", &storage.LogEntry{
+			Timestamp: now.Add(time.Duration(i) * time.Minute),
+			BuildID:   buildID,
+			SiteID:    "site",
+			Action:    "build",
+			Status:    "success",
+		}))
+	}
+	require.NoError(t, primary.StoreDocument("site", "build-2", storage.DocumentLogs, []byte(`{"lines":[]}`)))
+	require.NoError(t, primary.RecordLineage("site", &storage.LineageLink{BuildID: "build-2", ParentBuildID: "build-1", CreatedAt: now}))
+	require.NoError(t, primary.AppendSessionTurn("site", &storage.SessionTurn{Timestamp: now, JobID: "job-1", Prompt: "hi"}))
+	require.NoError(t, primary.SetPinned("site", "build-1", true))
+	require.NoError(t, primary.SetOwner("site", "user-1"))
+
+	// The secondary has build-1 already, a stale copy of build-2, a version deleted since
+	// and a site deleted since
+	require.NoError(t, secondary.StoreArtifact("site", "build-1", bytes.NewReader([]byte("artifact build-1"))))
+	require.NoError(t, secondary.StoreArtifact("site", "build-2", bytes.NewReader([]byte("stale"))))
+	require.NoError(t, secondary.StoreArtifact("site", "build-0", bytes.NewReader([]byte("artifact build-0"))))
+	require.NoError(t, secondary.SetPinned("site", "build-0", true))
+	require.NoError(t, secondary.StoreArtifact("gone", "build-1", bytes.NewReader([]byte("artifact"))))
+
+	report, err := Reconcile(primary, secondary, true)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"site/build-2"}, report.CopiedArtifacts)
+	assert.Equal(t, []string{"site/build-0"}, report.DeletedArtifacts)
+	assert.Equal(t, []string{"gone"}, report.DeletedSites)
+	assert.Equal(t, 1, report.CopiedDocuments)
+	assert.Equal(t, 2, report.CopiedLogEntries)
+	assert.Equal(t, 1, report.CopiedLineage)
+	assert.Equal(t, 1, report.CopiedTurns)
+	assert.Equal(t, 1, report.FixedPins)
+	assert.Equal(t, 1, report.FixedOwners)
+
+	// A dry run changes nothing
+	builds, err := secondary.ListArtifacts("site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-0", "build-1", "build-2"}, builds)
+
+	report, err = Reconcile(primary, secondary, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"site/build-2"}, report.CopiedArtifacts)
+
+	builds, err = secondary.ListArtifacts("site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1", "build-2"}, builds)
+	assert.Equal(t, "artifact build-2", readArtifact(t, secondary, "site", "build-2"))
+
+	sites, err := secondary.ListSites()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"site"}, sites)
+
+	page, err := secondary.ListVersions("site", storage.VersionQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Versions, 2)
+	assert.Equal(t, "build-2", page.Versions[0].BuildID)
+
+	pinned, err := secondary.ListPinned("site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1"}, pinned)
+
+	owner, err := secondary.GetOwner("site")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", owner)
+
+	// Once in sync there is nothing left to do
+	report, err = Reconcile(primary, secondary, false)
+	require.NoError(t, err)
+	assert.Equal(t, &ReconcileReport{
+		Sites:            1,
+		CopiedArtifacts:  []string{},
+		DeletedArtifacts: []string{},
+		DeletedSites:     []string{},
+	}, report)
+}
@@ -0,0 +1,441 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Retry delays for an op the secondary did not take; each failure doubles the delay
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// pollInterval bounds how long a journaled op can wait when no new write wakes the worker
+const pollInterval = 30 * time.Second
+
+// maxAttempts is how often an op is tried before it is parked, about an hour of retries
+const maxAttempts = 20
+
+// Backend writes to a primary backend and mirrors every write to a secondary in the
+// background, through a durable journal. Reads that fail on the primary are served
+// from the secondary. Upload sessions live on the primary only; the artifact of a
+// committed session is mirrored like any other.
+type Backend struct {
+	primary   storage.Backend
+	secondary storage.Backend
+	journal   *Journal
+	wake      chan struct{}
+}
+
+func New(primary, secondary storage.Backend, journal *Journal) *Backend {
+	return &Backend{
+		primary:   primary,
+		secondary: secondary,
+		journal:   journal,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Run applies journaled ops to the secondary, in order, until ctx is cancelled. An op
+// that fails is retried with backoff before any later op is applied, so the secondary
+// never sees writes out of order.
+func (b *Backend) Run(ctx context.Context) {
+	delay := minRetryDelay
+	for {
+		wait := pollInterval
+		if err := b.Drain(); err != nil {
+			log.Printf("Replication to secondary failed, retrying in %s: %v", delay, err)
+			wait = delay
+			delay = min(delay*2, maxRetryDelay)
+		} else {
+			delay = minRetryDelay
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-b.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Drain applies every pending op, stopping at the first one that fails. An op that has
+// failed maxAttempts times is parked instead, so it cannot hold back the ops after it.
+func (b *Backend) Drain() error {
+	ops, err := b.journal.Pending()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err := b.apply(op); err != nil {
+			op.Attempts++
+			op.LastError = err.Error()
+			if op.Attempts >= maxAttempts {
+				if parkErr := b.journal.Park(op); parkErr != nil {
+					return parkErr
+				}
+				log.Printf("Parked replication op %d (%s %s/%s) after %d attempts, run reconcile to repair: %v",
+					op.Seq, op.Kind, op.SiteID, op.BuildID, op.Attempts, err)
+				continue
+			}
+			if updateErr := b.journal.Update(op); updateErr != nil {
+				log.Printf("Failed to record replication attempt %d: %v", op.Seq, updateErr)
+			}
+			return fmt.Errorf("op %d (%s %s/%s): %w", op.Seq, op.Kind, op.SiteID, op.BuildID, err)
+		}
+		if err := b.journal.Done(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record journals a write that succeeded on the primary. A write that cannot be
+// journaled is still reported as stored; reconcile copies it later.
+func (b *Backend) record(op *Op) {
+	if err := b.journal.Append(op); err != nil {
+		log.Printf("Failed to journal %s of %s/%s for replication: %v", op.Kind, op.SiteID, op.BuildID, err)
+		return
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Backend) apply(op *Op) error {
+	switch op.Kind {
+	case OpArtifact:
+		reader, err := b.primary.FetchArtifact(op.SiteID, op.BuildID)
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			return nil // Deleted since; a later op deletes it on the secondary too
+		}
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return b.secondary.StoreArtifact(op.SiteID, op.BuildID, reader)
+
+	case OpDocument:
+		data, err := b.primary.FetchDocument(op.SiteID, op.BuildID, op.Name)
+		if errors.Is(err, storage.ErrDocumentNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return b.secondary.StoreDocument(op.SiteID, op.BuildID, op.Name, data)
+
+	case OpAttachment:
+		reader, err := b.primary.FetchAttachment(op.SiteID, op.UploadID, op.Name)
+		if errors.Is(err, storage.ErrAttachmentNotFound) {
+			return nil // Removed with its site since
+		}
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return b.secondary.StoreAttachment(op.SiteID, op.UploadID, op.Name, reader)
+
+	case OpLogEntry:
+		return b.secondary.WriteLogEntry(op.SiteID, op.LogEntry)
+
+	case OpLineage:
+		return b.secondary.RecordLineage(op.SiteID, op.Lineage)
+
+	case OpSessionTurn:
+		return b.secondary.AppendSessionTurn(op.SiteID, op.Turn)
+
+	case OpDeleteArtifact:
+		err := b.secondary.DeleteArtifact(op.SiteID, op.BuildID)
+		if errors.Is(err, storage.ErrPinned) {
+			// Unpinned on the primary, so the pin on the secondary is stale
+			if err := b.secondary.SetPinned(op.SiteID, op.BuildID, false); err != nil {
+				return err
+			}
+			err = b.secondary.DeleteArtifact(op.SiteID, op.BuildID)
+		}
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			return nil
+		}
+		return err
+
+	case OpDeleteSite:
+		return b.secondary.DeleteSite(op.SiteID)
+
+	case OpPin:
+		err := b.secondary.SetPinned(op.SiteID, op.BuildID, op.Pinned)
+		if errors.Is(err, storage.ErrArtifactNotFound) {
+			return nil // Deleted since
+		}
+		return err
+
+	case OpOwner:
+		return b.secondary.SetOwner(op.SiteID, op.OwnerID)
+	}
+
+	return fmt.Errorf("unknown replication op %q", op.Kind)
+}
+
+// definitive reports whether err is an answer about the data rather than a failure of the
+// primary. Such errors are returned as they are instead of asking the secondary, which may
+// lag behind.
+func definitive(err error) bool {
+	for _, target := range []error{
+		storage.ErrArtifactNotFound,
+		storage.ErrDocumentNotFound,
+		storage.ErrAttachmentNotFound,
+		storage.ErrLineageNotFound,
+		storage.ErrNoManifest,
+		storage.ErrNotArchive,
+		storage.ErrFileNotFound,
+		storage.ErrInvalidCursor,
+		storage.ErrPinned,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// read runs fn on the primary and, if the primary fails, on the secondary. The primary's
+// error is returned if both fail.
+func read[T any](b *Backend, fn func(storage.Backend) (T, error)) (T, error) {
+	value, err := fn(b.primary)
+	if err == nil || definitive(err) {
+		return value, err
+	}
+
+	fallback, fallbackErr := fn(b.secondary)
+	if fallbackErr != nil {
+		return value, err
+	}
+	log.Printf("Primary storage failed, served from secondary: %v", err)
+	return fallback, nil
+}
+
+func (b *Backend) StoreArtifact(siteID, buildID string, reader io.Reader) error {
+	if err := b.primary.StoreArtifact(siteID, buildID, reader); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpArtifact, SiteID: siteID, BuildID: buildID})
+	return nil
+}
+
+func (b *Backend) FetchArtifact(siteID, buildID string) (io.ReadCloser, error) {
+	return read(b, func(backend storage.Backend) (io.ReadCloser, error) {
+		return backend.FetchArtifact(siteID, buildID)
+	})
+}
+
+func (b *Backend) ArtifactInfo(siteID, buildID string) (*storage.ArtifactInfo, error) {
+	return read(b, func(backend storage.Backend) (*storage.ArtifactInfo, error) {
+		return backend.ArtifactInfo(siteID, buildID)
+	})
+}
+
+func (b *Backend) ListArtifacts(siteID string) ([]string, error) {
+	return read(b, func(backend storage.Backend) ([]string, error) {
+		return backend.ListArtifacts(siteID)
+	})
+}
+
+func (b *Backend) CreateUploadSession(siteID, buildID string) (*storage.UploadSession, error) {
+	return b.primary.CreateUploadSession(siteID, buildID)
+}
+
+func (b *Backend) GetUploadSession(sessionID string) (*storage.UploadSession, error) {
+	return b.primary.GetUploadSession(sessionID)
+}
+
+func (b *Backend) PutUploadChunk(sessionID string, index int, reader io.Reader) error {
+	return b.primary.PutUploadChunk(sessionID, index, reader)
+}
+
+func (b *Backend) CommitUploadSession(sessionID, sha256 string) (*storage.UploadSession, error) {
+	session, err := b.primary.CommitUploadSession(sessionID, sha256)
+	if err != nil {
+		return nil, err
+	}
+	b.record(&Op{Kind: OpArtifact, SiteID: session.SiteID, BuildID: session.BuildID})
+	return session, nil
+}
+
+func (b *Backend) AbortUploadSession(sessionID string) error {
+	return b.primary.AbortUploadSession(sessionID)
+}
+
+func (b *Backend) PruneUploadSessions(maxAge time.Duration) (int, error) {
+	return b.primary.PruneUploadSessions(maxAge)
+}
+
+func (b *Backend) ReadManifest(siteID, buildID string) (*storage.ArtifactManifest, error) {
+	return read(b, func(backend storage.Backend) (*storage.ArtifactManifest, error) {
+		return backend.ReadManifest(siteID, buildID)
+	})
+}
+
+func (b *Backend) OpenBlob(hash string) (io.ReadCloser, error) {
+	// Blobs are named by content, so a manifest from either backend resolves on both
+	return read(b, func(backend storage.Backend) (io.ReadCloser, error) {
+		return backend.OpenBlob(hash)
+	})
+}
+
+func (b *Backend) StoreDocument(siteID, buildID, name string, data []byte) error {
+	if err := b.primary.StoreDocument(siteID, buildID, name, data); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpDocument, SiteID: siteID, BuildID: buildID, Name: name})
+	return nil
+}
+
+func (b *Backend) FetchDocument(siteID, buildID, name string) ([]byte, error) {
+	return read(b, func(backend storage.Backend) ([]byte, error) {
+		return backend.FetchDocument(siteID, buildID, name)
+	})
+}
+
+func (b *Backend) WriteLogEntry(siteID string, entry *storage.LogEntry) error {
+	if err := b.primary.WriteLogEntry(siteID, entry); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpLogEntry, SiteID: siteID, BuildID: entry.BuildID, LogEntry: entry})
+	return nil
+}
+
+func (b *Backend) ListVersions(siteID string, query storage.VersionQuery) (*storage.VersionPage, error) {
+	return read(b, func(backend storage.Backend) (*storage.VersionPage, error) {
+		return backend.ListVersions(siteID, query)
+	})
+}
+
+func (b *Backend) RecordLineage(siteID string, link *storage.LineageLink) error {
+	if err := b.primary.RecordLineage(siteID, link); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpLineage, SiteID: siteID, BuildID: link.BuildID, Lineage: link})
+	return nil
+}
+
+func (b *Backend) GetLineage(siteID, buildID string) (*storage.LineageLink, error) {
+	return read(b, func(backend storage.Backend) (*storage.LineageLink, error) {
+		return backend.GetLineage(siteID, buildID)
+	})
+}
+
+func (b *Backend) ListLineage(siteID string) ([]*storage.LineageLink, error) {
+	return read(b, func(backend storage.Backend) ([]*storage.LineageLink, error) {
+		return backend.ListLineage(siteID)
+	})
+}
+
+func (b *Backend) AppendSessionTurn(siteID string, turn *storage.SessionTurn) error {
+	if err := b.primary.AppendSessionTurn(siteID, turn); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpSessionTurn, SiteID: siteID, BuildID: turn.BuildID, Turn: turn})
+	return nil
+}
+
+func (b *Backend) ListSessionTurns(siteID string, limit int) ([]*storage.SessionTurn, error) {
+	return read(b, func(backend storage.Backend) ([]*storage.SessionTurn, error) {
+		return backend.ListSessionTurns(siteID, limit)
+	})
+}
+
+func (b *Backend) StoreAttachment(siteID, uploadID, name string, reader io.Reader) error {
+	if err := b.primary.StoreAttachment(siteID, uploadID, name, reader); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpAttachment, SiteID: siteID, UploadID: uploadID, Name: name})
+	return nil
+}
+
+func (b *Backend) FetchAttachment(siteID, uploadID, name string) (io.ReadCloser, error) {
+	return read(b, func(backend storage.Backend) (io.ReadCloser, error) {
+		return backend.FetchAttachment(siteID, uploadID, name)
+	})
+}
+
+func (b *Backend) ListAttachments(siteID, uploadID string) ([]*storage.Attachment, error) {
+	return read(b, func(backend storage.Backend) ([]*storage.Attachment, error) {
+		return backend.ListAttachments(siteID, uploadID)
+	})
+}
+
+func (b *Backend) DeleteArtifact(siteID, buildID string) error {
+	if err := b.primary.DeleteArtifact(siteID, buildID); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpDeleteArtifact, SiteID: siteID, BuildID: buildID})
+	return nil
+}
+
+func (b *Backend) DeleteSite(siteID string) error {
+	if err := b.primary.DeleteSite(siteID); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpDeleteSite, SiteID: siteID})
+	return nil
+}
+
+func (b *Backend) SetPinned(siteID, buildID string, pinned bool) error {
+	if err := b.primary.SetPinned(siteID, buildID, pinned); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpPin, SiteID: siteID, BuildID: buildID, Pinned: pinned})
+	return nil
+}
+
+func (b *Backend) ListPinned(siteID string) ([]string, error) {
+	return read(b, func(backend storage.Backend) ([]string, error) {
+		return backend.ListPinned(siteID)
+	})
+}
+
+func (b *Backend) SetOwner(siteID, ownerID string) error {
+	if err := b.primary.SetOwner(siteID, ownerID); err != nil {
+		return err
+	}
+	b.record(&Op{Kind: OpOwner, SiteID: siteID, OwnerID: ownerID})
+	return nil
+}
+
+func (b *Backend) GetOwner(siteID string) (string, error) {
+	return read(b, func(backend storage.Backend) (string, error) {
+		return backend.GetOwner(siteID)
+	})
+}
+
+func (b *Backend) ListSites() ([]string, error) {
+	return read(b, func(backend storage.Backend) ([]string, error) {
+		return backend.ListSites()
+	})
+}
+
+// PruneBlobs prunes both backends, each of which tracks its own references. It reports
+// what the primary removed; a failure on the secondary is only logged.
+func (b *Backend) PruneBlobs(grace time.Duration) (int, error) {
+	pruned, err := b.primary.PruneBlobs(grace)
+	if err != nil {
+		return pruned, err
+	}
+	if _, err := b.secondary.PruneBlobs(grace); err != nil {
+		log.Printf("Failed to prune blobs on secondary: %v", err)
+	}
+	return pruned, nil
+}
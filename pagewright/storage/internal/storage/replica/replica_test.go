@@ -0,0 +1,231 @@
+package replica
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage/nfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDown = errors.New("backend unavailable")
+
+// flakyBackend fails artifact reads and writes while down
+type flakyBackend struct {
+	storage.Backend
+	down bool
+}
+
+func (f *flakyBackend) StoreArtifact(siteID, buildID string, reader io.Reader) error {
+	if f.down {
+		return errDown
+	}
+	return f.Backend.StoreArtifact(siteID, buildID, reader)
+}
+
+func (f *flakyBackend) FetchArtifact(siteID, buildID string) (io.ReadCloser, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.Backend.FetchArtifact(siteID, buildID)
+}
+
+func newBackends(t *testing.T) (*flakyBackend, *flakyBackend, *Journal) {
+	primary, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	secondary, err := nfs.NewNFSBackend(t.TempDir())
+	require.NoError(t, err)
+	journal, err := OpenJournal(t.TempDir())
+	require.NoError(t, err)
+	return &flakyBackend{Backend: primary}, &flakyBackend{Backend: secondary}, journal
+}
+
+func readArtifact(t *testing.T, backend storage.Backend, siteID, buildID string) string {
+	reader, err := backend.FetchArtifact(siteID, buildID)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestReplicatesWrites(t *testing.T) {
+	primary, secondary, journal := newBackends(t)
+	backend := New(primary, secondary, journal)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, backend.StoreArtifact("site", "build-1", bytes.NewReader([]byte("v1"))))
+	require.NoError(t, backend.StoreArtifact("site", "build-2", bytes.NewReader([]byte("v2"))))
+	require.NoError(t, backend.StoreDocument("site", "build-1", storage.DocumentManifest, []byte(`{"prompt":"hi"}`)))
+	require.NoError(t, backend.WriteLogEntry("site", &storage.LogEntry{Timestamp: now, BuildID: "build-1", SiteID: "site", Action: "build", Status: "success"}))
+	require.NoError(t, backend.RecordLineage("site", &storage.LineageLink{BuildID: "build-2", ParentBuildID: "build-1", CreatedAt: now}))
+	require.NoError(t, backend.AppendSessionTurn("site", &storage.SessionTurn{Timestamp: now, JobID: "job-1", Prompt: "hi"}))
+	require.NoError(t, backend.StoreAttachment("site", "upload-1", "logo.png", bytes.NewReader([]byte("png"))))
+	require.NoError(t, backend.SetPinned("site", "build-1", true))
+	require.NoError(t, backend.SetOwner("site", "user-1"))
+	require.NoError(t, backend.DeleteArtifact("site", "build-2"))
+
+	// Nothing reaches the secondary until the journal is drained
+	_, err := secondary.FetchArtifact("site", "build-1")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+
+	require.NoError(t, backend.Drain())
+	ops, err := journal.Pending()
+	require.NoError(t, err)
+	assert.Empty(t, ops)
+
+	assert.Equal(t, "v1", readArtifact(t, secondary, "site", "build-1"))
+	builds, err := secondary.ListArtifacts("site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1"}, builds)
+
+	document, err := secondary.FetchDocument("site", "build-1", storage.DocumentManifest)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"prompt":"hi"}`, string(document))
+
+	page, err := secondary.ListVersions("site", storage.VersionQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Versions, 1)
+	assert.Equal(t, "build-1", page.Versions[0].BuildID)
+
+	links, err := secondary.ListLineage("site")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "build-1", links[0].ParentBuildID)
+
+	turns, err := secondary.ListSessionTurns("site", 0)
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+
+	attachment, err := secondary.FetchAttachment("site", "upload-1", "logo.png")
+	require.NoError(t, err)
+	attachment.Close()
+
+	pinned, err := secondary.ListPinned("site")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build-1"}, pinned)
+
+	owner, err := secondary.GetOwner("site")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", owner)
+}
+
+func TestRetriesFailedOps(t *testing.T) {
+	primary, secondary, journal := newBackends(t)
+	backend := New(primary, secondary, journal)
+
+	secondary.down = true
+	require.NoError(t, backend.StoreArtifact("site", "build-1", bytes.NewReader([]byte("v1"))))
+	require.NoError(t, backend.SetOwner("site", "user-1"))
+
+	assert.ErrorIs(t, backend.Drain(), errDown)
+	assert.ErrorIs(t, backend.Drain(), errDown)
+
+	// The failed op stays first, so the owner is not written ahead of it
+	ops, err := journal.Pending()
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	assert.Equal(t, OpArtifact, ops[0].Kind)
+	assert.Equal(t, 2, ops[0].Attempts)
+	assert.Contains(t, ops[0].LastError, errDown.Error())
+	owner, err := secondary.GetOwner("site")
+	require.NoError(t, err)
+	assert.Empty(t, owner)
+
+	// A restart picks the journal up where it stopped
+	journal, err = OpenJournal(journal.dir)
+	require.NoError(t, err)
+	backend = New(primary, secondary, journal)
+
+	secondary.down = false
+	require.NoError(t, backend.Drain())
+	assert.Equal(t, "v1", readArtifact(t, secondary, "site", "build-1"))
+	owner, err = secondary.GetOwner("site")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", owner)
+}
+
+func TestParksOpsThatKeepFailing(t *testing.T) {
+	primary, secondary, journal := newBackends(t)
+	backend := New(primary, secondary, journal)
+
+	secondary.down = true
+	require.NoError(t, backend.StoreArtifact("site", "build-1", bytes.NewReader([]byte("v1"))))
+	require.NoError(t, backend.SetOwner("site", "user-1"))
+
+	for i := 1; i < maxAttempts; i++ {
+		assert.ErrorIs(t, backend.Drain(), errDown)
+	}
+
+	// The last attempt parks the artifact op and the owner goes through
+	require.NoError(t, backend.Drain())
+	ops, err := journal.Pending()
+	require.NoError(t, err)
+	assert.Empty(t, ops)
+	owner, err := secondary.GetOwner("site")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", owner)
+	assert.FileExists(t, filepath.Join(journal.dir, "parked", "00000000000000000001.json"))
+}
+
+func TestSkipsAttachmentsDeletedSince(t *testing.T) {
+	primary, secondary, journal := newBackends(t)
+	backend := New(primary, secondary, journal)
+
+	require.NoError(t, backend.StoreAttachment("site", "upload-1", "logo.png", bytes.NewReader([]byte("png"))))
+	require.NoError(t, primary.DeleteSite("site"))
+	require.NoError(t, backend.SetOwner("other", "user-1"))
+
+	require.NoError(t, backend.Drain())
+	_, err := secondary.FetchAttachment("site", "upload-1", "logo.png")
+	assert.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+	owner, err := secondary.GetOwner("other")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", owner)
+}
+
+func TestReadsFallBackToSecondary(t *testing.T) {
+	primary, secondary, journal := newBackends(t)
+	backend := New(primary, secondary, journal)
+
+	require.NoError(t, backend.StoreArtifact("site", "build-1", bytes.NewReader([]byte("v1"))))
+	require.NoError(t, backend.Drain())
+
+	primary.down = true
+	assert.Equal(t, "v1", readArtifact(t, backend, "site", "build-1"))
+
+	// Writes are not redirected
+	assert.ErrorIs(t, backend.StoreArtifact("site", "build-2", bytes.NewReader([]byte("v2"))), errDown)
+
+	// The primary's answer is final when it has one, even if the secondary lags behind
+	primary.down = false
+	require.NoError(t, secondary.StoreArtifact("site", "build-3", bytes.NewReader([]byte("stale"))))
+	_, err := backend.FetchArtifact("site", "build-3")
+	assert.ErrorIs(t, err, storage.ErrArtifactNotFound)
+
+	// With both failing the primary's error is returned
+	primary.down = true
+	secondary.down = true
+	_, err = backend.FetchArtifact("site", "build-1")
+	assert.ErrorIs(t, err, errDown)
+}
+
+func TestReplicatesCommittedUploads(t *testing.T) {
+	primary, secondary, journal := newBackends(t)
+	backend := New(primary, secondary, journal)
+
+	session, err := backend.CreateUploadSession("site", "build-1")
+	require.NoError(t, err)
+	require.NoError(t, backend.PutUploadChunk(session.SessionID, 0, bytes.NewReader([]byte("v1"))))
+	_, err = backend.CommitUploadSession(session.SessionID, "3bfc269594ef649228e9a74bab00f042efc91d5acc6fbee31a382e80d42388fe")
+	require.NoError(t, err)
+
+	require.NoError(t, backend.Drain())
+	assert.Equal(t, "v1", readArtifact(t, secondary, "site", "build-1"))
+}
@@ -0,0 +1,207 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-process S3 endpoint with path-style addressing. It implements the
+// calls the backend makes: object PUT/GET/HEAD/DELETE, ListObjectsV2 and multipart uploads.
+type fakeS3 struct {
+	t         *testing.T
+	bucket    string
+	accessKey string
+	pageSize  int
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	modTimes map[string]time.Time
+	uploads  map[string]map[int][]byte
+	nextID   int
+	requests map[string]int // "METHOD kind" -> count
+}
+
+func newFakeS3(t *testing.T, bucket, accessKey string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{
+		t:         t,
+		bucket:    bucket,
+		accessKey: accessKey,
+		pageSize:  1000,
+		objects:   make(map[string][]byte),
+		modTimes:  make(map[string]time.Time),
+		uploads:   make(map[string]map[int][]byte),
+		requests:  make(map[string]int),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeS3) count(kind string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[kind]
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+f.accessKey+"/") ||
+		r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		writeError(w, http.StatusForbidden, "AccessDenied", "request is not signed")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", bucket)
+		return
+	}
+
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		if r.ContentLength < 0 {
+			writeError(w, http.StatusLengthRequired, "MissingContentLength", "Content-Length is required")
+			return
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodGet && query.Get("list-type") == "2":
+		f.requests["GET list"]++
+		after := query.Get("start-after")
+		if token := query.Get("continuation-token"); token > after {
+			after = token
+		}
+		f.list(w, query.Get("prefix"), after)
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.requests["POST create"]++
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		writeXML(w, initiateMultipartUploadResult{UploadID: id})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.requests["PUT part"]++
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", query.Get("uploadId"))
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, query.Get("uploadId"), number))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.requests["POST complete"]++
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", query.Get("uploadId"))
+			return
+		}
+		var complete completeMultipartUpload
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML", err.Error())
+			return
+		}
+		var data []byte
+		for _, part := range complete.Parts {
+			data = append(data, parts[part.PartNumber]...)
+		}
+		f.objects[key] = data
+		f.modTimes[key] = time.Now().UTC()
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Key     string   `xml:"Key"`
+		}{Key: key})
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.requests["DELETE upload"]++
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		f.requests["PUT object"]++
+		f.objects[key] = body
+		f.modTimes[key] = time.Now().UTC()
+
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		f.requests[r.Method+" object"]++
+		data, ok := f.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", key)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case r.Method == http.MethodDelete:
+		f.requests["DELETE object"]++
+		delete(f.objects, key)
+		delete(f.modTimes, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" "+r.URL.String())
+	}
+}
+
+// list serves ListObjectsV2; the continuation token is the last key of the previous page,
+// so both it and start-after list the keys after a given one
+func (f *fakeS3) list(w http.ResponseWriter, prefix, after string) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := struct {
+		XMLName xml.Name `xml:"ListBucketResult"`
+		listBucketResult
+	}{}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, object{Key: key, Size: int64(len(f.objects[key])), LastModified: f.modTimes[key]})
+	}
+	writeXML(w, result)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		errorResponse
+	}{errorResponse: errorResponse{Code: code, Message: message}})
+}
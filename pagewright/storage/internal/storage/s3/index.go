@@ -0,0 +1,167 @@
+package s3
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// The version index holds one object per log entry, keyed so that a listing returns the
+// newest first and carries the action and status:
+//
+//	sites/{site}/version-index/{MaxInt64 - unix nanos}/{action}/{status}/{build}.json
+//
+// Filters and pages are resolved from the keys alone; only the versions returned are read.
+// Log entries stay the source of truth: a site without the ready marker is indexed from
+// them first.
+
+func versionIndexPrefix(siteID string) string {
+	return fmt.Sprintf("sites/%s/version-index/", siteID)
+}
+
+func versionIndexMarker(siteID string) string {
+	return fmt.Sprintf("sites/%s/version-index.ready", siteID)
+}
+
+func versionIndexKey(siteID string, version *storage.Version) string {
+	return versionIndexPrefix(siteID) + fmt.Sprintf("%019d/%s/%s/%s.json",
+		math.MaxInt64-version.Timestamp.UnixNano(),
+		url.PathEscape(version.Action), url.PathEscape(version.Status), url.PathEscape(version.BuildID))
+}
+
+// parseVersionIndexKey recovers what an index key records about its version
+func parseVersionIndexKey(siteID, key string) (*storage.Version, bool) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, versionIndexPrefix(siteID)), ".json"), "/")
+	if len(parts) != 4 {
+		return nil, false
+	}
+	inverted, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	version := &storage.Version{Timestamp: time.Unix(0, math.MaxInt64-inverted).UTC()}
+	for i, field := range []*string{&version.Action, &version.Status, &version.BuildID} {
+		if *field, err = url.PathUnescape(parts[i+1]); err != nil {
+			return nil, false
+		}
+	}
+	return version, true
+}
+
+// ensureVersionIndex indexes the log entries of a site written before the index existed
+func (s *S3Backend) ensureVersionIndex(siteID string) error {
+	ready, err := s.exists(versionIndexMarker(siteID))
+	if err != nil {
+		return fmt.Errorf("failed to check version index: %w", err)
+	}
+	if ready {
+		return nil
+	}
+
+	objects, err := s.client.listObjects(fmt.Sprintf("sites/%s/logs/", siteID))
+	if err != nil {
+		return fmt.Errorf("failed to list log entries: %w", err)
+	}
+	if len(objects) == 0 {
+		return nil // Nothing to index; the marker would make an empty site appear
+	}
+
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, ".json") {
+			continue
+		}
+		var logEntry storage.LogEntry
+		if err := s.getJSON(obj.Key, &logEntry); err != nil {
+			continue // Skip unreadable or malformed entries
+		}
+		version := storage.VersionFromLog(&logEntry)
+		if err := s.putJSON(versionIndexKey(siteID, version), version); err != nil {
+			return err
+		}
+	}
+
+	return s.putJSON(versionIndexMarker(siteID), map[string]time.Time{"indexed_at": time.Now().UTC()})
+}
+
+// ListVersions returns a page of the site's versions, newest first, from the version index
+func (s *S3Backend) ListVersions(siteID string, query storage.VersionQuery) (*storage.VersionPage, error) {
+	if err := s.ensureVersionIndex(siteID); err != nil {
+		return nil, err
+	}
+
+	prefix := versionIndexPrefix(siteID)
+	startAfter := ""
+	if !query.Until.IsZero() {
+		startAfter = prefix + fmt.Sprintf("%019d", math.MaxInt64-query.Until.UnixNano())
+	}
+	if query.Cursor != "" {
+		key, err := storage.DecodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil, fmt.Errorf("%w: %q", storage.ErrInvalidCursor, query.Cursor)
+		}
+		if key > startAfter {
+			startAfter = key
+		}
+	}
+
+	var keys []string
+	page := &storage.VersionPage{Versions: []*storage.Version{}}
+	err := s.client.listObjectsAfter(prefix, startAfter, func(obj object) bool {
+		version, ok := parseVersionIndexKey(siteID, obj.Key)
+		if !ok {
+			return true
+		}
+		if !query.Since.IsZero() && version.Timestamp.Before(query.Since) {
+			return false
+		}
+		if !query.Matches(version) {
+			return true
+		}
+		if query.Limit > 0 && len(keys) == query.Limit {
+			page.NextCursor = storage.EncodeCursor(keys[len(keys)-1])
+			return false
+		}
+		keys = append(keys, obj.Key)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list version index: %w", err)
+	}
+
+	for _, key := range keys {
+		var version storage.Version
+		if err := s.getJSON(key, &version); err != nil {
+			continue // Deleted since the listing
+		}
+		page.Versions = append(page.Versions, &version)
+	}
+
+	return page, nil
+}
+
+// removeFromVersionIndex deletes the index objects of buildID
+func (s *S3Backend) removeFromVersionIndex(siteID, buildID string) error {
+	objects, err := s.client.listObjects(versionIndexPrefix(siteID))
+	if err != nil {
+		return fmt.Errorf("failed to list version index: %w", err)
+	}
+	for _, obj := range objects {
+		version, ok := parseVersionIndexKey(siteID, obj.Key)
+		if !ok || version.BuildID != buildID {
+			continue
+		}
+		if err := s.client.deleteObject(obj.Key); err != nil {
+			return fmt.Errorf("failed to delete version index entry: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,654 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// defaultPartSize is the multipart upload part size; S3 requires at least 5 MiB
+const defaultPartSize = 8 << 20
+
+// S3Backend stores everything as objects in one bucket, using the same layout as the
+// NFS backend: blobs/{hash[:2]}/{hash} and sites/{site_id}/...
+type S3Backend struct {
+	client   *client
+	partSize int64
+}
+
+// Config holds the settings of an S3-compatible endpoint
+type Config struct {
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+func NewS3Backend(cfg Config) (*S3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+
+	c, err := newClient(cfg.Endpoint, cfg.Bucket, cfg.Region, cfg.AccessKeyID, cfg.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Backend{
+		client:   c,
+		partSize: defaultPartSize,
+	}, nil
+}
+
+// StoreArtifact splits the artifact into content-addressed blobs and records the version
+// as a manifest object
+func (s *S3Backend) StoreArtifact(siteID, buildID string, reader io.Reader) error {
+	manifest, err := storage.SplitArtifact(reader, s)
+	if err != nil {
+		return fmt.Errorf("failed to split artifact: %w", err)
+	}
+	manifest.SiteID = siteID
+	manifest.BuildID = buildID
+	manifest.CreatedAt = time.Now().UTC()
+
+	return s.putJSON(manifestKey(siteID, buildID), manifest)
+}
+
+// FetchArtifact rebuilds the tar.gz from the version's manifest, streaming blobs from S3
+func (s *S3Backend) FetchArtifact(siteID, buildID string) (io.ReadCloser, error) {
+	manifest, err := s.ReadManifest(siteID, buildID)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(storage.WriteArtifact(pw, manifest, s))
+	}()
+
+	return pr, nil
+}
+
+func (s *S3Backend) ArtifactInfo(siteID, buildID string) (*storage.ArtifactInfo, error) {
+	manifest, err := s.ReadManifest(siteID, buildID)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Info(), nil
+}
+
+// ReadManifest returns a version's manifest. Every S3 version has one: the backend was
+// added after full archives were retired.
+func (s *S3Backend) ReadManifest(siteID, buildID string) (*storage.ArtifactManifest, error) {
+	var manifest storage.ArtifactManifest
+	if err := s.getJSON(manifestKey(siteID, buildID), &manifest); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+		}
+		return nil, fmt.Errorf("failed to read artifact manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func (s *S3Backend) ListArtifacts(siteID string) ([]string, error) {
+	prefix := fmt.Sprintf("sites/%s/artifacts/", siteID)
+	objects, err := s.client.listObjects(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+
+	buildIDs := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if strings.HasSuffix(name, ".json") && !strings.Contains(name, "/") {
+			buildIDs = append(buildIDs, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return buildIDs, nil
+}
+
+// PutBlob spools r to a temporary file to learn its hash, then uploads it unless the
+// bucket already has it. Blobs larger than one part use a multipart upload.
+func (s *S3Backend) PutBlob(r io.Reader) (string, int64, error) {
+	tmpFile, err := os.CreateTemp("", "pagewright-blob-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hasher), r)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %w", err)
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	key := blobKey(hash)
+
+	if _, err := s.client.headObject(key); err == nil {
+		// S3 cannot touch an object, so a marker tells PruneBlobs the blob is in use again
+		if err := s.client.putObject(blobRefKey(hash), bytes.NewReader(nil), 0); err != nil {
+			return "", 0, fmt.Errorf("failed to mark blob: %w", err)
+		}
+		return hash, size, nil
+	} else if !errors.Is(err, errNotFound) {
+		return "", 0, fmt.Errorf("failed to check blob: %w", err)
+	}
+
+	if size <= s.partSize {
+		if err := s.client.putObject(key, io.NewSectionReader(tmpFile, 0, size), size); err != nil {
+			return "", 0, fmt.Errorf("failed to upload blob: %w", err)
+		}
+		return hash, size, nil
+	}
+
+	if err := s.multipartUpload(key, tmpFile, size); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+func (s *S3Backend) multipartUpload(key string, file io.ReaderAt, size int64) error {
+	uploadID, err := s.client.createMultipartUpload(key)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	var parts []completedPart
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+s.partSize, number+1 {
+		partSize := s.partSize
+		if offset+partSize > size {
+			partSize = size - offset
+		}
+
+		etag, err := s.client.uploadPart(key, uploadID, number, io.NewSectionReader(file, offset, partSize), partSize)
+		if err != nil {
+			s.client.abortMultipartUpload(key, uploadID)
+			return fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		parts = append(parts, completedPart{PartNumber: number, ETag: etag})
+	}
+
+	if err := s.client.completeMultipartUpload(key, uploadID, parts); err != nil {
+		s.client.abortMultipartUpload(key, uploadID)
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3Backend) OpenBlob(hash string) (io.ReadCloser, error) {
+	if !storage.ValidBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash: %q", hash)
+	}
+
+	reader, err := s.client.getObject(blobKey(hash))
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("blob not found: %s", hash)
+		}
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+
+	return reader, nil
+}
+
+func (s *S3Backend) WriteLogEntry(siteID string, entry *storage.LogEntry) error {
+	// Index the entries written so far before adding one, so none is left out
+	if err := s.ensureVersionIndex(siteID); err != nil {
+		return err
+	}
+
+	// Same naming scheme as the NFS backend: sortable by time, unique per build
+	timestamp := entry.Timestamp.UTC().Format("20060102-150405.000000")
+	key := fmt.Sprintf("sites/%s/logs/%s-%s.json", siteID, timestamp, entry.BuildID)
+
+	if err := s.putJSON(key, entry); err != nil {
+		return err
+	}
+
+	version := storage.VersionFromLog(entry)
+	return s.putJSON(versionIndexKey(siteID, version), version)
+}
+
+func (s *S3Backend) AppendSessionTurn(siteID string, turn *storage.SessionTurn) error {
+	timestamp := turn.Timestamp.UTC().Format("20060102-150405.000000")
+	key := fmt.Sprintf("sites/%s/session/%s-%s.json", siteID, timestamp, turn.JobID)
+
+	return s.putJSON(key, turn)
+}
+
+func (s *S3Backend) RecordLineage(siteID string, link *storage.LineageLink) error {
+	return s.putJSON(fmt.Sprintf("sites/%s/lineage/%s.json", siteID, link.BuildID), link)
+}
+
+func (s *S3Backend) GetLineage(siteID, buildID string) (*storage.LineageLink, error) {
+	var link storage.LineageLink
+	if err := s.getJSON(fmt.Sprintf("sites/%s/lineage/%s.json", siteID, buildID), &link); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s/%s", storage.ErrLineageNotFound, siteID, buildID)
+		}
+		return nil, fmt.Errorf("failed to read lineage link: %w", err)
+	}
+	return &link, nil
+}
+
+func (s *S3Backend) ListLineage(siteID string) ([]*storage.LineageLink, error) {
+	objects, err := s.client.listObjects(fmt.Sprintf("sites/%s/lineage/", siteID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lineage: %w", err)
+	}
+
+	links := make([]*storage.LineageLink, 0, len(objects))
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, ".json") {
+			continue
+		}
+
+		var link storage.LineageLink
+		if err := s.getJSON(obj.Key, &link); err != nil {
+			continue // Skip unreadable or malformed links
+		}
+		links = append(links, &link)
+	}
+
+	return links, nil
+}
+
+func (s *S3Backend) ListSessionTurns(siteID string, limit int) ([]*storage.SessionTurn, error) {
+	objects, err := s.client.listObjects(fmt.Sprintf("sites/%s/session/", siteID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session turns: %w", err)
+	}
+
+	turns := make([]*storage.SessionTurn, 0, len(objects))
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, ".json") {
+			continue
+		}
+
+		var turn storage.SessionTurn
+		if err := s.getJSON(obj.Key, &turn); err != nil {
+			continue // Skip unreadable or malformed turns
+		}
+		turns = append(turns, &turn)
+	}
+
+	// Oldest first, so the history reads as a conversation
+	sort.Slice(turns, func(i, j int) bool {
+		return turns[i].Timestamp.Before(turns[j].Timestamp)
+	})
+
+	if limit > 0 && len(turns) > limit {
+		turns = turns[len(turns)-limit:]
+	}
+
+	return turns, nil
+}
+
+func (s *S3Backend) StoreDocument(siteID, buildID, name string, data []byte) error {
+	if err := s.client.putObject(documentKey(siteID, buildID, name), bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to write document: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Backend) FetchDocument(siteID, buildID, name string) ([]byte, error) {
+	reader, err := s.client.getObject(documentKey(siteID, buildID, name))
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s/%s/%s", storage.ErrDocumentNotFound, siteID, buildID, name)
+		}
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return data, nil
+}
+
+func (s *S3Backend) StoreAttachment(siteID, uploadID, name string, reader io.Reader) error {
+	tmpFile, err := os.CreateTemp("", "pagewright-attachment-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	// S3 needs the length up front
+	size, err := io.Copy(tmpFile, reader)
+	if err != nil {
+		return fmt.Errorf("failed to write attachment: %w", err)
+	}
+
+	if err := s.client.putObject(attachmentKey(siteID, uploadID, name), io.NewSectionReader(tmpFile, 0, size), size); err != nil {
+		return fmt.Errorf("failed to upload attachment: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Backend) FetchAttachment(siteID, uploadID, name string) (io.ReadCloser, error) {
+	reader, err := s.client.getObject(attachmentKey(siteID, uploadID, name))
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s/%s/%s", storage.ErrAttachmentNotFound, siteID, uploadID, name)
+		}
+		return nil, fmt.Errorf("failed to open attachment: %w", err)
+	}
+
+	return reader, nil
+}
+
+func (s *S3Backend) ListAttachments(siteID, uploadID string) ([]*storage.Attachment, error) {
+	prefix := attachmentKey(siteID, uploadID, "")
+	objects, err := s.client.listObjects(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	attachments := make([]*storage.Attachment, 0, len(objects))
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		attachments = append(attachments, &storage.Attachment{
+			Name: name,
+			Size: obj.Size,
+		})
+	}
+
+	// Listings are already sorted by key
+	return attachments, nil
+}
+
+func (s *S3Backend) DeleteArtifact(siteID, buildID string) error {
+	pinned, err := s.exists(pinKey(siteID, buildID))
+	if err != nil {
+		return fmt.Errorf("failed to check pin: %w", err)
+	}
+	if pinned {
+		return fmt.Errorf("%w: %s/%s", storage.ErrPinned, siteID, buildID)
+	}
+
+	found, err := s.exists(manifestKey(siteID, buildID))
+	if err != nil {
+		return fmt.Errorf("failed to check artifact: %w", err)
+	}
+	if found {
+		if err := s.client.deleteObject(manifestKey(siteID, buildID)); err != nil {
+			return fmt.Errorf("failed to delete artifact: %w", err)
+		}
+	}
+
+	documents, err := s.client.listObjects(documentKey(siteID, buildID, ""))
+	if err != nil {
+		return fmt.Errorf("failed to list version documents: %w", err)
+	}
+	for _, obj := range documents {
+		if err := s.client.deleteObject(obj.Key); err != nil {
+			return fmt.Errorf("failed to delete version document: %w", err)
+		}
+		found = true
+	}
+
+	// Drop the version's log entries too, so it leaves the version list
+	objects, err := s.client.listObjects(fmt.Sprintf("sites/%s/logs/", siteID))
+	if err != nil {
+		return fmt.Errorf("failed to list log entries: %w", err)
+	}
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, "-"+buildID+".json") {
+			continue
+		}
+		var logEntry storage.LogEntry
+		if s.getJSON(obj.Key, &logEntry) != nil || logEntry.BuildID != buildID {
+			continue
+		}
+		if err := s.client.deleteObject(obj.Key); err != nil {
+			return fmt.Errorf("failed to delete log entry: %w", err)
+		}
+		found = true
+	}
+
+	if err := s.removeFromVersionIndex(siteID, buildID); err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+	}
+	return nil
+}
+
+func (s *S3Backend) DeleteSite(siteID string) error {
+	objects, err := s.client.listObjects(fmt.Sprintf("sites/%s/", siteID))
+	if err != nil {
+		return fmt.Errorf("failed to list site objects: %w", err)
+	}
+
+	for _, obj := range objects {
+		if err := s.client.deleteObject(obj.Key); err != nil && !errors.Is(err, errNotFound) {
+			return fmt.Errorf("failed to delete site: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *S3Backend) SetPinned(siteID, buildID string, pinned bool) error {
+	if !pinned {
+		if err := s.client.deleteObject(pinKey(siteID, buildID)); err != nil && !errors.Is(err, errNotFound) {
+			return fmt.Errorf("failed to unpin version: %w", err)
+		}
+		return nil
+	}
+
+	found, err := s.exists(manifestKey(siteID, buildID))
+	if err != nil {
+		return fmt.Errorf("failed to check artifact: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s/%s", storage.ErrArtifactNotFound, siteID, buildID)
+	}
+
+	data := []byte(time.Now().UTC().Format(time.RFC3339))
+	if err := s.client.putObject(pinKey(siteID, buildID), bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to pin version: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Backend) ListPinned(siteID string) ([]string, error) {
+	prefix := pinKey(siteID, "")
+	objects, err := s.client.listObjects(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pins: %w", err)
+	}
+
+	pinned := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		pinned = append(pinned, strings.TrimPrefix(obj.Key, prefix))
+	}
+	return pinned, nil
+}
+
+func (s *S3Backend) SetOwner(siteID, ownerID string) error {
+	data := []byte(ownerID)
+	if err := s.client.putObject(fmt.Sprintf("sites/%s/owner", siteID), bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to record site owner: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Backend) GetOwner(siteID string) (string, error) {
+	reader, err := s.client.getObject(fmt.Sprintf("sites/%s/owner", siteID))
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read site owner: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read site owner: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s *S3Backend) ListSites() ([]string, error) {
+	objects, err := s.client.listObjects("sites/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sites: %w", err)
+	}
+
+	// Keys are sorted, so each site's objects are contiguous
+	var sites []string
+	for _, obj := range objects {
+		siteID, _, _ := strings.Cut(strings.TrimPrefix(obj.Key, "sites/"), "/")
+		if siteID != "" && (len(sites) == 0 || sites[len(sites)-1] != siteID) {
+			sites = append(sites, siteID)
+		}
+	}
+	return sites, nil
+}
+
+func (s *S3Backend) PruneBlobs(grace time.Duration) (int, error) {
+	// Collect every referenced blob before listing the blobs
+	siteObjects, err := s.client.listObjects("sites/")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list manifests: %w", err)
+	}
+	referenced := make(map[string]bool)
+	for _, obj := range siteObjects {
+		parts := strings.Split(obj.Key, "/")
+		if len(parts) != 4 || parts[2] != "artifacts" || !strings.HasSuffix(parts[3], ".json") {
+			continue
+		}
+
+		var manifest storage.ArtifactManifest
+		if err := s.getJSON(obj.Key, &manifest); err != nil {
+			if errors.Is(err, errNotFound) {
+				continue // Deleted meanwhile
+			}
+			// Keep everything rather than risk deleting blobs of an unreadable version
+			return 0, fmt.Errorf("failed to read manifest %s: %w", obj.Key, err)
+		}
+		for _, hash := range manifest.Blobs() {
+			referenced[hash] = true
+		}
+	}
+
+	// Blobs reused by an upload within the grace period are kept; older markers go
+	cutoff := time.Now().Add(-grace)
+	marks, err := s.client.listObjects("blob-refs/")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blob markers: %w", err)
+	}
+	for _, obj := range marks {
+		if obj.LastModified.After(cutoff) {
+			referenced[strings.TrimPrefix(obj.Key, "blob-refs/")] = true
+		} else if err := s.client.deleteObject(obj.Key); err != nil && !errors.Is(err, errNotFound) {
+			return 0, fmt.Errorf("failed to delete blob marker: %w", err)
+		}
+	}
+
+	blobs, err := s.client.listObjects("blobs/")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	removed := 0
+	for _, obj := range blobs {
+		hash := obj.Key[strings.LastIndex(obj.Key, "/")+1:]
+		if referenced[hash] || obj.LastModified.After(cutoff) {
+			continue
+		}
+		if err := s.client.deleteObject(obj.Key); err != nil && !errors.Is(err, errNotFound) {
+			return removed, fmt.Errorf("failed to delete blob: %w", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// exists reports whether an object is present
+func (s *S3Backend) exists(key string) (bool, error) {
+	if _, err := s.client.headObject(key); err != nil {
+		if errors.Is(err, errNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *S3Backend) putJSON(key string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+
+	if err := s.client.putObject(key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Backend) getJSON(key string, v interface{}) error {
+	reader, err := s.client.getObject(key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return nil
+}
+
+func manifestKey(siteID, buildID string) string {
+	return fmt.Sprintf("sites/%s/artifacts/%s.json", siteID, buildID)
+}
+
+// documentKey returns the key of a version document; an empty name gives the version's prefix
+func documentKey(siteID, buildID, name string) string {
+	if name == "" {
+		return fmt.Sprintf("sites/%s/versions/%s/", siteID, buildID)
+	}
+	return fmt.Sprintf("sites/%s/versions/%s/%s.json", siteID, buildID, name)
+}
+
+func attachmentKey(siteID, uploadID, name string) string {
+	return fmt.Sprintf("sites/%s/attachments/%s/%s", siteID, uploadID, name)
+}
+
+func pinKey(siteID, buildID string) string {
+	return fmt.Sprintf("sites/%s/pins/%s", siteID, buildID)
+}
+
+func blobKey(hash string) string {
+	return fmt.Sprintf("blobs/%s/%s", hash[:2], hash)
+}
+
+// blobRefKey marks when an upload last reused an existing blob
+func blobRefKey(hash string) string {
+	return "blob-refs/" + hash
+}
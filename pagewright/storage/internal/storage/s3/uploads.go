@@ -0,0 +1,193 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/storage/internal/storage"
+)
+
+// Upload sessions mirror the NFS layout: upload-sessions/{session_id}/session.json and
+// upload-sessions/{session_id}/chunks/{index}
+
+func uploadSessionPrefix(sessionID string) string {
+	return fmt.Sprintf("upload-sessions/%s/", sessionID)
+}
+
+func (s *S3Backend) CreateUploadSession(siteID, buildID string) (*storage.UploadSession, error) {
+	sessionID, err := storage.NewUploadSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	session := &storage.UploadSession{
+		SessionID: sessionID,
+		SiteID:    siteID,
+		BuildID:   buildID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Chunks:    []storage.UploadChunk{},
+	}
+	if err := s.putJSON(uploadSessionPrefix(sessionID)+"session.json", session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *S3Backend) GetUploadSession(sessionID string) (*storage.UploadSession, error) {
+	prefix := uploadSessionPrefix(sessionID)
+
+	var session storage.UploadSession
+	if err := s.getJSON(prefix+"session.json", &session); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrUploadSessionNotFound, sessionID)
+		}
+		return nil, fmt.Errorf("failed to read upload session: %w", err)
+	}
+
+	objects, err := s.client.listObjects(prefix + "chunks/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+	session.Chunks = []storage.UploadChunk{}
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix+"chunks/")
+		index, err := strconv.Atoi(name)
+		if err != nil || name != storage.ChunkName(index) {
+			continue
+		}
+		session.Chunks = append(session.Chunks, storage.UploadChunk{Index: index, Size: obj.Size})
+		if modTime := obj.LastModified.UTC(); modTime.After(session.UpdatedAt) {
+			session.UpdatedAt = modTime
+		}
+	}
+	sort.Slice(session.Chunks, func(i, j int) bool { return session.Chunks[i].Index < session.Chunks[j].Index })
+
+	return &session, nil
+}
+
+// PutUploadChunk spools the chunk first: S3 needs the length up front, and a dropped
+// request then never reaches the bucket
+func (s *S3Backend) PutUploadChunk(sessionID string, index int, reader io.Reader) error {
+	found, err := s.exists(uploadSessionPrefix(sessionID) + "session.json")
+	if err != nil {
+		return fmt.Errorf("failed to check upload session: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", storage.ErrUploadSessionNotFound, sessionID)
+	}
+
+	tmpFile, err := os.CreateTemp("", "pagewright-chunk-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size, err := io.Copy(tmpFile, reader)
+	if err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	key := uploadSessionPrefix(sessionID) + "chunks/" + storage.ChunkName(index)
+	if err := s.client.putObject(key, io.NewSectionReader(tmpFile, 0, size), size); err != nil {
+		return fmt.Errorf("failed to upload chunk: %w", err)
+	}
+	return nil
+}
+
+// CommitUploadSession streams the chunks from the bucket into StoreArtifact. The manifest is
+// only written once the digest checked out, so a mismatch stores nothing.
+func (s *S3Backend) CommitUploadSession(sessionID, sha256 string) (*storage.UploadSession, error) {
+	session, err := s.GetUploadSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if missing := session.Missing(); missing >= 0 {
+		return nil, fmt.Errorf("%w: chunk %d", storage.ErrUploadIncomplete, missing)
+	}
+
+	prefix := uploadSessionPrefix(sessionID)
+	chunks := storage.JoinChunks(len(session.Chunks), func(index int) (io.ReadCloser, error) {
+		return s.client.getObject(prefix + "chunks/" + storage.ChunkName(index))
+	}, sha256)
+	defer chunks.Close()
+
+	if err := s.StoreArtifact(session.SiteID, session.BuildID, chunks); err != nil {
+		return nil, err
+	}
+
+	if err := s.deletePrefix(prefix); err != nil {
+		return nil, fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *S3Backend) AbortUploadSession(sessionID string) error {
+	found, err := s.exists(uploadSessionPrefix(sessionID) + "session.json")
+	if err != nil {
+		return fmt.Errorf("failed to check upload session: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", storage.ErrUploadSessionNotFound, sessionID)
+	}
+
+	if err := s.deletePrefix(uploadSessionPrefix(sessionID)); err != nil {
+		return fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	return nil
+}
+
+// PruneUploadSessions ages sessions by their newest object, so no session.json is read
+func (s *S3Backend) PruneUploadSessions(maxAge time.Duration) (int, error) {
+	objects, err := s.client.listObjects("upload-sessions/")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list upload sessions: %w", err)
+	}
+
+	updated := make(map[string]time.Time)
+	for _, obj := range objects {
+		sessionID, _, ok := strings.Cut(strings.TrimPrefix(obj.Key, "upload-sessions/"), "/")
+		if !ok || !storage.ValidUploadSessionID(sessionID) {
+			continue
+		}
+		if obj.LastModified.After(updated[sessionID]) {
+			updated[sessionID] = obj.LastModified
+		}
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	pruned := 0
+	for sessionID, lastModified := range updated {
+		if lastModified.After(cutoff) {
+			continue
+		}
+		if err := s.deletePrefix(uploadSessionPrefix(sessionID)); err != nil {
+			return pruned, fmt.Errorf("failed to remove upload session: %w", err)
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+func (s *S3Backend) deletePrefix(prefix string) error {
+	objects, err := s.client.listObjects(prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.client.deleteObject(obj.Key); err != nil && !errors.Is(err, errNotFound) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"time"
+)
+
+var (
+	// ErrUploadSessionNotFound is returned for an unknown, committed or expired upload session
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+
+	// ErrUploadIncomplete is returned when committing a session with missing chunks
+	ErrUploadIncomplete = errors.New("upload is missing chunks")
+
+	// ErrUploadDigestMismatch is returned when the joined chunks do not match the committed digest
+	ErrUploadDigestMismatch = errors.New("upload digest mismatch")
+)
+
+// MaxUploadChunks bounds the chunk indexes of an upload session
+const MaxUploadChunks = 10000
+
+// UploadSession is a resumable artifact upload. Chunks are sent one per request, in any
+// order and as often as needed, then committed together as the artifact of BuildID.
+type UploadSession struct {
+	SessionID string        `json:"session_id"`
+	SiteID    string        `json:"site_id"`
+	BuildID   string        `json:"build_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"` // Last chunk received; idle sessions expire from here
+	Chunks    []UploadChunk `json:"chunks"`     // Received chunks, by index
+}
+
+// UploadChunk is one received part of an upload
+type UploadChunk struct {
+	Index int   `json:"index"`
+	Size  int64 `json:"size"`
+}
+
+// Missing returns the first index absent from the chunks 0..n-1, or -1 if none is.
+// A session needs at least one chunk to be complete.
+func (s *UploadSession) Missing() int {
+	for i, chunk := range s.Chunks {
+		if chunk.Index != i {
+			return i
+		}
+	}
+	if len(s.Chunks) == 0 {
+		return 0
+	}
+	return -1
+}
+
+var uploadSessionIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// NewUploadSessionID returns a random session ID
+func NewUploadSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// ValidUploadSessionID reports whether id has the form NewUploadSessionID produces
+func ValidUploadSessionID(id string) bool {
+	return uploadSessionIDPattern.MatchString(id)
+}
+
+// ChunkName is the file or object name of a chunk; the padding keeps names in index order
+func ChunkName(index int) string {
+	return fmt.Sprintf("%06d", index)
+}
+
+// JoinChunks reads chunks 0..count-1 one after another, opening each only when the one
+// before it is used up. The digest of the whole stream is checked against expectedSHA256
+// and a mismatch is reported in place of io.EOF, so a write of it is discarded.
+func JoinChunks(count int, open func(index int) (io.ReadCloser, error), expectedSHA256 string) io.ReadCloser {
+	return &chunkReader{count: count, open: open, hash: sha256.New(), expected: expectedSHA256}
+}
+
+type chunkReader struct {
+	count    int
+	open     func(index int) (io.ReadCloser, error)
+	next     int
+	current  io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if c.next == c.count {
+				if sum := hex.EncodeToString(c.hash.Sum(nil)); sum != c.expected {
+					return 0, fmt.Errorf("%w: expected %s, got %s", ErrUploadDigestMismatch, c.expected, sum)
+				}
+				return 0, io.EOF
+			}
+			reader, err := c.open(c.next)
+			if err != nil {
+				return 0, fmt.Errorf("failed to open chunk %d: %w", c.next, err)
+			}
+			c.current = reader
+			c.next++
+		}
+
+		n, err := c.current.Read(p)
+		c.hash.Write(p[:n])
+		if err == io.EOF {
+			c.current.Close()
+			c.current = nil
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.current != nil {
+		return c.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSessionMissing(t *testing.T) {
+	assert.Equal(t, 0, (&UploadSession{}).Missing())
+	assert.Equal(t, 1, (&UploadSession{Chunks: []UploadChunk{{Index: 0}, {Index: 2}}}).Missing())
+	assert.Equal(t, -1, (&UploadSession{Chunks: []UploadChunk{{Index: 0}, {Index: 1}}}).Missing())
+}
+
+func TestJoinChunks(t *testing.T) {
+	chunks := [][]byte{[]byte("first "), {}, []byte("second")}
+	open := func(index int) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(chunks[index])), nil
+	}
+	sum := sha256.Sum256([]byte("first second"))
+
+	joined, err := io.ReadAll(JoinChunks(len(chunks), open, hex.EncodeToString(sum[:])))
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(joined))
+
+	// The bytes still come through, but the stream ends in an error instead of EOF
+	_, err = io.ReadAll(JoinChunks(len(chunks), open, hex.EncodeToString(make([]byte, 32))))
+	assert.True(t, errors.Is(err, ErrUploadDigestMismatch))
+}
+
+func TestUploadSessionID(t *testing.T) {
+	id, err := NewUploadSessionID()
+	require.NoError(t, err)
+	assert.True(t, ValidUploadSessionID(id))
+	assert.False(t, ValidUploadSessionID("../sites"))
+	assert.Equal(t, "000042", ChunkName(42))
+}
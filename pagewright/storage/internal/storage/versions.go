@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that no listing of the site produced
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// VersionQuery selects a page of a site's versions, newest first. Zero values select all.
+type VersionQuery struct {
+	Limit  int       // Versions per page; 0 for all
+	Cursor string    // NextCursor of the previous page
+	Status string    // Only versions with this status
+	Action string    // Only versions with this action
+	Since  time.Time // Only versions written at or after this time
+	Until  time.Time // Only versions written before this time
+}
+
+// Matches reports whether v passes the status, action and date filters
+func (q VersionQuery) Matches(v *Version) bool {
+	if q.Status != "" && v.Status != q.Status {
+		return false
+	}
+	if q.Action != "" && v.Action != q.Action {
+		return false
+	}
+	if !q.Since.IsZero() && v.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !v.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// VersionPage is one page of a version listing. NextCursor is empty on the last page.
+type VersionPage struct {
+	Versions   []*Version `json:"versions"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// VersionFromLog converts a log entry to the version it records
+func VersionFromLog(entry *LogEntry) *Version {
+	return &Version{
+		BuildID:   entry.BuildID,
+		Timestamp: entry.Timestamp,
+		Action:    entry.Action,
+		Status:    entry.Status,
+		Metadata:  entry.Metadata,
+	}
+}
+
+// LogFromVersion converts a listed version back to the log entry of a site that records it
+func LogFromVersion(siteID string, version *Version) *LogEntry {
+	return &LogEntry{
+		Timestamp: version.Timestamp,
+		BuildID:   version.BuildID,
+		SiteID:    siteID,
+		Action:    version.Action,
+		Status:    version.Status,
+		Metadata:  version.Metadata,
+	}
+}
+
+// EncodeCursor wraps a backend position so clients treat it as opaque
+func EncodeCursor(position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// DecodeCursor returns the backend position of a cursor made by EncodeCursor
+func DecodeCursor(cursor string) (string, error) {
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(position) == 0 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+	return string(position), nil
+}
@@ -10,7 +10,15 @@ import type {
   Site,
   CreateSiteRequest,
   PaginatedResponse,
+  CursorResponse,
+  VersionFilters,
   Version,
+  VersionManifest,
+  VersionLogs,
+  VersionAncestry,
+  VersionChildren,
+  VersionDiff,
+  VersionTree,
   SiteAlias,
   AddAliasRequest,
   DeployVersionRequest,
@@ -125,9 +133,17 @@ class ApiClient {
   }
 
   // Versions endpoints
-  async listVersions(fqdn: string, page = 1, pageSize = 25): Promise<PaginatedResponse<Version>> {
+  async listVersions(fqdn: string, page = 1, pageSize = 25, filters: VersionFilters = {}): Promise<PaginatedResponse<Version>> {
     const response = await this.client.get<PaginatedResponse<Version>>(`/sites/${fqdn}/versions`, {
-      params: { page, page_size: pageSize },
+      params: { page, page_size: pageSize, ...filters },
+    });
+    return response.data;
+  }
+
+  // Pass the previous page's next_cursor to continue; it is absent on the last page
+  async listVersionsPage(fqdn: string, limit = 25, cursor?: string, filters: VersionFilters = {}): Promise<CursorResponse<Version>> {
+    const response = await this.client.get<CursorResponse<Version>>(`/sites/${fqdn}/versions`, {
+      params: { limit, ...(cursor ? { cursor } : {}), ...filters },
     });
     return response.data;
   }
@@ -136,10 +152,52 @@ class ApiClient {
     await this.client.post(`/sites/${fqdn}/versions/${versionId}/deploy`, data);
   }
 
+  async approveVersion(fqdn: string, versionId: string): Promise<void> {
+    await this.client.post(`/sites/${fqdn}/versions/${versionId}/approve`);
+  }
+
   async deleteVersion(fqdn: string, versionId: string): Promise<void> {
     await this.client.delete(`/sites/${fqdn}/versions/${versionId}`);
   }
 
+  async getVersionManifest(fqdn: string, versionId: string): Promise<VersionManifest> {
+    const response = await this.client.get<VersionManifest>(`/sites/${fqdn}/versions/${versionId}/manifest`);
+    return response.data;
+  }
+
+  async getVersionLogs(fqdn: string, versionId: string): Promise<VersionLogs> {
+    const response = await this.client.get<VersionLogs>(`/sites/${fqdn}/versions/${versionId}/logs`);
+    return response.data;
+  }
+
+  async getVersionAncestry(fqdn: string, versionId: string): Promise<VersionAncestry> {
+    const response = await this.client.get<VersionAncestry>(`/sites/${fqdn}/versions/${versionId}/ancestry`);
+    return response.data;
+  }
+
+  async getVersionChildren(fqdn: string, versionId: string): Promise<VersionChildren> {
+    const response = await this.client.get<VersionChildren>(`/sites/${fqdn}/versions/${versionId}/children`);
+    return response.data;
+  }
+
+  async diffVersions(fqdn: string, fromVersionId: string, toVersionId: string): Promise<VersionDiff> {
+    const response = await this.client.get<VersionDiff>(`/sites/${fqdn}/versions/${fromVersionId}/diff/${toVersionId}`);
+    return response.data;
+  }
+
+  async getVersionTree(fqdn: string, versionId: string): Promise<VersionTree> {
+    const response = await this.client.get<VersionTree>(`/sites/${fqdn}/versions/${versionId}/tree`);
+    return response.data;
+  }
+
+  async getVersionFile(fqdn: string, versionId: string, path: string): Promise<string> {
+    const encoded = path.split('/').map(encodeURIComponent).join('/');
+    const response = await this.client.get<string>(`/sites/${fqdn}/versions/${versionId}/files/${encoded}`, {
+      responseType: 'text',
+    });
+    return response.data;
+  }
+
   async downloadVersion(fqdn: string, versionId: string): Promise<Blob> {
     const response = await this.client.get(`/sites/${fqdn}/versions/${versionId}/download`, {
       responseType: 'blob',
@@ -156,6 +214,9 @@ class ApiClient {
       if (data.conversation_id) {
         formData.append('conversation_id', data.conversation_id);
       }
+      if (data.dry_run) {
+        formData.append('dry_run', 'true');
+      }
       data.files.forEach((file) => {
         formData.append('files', file);
       });
@@ -38,6 +38,16 @@ export const Chat: React.FC = () => {
         },
       ]);
       setVersionRefresh((prev) => prev + 1);
+    } else if (update.status === 'no_changes') {
+      setMessages((prev) => [
+        ...prev,
+        {
+          id: Date.now().toString(),
+          text: `No changes were needed${update.message ? `: ${update.message}` : '.'}`,
+          sender: 'agent',
+          timestamp: new Date(),
+        },
+      ]);
     } else if (update.status === 'failed') {
       setMessages((prev) => [
         ...prev,
@@ -35,10 +35,85 @@ export interface Version {
   id: string;
   site_id: string;
   build_id: string;
+  parent_build_id?: string;
   status: string;
+  draft?: boolean;
   created_at: string;
 }
 
+export interface VersionManifest {
+  build_id: string;
+  base_build_id: string;
+  prompt: string;
+  created_at: string;
+  file_count: number;
+  total_size: number;
+  files_changed: string[];
+  changes_summary: string;
+  checks_passed: boolean;
+  draft?: boolean;
+}
+
+export interface LineageNode {
+  build_id: string;
+  parent_build_id?: string;
+  created_at: string;
+  deleted?: boolean;
+}
+
+export interface VersionAncestry {
+  site_id: string;
+  build_id: string;
+  ancestry: LineageNode[];
+}
+
+export interface VersionChildren {
+  site_id: string;
+  build_id: string;
+  children: LineageNode[];
+}
+
+export interface FileChange {
+  path: string;
+  status: 'added' | 'removed' | 'modified';
+  old_size: number;
+  new_size: number;
+  binary?: boolean;
+  diff?: string;
+}
+
+export interface VersionDiff {
+  site_id: string;
+  from: string;
+  to: string;
+  added: number;
+  removed: number;
+  modified: number;
+  files: FileChange[];
+  truncated?: boolean;
+}
+
+export interface VersionFile {
+  path: string;
+  type: 'file' | 'dir' | 'symlink';
+  mode: number;
+  size?: number;
+  sha256?: string;
+  link?: string;
+  mtime?: number;
+}
+
+export interface VersionTree {
+  site_id: string;
+  build_id: string;
+  files: VersionFile[];
+  count: number;
+}
+
+export interface VersionLogs {
+  content: string;
+}
+
 export interface PaginatedResponse<T> {
   data: T[];
   page: number;
@@ -47,10 +122,23 @@ export interface PaginatedResponse<T> {
   total_pages: number;
 }
 
+export interface CursorResponse<T> {
+  data: T[];
+  limit: number;
+  next_cursor?: string;
+}
+
+export interface VersionFilters {
+  status?: string;
+  action?: string;
+  since?: string; // RFC 3339
+  until?: string; // RFC 3339
+}
+
 export interface JobStatusUpdate {
   job_id: string;
   site_id: string;
-  status: 'queued' | 'running' | 'success' | 'failed';
+  status: 'queued' | 'running' | 'success' | 'failed' | 'no_changes';
   build_id?: string;
   message?: string;
   timestamp: string;
@@ -99,10 +187,14 @@ export interface BuildRequest {
   message: string;
   conversation_id?: string;
   files?: File[];
+  dry_run?: boolean;
 }
 
 export interface BuildResponse {
   job_id?: string;
+  version_id?: string;
+  draft?: boolean;
+  attachments?: string[];
   question?: string;
   conversation_id?: string;
 }
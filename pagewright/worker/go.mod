@@ -4,7 +4,9 @@ go 1.22
 
 require (
 	github.com/gorilla/mux v1.8.1
+	github.com/klauspost/compress v1.17.9
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/net v0.25.0
 )
 
 require (
@@ -0,0 +1,127 @@
+package artifact
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// deterministicModTime is stamped on every entry of a deterministic archive
+var deterministicModTime = time.Unix(0, 0).UTC()
+
+// PackDeterministic writes a reproducible tar.gz or tar.zst archive of the source directory
+// to w. Entries are sorted by path, owners are zeroed, mtimes are fixed, permissions are
+// normalized to 0755/0644 and the codec is deterministic, so identical content always
+// produces byte-identical archives.
+func PackDeterministic(srcDir string, w io.Writer, format string) error {
+	return packDeterministic(srcDir, w, format, nil)
+}
+
+func packDeterministic(srcDir string, w io.Writer, format string, excluded []string) error {
+	type entry struct {
+		path    string
+		relPath string
+		info    os.FileInfo
+	}
+
+	var entries []entry
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+
+		// Skip the root directory itself and anything that is not a plain file or directory
+		if relPath == "." || !(info.IsDir() || info.Mode().IsRegular()) {
+			return nil
+		}
+
+		for _, ex := range excluded {
+			if relPath == ex {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		entries = append(entries, entry{path: path, relPath: filepath.ToSlash(relPath), info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].relPath < entries[j].relPath
+	})
+
+	cw, err := newCompressor(w, format)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(cw)
+
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:    e.relPath,
+			ModTime: deterministicModTime,
+			Format:  tar.FormatPAX,
+		}
+
+		if e.info.IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			header.Mode = 0755
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = e.info.Size()
+			header.Mode = 0644
+			if e.info.Mode()&0111 != 0 {
+				header.Mode = 0755
+			}
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			if err := copyFile(tw, e.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("failed to close %s writer: %w", format, err)
+	}
+
+	return nil
+}
+
+// Digest returns the SHA-256 of the deterministic archive of srcDir without storing it.
+// Two directories with the same content have the same digest. Top-level paths listed in
+// excluded (relative to srcDir) are left out, e.g. generated output. The digest is always
+// taken over the tar.gz archive, whatever format is uploaded.
+func Digest(srcDir string, excluded ...string) (string, error) {
+	hasher := sha256.New()
+	if err := packDeterministic(srcDir, hasher, FormatTarGz, excluded); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
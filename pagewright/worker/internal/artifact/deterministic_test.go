@@ -0,0 +1,99 @@
+package artifact
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSite(t *testing.T, dir string, files map[string]string, mode os.FileMode, mtime time.Time) {
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), mode))
+		require.NoError(t, os.Chmod(fullPath, mode))
+		require.NoError(t, os.Chtimes(fullPath, mtime, mtime))
+	}
+}
+
+func TestPackDeterministicIsReproducible(t *testing.T) {
+	files := map[string]string{
+		"content/home/index.md":  "# Home",
+		"content/about/index.md": "# About",
+		"public/index.html":      "<html></html>",
+	}
+
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+	writeSite(t, dirA, files, 0644, time.Now().Add(-time.Hour))
+	writeSite(t, dirB, files, 0664, time.Now())
+
+	for _, format := range []string{FormatTarGz, FormatTarZst} {
+		var archiveA, archiveB bytes.Buffer
+		require.NoError(t, PackDeterministic(dirA, &archiveA, format))
+		require.NoError(t, PackDeterministic(dirB, &archiveB, format))
+
+		assert.Equal(t, archiveA.Bytes(), archiveB.Bytes(), format)
+	}
+
+	digestA, err := Digest(dirA)
+	require.NoError(t, err)
+	digestB, err := Digest(dirB)
+	require.NoError(t, err)
+	assert.Equal(t, digestA, digestB)
+}
+
+func TestDigestChangesWithContent(t *testing.T) {
+	dir := t.TempDir()
+	writeSite(t, dir, map[string]string{"content/home/index.md": "# Home"}, 0644, time.Now())
+
+	before, err := Digest(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "content", "home", "index.md"), []byte("# Welcome"), 0644))
+
+	after, err := Digest(dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+}
+
+func TestPackDeterministicUnpacks(t *testing.T) {
+	srcDir := t.TempDir()
+	writeSite(t, srcDir, map[string]string{"content/home/index.md": "# Home"}, 0600, time.Now())
+
+	for _, format := range []string{FormatTarGz, FormatTarZst} {
+		destDir := t.TempDir()
+		var archive bytes.Buffer
+		require.NoError(t, PackDeterministic(srcDir, &archive, format))
+		require.NoError(t, UnpackFrom(&archive, destDir))
+
+		info, err := os.Stat(filepath.Join(destDir, "content", "home", "index.md"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), format)
+	}
+
+	var archive bytes.Buffer
+	assert.Error(t, PackDeterministic(srcDir, &archive, "zip"))
+}
+
+func TestDigestExcluded(t *testing.T) {
+	dir := t.TempDir()
+	writeSite(t, dir, map[string]string{
+		"content/home/index.md": "# Home",
+		"public/index.html":     "<h1>Home</h1>",
+	}, 0644, time.Now())
+
+	before, err := Digest(dir, "public")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "public", "index.html"), []byte("<h1>Rebuilt</h1>"), 0644))
+
+	after, err := Digest(dir, "public")
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
@@ -0,0 +1,84 @@
+package artifact
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Archive formats, named as storage records them
+const (
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// ValidFormat reports whether format is an archive format the worker can pack
+func ValidFormat(format string) bool {
+	return format == FormatTarGz || format == FormatTarZst
+}
+
+// ContentType returns the media type to upload an archive format with
+func ContentType(format string) string {
+	if format == FormatTarZst {
+		return "application/zstd"
+	}
+	return "application/gzip"
+}
+
+// formatForPath picks the format from an archive's file name; anything but .tar.zst is tar.gz
+func formatForPath(archivePath string) string {
+	if strings.HasSuffix(archivePath, "."+FormatTarZst) {
+		return FormatTarZst
+	}
+	return FormatTarGz
+}
+
+// newCompressor wraps w in the codec of format. Both codecs write reproducible streams: the
+// gzip header carries no name, time or OS and zstd runs single-threaded, which is also how
+// storage rebuilds archives.
+func newCompressor(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case FormatTarGz:
+		gzw, err := gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		gzw.Header = gzip.Header{OS: 255} // unknown OS, no name, no mtime
+		return gzw, nil
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %q", format)
+	}
+}
+
+// newDecompressor opens a gzip or zstd stream, telling them apart by their magic bytes
+func newDecompressor(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if len(magic) == 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd {
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	gzr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return gzr, nil
+}
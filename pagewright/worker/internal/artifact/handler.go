@@ -2,7 +2,6 @@ package artifact
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -10,7 +9,7 @@ import (
 	"strings"
 )
 
-// Unpack extracts a tar.gz archive to the destination directory
+// Unpack extracts a tar.gz or tar.zst archive to the destination directory
 func Unpack(archivePath, destDir string) error {
 	// Open the archive
 	file, err := os.Open(archivePath)
@@ -19,15 +18,20 @@ func Unpack(archivePath, destDir string) error {
 	}
 	defer file.Close()
 
-	// Create gzip reader
-	gzr, err := gzip.NewReader(file)
+	return UnpackFrom(file, destDir)
+}
+
+// UnpackFrom extracts a tar.gz or tar.zst stream to the destination directory
+func UnpackFrom(r io.Reader, destDir string) error {
+	// Open the gzip or zstd stream
+	cr, err := newDecompressor(r)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return err
 	}
-	defer gzr.Close()
+	defer cr.Close()
 
 	// Create tar reader
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(cr)
 
 	// Extract all files
 	for {
@@ -77,7 +81,8 @@ func Unpack(archivePath, destDir string) error {
 	return nil
 }
 
-// Pack creates a tar.gz archive from the source directory
+// Pack creates an archive from the source directory: tar.zst if archivePath ends in
+// .tar.zst, tar.gz otherwise
 func Pack(srcDir, archivePath string) error {
 	// Create the archive file
 	outFile, err := os.Create(archivePath)
@@ -86,16 +91,27 @@ func Pack(srcDir, archivePath string) error {
 	}
 	defer outFile.Close()
 
-	// Create gzip writer
-	gzw := gzip.NewWriter(outFile)
-	defer gzw.Close()
+	return packTo(srcDir, outFile, formatForPath(archivePath))
+}
+
+// PackTo writes a tar.gz archive of the source directory to w.
+// The stream is complete only when PackTo returns nil.
+func PackTo(srcDir string, w io.Writer) error {
+	return packTo(srcDir, w, FormatTarGz)
+}
+
+func packTo(srcDir string, w io.Writer, format string) error {
+	// Create gzip or zstd writer
+	cw, err := newCompressor(w, format)
+	if err != nil {
+		return err
+	}
 
 	// Create tar writer
-	tw := tar.NewWriter(gzw)
-	defer tw.Close()
+	tw := tar.NewWriter(cw)
 
 	// Walk the source directory
-	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -125,19 +141,38 @@ func Pack(srcDir, archivePath string) error {
 
 		// If it's a file, write content
 		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return fmt.Errorf("failed to open file: %w", err)
-			}
-			defer file.Close()
-
-			if _, err := io.Copy(tw, file); err != nil {
-				return fmt.Errorf("failed to copy file content: %w", err)
-			}
+			return copyFile(tw, path)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	// Flush tar and compression footers explicitly so write errors are not lost
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := cw.Close(); err != nil {
+		return fmt.Errorf("failed to close %s writer: %w", format, err)
+	}
+
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("failed to copy file content: %w", err)
+	}
+
+	return nil
 }
 
 // PatchInstructions replaces .codex/instructions.md in the unpacked site
@@ -1,6 +1,7 @@
 package artifact
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -33,28 +34,57 @@ func TestPackAndUnpack(t *testing.T) {
 		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
 	}
 
-	// Pack
-	archivePath := filepath.Join(os.TempDir(), "test-archive.tar.gz")
-	defer os.Remove(archivePath)
+	// The file name picks the codec
+	for _, name := range []string{"test-archive.tar.gz", "test-archive.tar.zst"} {
+		// Pack
+		archivePath := filepath.Join(t.TempDir(), name)
+		err = Pack(srcDir, archivePath)
+		require.NoError(t, err)
 
-	err = Pack(srcDir, archivePath)
-	require.NoError(t, err)
+		// Verify archive exists
+		_, err = os.Stat(archivePath)
+		require.NoError(t, err)
 
-	// Verify archive exists
-	_, err = os.Stat(archivePath)
-	require.NoError(t, err)
+		// Unpack
+		unpackDir := filepath.Join(destDir, name)
+		err = Unpack(archivePath, unpackDir)
+		require.NoError(t, err)
+
+		// Verify files
+		for path, expectedContent := range testFiles {
+			fullPath := filepath.Join(unpackDir, path)
+			content, err := os.ReadFile(fullPath)
+			require.NoError(t, err)
+			assert.Equal(t, expectedContent, string(content))
+		}
+	}
 
-	// Unpack
-	err = Unpack(archivePath, destDir)
+	// A .tar.zst name gets zstd magic bytes
+	archivePath := filepath.Join(t.TempDir(), "site.tar.zst")
+	require.NoError(t, Pack(srcDir, archivePath))
+	data, err := os.ReadFile(archivePath)
 	require.NoError(t, err)
+	assert.Equal(t, []byte{0x28, 0xb5, 0x2f, 0xfd}, data[:4])
+}
 
-	// Verify files
-	for path, expectedContent := range testFiles {
-		fullPath := filepath.Join(destDir, path)
-		content, err := os.ReadFile(fullPath)
-		require.NoError(t, err)
-		assert.Equal(t, expectedContent, string(content))
-	}
+func TestPackToUnpackFromPipe(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "content", "home"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "content", "home", "index.md"), []byte("# Home"), 0644))
+
+	// Stream the archive through a pipe without touching disk
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(PackTo(srcDir, pw))
+	}()
+
+	require.NoError(t, UnpackFrom(pr, destDir))
+
+	content, err := os.ReadFile(filepath.Join(destDir, "content", "home", "index.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Home", string(content))
 }
 
 func TestPatchInstructions(t *testing.T) {
@@ -0,0 +1,116 @@
+package attachments
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// namePattern matches the file names storage accepts: no path separators and no leading dot
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// Fetcher streams the named attachment into w
+type Fetcher func(name string, w io.Writer) error
+
+// File is an attachment placed in the site
+type File struct {
+	Name string // as uploaded
+	Path string // relative to the site directory
+	URL  string // where the compiler publishes it
+}
+
+// AssetsDir returns the home page's assets directory, relative to siteDir. The compiler copies
+// it to /assets/pages/home/. Sites with a top-level content/index.md keep the home page there;
+// otherwise it lives in content/home.
+func AssetsDir(siteDir string) string {
+	if _, err := os.Stat(filepath.Join(siteDir, "content", "index.md")); err == nil {
+		return filepath.Join("content", "assets")
+	}
+	return filepath.Join("content", "home", "assets")
+}
+
+// Place fetches each attachment into the home page's assets directory, replacing files
+// with the same name
+func Place(siteDir string, names []string, fetch Fetcher) ([]File, error) {
+	dir := AssetsDir(siteDir)
+	if err := os.MkdirAll(filepath.Join(siteDir, dir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create assets directory: %w", err)
+	}
+
+	files := make([]File, 0, len(names))
+	for _, name := range names {
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid attachment name: %q", name)
+		}
+
+		rel := filepath.Join(dir, name)
+		if err := writeFile(filepath.Join(siteDir, rel), name, fetch); err != nil {
+			return nil, err
+		}
+
+		files = append(files, File{
+			Name: name,
+			Path: filepath.ToSlash(rel),
+			URL:  "/assets/pages/home/" + name,
+		})
+	}
+
+	return files, nil
+}
+
+func writeFile(path, name string, fetch Fetcher) error {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	if err := fetch(name, f); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to place %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Paths returns where each file was placed, relative to the site directory
+func Paths(files []File) []string {
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+// Prompt tells the agent where the files the request mentions by name were placed.
+// Without attachments the prompt is unchanged.
+func Prompt(files []File, prompt string) string {
+	if len(files) == 0 {
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\n## Attached files\n\n")
+	b.WriteString("The user uploaded these files with the request, and they were added to the site ")
+	b.WriteString("as part of this change. Reference them by their published URL, or move them to ")
+	b.WriteString("another page's assets/ directory if they belong there.\n\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "- %s: %s (published at %s)\n", f.Name, f.Path, f.URL)
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package attachments
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fetchName(name string, w io.Writer) error {
+	_, err := io.WriteString(w, "data for "+name)
+	return err
+}
+
+func TestAssetsDir(t *testing.T) {
+	siteDir := t.TempDir()
+	assert.Equal(t, filepath.Join("content", "home", "assets"), AssetsDir(siteDir))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(siteDir, "content"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(siteDir, "content", "index.md"), []byte("# Home"), 0644))
+	assert.Equal(t, filepath.Join("content", "assets"), AssetsDir(siteDir))
+}
+
+func TestPlace(t *testing.T) {
+	siteDir := t.TempDir()
+
+	files, err := Place(siteDir, []string{"logo.png", "menu.pdf"}, fetchName)
+	require.NoError(t, err)
+
+	require.Len(t, files, 2)
+	assert.Equal(t, File{Name: "menu.pdf", Path: "content/home/assets/menu.pdf", URL: "/assets/pages/home/menu.pdf"}, files[1])
+
+	data, err := os.ReadFile(filepath.Join(siteDir, "content", "home", "assets", "logo.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "data for logo.png", string(data))
+}
+
+func TestPlaceRejectsUnsafeNames(t *testing.T) {
+	for _, name := range []string{"../escape.png", ".htaccess", "a/b.png", ""} {
+		_, err := Place(t.TempDir(), []string{name}, fetchName)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestPlaceFetchFailureLeavesNoFile(t *testing.T) {
+	siteDir := t.TempDir()
+	_, err := Place(siteDir, []string{"logo.png"}, func(name string, w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("connection reset")
+	})
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(filepath.Join(siteDir, "content", "home", "assets"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPrompt(t *testing.T) {
+	assert.Equal(t, "Add a menu page", Prompt(nil, "Add a menu page"))
+
+	prompt := Prompt([]File{{Name: "menu.pdf", Path: "content/home/assets/menu.pdf", URL: "/assets/pages/home/menu.pdf"}}, "Put menu.pdf on the site")
+	assert.Contains(t, prompt, "Put menu.pdf on the site\n\n## Attached files")
+	assert.Contains(t, prompt, "- menu.pdf: content/home/assets/menu.pdf (published at /assets/pages/home/menu.pdf)")
+}
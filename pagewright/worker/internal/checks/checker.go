@@ -0,0 +1,266 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+)
+
+// maxPages bounds the crawl so a generated link loop cannot run forever
+const maxPages = 500
+
+// Result holds the render check outcome in the shape the manifest needs
+type Result struct {
+	Report        *types.CheckReport
+	Pages         []string // crawled page paths, in crawl order
+	Screenshots   []string // screenshot paths relative to the site directory
+	ConsoleErrors int
+	Passed        bool // no error-severity findings
+}
+
+// Checker serves the compiled site on loopback and crawls it
+type Checker struct {
+	screenshotter *Screenshotter
+	client        *http.Client
+}
+
+// NewChecker creates a new render checker. Screenshots are skipped when screenshotter is nil.
+func NewChecker(screenshotter *Screenshotter) *Checker {
+	return &Checker{
+		screenshotter: screenshotter,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run crawls siteDir/public starting from "/" and every HTML file on disk, and checks each
+// page for HTTP errors, broken internal links and HTML-level accessibility problems.
+func (c *Checker) Run(ctx context.Context, siteDir string) (*Result, error) {
+	publicDir := filepath.Join(siteDir, "public")
+
+	seeds, err := htmlPages(publicDir)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on loopback: %w", err)
+	}
+
+	server := &http.Server{Handler: http.FileServer(http.Dir(publicDir))}
+	go server.Serve(listener)
+	defer server.Close()
+
+	base := &url.URL{Scheme: "http", Host: listener.Addr().String()}
+	crawl := &crawl{
+		ctx:     ctx,
+		client:  c.client,
+		base:    base,
+		report:  &types.CheckReport{Findings: []types.CheckFinding{}},
+		visited: make(map[string]bool),
+		status:  make(map[string]int),
+	}
+	crawl.run(append([]string{"/"}, seeds...))
+
+	result := &Result{Report: crawl.report, Pages: crawl.pages}
+
+	if c.screenshotter != nil {
+		shots, consoleErrors, findings := c.screenshotter.Capture(ctx, base, crawl.pages, siteDir)
+		result.Screenshots = shots
+		result.ConsoleErrors = consoleErrors
+		for _, f := range findings {
+			crawl.add(f)
+		}
+	}
+
+	result.Passed = crawl.report.Errors == 0
+	return result, nil
+}
+
+// htmlPages lists the URL paths of every HTML file under publicDir, sorted
+func htmlPages(publicDir string) ([]string, error) {
+	var pages []string
+	err := filepath.WalkDir(publicDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".html") {
+			return nil
+		}
+		rel, err := filepath.Rel(publicDir, p)
+		if err != nil {
+			return err
+		}
+		page := "/" + filepath.ToSlash(rel)
+		if d.Name() == "index.html" {
+			page = strings.TrimSuffix(page, "index.html")
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compiled pages: %w", err)
+	}
+	sort.Strings(pages)
+	return pages, nil
+}
+
+type crawl struct {
+	ctx    context.Context
+	client *http.Client
+	base   *url.URL
+
+	report  *types.CheckReport
+	pages   []string
+	visited map[string]bool
+	status  map[string]int // cached status of checked link targets
+}
+
+func (c *crawl) run(queue []string) {
+	for len(queue) > 0 && len(c.pages) < maxPages && c.ctx.Err() == nil {
+		page := queue[0]
+		queue = queue[1:]
+		if c.visited[page] {
+			continue
+		}
+		c.visited[page] = true
+
+		queue = append(queue, c.checkPage(page)...)
+	}
+}
+
+// checkPage fetches and inspects one page and returns the internal pages it links to
+func (c *crawl) checkPage(page string) []string {
+	resp, err := c.get(page)
+	if err != nil {
+		c.add(types.CheckFinding{Page: page, Check: "http_error", Severity: types.SeverityError, Message: err.Error()})
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.add(types.CheckFinding{Page: page, Check: "http_error", Severity: types.SeverityError, Message: fmt.Sprintf("page returned HTTP %d", resp.StatusCode)})
+		return nil
+	}
+
+	// Redirects such as /about to /about/ may land on a page that was already checked
+	if final := resp.Request.URL.Path; final != page {
+		if c.visited[final] {
+			return nil
+		}
+		c.visited[final] = true
+		page = final
+	}
+
+	c.pages = append(c.pages, page)
+	c.report.PagesChecked++
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+
+	doc, err := parsePage(resp.Body)
+	if err != nil {
+		c.add(types.CheckFinding{Page: page, Check: "http_error", Severity: types.SeverityError, Message: fmt.Sprintf("failed to parse HTML: %v", err)})
+		return nil
+	}
+
+	for _, f := range doc.accessibilityFindings() {
+		f.Page = page
+		c.add(f)
+	}
+
+	pageURL := c.base.ResolveReference(&url.URL{Path: page})
+	var next []string
+	for _, link := range doc.links {
+		target, ok := c.internalPath(pageURL, link.ref)
+		if !ok {
+			continue
+		}
+
+		c.report.LinksChecked++
+		if status := c.targetStatus(target); status != http.StatusOK {
+			message := fmt.Sprintf("%s %q returned HTTP %d", link.element, link.ref, status)
+			if status == 0 {
+				message = fmt.Sprintf("%s %q could not be fetched", link.element, link.ref)
+			}
+			c.add(types.CheckFinding{Page: page, Check: "broken_link", Severity: types.SeverityError, Target: link.ref, Message: message})
+			continue
+		}
+
+		if link.page {
+			next = append(next, target)
+		}
+	}
+
+	return next
+}
+
+// internalPath resolves ref against the page and returns its path if it points at the site
+func (c *crawl) internalPath(pageURL *url.URL, ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", false
+	}
+	resolved := pageURL.ResolveReference(u)
+	if resolved.Scheme != "http" || resolved.Host != c.base.Host {
+		return "", false
+	}
+	if resolved.Path == "" {
+		return "/", true
+	}
+	return resolved.Path, true
+}
+
+// targetStatus returns the HTTP status of an internal target, following redirects
+func (c *crawl) targetStatus(target string) int {
+	if status, ok := c.status[target]; ok {
+		return status
+	}
+
+	status := 0
+	if resp, err := c.get(target); err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		status = resp.StatusCode
+	}
+
+	c.status[target] = status
+	return status
+}
+
+func (c *crawl) get(p string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.base.ResolveReference(&url.URL{Path: p}).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *crawl) add(f types.CheckFinding) {
+	c.report.Findings = append(c.report.Findings, f)
+	if f.Severity == types.SeverityError {
+		c.report.Errors++
+	} else {
+		c.report.Warnings++
+	}
+}
@@ -0,0 +1,172 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pageTemplate = `<!DOCTYPE html>
+<html lang="en"><head><title>%[1]s</title><link rel="stylesheet" href="/assets/site.css"></head>
+<body><h1>%[1]s</h1><h2>Section</h2><img src="/assets/logo.png" alt="Logo">%[2]s</body></html>`
+
+// cleanPage renders a page that passes every accessibility check
+func cleanPage(title, body string) string {
+	return fmt.Sprintf(pageTemplate, title, body)
+}
+
+// writePublic creates siteDir/public with the given files
+func writePublic(t *testing.T, files map[string]string) string {
+	t.Helper()
+	siteDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(siteDir, "public", name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return siteDir
+}
+
+func findings(report *types.CheckReport, check string) []types.CheckFinding {
+	var out []types.CheckFinding
+	for _, f := range report.Findings {
+		if f.Check == check {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func TestRunCleanSite(t *testing.T) {
+	siteDir := writePublic(t, map[string]string{
+		"index.html":       cleanPage("Home", `<a href="/about">About</a>`),
+		"about/index.html": cleanPage("About", `<a href="../">Home</a>`),
+		"assets/site.css":  "body{}",
+		"assets/logo.png":  "png",
+	})
+
+	result, err := NewChecker(nil).Run(context.Background(), siteDir)
+	require.NoError(t, err)
+
+	assert.True(t, result.Passed)
+	assert.Empty(t, result.Report.Findings)
+	assert.Equal(t, []string{"/", "/about/"}, result.Pages)
+	assert.Equal(t, 2, result.Report.PagesChecked)
+	assert.Equal(t, 6, result.Report.LinksChecked)
+}
+
+func TestRunReportsBrokenLinks(t *testing.T) {
+	siteDir := writePublic(t, map[string]string{
+		"index.html":      cleanPage("Home", `<a href="/missing/">Gone</a><a href="https://example.com/">External</a><a href="mailto:a@b.c">Mail</a>`),
+		"assets/site.css": "body{}",
+	})
+
+	result, err := NewChecker(nil).Run(context.Background(), siteDir)
+	require.NoError(t, err)
+
+	assert.False(t, result.Passed)
+	broken := findings(result.Report, "broken_link")
+	require.Len(t, broken, 2)
+	assert.Equal(t, "/assets/logo.png", broken[0].Target)
+	assert.Equal(t, "/missing/", broken[1].Target)
+	assert.Contains(t, broken[1].Message, "404")
+	assert.Equal(t, types.SeverityError, broken[1].Severity)
+}
+
+func TestRunReportsAccessibility(t *testing.T) {
+	siteDir := writePublic(t, map[string]string{
+		"index.html": `<html><body><h2>Intro</h2><h4>Deep</h4><img src="/a.png"><img src="/b.png" alt=""></body></html>`,
+		"a.png":      "png",
+		"b.png":      "png",
+	})
+
+	result, err := NewChecker(nil).Run(context.Background(), siteDir)
+	require.NoError(t, err)
+
+	alt := findings(result.Report, "missing_alt")
+	require.Len(t, alt, 1)
+	assert.Equal(t, "/a.png", alt[0].Target)
+
+	headings := findings(result.Report, "heading_order")
+	require.Len(t, headings, 2)
+	assert.Equal(t, "h4 follows h2, skipping a level", headings[0].Message)
+	assert.Equal(t, "page has no h1", headings[1].Message)
+
+	assert.Len(t, findings(result.Report, "missing_lang"), 1)
+	assert.Len(t, findings(result.Report, "missing_title"), 1)
+	assert.Equal(t, 1, result.Report.Errors)
+	assert.Equal(t, 4, result.Report.Warnings)
+	assert.False(t, result.Passed)
+}
+
+func TestRunFindsUnlinkedPages(t *testing.T) {
+	siteDir := writePublic(t, map[string]string{
+		"index.html":        `<html lang="en"><title>Home</title><h1>Home</h1></html>`,
+		"orphan/index.html": `<html lang="en"><title>Orphan</title><h1>Orphan</h1></html>`,
+	})
+
+	result, err := NewChecker(nil).Run(context.Background(), siteDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/", "/orphan/"}, result.Pages)
+	assert.True(t, result.Passed)
+}
+
+func TestRunCapturesScreenshots(t *testing.T) {
+	siteDir := writePublic(t, map[string]string{
+		"index.html":           `<html lang="en"><title>Home</title><h1>Home</h1><a href="/blog/post/">Post</a></html>`,
+		"blog/post/index.html": `<html lang="en"><title>Post</title><h1>Post</h1></html>`,
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(siteDir, ScreenshotDir), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(siteDir, ScreenshotDir, "stale.png"), []byte("old"), 0644))
+
+	// Mock browser: writes the screenshot and logs one console error per page
+	browser := filepath.Join(t.TempDir(), "mock-chromium")
+	script := `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in --screenshot=*) printf png > "${arg#--screenshot=}" ;; esac
+done
+echo '[1:1:0101/000000.000000:INFO:CONSOLE(3)] "Uncaught TypeError: x is undefined", source: page (3)' >&2
+echo '[1:1:0101/000000.000000:INFO:CONSOLE(4)] "hello", source: page (4)' >&2
+`
+	require.NoError(t, os.WriteFile(browser, []byte(script), 0755))
+
+	result, err := NewChecker(NewScreenshotter(browser, 10)).Run(context.Background(), siteDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		".pagewright/screenshots/index.png",
+		".pagewright/screenshots/blog-post.png",
+	}, result.Screenshots)
+	assert.Equal(t, 2, result.ConsoleErrors)
+	assert.FileExists(t, filepath.Join(siteDir, ".pagewright/screenshots/blog-post.png"))
+	assert.NoFileExists(t, filepath.Join(siteDir, ScreenshotDir, "stale.png"))
+}
+
+func TestRunScreenshotFailureIsWarning(t *testing.T) {
+	siteDir := writePublic(t, map[string]string{
+		"index.html": `<html lang="en"><title>Home</title><h1>Home</h1></html>`,
+	})
+
+	result, err := NewChecker(NewScreenshotter("/nonexistent/chromium", 10)).Run(context.Background(), siteDir)
+	require.NoError(t, err)
+
+	shots := findings(result.Report, "screenshot")
+	require.Len(t, shots, 1)
+	assert.Equal(t, types.SeverityWarning, shots[0].Severity)
+	assert.Empty(t, result.Screenshots)
+	assert.True(t, result.Passed)
+}
+
+func TestScreenshotName(t *testing.T) {
+	assert.Equal(t, "index.png", screenshotName("/"))
+	assert.Equal(t, "about.png", screenshotName("/about/"))
+	assert.Equal(t, "blog-post.png", screenshotName("/blog/post/"))
+	assert.Equal(t, "404.png", screenshotName("/404.html"))
+}
@@ -0,0 +1,165 @@
+package checks
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+	"golang.org/x/net/html"
+)
+
+// link is a reference found in a page
+type link struct {
+	element string // a, img, link, script, source
+	ref     string
+	page    bool // followed by the crawl, not just checked
+}
+
+// heading is a heading element in document order
+type heading struct {
+	level int
+	text  string
+}
+
+// page holds what the checks need from a parsed HTML document
+type page struct {
+	lang       bool
+	title      string
+	links      []link
+	missingAlt []string // src of images without an alt attribute
+	headings   []heading
+}
+
+func parsePage(r io.Reader) (*page, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{}
+	p.walk(root)
+	return p, nil
+}
+
+func (p *page) walk(n *html.Node) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "html":
+			p.lang = strings.TrimSpace(attr(n, "lang")) != ""
+		case "title":
+			p.title = strings.TrimSpace(text(n))
+		case "a":
+			if href, ok := attrOK(n, "href"); ok && !strings.HasPrefix(href, "#") {
+				p.links = append(p.links, link{element: "a", ref: href, page: true})
+			}
+		case "img":
+			if src, ok := attrOK(n, "src"); ok {
+				p.links = append(p.links, link{element: "img", ref: src})
+			}
+			// alt="" is valid for decorative images; only a missing attribute is reported
+			if _, ok := attrOK(n, "alt"); !ok {
+				p.missingAlt = append(p.missingAlt, attr(n, "src"))
+			}
+		case "link":
+			if href, ok := attrOK(n, "href"); ok && isLoadedRel(attr(n, "rel")) {
+				p.links = append(p.links, link{element: "link", ref: href})
+			}
+		case "script", "source":
+			if src, ok := attrOK(n, "src"); ok {
+				p.links = append(p.links, link{element: n.Data, ref: src})
+			}
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			p.headings = append(p.headings, heading{level: int(n.Data[1] - '0'), text: strings.TrimSpace(text(n))})
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		p.walk(child)
+	}
+}
+
+// accessibilityFindings reports missing alt text, heading order, language and title problems
+func (p *page) accessibilityFindings() []types.CheckFinding {
+	var findings []types.CheckFinding
+
+	for _, src := range p.missingAlt {
+		findings = append(findings, types.CheckFinding{
+			Check:    "missing_alt",
+			Severity: types.SeverityError,
+			Target:   src,
+			Message:  "image has no alt attribute",
+		})
+	}
+
+	h1s := 0
+	previous := 0
+	for _, h := range p.headings {
+		if h.level == 1 {
+			h1s++
+		}
+		if previous > 0 && h.level > previous+1 {
+			findings = append(findings, types.CheckFinding{
+				Check:    "heading_order",
+				Severity: types.SeverityWarning,
+				Target:   h.text,
+				Message:  fmt.Sprintf("h%d follows h%d, skipping a level", h.level, previous),
+			})
+		}
+		previous = h.level
+	}
+	if h1s == 0 {
+		findings = append(findings, types.CheckFinding{Check: "heading_order", Severity: types.SeverityWarning, Message: "page has no h1"})
+	} else if h1s > 1 {
+		findings = append(findings, types.CheckFinding{Check: "heading_order", Severity: types.SeverityWarning, Message: fmt.Sprintf("page has %d h1 elements", h1s)})
+	}
+
+	if !p.lang {
+		findings = append(findings, types.CheckFinding{Check: "missing_lang", Severity: types.SeverityWarning, Message: "html element has no lang attribute"})
+	}
+	if p.title == "" {
+		findings = append(findings, types.CheckFinding{Check: "missing_title", Severity: types.SeverityWarning, Message: "page has no title"})
+	}
+
+	return findings
+}
+
+// isLoadedRel reports whether a <link rel> points at a resource the browser loads
+func isLoadedRel(rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		switch r {
+		case "stylesheet", "icon", "preload", "manifest", "apple-touch-icon":
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOK(n, key)
+	return v
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func text(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return b.String()
+}
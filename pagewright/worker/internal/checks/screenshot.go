@@ -0,0 +1,128 @@
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+)
+
+// ScreenshotDir is where screenshots are written, relative to the site directory.
+// It sits outside public/ so screenshots are stored with the version but never served.
+const ScreenshotDir = ".pagewright/screenshots"
+
+// consoleError matches console errors in Chromium's stderr log, e.g.
+// [..:INFO:CONSOLE(12)] "Uncaught ReferenceError: x is not defined", source: ...
+var consoleError = regexp.MustCompile(`:CONSOLE\(\d+\)\] "(Uncaught|.*Error\b)`)
+
+// Screenshotter captures page screenshots with a headless Chromium-compatible browser
+type Screenshotter struct {
+	binaryPath string
+	maxPages   int
+	timeout    time.Duration
+}
+
+// NewScreenshotter creates a new screenshotter that captures at most maxPages pages
+func NewScreenshotter(binaryPath string, maxPages int) *Screenshotter {
+	return &Screenshotter{
+		binaryPath: binaryPath,
+		maxPages:   maxPages,
+		timeout:    30 * time.Second,
+	}
+}
+
+// Capture screenshots the given pages into siteDir/ScreenshotDir and counts console errors.
+// A page the browser fails on is reported as a warning finding rather than an error.
+func (s *Screenshotter) Capture(ctx context.Context, base *url.URL, pages []string, siteDir string) ([]string, int, []types.CheckFinding) {
+	dir := filepath.Join(siteDir, ScreenshotDir)
+
+	// Screenshots from the source version would describe the old site
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, 0, []types.CheckFinding{s.failed("", fmt.Errorf("failed to clear screenshots: %w", err))}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, 0, []types.CheckFinding{s.failed("", fmt.Errorf("failed to create screenshot directory: %w", err))}
+	}
+
+	var shots []string
+	var findings []types.CheckFinding
+	consoleErrors := 0
+
+	for i, page := range pages {
+		if i >= s.maxPages {
+			break
+		}
+
+		rel := filepath.ToSlash(filepath.Join(ScreenshotDir, screenshotName(page)))
+		errs, err := s.capture(ctx, base.ResolveReference(&url.URL{Path: page}).String(), filepath.Join(siteDir, rel))
+		consoleErrors += errs
+		if err != nil {
+			findings = append(findings, s.failed(page, err))
+			continue
+		}
+		shots = append(shots, rel)
+	}
+
+	return shots, consoleErrors, findings
+}
+
+func (s *Screenshotter) capture(ctx context.Context, pageURL, outPath string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.binaryPath,
+		"--headless",
+		"--disable-gpu",
+		"--no-sandbox",
+		"--hide-scrollbars",
+		"--enable-logging=stderr",
+		"--window-size=1280,800",
+		"--screenshot="+outPath,
+		pageURL,
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	consoleErrors := countConsoleErrors(stderr.String())
+	if err != nil {
+		return consoleErrors, fmt.Errorf("browser failed: %w", err)
+	}
+	if _, err := os.Stat(outPath); err != nil {
+		return consoleErrors, fmt.Errorf("browser wrote no screenshot")
+	}
+	return consoleErrors, nil
+}
+
+func (s *Screenshotter) failed(page string, err error) types.CheckFinding {
+	return types.CheckFinding{Page: page, Check: "screenshot", Severity: types.SeverityWarning, Message: err.Error()}
+}
+
+func countConsoleErrors(log string) int {
+	count := 0
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	for scanner.Scan() {
+		if consoleError.MatchString(scanner.Text()) {
+			count++
+		}
+	}
+	return count
+}
+
+// screenshotName maps a page path to a file name: / is index.png, /blog/post/ is blog-post.png
+func screenshotName(page string) string {
+	name := strings.Trim(strings.TrimSuffix(page, ".html"), "/")
+	if name == "" {
+		name = "index"
+	}
+	return strings.ReplaceAll(name, "/", "-") + ".png"
+}
@@ -9,6 +9,9 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/redact"
 )
 
 // Executor wraps codex exec command execution
@@ -17,12 +20,16 @@ type Executor struct {
 	workDir    string
 	llmKey     string
 	llmBaseURL string
-
-	mu      sync.Mutex
-	cmd     *exec.Cmd
-	cancel  context.CancelFunc
-	running bool
-	output  strings.Builder
+	limits     Limits
+	redactor   *redact.Redactor
+
+	mu          sync.Mutex
+	cmd         *exec.Cmd
+	cancel      context.CancelFunc
+	running     bool
+	output      strings.Builder
+	outputBytes int64
+	limitErr    *LimitError
 }
 
 // NewExecutor creates a new Codex executor
@@ -32,10 +39,27 @@ func NewExecutor(binaryPath, workDir, llmKey, llmBaseURL string) *Executor {
 		workDir:    workDir,
 		llmKey:     llmKey,
 		llmBaseURL: llmBaseURL,
+		redactor:   redact.New(llmKey),
 	}
 }
 
-// Execute runs codex exec with the given prompt
+// SetRedactor replaces the redactor applied to captured output.
+// By default only the LLM key is masked, along with common credential patterns.
+func (e *Executor) SetRedactor(redactor *redact.Redactor) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.redactor = redactor
+}
+
+// SetLimits sets the limits applied to subsequent runs
+func (e *Executor) SetLimits(limits Limits) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.limits = limits
+}
+
+// Execute runs codex exec with the given prompt.
+// A run stopped for exceeding one of the configured limits returns a *LimitError.
 func (e *Executor) Execute(ctx context.Context, prompt string) error {
 	e.mu.Lock()
 	if e.running {
@@ -44,6 +68,10 @@ func (e *Executor) Execute(ctx context.Context, prompt string) error {
 	}
 	e.running = true
 	e.output.Reset()
+	e.outputBytes = 0
+	e.limitErr = nil
+	limits := e.limits
+	redactor := e.redactor
 	e.mu.Unlock()
 
 	defer func() {
@@ -54,8 +82,14 @@ func (e *Executor) Execute(ctx context.Context, prompt string) error {
 		e.mu.Unlock()
 	}()
 
-	// Create cancellable context
-	cmdCtx, cancel := context.WithCancel(ctx)
+	// Create cancellable context, bounded by the wall-clock limit
+	var cmdCtx context.Context
+	var cancel context.CancelFunc
+	if limits.Timeout > 0 {
+		cmdCtx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	} else {
+		cmdCtx, cancel = context.WithCancel(ctx)
+	}
 	e.mu.Lock()
 	e.cancel = cancel
 	e.mu.Unlock()
@@ -72,6 +106,17 @@ func (e *Executor) Execute(ctx context.Context, prompt string) error {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("OPENAI_BASE_URL=%s", e.llmBaseURL))
 	}
 
+	guard := prepareResources(cmd, limits)
+	defer guard.close()
+
+	var baseline workspace
+	if limits.watchesWorkspace() {
+		var err error
+		if baseline, err = snapshotWorkspace(e.workDir); err != nil {
+			return err
+		}
+	}
+
 	e.mu.Lock()
 	e.cmd = cmd
 	e.mu.Unlock()
@@ -92,23 +137,73 @@ func (e *Executor) Execute(ctx context.Context, prompt string) error {
 		return fmt.Errorf("failed to start codex: %w", err)
 	}
 
+	if err := guard.started(cmd.Process.Pid); err != nil {
+		cancel()
+		cmd.Wait()
+		return err
+	}
+
+	// Watch the work directory for file limits and the cgroup for CPU time until the process exits
+	watchDone := make(chan struct{})
+	var watchWG sync.WaitGroup
+	watchWG.Add(1)
+	go func() {
+		defer watchWG.Done()
+		guard.watch(watchDone, e.exceeded)
+	}()
+	if limits.watchesWorkspace() {
+		watchWG.Add(1)
+		go func() {
+			defer watchWG.Done()
+			e.watchWorkspace(limits, baseline, watchDone)
+		}()
+	}
+
 	// Read output in goroutines
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		e.readOutput(stdout, "STDOUT")
+		e.readOutput(stdout, "STDOUT", limits.MaxOutputBytes, redactor.NewStream())
 	}()
 
 	go func() {
 		defer wg.Done()
-		e.readOutput(stderr, "STDERR")
+		e.readOutput(stderr, "STDERR", limits.MaxOutputBytes, redactor.NewStream())
 	}()
 
-	// Wait for command to complete
-	err = cmd.Wait()
+	// Drain output before waiting: Wait closes the pipes and would drop unread lines
 	wg.Wait()
+	err = cmd.Wait()
+
+	close(watchDone)
+	watchWG.Wait()
+
+	// Writes made just before exit are caught by a final scan
+	if limits.watchesWorkspace() {
+		limitErr, scanErr := limits.checkWorkspace(e.workDir, baseline)
+		if scanErr != nil {
+			return scanErr
+		}
+		if limitErr != nil {
+			e.exceeded(limitErr)
+		}
+	}
+
+	if limitErr := guard.exceeded(cmd.ProcessState); limitErr != nil {
+		e.exceeded(limitErr)
+	}
+	if cmdCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		e.exceeded(&LimitError{Limit: LimitWallClock, Detail: fmt.Sprintf("still running after %s", limits.Timeout)})
+	}
+
+	e.mu.Lock()
+	limitErr := e.limitErr
+	e.mu.Unlock()
+	if limitErr != nil {
+		return limitErr
+	}
 
 	if err != nil {
 		if cmdCtx.Err() == context.Canceled {
@@ -120,11 +215,55 @@ func (e *Executor) Execute(ctx context.Context, prompt string) error {
 	return nil
 }
 
-func (e *Executor) readOutput(r io.Reader, prefix string) {
+// exceeded records the first limit hit during a run and stops the process
+func (e *Executor) exceeded(limitErr *LimitError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.limitErr != nil {
+		return
+	}
+	e.limitErr = limitErr
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
+func (e *Executor) watchWorkspace(limits Limits, baseline workspace, done <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			limitErr, err := limits.checkWorkspace(e.workDir, baseline)
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+				continue
+			}
+			if limitErr != nil {
+				e.exceeded(limitErr)
+				return
+			}
+		}
+	}
+}
+
+// readOutput captures one output stream. Lines are redacted before they are stored or
+// printed, so secrets echoed by the agent never reach logs, storage or the job result.
+func (e *Executor) readOutput(r io.Reader, prefix string, maxBytes int64, stream *redact.Stream) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := stream.Line(scanner.Text())
 		e.mu.Lock()
+		e.outputBytes += int64(len(line)) + 1
+		if maxBytes > 0 && e.outputBytes > maxBytes {
+			e.mu.Unlock()
+			e.exceeded(&LimitError{Limit: LimitOutputSize, Detail: fmt.Sprintf("more than %d bytes of output", maxBytes)})
+			// Keep draining so the process is not blocked on a full pipe before it is killed
+			continue
+		}
 		e.output.WriteString(fmt.Sprintf("[%s] %s\n", prefix, line))
 		e.mu.Unlock()
 
@@ -174,7 +313,7 @@ func (e *Executor) GetOutput() string {
 
 // ParseOutput extracts FILES_CHANGED and SUMMARY from codex output
 func (e *Executor) ParseOutput() (filesChanged []string, summary string) {
-	output := e.GetOutput()
+	output := stdoutText(e.GetOutput())
 
 	// Look for FILES_CHANGED section
 	if idx := strings.Index(output, "FILES_CHANGED:"); idx != -1 {
@@ -208,6 +347,10 @@ func (e *Executor) ParseOutput() (filesChanged []string, summary string) {
 		lines := strings.Split(section, "\n")
 		for i := 0; i < len(lines); i++ {
 			line := strings.TrimSpace(lines[i])
+			// The summary may start on the SUMMARY: line itself or on the next one
+			if i == 0 && line == "" {
+				continue
+			}
 			if line == "" || strings.HasPrefix(line, "```") {
 				break
 			}
@@ -220,3 +363,16 @@ func (e *Executor) ParseOutput() (filesChanged []string, summary string) {
 
 	return filesChanged, summary
 }
+
+// stdoutText strips the stream prefixes added by readOutput and drops stderr lines
+func stdoutText(output string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "[STDERR] ") {
+			continue
+		}
+		b.WriteString(strings.TrimPrefix(line, "[STDOUT] "))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
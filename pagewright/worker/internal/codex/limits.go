@@ -0,0 +1,131 @@
+package codex
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Limit names reported by LimitError
+const (
+	LimitWallClock    = "wall_clock"
+	LimitOutputSize   = "output_size"
+	LimitFilesTouched = "files_touched"
+	LimitBytesWritten = "bytes_written"
+	LimitCPUTime      = "cpu_time"
+	LimitMemory       = "memory"
+)
+
+// watchInterval is how often the work directory is scanned for file limits
+var watchInterval = 250 * time.Millisecond
+
+// Limits bounds a single agent run. A zero value disables the corresponding limit.
+type Limits struct {
+	Timeout         time.Duration // wall-clock time per run
+	MaxOutputBytes  int64         // combined stdout and stderr
+	MaxFilesTouched int           // files created, modified or deleted in the work directory
+	MaxBytesWritten int64         // total size of created or modified files
+	CPUSeconds      uint64        // CPU time, of the whole cgroup when CgroupRoot is set, of each process with RLIMIT_CPU otherwise
+	CPUs            float64       // CPU bandwidth, cpu.max in the cgroup; needs CgroupRoot
+	MemoryBytes     uint64        // memory.max in a cgroup v2 when CgroupRoot is set, RLIMIT_DATA otherwise
+	CgroupRoot      string        // delegated cgroup v2 directory to create per-run cgroups in
+}
+
+// usesResources reports whether any CPU or memory limit is set
+func (l Limits) usesResources() bool {
+	return l.CPUSeconds > 0 || l.CPUs > 0 || l.MemoryBytes > 0
+}
+
+// LimitError is returned when the agent was stopped for exceeding a limit
+type LimitError struct {
+	Limit  string // one of the Limit* constants
+	Detail string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("agent exceeded %s limit: %s", e.Limit, e.Detail)
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// workspace is a snapshot of the regular files under a directory
+type workspace map[string]fileState
+
+func snapshotWorkspace(dir string) (workspace, error) {
+	snap := make(workspace)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may disappear while the agent is running
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		snap[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan work directory: %w", err)
+	}
+	return snap, nil
+}
+
+// changesSince counts files that differ from base and the bytes held by new or modified files
+func (w workspace) changesSince(base workspace) (files int, bytes int64) {
+	for path, cur := range w {
+		prev, ok := base[path]
+		if ok && prev == cur {
+			continue
+		}
+		files++
+		bytes += cur.size
+	}
+	for path := range base {
+		if _, ok := w[path]; !ok {
+			files++
+		}
+	}
+	return files, bytes
+}
+
+// checkWorkspace compares the work directory against base and reports a file limit breach
+func (l Limits) checkWorkspace(dir string, base workspace) (*LimitError, error) {
+	snap, err := snapshotWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, bytes := snap.changesSince(base)
+	if l.MaxFilesTouched > 0 && files > l.MaxFilesTouched {
+		return &LimitError{
+			Limit:  LimitFilesTouched,
+			Detail: fmt.Sprintf("%d files touched, maximum is %d", files, l.MaxFilesTouched),
+		}, nil
+	}
+	if l.MaxBytesWritten > 0 && bytes > l.MaxBytesWritten {
+		return &LimitError{
+			Limit:  LimitBytesWritten,
+			Detail: fmt.Sprintf("%d bytes written, maximum is %d", bytes, l.MaxBytesWritten),
+		}, nil
+	}
+	return nil, nil
+}
+
+func (l Limits) watchesWorkspace() bool {
+	return l.MaxFilesTouched > 0 || l.MaxBytesWritten > 0
+}
@@ -0,0 +1,162 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// limitedExecutor returns an executor running script in a fresh work directory
+func limitedExecutor(t *testing.T, script string, limits Limits) (*Executor, string) {
+	t.Helper()
+
+	binDir := t.TempDir()
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "existing.md"), []byte("hello"), 0644))
+
+	mockCodex := filepath.Join(binDir, "mock-codex")
+	require.NoError(t, os.WriteFile(mockCodex, []byte("#!/bin/sh\n"+script), 0755))
+
+	executor := NewExecutor(mockCodex, workDir, "test-key", "")
+	executor.SetLimits(limits)
+	return executor, workDir
+}
+
+func requireLimit(t *testing.T, err error, limit string) {
+	t.Helper()
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr), "expected a limit error, got %v", err)
+	assert.Equal(t, limit, limitErr.Limit)
+}
+
+func TestExecuteWithinLimits(t *testing.T) {
+	executor, _ := limitedExecutor(t, "echo one > a.md\necho done\n", Limits{
+		Timeout:         5 * time.Second,
+		MaxOutputBytes:  1024,
+		MaxFilesTouched: 2,
+		MaxBytesWritten: 1024,
+		CPUSeconds:      10,
+		MemoryBytes:     1 << 30,
+	})
+
+	require.NoError(t, executor.Execute(context.Background(), "prompt"))
+	assert.Contains(t, executor.GetOutput(), "done")
+}
+
+func TestExecuteWallClockLimit(t *testing.T) {
+	executor, _ := limitedExecutor(t, "sleep 10\n", Limits{Timeout: 200 * time.Millisecond})
+
+	start := time.Now()
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitWallClock)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestExecuteWallClockKillsChildren(t *testing.T) {
+	// The grandchild keeps stdout open; it must be killed along with the agent
+	executor, _ := limitedExecutor(t, "sleep 10 &\nwait\n", Limits{Timeout: 200 * time.Millisecond})
+
+	start := time.Now()
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitWallClock)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestExecuteOutputLimit(t *testing.T) {
+	executor, _ := limitedExecutor(t, "while :; do echo 0123456789; done\n", Limits{MaxOutputBytes: 4096})
+
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitOutputSize)
+	assert.Less(t, len(executor.GetOutput()), 2*4096)
+}
+
+func TestExecuteFilesTouchedLimit(t *testing.T) {
+	executor, _ := limitedExecutor(t, "for i in 1 2 3 4 5; do echo $i > page$i.md; done\n", Limits{MaxFilesTouched: 3})
+
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitFilesTouched)
+}
+
+func TestExecuteFilesTouchedCountsDeletes(t *testing.T) {
+	executor, _ := limitedExecutor(t, "rm existing.md\necho new > new.md\n", Limits{MaxFilesTouched: 1})
+
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitFilesTouched)
+}
+
+func TestExecuteBytesWrittenLimit(t *testing.T) {
+	executor, _ := limitedExecutor(t, "head -c 65536 /dev/zero > big.bin\n", Limits{MaxBytesWritten: 1024})
+
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitBytesWritten)
+}
+
+func TestExecuteBytesWrittenLimitWhileRunning(t *testing.T) {
+	executor, _ := limitedExecutor(t, "head -c 65536 /dev/zero > big.bin\nsleep 10\n", Limits{MaxBytesWritten: 1024})
+
+	start := time.Now()
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitBytesWritten)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestExecuteCPULimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("CPU limits use Linux rlimits")
+	}
+
+	executor, _ := limitedExecutor(t, "while :; do :; done\n", Limits{
+		Timeout:    30 * time.Second,
+		CPUSeconds: 1,
+	})
+
+	err := executor.Execute(context.Background(), "prompt")
+
+	requireLimit(t, err, LimitCPUTime)
+}
+
+func TestExecuteCancelledIsNotALimit(t *testing.T) {
+	executor, _ := limitedExecutor(t, "sleep 10\n", Limits{Timeout: 10 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err := executor.Execute(ctx, "prompt")
+
+	require.Error(t, err)
+	var limitErr *LimitError
+	assert.False(t, errors.As(err, &limitErr))
+}
+
+func TestWorkspaceChangesSince(t *testing.T) {
+	now := time.Now()
+	base := workspace{
+		"kept":     {size: 10, modTime: now},
+		"modified": {size: 10, modTime: now},
+		"deleted":  {size: 10, modTime: now},
+	}
+	current := workspace{
+		"kept":     {size: 10, modTime: now},
+		"modified": {size: 25, modTime: now.Add(time.Second)},
+		"created":  {size: 5, modTime: now},
+	}
+
+	files, bytes := current.changesSince(base)
+
+	assert.Equal(t, 3, files)
+	assert.Equal(t, int64(30), bytes)
+}
@@ -0,0 +1,291 @@
+package codex
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// rlimitEnv asks a re-executed worker binary to apply rlimits and exec the agent. Its value
+// is "<cpu seconds>:<data bytes>", zero leaving a limit unset.
+const rlimitEnv = "PAGEWRIGHT_AGENT_RLIMITS"
+
+// cpuPeriod is the cpu.max period, in microseconds
+const cpuPeriod = 100000
+
+func init() {
+	if spec, ok := os.LookupEnv(rlimitEnv); ok {
+		execLimited(spec)
+	}
+}
+
+// execLimited runs in the re-executed worker binary: it sets the rlimits on itself and
+// replaces itself with the agent, so the limits hold from the agent's first instruction
+// and are inherited by everything it spawns.
+func execLimited(spec string) {
+	if err := setRlimits(spec); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to apply agent limits: %v\n", err)
+		os.Exit(126)
+	}
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "failed to apply agent limits: no command")
+		os.Exit(126)
+	}
+
+	os.Unsetenv(rlimitEnv)
+	if err := syscall.Exec(os.Args[1], os.Args[1:], os.Environ()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to exec %s: %v\n", os.Args[1], err)
+		os.Exit(127)
+	}
+}
+
+func setRlimits(spec string) error {
+	cpu, data, ok := strings.Cut(spec, ":")
+	if !ok {
+		return fmt.Errorf("invalid %s %q", rlimitEnv, spec)
+	}
+	cpuSeconds, err := strconv.ParseUint(cpu, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid CPU limit %q: %w", cpu, err)
+	}
+	dataBytes, err := strconv.ParseUint(data, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid memory limit %q: %w", data, err)
+	}
+
+	if cpuSeconds > 0 {
+		// SIGXCPU at the soft limit, SIGKILL one second later
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: cpuSeconds, Max: cpuSeconds + 1}); err != nil {
+			return fmt.Errorf("failed to set CPU limit: %w", err)
+		}
+	}
+	if dataBytes > 0 {
+		if err := syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: dataBytes, Max: dataBytes}); err != nil {
+			return fmt.Errorf("failed to set memory limit: %w", err)
+		}
+	}
+	return nil
+}
+
+// resourceGuard applies CPU and memory limits to the agent process and its children
+type resourceGuard struct {
+	limits Limits
+	cgroup string   // per-run cgroup directory, empty when rlimits are used
+	cgFD   *os.File // held open until the process has started
+}
+
+// prepareResources configures cmd before it starts. The agent runs in its own process
+// group so that cancellation also kills the tools it spawns. With a delegated cgroup v2
+// root it runs in a fresh cgroup, which limits and measures the whole process tree;
+// otherwise it is started through the worker binary, which sets rlimits before exec.
+func prepareResources(cmd *exec.Cmd, limits Limits) *resourceGuard {
+	g := &resourceGuard{limits: limits}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	if limits.CgroupRoot != "" && limits.usesResources() {
+		if err := g.createCgroup(); err != nil {
+			// Fall back to rlimits; a missing delegation must not block jobs
+			fmt.Printf("Warning: cgroup limits unavailable, using rlimits: %v\n", err)
+			g.removeCgroup()
+		} else {
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = int(g.cgFD.Fd())
+			return g
+		}
+	}
+
+	if limits.CPUSeconds > 0 || limits.MemoryBytes > 0 {
+		self, err := os.Executable()
+		if err != nil {
+			fmt.Printf("Warning: rlimits unavailable, running the agent without CPU and memory limits: %v\n", err)
+			return g
+		}
+		cmd.Args = append([]string{self, cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = self
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d:%d", rlimitEnv, limits.CPUSeconds, limits.MemoryBytes))
+	}
+
+	return g
+}
+
+func (g *resourceGuard) createCgroup() error {
+	dir := filepath.Join(g.limits.CgroupRoot, fmt.Sprintf("agent-%d-%d", os.Getpid(), time.Now().UnixNano()))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	g.cgroup = dir
+
+	if g.limits.MemoryBytes > 0 {
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatUint(g.limits.MemoryBytes, 10)), 0644); err != nil {
+			return fmt.Errorf("failed to set memory.max: %w", err)
+		}
+		// Without this the memory limit can be sidestepped by swapping; not every host has swap accounting
+		_ = os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0644)
+	}
+
+	if g.limits.CPUs > 0 {
+		quota := int64(g.limits.CPUs * cpuPeriod)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(fmt.Sprintf("%d %d", quota, cpuPeriod)), 0644); err != nil {
+			return fmt.Errorf("failed to set cpu.max: %w", err)
+		}
+	}
+
+	if g.limits.CPUSeconds > 0 {
+		if _, err := cpuUsage(dir); err != nil {
+			return err
+		}
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open cgroup: %w", err)
+	}
+	g.cgFD = fd
+	return nil
+}
+
+// started releases what the running process no longer needs
+func (g *resourceGuard) started(pid int) error {
+	if g.cgFD != nil {
+		g.cgFD.Close()
+		g.cgFD = nil
+	}
+	return nil
+}
+
+// watch checks the CPU time used by the whole cgroup until done is closed. rlimits count
+// each process on its own, so only a cgroup catches an agent that spreads work over children.
+func (g *resourceGuard) watch(done <-chan struct{}, report func(*LimitError)) {
+	if g.cgroup == "" || g.limits.CPUSeconds == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if limitErr := g.cpuExceeded(); limitErr != nil {
+				report(limitErr)
+				return
+			}
+		}
+	}
+}
+
+// cpuExceeded reports whether the cgroup used up its CPU time
+func (g *resourceGuard) cpuExceeded() *LimitError {
+	used, err := cpuUsage(g.cgroup)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	if used < time.Duration(g.limits.CPUSeconds)*time.Second {
+		return nil
+	}
+	return &LimitError{
+		Limit:  LimitCPUTime,
+		Detail: fmt.Sprintf("used %s of CPU, maximum is %ds", used.Round(time.Millisecond), g.limits.CPUSeconds),
+	}
+}
+
+// exceeded reports whether the process was stopped by a CPU or memory limit
+func (g *resourceGuard) exceeded(state *os.ProcessState) *LimitError {
+	if state == nil {
+		return nil
+	}
+
+	if g.cgroup != "" {
+		if g.limits.MemoryBytes > 0 && g.oomKilled() {
+			return &LimitError{
+				Limit:  LimitMemory,
+				Detail: fmt.Sprintf("killed after reaching %d bytes", g.limits.MemoryBytes),
+			}
+		}
+		if g.limits.CPUSeconds > 0 {
+			return g.cpuExceeded()
+		}
+		return nil
+	}
+
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || g.limits.CPUSeconds == 0 {
+		return nil
+	}
+
+	used := state.UserTime() + state.SystemTime()
+	if ws.Signal() == syscall.SIGXCPU || (ws.Signal() == syscall.SIGKILL && used >= time.Duration(g.limits.CPUSeconds)*time.Second) {
+		return &LimitError{
+			Limit:  LimitCPUTime,
+			Detail: fmt.Sprintf("used %s of CPU, maximum is %ds", used.Round(time.Millisecond), g.limits.CPUSeconds),
+		}
+	}
+
+	return nil
+}
+
+func (g *resourceGuard) oomKilled() bool {
+	data, err := os.ReadFile(filepath.Join(g.cgroup, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if count, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			return strings.TrimSpace(count) != "0"
+		}
+	}
+	return false
+}
+
+// cpuUsage returns the CPU time used by every process that ran in the cgroup
+func cpuUsage(cgroup string) (time.Duration, error) {
+	data, err := os.ReadFile(filepath.Join(cgroup, "cpu.stat"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cpu.stat: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if usec, ok := strings.CutPrefix(line, "usage_usec "); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(usec), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid cpu.stat usage %q: %w", usec, err)
+			}
+			return time.Duration(n) * time.Microsecond, nil
+		}
+	}
+	return 0, fmt.Errorf("cpu.stat has no usage_usec")
+}
+
+// close kills anything left in the cgroup and removes it
+func (g *resourceGuard) close() {
+	if g.cgFD != nil {
+		g.cgFD.Close()
+		g.cgFD = nil
+	}
+	g.removeCgroup()
+}
+
+func (g *resourceGuard) removeCgroup() {
+	if g.cgroup == "" {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(g.cgroup, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < 10; i++ {
+		if err := os.Remove(g.cgroup); err == nil || os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	g.cgroup = ""
+}
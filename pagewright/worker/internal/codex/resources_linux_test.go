@@ -0,0 +1,40 @@
+package codex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRlimitsSetBeforeExec(t *testing.T) {
+	executor, _ := limitedExecutor(t, "ulimit -t\n", Limits{
+		Timeout:    10 * time.Second,
+		CPUSeconds: 7,
+	})
+
+	require.NoError(t, executor.Execute(context.Background(), "prompt"))
+
+	assert.Contains(t, executor.GetOutput(), "[STDOUT] 7")
+}
+
+func TestCPUUsage(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n"), 0644))
+
+	used, err := cpuUsage(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2500*time.Millisecond, used)
+
+	g := &resourceGuard{limits: Limits{CPUSeconds: 2}, cgroup: dir}
+	limitErr := g.cpuExceeded()
+	require.NotNil(t, limitErr)
+	assert.Equal(t, LimitCPUTime, limitErr.Limit)
+
+	g.limits.CPUSeconds = 3
+	assert.Nil(t, g.cpuExceeded())
+}
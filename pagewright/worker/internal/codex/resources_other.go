@@ -0,0 +1,23 @@
+//go:build !linux
+
+package codex
+
+import (
+	"os"
+	"os/exec"
+)
+
+// resourceGuard is a no-op outside Linux: CPU and memory limits need rlimits or cgroup v2
+type resourceGuard struct{}
+
+func prepareResources(cmd *exec.Cmd, limits Limits) *resourceGuard {
+	return &resourceGuard{}
+}
+
+func (g *resourceGuard) started(pid int) error { return nil }
+
+func (g *resourceGuard) watch(done <-chan struct{}, report func(*LimitError)) {}
+
+func (g *resourceGuard) exceeded(state *os.ProcessState) *LimitError { return nil }
+
+func (g *resourceGuard) close() {}
@@ -0,0 +1,113 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+)
+
+// locatedError matches "path:line:col: message" anywhere in a compiler output line
+var locatedError = regexp.MustCompile(`(\S+?):(\d+):(\d+): (.+)$`)
+
+// Builder wraps the pagewrightc build command
+type Builder struct {
+	binaryPath string
+	themeDir   string
+}
+
+// NewBuilder creates a new compiler builder
+func NewBuilder(binaryPath, themeDir string) *Builder {
+	return &Builder{
+		binaryPath: binaryPath,
+		themeDir:   themeDir,
+	}
+}
+
+// Build compiles siteDir/content into siteDir/public.
+// Compile errors in the site are returned as a list so they can be fed back to the agent;
+// the error return is reserved for failures to run the compiler at all.
+func (b *Builder) Build(ctx context.Context, siteDir string) ([]types.CompileError, error) {
+	cmd := exec.CommandContext(ctx, b.binaryPath, "build",
+		"--theme", b.themeDir,
+		"--content", filepath.Join(siteDir, "content"),
+		"--out", filepath.Join(siteDir, "public"),
+	)
+	cmd.Dir = siteDir
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil, nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run compiler: %w", err)
+	}
+
+	compileErrors := ParseErrors(string(output), siteDir)
+	if len(compileErrors) == 0 {
+		return nil, fmt.Errorf("compiler failed without reporting errors: %w", err)
+	}
+
+	return compileErrors, nil
+}
+
+// ParseErrors extracts compile errors from compiler output.
+// Lines with a file:line:col location are preferred; otherwise the last error line is used.
+// File paths under baseDir are made relative so the agent sees site paths.
+func ParseErrors(output, baseDir string) []types.CompileError {
+	var located []types.CompileError
+	var lastError string
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if m := locatedError.FindStringSubmatch(line); m != nil {
+			lineNum, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			located = append(located, types.CompileError{
+				File:    relativePath(m[1], baseDir),
+				Line:    lineNum,
+				Column:  column,
+				Message: m[4],
+			})
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(line), "error") {
+			lastError = line
+		}
+	}
+
+	if len(located) > 0 {
+		return located
+	}
+
+	if lastError == "" {
+		return nil
+	}
+
+	message := strings.TrimSpace(lastError[strings.Index(lastError, ":")+1:])
+	return []types.CompileError{{Message: message}}
+}
+
+func relativePath(path, baseDir string) string {
+	if baseDir == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
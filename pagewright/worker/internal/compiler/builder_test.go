@@ -0,0 +1,64 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorsLocated(t *testing.T) {
+	output := `Loading theme...
+Processing pages...
+Error: failed to compile page /work/site/content/home/index.md: /work/site/content/home/index.md:12:0: component block not closed (missing :::)
+`
+
+	errs := ParseErrors(output, "/work/site")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "content/home/index.md", errs[0].File)
+	assert.Equal(t, 12, errs[0].Line)
+	assert.Equal(t, 0, errs[0].Column)
+	assert.Equal(t, "component block not closed (missing :::)", errs[0].Message)
+}
+
+func TestParseErrorsFallback(t *testing.T) {
+	output := `Discovering pages...
+Error: /work/site/content: no home page found (missing home/index.md or index.md)
+`
+
+	errs := ParseErrors(output, "/work/site")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "", errs[0].File)
+	assert.Contains(t, errs[0].Message, "no home page found")
+}
+
+func TestParseErrorsNone(t *testing.T) {
+	assert.Empty(t, ParseErrors("Build complete!\n", "/work/site"))
+}
+
+func TestBuildReportsCompileErrors(t *testing.T) {
+	siteDir := t.TempDir()
+
+	mockCompiler := filepath.Join(t.TempDir(), "mock-pagewrightc")
+	script := `#!/bin/sh
+echo "Error: $PWD/content/about/index.md:3:1: unknown component: Banner"
+exit 1
+`
+	require.NoError(t, os.WriteFile(mockCompiler, []byte(script), 0755))
+
+	builder := NewBuilder(mockCompiler, "/themes/starter")
+	errs, err := builder.Build(context.Background(), siteDir)
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "content/about/index.md", errs[0].File)
+	assert.Equal(t, 3, errs[0].Line)
+}
+
+func TestBuildMissingBinary(t *testing.T) {
+	builder := NewBuilder("/nonexistent/pagewrightc", "/themes/starter")
+	_, err := builder.Build(context.Background(), t.TempDir())
+	assert.Error(t, err)
+}
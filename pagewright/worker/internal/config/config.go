@@ -3,22 +3,66 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Port             int
-	WorkDir          string
-	LLMKey           string
-	LLMBaseURL       string
-	ManagerURL       string
-	StorageURL       string
-	JobJSON          string
+	Port       int
+	WorkDir    string
+	LLMKey     string
+	LLMBaseURL string
+	ManagerURL string
+	StorageURL string
+	JobJSON    string // legacy: full job JSON in PAGEWRIGHT_JOB
+
+	// Job payload sources, preferred over JobJSON: a mounted file, or a one-time manager claim
+	JobFile    string
+	JobID      string
+	ClaimToken string
+
 	CodexBinary      string
 	InstructionsPath string
+	CompilerBinary   string
+	ThemeDir         string
+	MaxRepairs       int
+	SessionTurns     int
+
+	// Archive format of uploaded artifacts: tar.gz or tar.zst
+	ArtifactFormat string
+
+	// Limits applied to each agent run; zero disables a limit
+	AgentTimeout         time.Duration
+	AgentMaxOutputBytes  int64
+	AgentMaxFilesTouched int
+	AgentMaxBytesWritten int64
+	AgentCPUSeconds      uint64
+	AgentCPUs            float64
+	AgentMemoryBytes     uint64
+	AgentCgroupRoot      string
+
+	// Names of environment variables whose values are masked in agent output, logs and results
+	RedactEnv []string
+
+	// Post-build render checks
+	RenderChecks     bool
+	ScreenshotBinary string
+	ScreenshotPages  int
 }
 
 func LoadConfig() *Config {
 	port, _ := strconv.Atoi(getEnv("PAGEWRIGHT_WORKER_PORT", "8082"))
+	maxRepairs, _ := strconv.Atoi(getEnv("PAGEWRIGHT_MAX_REPAIRS", "2"))
+	sessionTurns, _ := strconv.Atoi(getEnv("PAGEWRIGHT_SESSION_TURNS", "5"))
+	agentTimeout, _ := time.ParseDuration(getEnv("PAGEWRIGHT_AGENT_TIMEOUT", "15m"))
+	agentMaxOutput, _ := strconv.ParseInt(getEnv("PAGEWRIGHT_AGENT_MAX_OUTPUT_BYTES", "10485760"), 10, 64)
+	agentMaxFiles, _ := strconv.Atoi(getEnv("PAGEWRIGHT_AGENT_MAX_FILES_TOUCHED", "200"))
+	agentMaxWritten, _ := strconv.ParseInt(getEnv("PAGEWRIGHT_AGENT_MAX_BYTES_WRITTEN", "52428800"), 10, 64)
+	agentCPU, _ := strconv.ParseUint(getEnv("PAGEWRIGHT_AGENT_CPU_SECONDS", "600"), 10, 64)
+	agentCPUs, _ := strconv.ParseFloat(getEnv("PAGEWRIGHT_AGENT_CPUS", "0"), 64)
+	agentMemory, _ := strconv.ParseUint(getEnv("PAGEWRIGHT_AGENT_MEMORY_BYTES", "2147483648"), 10, 64)
+	renderChecks, _ := strconv.ParseBool(getEnv("PAGEWRIGHT_RENDER_CHECKS", "false"))
+	screenshotPages, _ := strconv.Atoi(getEnv("PAGEWRIGHT_SCREENSHOT_PAGES", "10"))
 
 	return &Config{
 		Port:             port,
@@ -28,9 +72,53 @@ func LoadConfig() *Config {
 		ManagerURL:       getEnv("PAGEWRIGHT_MANAGER_URL", "http://localhost:8081"),
 		StorageURL:       getEnv("PAGEWRIGHT_STORAGE_URL", "http://localhost:8080"),
 		JobJSON:          getEnv("PAGEWRIGHT_JOB", ""),
+		JobFile:          getEnv("PAGEWRIGHT_JOB_FILE", ""),
+		JobID:            getEnv("PAGEWRIGHT_JOB_ID", ""),
+		ClaimToken:       getEnv("PAGEWRIGHT_CLAIM_TOKEN", ""),
 		CodexBinary:      getEnv("PAGEWRIGHT_CODEX_BINARY", "/usr/local/bin/codex"),
 		InstructionsPath: getEnv("PAGEWRIGHT_INSTRUCTIONS_PATH", "/.codex/instructions.md"),
+		CompilerBinary:   getEnv("PAGEWRIGHT_COMPILER_BINARY", "/usr/local/bin/pagewrightc"),
+		ThemeDir:         getEnv("PAGEWRIGHT_THEME_DIR", "/themes/starter"),
+		MaxRepairs:       maxRepairs,
+		SessionTurns:     sessionTurns,
+		ArtifactFormat:   getEnv("PAGEWRIGHT_ARTIFACT_FORMAT", "tar.gz"),
+
+		AgentTimeout:         agentTimeout,
+		AgentMaxOutputBytes:  agentMaxOutput,
+		AgentMaxFilesTouched: agentMaxFiles,
+		AgentMaxBytesWritten: agentMaxWritten,
+		AgentCPUSeconds:      agentCPU,
+		AgentCPUs:            agentCPUs,
+		AgentMemoryBytes:     agentMemory,
+		AgentCgroupRoot:      getEnv("PAGEWRIGHT_AGENT_CGROUP_ROOT", ""),
+
+		RedactEnv: splitList(getEnv("PAGEWRIGHT_REDACT_ENV", "")),
+
+		RenderChecks:     renderChecks,
+		ScreenshotBinary: getEnv("PAGEWRIGHT_SCREENSHOT_BINARY", ""),
+		ScreenshotPages:  screenshotPages,
+	}
+}
+
+// RedactSecrets returns the secret values to mask: the LLM key, the claim token and every RedactEnv variable
+func (c *Config) RedactSecrets() []string {
+	secrets := []string{c.LLMKey, c.ClaimToken}
+	for _, name := range c.RedactEnv {
+		if value := os.Getenv(name); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
 
 func getEnv(key, defaultValue string) string {
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,8 +20,29 @@ func TestLoadConfigDefaults(t *testing.T) {
 	assert.Equal(t, "https://api.openai.com/v1", cfg.LLMBaseURL)
 	assert.Equal(t, "http://localhost:8081", cfg.ManagerURL)
 	assert.Equal(t, "http://localhost:8080", cfg.StorageURL)
+	assert.Equal(t, "", cfg.JobJSON)
+	assert.Equal(t, "", cfg.JobFile)
+	assert.Equal(t, "", cfg.JobID)
+	assert.Equal(t, "", cfg.ClaimToken)
 	assert.Equal(t, "/usr/local/bin/codex", cfg.CodexBinary)
 	assert.Equal(t, "/.codex/instructions.md", cfg.InstructionsPath)
+	assert.Equal(t, "/usr/local/bin/pagewrightc", cfg.CompilerBinary)
+	assert.Equal(t, "/themes/starter", cfg.ThemeDir)
+	assert.Equal(t, 2, cfg.MaxRepairs)
+	assert.Equal(t, 5, cfg.SessionTurns)
+	assert.Equal(t, "tar.gz", cfg.ArtifactFormat)
+	assert.Empty(t, cfg.RedactEnv)
+	assert.Equal(t, 15*time.Minute, cfg.AgentTimeout)
+	assert.Equal(t, int64(10485760), cfg.AgentMaxOutputBytes)
+	assert.Equal(t, 200, cfg.AgentMaxFilesTouched)
+	assert.Equal(t, int64(52428800), cfg.AgentMaxBytesWritten)
+	assert.Equal(t, uint64(600), cfg.AgentCPUSeconds)
+	assert.Equal(t, float64(0), cfg.AgentCPUs)
+	assert.Equal(t, uint64(2147483648), cfg.AgentMemoryBytes)
+	assert.Equal(t, "", cfg.AgentCgroupRoot)
+	assert.False(t, cfg.RenderChecks)
+	assert.Equal(t, "", cfg.ScreenshotBinary)
+	assert.Equal(t, 10, cfg.ScreenshotPages)
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
@@ -31,8 +53,28 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	os.Setenv("PAGEWRIGHT_LLM_URL", "https://custom.api.com")
 	os.Setenv("PAGEWRIGHT_MANAGER_URL", "http://manager:8081")
 	os.Setenv("PAGEWRIGHT_STORAGE_URL", "http://storage:8080")
+	os.Setenv("PAGEWRIGHT_JOB_FILE", "/run/pagewright/job.json")
+	os.Setenv("PAGEWRIGHT_JOB_ID", "job-123")
+	os.Setenv("PAGEWRIGHT_CLAIM_TOKEN", "claim-token")
 	os.Setenv("PAGEWRIGHT_CODEX_BINARY", "/custom/codex")
 	os.Setenv("PAGEWRIGHT_INSTRUCTIONS_PATH", "/custom/instructions.md")
+	os.Setenv("PAGEWRIGHT_COMPILER_BINARY", "/custom/pagewrightc")
+	os.Setenv("PAGEWRIGHT_THEME_DIR", "/custom/theme")
+	os.Setenv("PAGEWRIGHT_MAX_REPAIRS", "5")
+	os.Setenv("PAGEWRIGHT_SESSION_TURNS", "8")
+	os.Setenv("PAGEWRIGHT_ARTIFACT_FORMAT", "tar.zst")
+	os.Setenv("PAGEWRIGHT_REDACT_ENV", "GITHUB_TOKEN, DEPLOY_KEY")
+	os.Setenv("PAGEWRIGHT_AGENT_TIMEOUT", "90s")
+	os.Setenv("PAGEWRIGHT_AGENT_MAX_OUTPUT_BYTES", "1024")
+	os.Setenv("PAGEWRIGHT_AGENT_MAX_FILES_TOUCHED", "10")
+	os.Setenv("PAGEWRIGHT_AGENT_MAX_BYTES_WRITTEN", "2048")
+	os.Setenv("PAGEWRIGHT_AGENT_CPU_SECONDS", "30")
+	os.Setenv("PAGEWRIGHT_AGENT_CPUS", "1.5")
+	os.Setenv("PAGEWRIGHT_AGENT_MEMORY_BYTES", "536870912")
+	os.Setenv("PAGEWRIGHT_AGENT_CGROUP_ROOT", "/sys/fs/cgroup/pagewright")
+	os.Setenv("PAGEWRIGHT_RENDER_CHECKS", "true")
+	os.Setenv("PAGEWRIGHT_SCREENSHOT_BINARY", "/usr/bin/chromium")
+	os.Setenv("PAGEWRIGHT_SCREENSHOT_PAGES", "3")
 
 	cfg := LoadConfig()
 
@@ -42,8 +84,42 @@ func TestLoadConfigFromEnv(t *testing.T) {
 	assert.Equal(t, "https://custom.api.com", cfg.LLMBaseURL)
 	assert.Equal(t, "http://manager:8081", cfg.ManagerURL)
 	assert.Equal(t, "http://storage:8080", cfg.StorageURL)
+	assert.Equal(t, "/run/pagewright/job.json", cfg.JobFile)
+	assert.Equal(t, "job-123", cfg.JobID)
+	assert.Equal(t, "claim-token", cfg.ClaimToken)
 	assert.Equal(t, "/custom/codex", cfg.CodexBinary)
 	assert.Equal(t, "/custom/instructions.md", cfg.InstructionsPath)
+	assert.Equal(t, "/custom/pagewrightc", cfg.CompilerBinary)
+	assert.Equal(t, "/custom/theme", cfg.ThemeDir)
+	assert.Equal(t, 5, cfg.MaxRepairs)
+	assert.Equal(t, 8, cfg.SessionTurns)
+	assert.Equal(t, "tar.zst", cfg.ArtifactFormat)
+	assert.Equal(t, []string{"GITHUB_TOKEN", "DEPLOY_KEY"}, cfg.RedactEnv)
+	assert.Equal(t, 90*time.Second, cfg.AgentTimeout)
+	assert.Equal(t, int64(1024), cfg.AgentMaxOutputBytes)
+	assert.Equal(t, 10, cfg.AgentMaxFilesTouched)
+	assert.Equal(t, int64(2048), cfg.AgentMaxBytesWritten)
+	assert.Equal(t, uint64(30), cfg.AgentCPUSeconds)
+	assert.Equal(t, 1.5, cfg.AgentCPUs)
+	assert.Equal(t, uint64(536870912), cfg.AgentMemoryBytes)
+	assert.Equal(t, "/sys/fs/cgroup/pagewright", cfg.AgentCgroupRoot)
+	assert.True(t, cfg.RenderChecks)
+	assert.Equal(t, "/usr/bin/chromium", cfg.ScreenshotBinary)
+	assert.Equal(t, 3, cfg.ScreenshotPages)
+
+	os.Clearenv()
+}
+
+func TestRedactSecrets(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("PAGEWRIGHT_LLM_KEY", "sk-test-key")
+	os.Setenv("PAGEWRIGHT_CLAIM_TOKEN", "claim-token-123")
+	os.Setenv("PAGEWRIGHT_REDACT_ENV", "GITHUB_TOKEN,UNSET_VAR")
+	os.Setenv("GITHUB_TOKEN", "ghp_secret")
+
+	cfg := LoadConfig()
+
+	assert.Equal(t, []string{"sk-test-key", "claim-token-123", "ghp_secret"}, cfg.RedactSecrets())
 
 	os.Clearenv()
 }
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/config"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+)
+
+// ErrNoJob is returned when no job source is configured
+var ErrNoJob = errors.New("no job configured: set PAGEWRIGHT_JOB_FILE or PAGEWRIGHT_JOB_ID and PAGEWRIGHT_CLAIM_TOKEN")
+
+// LoadJob returns the job this worker was spawned for. Sources are tried in order: a mounted
+// job file, a claim from the manager, then the legacy PAGEWRIGHT_JOB variable.
+func LoadJob(cfg *config.Config, client *Client) (*types.Job, error) {
+	switch {
+	case cfg.JobFile != "":
+		data, err := os.ReadFile(cfg.JobFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job file: %w", err)
+		}
+		return parseJob(data)
+
+	case cfg.ClaimToken != "":
+		if cfg.JobID == "" {
+			return nil, fmt.Errorf("PAGEWRIGHT_JOB_ID is required with PAGEWRIGHT_CLAIM_TOKEN")
+		}
+		job, err := client.ClaimJob(cfg.JobID, cfg.ClaimToken)
+		if err != nil {
+			return nil, err
+		}
+		if job.JobID != cfg.JobID {
+			return nil, fmt.Errorf("claimed job %s does not match %s", job.JobID, cfg.JobID)
+		}
+		return job, nil
+
+	case cfg.JobJSON != "":
+		return parseJob([]byte(cfg.JobJSON))
+	}
+
+	return nil, ErrNoJob
+}
+
+func parseJob(data []byte) (*types.Job, error) {
+	var job types.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job: %w", err)
+	}
+	if job.JobID == "" || job.SiteID == "" {
+		return nil, fmt.Errorf("job is missing job_id or site_id")
+	}
+	return &job, nil
+}
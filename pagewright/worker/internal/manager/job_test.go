@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jobJSON = `{"job_id":"job-1","site_id":"site-1","prompt":"Add a contact page","lock_token":"lock"}`
+
+func TestLoadJobFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.json")
+	require.NoError(t, os.WriteFile(path, []byte(jobJSON), 0600))
+
+	// The file wins over the other sources
+	cfg := &config.Config{JobFile: path, ClaimToken: "token", JobID: "job-1", JobJSON: `{}`}
+	job, err := LoadJob(cfg, NewClient("http://unused"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "job-1", job.JobID)
+	assert.Equal(t, "Add a contact page", job.Prompt)
+}
+
+func TestLoadJobClaimsFromManager(t *testing.T) {
+	claims := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/jobs/job-1/claim", r.URL.Path)
+		assert.Equal(t, "Bearer one-time-token", r.Header.Get("Authorization"))
+		claims++
+		if claims > 1 {
+			http.Error(w, "job has already been claimed", http.StatusConflict)
+			return
+		}
+		w.Write([]byte(jobJSON))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{JobID: "job-1", ClaimToken: "one-time-token", JobJSON: `{"job_id":"legacy"}`}
+	client := NewClient(server.URL)
+
+	job, err := LoadJob(cfg, client)
+	require.NoError(t, err)
+	assert.Equal(t, "lock", job.LockToken)
+
+	_, err = LoadJob(cfg, client)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "409")
+}
+
+func TestLoadJobRejectsMismatchedClaim(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobJSON))
+	}))
+	defer server.Close()
+
+	_, err := LoadJob(&config.Config{JobID: "job-2", ClaimToken: "token"}, NewClient(server.URL))
+	assert.Error(t, err)
+
+	_, err = LoadJob(&config.Config{ClaimToken: "token"}, NewClient(server.URL))
+	assert.Error(t, err)
+}
+
+func TestLoadJobLegacyEnv(t *testing.T) {
+	job, err := LoadJob(&config.Config{JobJSON: jobJSON}, NewClient("http://unused"))
+	require.NoError(t, err)
+	assert.Equal(t, "site-1", job.SiteID)
+
+	_, err = LoadJob(&config.Config{JobJSON: `{"prompt":"no ids"}`}, NewClient("http://unused"))
+	assert.Error(t, err)
+}
+
+func TestLoadJobNoSource(t *testing.T) {
+	_, err := LoadJob(&config.Config{}, NewClient("http://unused"))
+	assert.ErrorIs(t, err, ErrNoJob)
+}
@@ -0,0 +1,423 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/artifact"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/attachments"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/checks"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/codex"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/compiler"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/config"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/redact"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/session"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+)
+
+// StatusReporter receives progress updates while a job runs
+type StatusReporter interface {
+	UpdateStatus(state, step string, progress int)
+}
+
+// Pipeline runs a job through fetch, edit, compile, pack and upload
+type Pipeline struct {
+	workDir          string
+	instructionsPath string
+	maxRepairs       int
+	sessionTurns     int
+	artifactFormat   string
+
+	executor *codex.Executor
+	builder  *compiler.Builder
+	checker  *checks.Checker // nil when render checks are disabled
+	storage  *storage.Client
+	redactor *redact.Redactor
+	status   StatusReporter
+
+	log strings.Builder
+}
+
+// NewPipeline creates a new job pipeline.
+// The executor must be configured to run in SiteDir(cfg.WorkDir).
+func NewPipeline(cfg *config.Config, executor *codex.Executor, builder *compiler.Builder, storageClient *storage.Client, status StatusReporter) *Pipeline {
+	executor.SetLimits(codex.Limits{
+		Timeout:         cfg.AgentTimeout,
+		MaxOutputBytes:  cfg.AgentMaxOutputBytes,
+		MaxFilesTouched: cfg.AgentMaxFilesTouched,
+		MaxBytesWritten: cfg.AgentMaxBytesWritten,
+		CPUSeconds:      cfg.AgentCPUSeconds,
+		CPUs:            cfg.AgentCPUs,
+		MemoryBytes:     cfg.AgentMemoryBytes,
+		CgroupRoot:      cfg.AgentCgroupRoot,
+	})
+
+	redactor := redact.New(cfg.RedactSecrets()...)
+	executor.SetRedactor(redactor)
+
+	p := &Pipeline{
+		workDir:          cfg.WorkDir,
+		instructionsPath: cfg.InstructionsPath,
+		maxRepairs:       cfg.MaxRepairs,
+		sessionTurns:     cfg.SessionTurns,
+		artifactFormat:   cfg.ArtifactFormat,
+		executor:         executor,
+		builder:          builder,
+		storage:          storageClient,
+		redactor:         redactor,
+		status:           status,
+	}
+
+	if cfg.RenderChecks {
+		var screenshotter *checks.Screenshotter
+		if cfg.ScreenshotBinary != "" {
+			screenshotter = checks.NewScreenshotter(cfg.ScreenshotBinary, cfg.ScreenshotPages)
+		}
+		p.checker = checks.NewChecker(screenshotter)
+	}
+
+	return p
+}
+
+// maxTurnDiffBytes bounds the diff stored with each session turn
+const maxTurnDiffBytes = 4096
+
+// SiteDir returns the directory the site is unpacked into
+func SiteDir(workDir string) string {
+	return filepath.Join(workDir, "site")
+}
+
+// Run executes the job end to end and returns the result to report to the manager
+func (p *Pipeline) Run(ctx context.Context, job *types.Job) (*types.JobResult, error) {
+	siteDir := SiteDir(p.workDir)
+
+	p.log.Reset()
+
+	// Stream the source version straight into the site directory, or start from an empty site
+	if job.SourceVersion != "" {
+		p.status.UpdateStatus("fetching", "Fetching source artifact", 10)
+		err := p.storage.FetchArtifact(job.SiteID, job.SourceVersion, func(r io.Reader) error {
+			return artifact.UnpackFrom(r, siteDir)
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if err := os.MkdirAll(siteDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create site directory: %w", err)
+	}
+
+	if err := artifact.PatchInstructions(siteDir, p.instructionsPath); err != nil {
+		return nil, err
+	}
+
+	// Fingerprint the site sources so a run that changes nothing can be detected.
+	// public/ is compiler output and is left out.
+	baseDigest, err := artifact.Digest(siteDir, "public")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint site: %w", err)
+	}
+
+	before, err := session.Take(siteDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uploaded files go in after the fingerprint: placing them is a change, even if the
+	// agent does nothing else
+	var attached []attachments.File
+	if len(job.Attachments) > 0 {
+		p.status.UpdateStatus("fetching", "Fetching attachments", 20)
+		attached, err = attachments.Place(siteDir, job.Attachments, func(name string, w io.Writer) error {
+			return p.storage.FetchAttachment(job.SiteID, job.UploadID, name, w)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Run the agent with the site's earlier turns as context, repairing compile errors as needed
+	p.status.UpdateStatus("executing", "Running agent", 30)
+	prompt := session.Prompt(p.history(job), attachments.Prompt(attached, job.Prompt))
+	filesChanged, summary, attempts, err := p.executeWithRepair(ctx, prompt, siteDir)
+	filesChanged = mergeFiles(attachments.Paths(attached), filesChanged)
+	if err != nil {
+		if len(attempts) > 0 {
+			p.recordFailure(job, filesChanged, attempts, err)
+		}
+		return nil, err
+	}
+
+	after, err := session.Take(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	// Agent output is redacted as it is captured; the prompt and the diff of files the agent
+	// wrote are redacted here before they leave the worker.
+	jobPrompt := p.redactor.String(job.Prompt)
+	turn := &types.SessionTurn{
+		JobID:        job.JobID,
+		BaseBuildID:  job.SourceVersion,
+		Prompt:       jobPrompt,
+		Summary:      summary,
+		FilesChanged: filesChanged,
+		Diff:         session.Truncate(p.redactor.String(session.Diff(before, after)), maxTurnDiffBytes),
+	}
+
+	resultDigest, err := artifact.Digest(siteDir, "public")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint site: %w", err)
+	}
+
+	// Nothing changed: report it without creating a new version
+	if resultDigest == baseDigest {
+		turn.Status = types.JobResultNoChanges
+		turn.FilesChanged = nil
+		turn.Diff = ""
+		p.recordTurn(job.SiteID, turn)
+
+		p.status.UpdateStatus("done", "No changes", 100)
+		return &types.JobResult{
+			JobID:  job.JobID,
+			Status: types.JobResultNoChanges,
+			Result: summary,
+		}, nil
+	}
+
+	manifest := &types.Manifest{
+		SiteID:         job.SiteID,
+		BuildID:        job.TargetVersion,
+		BaseBuildID:    job.SourceVersion,
+		FencingToken:   job.FencingToken,
+		Prompt:         jobPrompt,
+		FilesChanged:   filesChanged,
+		ChangesSummary: summary,
+		RepairAttempts: attempts,
+		Draft:          job.DryRun,
+	}
+
+	if p.checker != nil {
+		p.status.UpdateStatus("checking", "Checking rendered pages", 60)
+		p.runChecks(ctx, siteDir, manifest)
+	}
+
+	fileCount, err := artifact.GetFileCount(siteDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	totalSize, err := artifact.GetTotalSize(siteDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure site size: %w", err)
+	}
+
+	// Pack the result straight into the upload. Packing is deterministic, so the digest
+	// identifies the site content.
+	p.status.UpdateStatus("uploading", "Packing and uploading artifact", 70)
+	contentType := artifact.ContentType(p.artifactFormat)
+	digest, err := p.storage.UploadArtifact(job.SiteID, job.TargetVersion, contentType, func(w io.Writer) error {
+		return artifact.PackDeterministic(siteDir, w, p.artifactFormat)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.CreatedAt = time.Now().UTC()
+	manifest.FileCount = fileCount
+	manifest.TotalSize = totalSize
+	manifest.ArtifactSHA256 = digest
+
+	// Upload manifest and logs
+	p.status.UpdateStatus("uploading", "Uploading manifest and logs", 90)
+	if err := p.storage.UploadManifest(job.SiteID, job.TargetVersion, manifest); err != nil {
+		return nil, err
+	}
+
+	if err := p.storage.UploadLog(job.SiteID, job.TargetVersion, p.Log()); err != nil {
+		return nil, err
+	}
+
+	turn.Status = types.JobResultCompleted
+	turn.BuildID = job.TargetVersion
+	p.recordTurn(job.SiteID, turn)
+
+	p.status.UpdateStatus("done", "Completed", 100)
+
+	return &types.JobResult{
+		JobID:         job.JobID,
+		Status:        types.JobResultCompleted,
+		TargetVersion: job.TargetVersion,
+		Result:        summary,
+		ManifestPath:  fmt.Sprintf("artifacts/%s/%s/manifest", job.SiteID, job.TargetVersion),
+		Draft:         job.DryRun,
+	}, nil
+}
+
+// history returns the earlier turns to give the agent: those sent with the job, or the
+// most recent ones stored for the site. Sessions are best effort and never fail a job.
+func (p *Pipeline) history(job *types.Job) []types.SessionTurn {
+	if len(job.History) > 0 || p.sessionTurns <= 0 {
+		return job.History
+	}
+
+	turns, err := p.storage.FetchSession(job.SiteID, p.sessionTurns)
+	if err != nil {
+		fmt.Printf("Warning: failed to fetch session for site %s: %v\n", job.SiteID, err)
+		return nil
+	}
+	return turns
+}
+
+// recordTurn stores the finished turn so later jobs on the site can refer to it
+func (p *Pipeline) recordTurn(siteID string, turn *types.SessionTurn) {
+	if p.sessionTurns <= 0 {
+		return
+	}
+	turn.Timestamp = time.Now().UTC()
+	if err := p.storage.AppendSessionTurn(siteID, turn); err != nil {
+		fmt.Printf("Warning: failed to record session turn for site %s: %v\n", siteID, err)
+	}
+}
+
+// recordFailure uploads a manifest and the logs for a build that stored no artifact, so the
+// compile checks of a failed repair loop are kept. It is best effort: the job fails anyway.
+func (p *Pipeline) recordFailure(job *types.Job, filesChanged []string, attempts []types.RepairAttempt, err error) {
+	manifest := &types.Manifest{
+		SiteID:         job.SiteID,
+		BuildID:        job.TargetVersion,
+		BaseBuildID:    job.SourceVersion,
+		FencingToken:   job.FencingToken,
+		Prompt:         p.redactor.String(job.Prompt),
+		CreatedAt:      time.Now().UTC(),
+		FilesChanged:   filesChanged,
+		RepairAttempts: attempts,
+		Draft:          job.DryRun,
+		Error:          p.redactor.String(err.Error()),
+	}
+
+	if err := p.storage.UploadManifest(job.SiteID, job.TargetVersion, manifest); err != nil {
+		fmt.Printf("Warning: failed to upload manifest of failed build %s: %v\n", job.TargetVersion, err)
+	}
+	if err := p.storage.UploadLog(job.SiteID, job.TargetVersion, p.Log()); err != nil {
+		fmt.Printf("Warning: failed to upload logs of failed build %s: %v\n", job.TargetVersion, err)
+	}
+}
+
+// runChecks crawls the compiled site and records the report in the manifest.
+// Check failures are reported, not fatal: the version is still created.
+func (p *Pipeline) runChecks(ctx context.Context, siteDir string, manifest *types.Manifest) {
+	result, err := p.checker.Run(ctx, siteDir)
+	if err != nil {
+		fmt.Fprintf(&p.log, "=== Render checks ===\nfailed to run: %v\n", err)
+		return
+	}
+
+	manifest.Entrypoints = result.Pages
+	manifest.Screenshots = result.Screenshots
+	manifest.ConsoleErrors = result.ConsoleErrors
+	manifest.ChecksPassed = result.Passed
+	manifest.Checks = result.Report
+
+	fmt.Fprintf(&p.log, "=== Render checks ===\n%d pages, %d links, %d errors, %d warnings\n",
+		result.Report.PagesChecked, result.Report.LinksChecked, result.Report.Errors, result.Report.Warnings)
+}
+
+// FailedResult builds the result reported to the manager when Run returns an error
+func (p *Pipeline) FailedResult(job *types.Job, err error) *types.JobResult {
+	result := &types.JobResult{
+		JobID:         job.JobID,
+		Status:        types.JobResultFailed,
+		TargetVersion: job.TargetVersion,
+		ErrorMessage:  p.redactor.String(err.Error()),
+	}
+
+	var limitErr *codex.LimitError
+	if errors.As(err, &limitErr) {
+		result.LimitExceeded = limitErr.Limit
+	}
+
+	return result
+}
+
+// Log returns the redacted agent output collected across all runs of the current job
+func (p *Pipeline) Log() string {
+	return p.redactor.String(p.log.String())
+}
+
+// executeWithRepair runs the agent and compiles the site. Compile errors are fed back to
+// the agent as a follow-up prompt until the site builds or the repair budget is spent.
+// The attempts made so far are returned with any error, for the failure record.
+func (p *Pipeline) executeWithRepair(ctx context.Context, prompt, siteDir string) ([]string, string, []types.RepairAttempt, error) {
+	var filesChanged []string
+	var summary string
+	var attempts []types.RepairAttempt
+
+	for attempt := 0; attempt <= p.maxRepairs; attempt++ {
+		err := p.executor.Execute(ctx, prompt)
+		fmt.Fprintf(&p.log, "=== Attempt %d ===\n%s", attempt, p.executor.GetOutput())
+		if err != nil {
+			return nil, "", attempts, err
+		}
+
+		files, runSummary := p.executor.ParseOutput()
+		filesChanged = mergeFiles(filesChanged, files)
+		if summary == "" {
+			summary = runSummary
+		}
+
+		p.status.UpdateStatus("executing", "Compiling site", 50)
+		compileErrors, err := p.builder.Build(ctx, siteDir)
+		if err != nil {
+			return nil, "", attempts, err
+		}
+
+		if len(compileErrors) == 0 {
+			attempts = append(attempts, types.RepairAttempt{Attempt: attempt, Outcome: "passed"})
+			return filesChanged, summary, attempts, nil
+		}
+
+		attempts = append(attempts, types.RepairAttempt{
+			Attempt: attempt,
+			Errors:  compileErrors,
+			Outcome: "failed",
+		})
+
+		p.status.UpdateStatus("executing", fmt.Sprintf("Repairing compile errors (attempt %d of %d)", attempt+1, p.maxRepairs), 55)
+		prompt = RepairPrompt(compileErrors)
+	}
+
+	last := attempts[len(attempts)-1].Errors
+	return filesChanged, "", attempts, fmt.Errorf("site still has %d compile errors after %d repair attempts: %s", len(last), p.maxRepairs, last[0].Error())
+}
+
+// RepairPrompt builds the follow-up prompt that asks the agent to fix compile errors
+func RepairPrompt(compileErrors []types.CompileError) string {
+	var b strings.Builder
+	b.WriteString("The site failed to compile after your last change. ")
+	b.WriteString("Fix the following errors without making any other changes:\n\n")
+	for _, e := range compileErrors {
+		fmt.Fprintf(&b, "- %s\n", e.Error())
+	}
+	b.WriteString("\nEnd your response with the FILES_CHANGED and SUMMARY sections as usual.")
+	return b.String()
+}
+
+func mergeFiles(existing, added []string) []string {
+	seen := make(map[string]bool, len(existing))
+	for _, f := range existing {
+		seen[f] = true
+	}
+	for _, f := range added {
+		if !seen[f] {
+			seen[f] = true
+			existing = append(existing, f)
+		}
+	}
+	return existing
+}
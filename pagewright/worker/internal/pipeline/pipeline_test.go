@@ -0,0 +1,375 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/artifact"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/checks"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/codex"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/compiler"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/redact"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/storage"
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopStatus struct{}
+
+func (noopStatus) UpdateStatus(state, step string, progress int) {}
+
+// mockCompiler fails while content/home/index.md contains BROKEN
+const mockCompiler = `#!/bin/sh
+if grep -q BROKEN "$PWD/content/home/index.md"; then
+  echo "Error: $PWD/content/home/index.md:2:1: component block not closed (missing :::)"
+  exit 1
+fi
+echo "Build complete!"
+`
+
+func setupPipeline(t *testing.T, codexScript string, maxRepairs int) (*Pipeline, string) {
+	workDir := t.TempDir()
+	siteDir := SiteDir(workDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(siteDir, "content", "home"), 0755))
+
+	binDir := t.TempDir()
+	codexPath := filepath.Join(binDir, "mock-codex")
+	compilerPath := filepath.Join(binDir, "mock-pagewrightc")
+	require.NoError(t, os.WriteFile(codexPath, []byte(codexScript), 0755))
+	require.NoError(t, os.WriteFile(compilerPath, []byte(mockCompiler), 0755))
+
+	p := &Pipeline{
+		workDir:        workDir,
+		maxRepairs:     maxRepairs,
+		artifactFormat: artifact.FormatTarGz,
+		executor:       codex.NewExecutor(codexPath, siteDir, "test-key", ""),
+		builder:        compiler.NewBuilder(compilerPath, "/themes/starter"),
+		status:         noopStatus{},
+	}
+	return p, siteDir
+}
+
+func TestExecuteWithRepairFixesErrors(t *testing.T) {
+	// First run writes a broken page, the repair run fixes it
+	script := `#!/bin/sh
+case "$2" in
+  *"failed to compile"*) echo "# Home" > content/home/index.md ;;
+  *) printf '# Home\n:::component Hero BROKEN\n' > content/home/index.md ;;
+esac
+echo "FILES_CHANGED:"
+echo "- modified: content/home/index.md"
+echo ""
+echo "SUMMARY:"
+echo "Updated the home page"
+`
+	p, siteDir := setupPipeline(t, script, 2)
+
+	files, summary, attempts, err := p.executeWithRepair(context.Background(), "Add a hero", siteDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"content/home/index.md"}, files)
+	assert.Equal(t, "Updated the home page", summary)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, "failed", attempts[0].Outcome)
+	require.Len(t, attempts[0].Errors, 1)
+	assert.Equal(t, "content/home/index.md", attempts[0].Errors[0].File)
+	assert.Equal(t, 2, attempts[0].Errors[0].Line)
+	assert.Equal(t, "passed", attempts[1].Outcome)
+	assert.Contains(t, p.Log(), "=== Attempt 1 ===")
+}
+
+func TestExecuteWithRepairGivesUp(t *testing.T) {
+	script := `#!/bin/sh
+printf '# Home\n:::component Hero BROKEN\n' > content/home/index.md
+`
+	p, siteDir := setupPipeline(t, script, 1)
+
+	_, _, attempts, err := p.executeWithRepair(context.Background(), "Add a hero", siteDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "after 1 repair attempts")
+	require.Len(t, attempts, 2)
+	assert.Equal(t, "failed", attempts[1].Outcome)
+}
+
+func TestRepairPrompt(t *testing.T) {
+	prompt := RepairPrompt([]types.CompileError{
+		{File: "content/home/index.md", Line: 4, Column: 1, Message: "unknown component: Banner"},
+	})
+
+	assert.Contains(t, prompt, "failed to compile")
+	assert.Contains(t, prompt, "- content/home/index.md:4:1: unknown component: Banner")
+}
+
+func TestRunReportsNoChanges(t *testing.T) {
+	script := `#!/bin/sh
+echo "SUMMARY: The hero already uses that headline, so nothing needed to change."
+`
+	p, siteDir := setupPipeline(t, script, 1)
+	require.NoError(t, os.WriteFile(filepath.Join(siteDir, "content", "home", "index.md"), []byte("# Home"), 0644))
+
+	instructions := filepath.Join(t.TempDir(), "instructions.md")
+	require.NoError(t, os.WriteFile(instructions, []byte("# Instructions"), 0644))
+	p.instructionsPath = instructions
+
+	// No storage client is configured, so any upload attempt would panic
+	result, err := p.Run(context.Background(), &types.Job{
+		JobID:         "job-1",
+		SiteID:        "site-1",
+		Prompt:        "Set the hero headline",
+		TargetVersion: "v2",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, types.JobResultNoChanges, result.Status)
+	assert.Empty(t, result.TargetVersion)
+	assert.Contains(t, result.Result, "nothing needed to change")
+}
+
+func TestFailedResultReportsLimit(t *testing.T) {
+	p, _ := setupPipeline(t, "#!/bin/sh\n", 0)
+	job := &types.Job{JobID: "job-1", TargetVersion: "v2"}
+
+	result := p.FailedResult(job, fmt.Errorf("agent run failed: %w", &codex.LimitError{
+		Limit:  codex.LimitFilesTouched,
+		Detail: "12 files touched, maximum is 10",
+	}))
+
+	assert.Equal(t, types.JobResultFailed, result.Status)
+	assert.Equal(t, codex.LimitFilesTouched, result.LimitExceeded)
+	assert.Contains(t, result.ErrorMessage, "files_touched")
+
+	result = p.FailedResult(job, fmt.Errorf("storage unavailable"))
+	assert.Empty(t, result.LimitExceeded)
+}
+
+func TestFailedResultRedactsSecrets(t *testing.T) {
+	p, _ := setupPipeline(t, "#!/bin/sh\n", 0)
+	p.redactor = redact.New("super-secret-value")
+
+	result := p.FailedResult(&types.Job{JobID: "job-1"}, fmt.Errorf("request with super-secret-value failed"))
+
+	assert.Equal(t, "request with [REDACTED] failed", result.ErrorMessage)
+}
+
+func TestRunChecksFillsManifest(t *testing.T) {
+	p, siteDir := setupPipeline(t, "#!/bin/sh\n", 0)
+	p.checker = checks.NewChecker(nil)
+
+	public := filepath.Join(siteDir, "public")
+	require.NoError(t, os.MkdirAll(public, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(public, "index.html"),
+		[]byte(`<html lang="en"><title>Home</title><h1>Home</h1><a href="/missing/">Gone</a></html>`), 0644))
+
+	manifest := &types.Manifest{}
+	p.runChecks(context.Background(), siteDir, manifest)
+
+	assert.Equal(t, []string{"/"}, manifest.Entrypoints)
+	assert.False(t, manifest.ChecksPassed)
+	require.NotNil(t, manifest.Checks)
+	assert.Equal(t, 1, manifest.Checks.Errors)
+	assert.Contains(t, p.Log(), "=== Render checks ===")
+}
+
+func TestRunUsesAndRecordsSession(t *testing.T) {
+	var recorded types.SessionTurn
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/sites/site-1/session":
+			assert.Equal(t, "3", r.URL.Query().Get("limit"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"turns": []types.SessionTurn{{
+					JobID:   "job-0",
+					Status:  types.JobResultCompleted,
+					Prompt:  "Add a call-to-action button",
+					Summary: "Added a red CTA to the home page",
+				}},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/sites/site-1/session":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&recorded))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	// The agent echoes its prompt and leaves the site untouched
+	script := `#!/bin/sh
+echo "$2"
+echo "SUMMARY: The button is already blue."
+`
+	p, siteDir := setupPipeline(t, script, 0)
+	p.storage = storage.NewClient(server.URL)
+	p.sessionTurns = 3
+	require.NoError(t, os.WriteFile(filepath.Join(siteDir, "content", "home", "index.md"), []byte("# Home"), 0644))
+
+	instructions := filepath.Join(t.TempDir(), "instructions.md")
+	require.NoError(t, os.WriteFile(instructions, []byte("# Instructions"), 0644))
+	p.instructionsPath = instructions
+
+	result, err := p.Run(context.Background(), &types.Job{
+		JobID:  "job-1",
+		SiteID: "site-1",
+		Prompt: "Now make that button blue too",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.JobResultNoChanges, result.Status)
+
+	assert.Contains(t, p.Log(), "Request: Add a call-to-action button")
+	assert.Contains(t, p.Log(), "## Current request")
+
+	assert.Equal(t, "job-1", recorded.JobID)
+	assert.Equal(t, types.JobResultNoChanges, recorded.Status)
+	assert.Equal(t, "Now make that button blue too", recorded.Prompt)
+	assert.Equal(t, "The button is already blue.", recorded.Summary)
+	assert.False(t, recorded.Timestamp.IsZero())
+}
+
+func TestRunPlacesAttachments(t *testing.T) {
+	var manifest types.Manifest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sites/site-1/attachments/upload-1/logo.png":
+			w.Write([]byte("png data"))
+			return
+		case "/artifacts/site-1/v2", "/artifacts/site-1/v2/logs":
+			io.Copy(io.Discard, r.Body)
+		case "/artifacts/site-1/v2/manifest":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&manifest))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// The agent echoes its prompt and leaves the site untouched
+	script := `#!/bin/sh
+echo "$2"
+echo "SUMMARY: Nothing to do."
+`
+	p, siteDir := setupPipeline(t, script, 0)
+	p.storage = storage.NewClient(server.URL)
+	require.NoError(t, os.WriteFile(filepath.Join(siteDir, "content", "home", "index.md"), []byte("# Home"), 0644))
+
+	instructions := filepath.Join(t.TempDir(), "instructions.md")
+	require.NoError(t, os.WriteFile(instructions, []byte("# Instructions"), 0644))
+	p.instructionsPath = instructions
+
+	result, err := p.Run(context.Background(), &types.Job{
+		JobID:         "job-1",
+		SiteID:        "site-1",
+		Prompt:        "Use logo.png in the header",
+		TargetVersion: "v2",
+		UploadID:      "upload-1",
+		Attachments:   []string{"logo.png"},
+	})
+	require.NoError(t, err)
+
+	// Placing the files is a change, even though the agent did nothing else
+	assert.Equal(t, types.JobResultCompleted, result.Status)
+	assert.Contains(t, manifest.FilesChanged, "content/home/assets/logo.png")
+
+	data, err := os.ReadFile(filepath.Join(siteDir, "content", "home", "assets", "logo.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png data", string(data))
+	assert.Contains(t, p.Log(), "- logo.png: content/home/assets/logo.png (published at /assets/pages/home/logo.png)")
+}
+
+func TestRunDryRunMarksDraft(t *testing.T) {
+	var manifest types.Manifest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artifacts/site-1/v2":
+			io.Copy(io.Discard, r.Body)
+		case "/artifacts/site-1/v2/manifest":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&manifest))
+		case "/artifacts/site-1/v2/logs":
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	script := `#!/bin/sh
+echo "# Home, now with a hero" > content/home/index.md
+echo "SUMMARY: Added a hero."
+`
+	p, siteDir := setupPipeline(t, script, 0)
+	p.storage = storage.NewClient(server.URL)
+	require.NoError(t, os.WriteFile(filepath.Join(siteDir, "content", "home", "index.md"), []byte("# Home"), 0644))
+
+	instructions := filepath.Join(t.TempDir(), "instructions.md")
+	require.NoError(t, os.WriteFile(instructions, []byte("# Instructions"), 0644))
+	p.instructionsPath = instructions
+
+	result, err := p.Run(context.Background(), &types.Job{
+		JobID:         "job-1",
+		SiteID:        "site-1",
+		Prompt:        "Add a hero, but show me first",
+		TargetVersion: "v2",
+		DryRun:        true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, types.JobResultCompleted, result.Status)
+	assert.True(t, result.Draft)
+	assert.True(t, manifest.Draft)
+	assert.Equal(t, "v2", manifest.BuildID)
+}
+
+func TestRunRecordsFailedRepairs(t *testing.T) {
+	var manifest types.Manifest
+	var logs bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artifacts/site-1/v2/manifest":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&manifest))
+		case "/artifacts/site-1/v2/logs":
+			logs = true
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	script := `#!/bin/sh
+printf '# Home\n:::component Hero BROKEN\n' > content/home/index.md
+echo "FILES_CHANGED:"
+echo "- modified: content/home/index.md"
+`
+	p, _ := setupPipeline(t, script, 1)
+	p.storage = storage.NewClient(server.URL)
+
+	instructions := filepath.Join(t.TempDir(), "instructions.md")
+	require.NoError(t, os.WriteFile(instructions, []byte("# Instructions"), 0644))
+	p.instructionsPath = instructions
+
+	_, err := p.Run(context.Background(), &types.Job{
+		JobID:         "job-1",
+		SiteID:        "site-1",
+		Prompt:        "Add a hero",
+		TargetVersion: "v2",
+	})
+	require.Error(t, err)
+
+	// The artifact is never uploaded, but the repair attempts and logs are kept
+	assert.Equal(t, "v2", manifest.BuildID)
+	assert.Equal(t, err.Error(), manifest.Error)
+	assert.Equal(t, []string{"content/home/index.md"}, manifest.FilesChanged)
+	require.Len(t, manifest.RepairAttempts, 2)
+	assert.Equal(t, "failed", manifest.RepairAttempts[1].Outcome)
+	require.Len(t, manifest.RepairAttempts[1].Errors, 1)
+	assert.Equal(t, "content/home/index.md", manifest.RepairAttempts[1].Errors[0].File)
+	assert.True(t, logs)
+}
@@ -0,0 +1,123 @@
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Mask replaces every redacted value
+const Mask = "[REDACTED]"
+
+// minSecretLength keeps short configured values from masking ordinary words
+const minSecretLength = 6
+
+// credentialPatterns match common credential formats. When a pattern has a capture group,
+// only the group is masked so the surrounding key name stays readable.
+var credentialPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{20,}`),                                                       // OpenAI-style API keys
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                                               // AWS access key IDs
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                                              // GitHub tokens
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),                                            // GitHub fine-grained tokens
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),                                           // Slack tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`),                // JWTs
+	regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----.*?-----END [A-Z0-9 ]*PRIVATE KEY-----`), // keys on one line
+	regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{8,}=*)`),
+	regexp.MustCompile(`(?i)://[^/\s:@]+:([^/\s@]{3,})@`), // passwords in URLs
+	regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:api[_-]?key|secret|password|passwd|token|credential)[A-Z0-9_]*["']?\s*[:=]\s*["']?([^\s"',;]{8,})`),
+}
+
+var privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----`)
+var privateKeyEnd = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY-----`)
+
+// Redactor masks configured secret values and common credential patterns
+type Redactor struct {
+	secrets []string
+}
+
+// New creates a redactor for the given secret values. Empty and very short values are ignored.
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, s := range secrets {
+		s = strings.TrimSpace(s)
+		if len(s) >= minSecretLength {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longest first, so a secret that contains another is masked whole
+	sort.Slice(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+	return r
+}
+
+// String redacts a block of text, including multi-line private keys
+func (r *Redactor) String(text string) string {
+	if r == nil {
+		return text
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	stream := r.NewStream()
+	var b strings.Builder
+	for _, line := range lines {
+		content := strings.TrimSuffix(line, "\n")
+		b.WriteString(stream.Line(content))
+		if len(content) < len(line) {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Line redacts a single line. Use a Stream for output that may contain private key blocks.
+func (r *Redactor) Line(line string) string {
+	if r == nil {
+		return line
+	}
+
+	for _, s := range r.secrets {
+		line = strings.ReplaceAll(line, s, Mask)
+	}
+
+	for _, re := range credentialPatterns {
+		line = re.ReplaceAllStringFunc(line, func(match string) string {
+			groups := re.FindStringSubmatchIndex(match)
+			if len(groups) < 4 || groups[2] < 0 {
+				return Mask
+			}
+			return match[:groups[2]] + Mask + match[groups[3]:]
+		})
+	}
+
+	return line
+}
+
+// Stream redacts output line by line, masking the body of private key blocks
+type Stream struct {
+	redactor *Redactor
+	inKey    bool
+}
+
+// NewStream starts redacting a new line-oriented stream
+func (r *Redactor) NewStream() *Stream {
+	return &Stream{redactor: r}
+}
+
+// Line redacts the next line of the stream
+func (s *Stream) Line(line string) string {
+	if s.inKey {
+		if privateKeyEnd.MatchString(line) {
+			s.inKey = false
+			return line
+		}
+		return Mask
+	}
+
+	if privateKeyBegin.MatchString(line) && !privateKeyEnd.MatchString(line) {
+		s.inKey = true
+		return line
+	}
+
+	return s.redactor.Line(line)
+}
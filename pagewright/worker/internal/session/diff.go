@@ -0,0 +1,195 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The unified diff in this file is copied to storage/internal/diff/unified.go. Each service
+// is its own module and Docker build context, so they cannot share a package: change both.
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// maxDiffLines bounds the Myers search; larger file pairs are summarised instead of diffed
+const maxDiffLines = 4000
+
+type edit struct {
+	kind byte // ' ' unchanged, '-' removed, '+' added
+	line string
+}
+
+// unifiedDiff renders a unified diff between two versions of a file.
+// created and deleted mark a file that is missing on one side.
+func unifiedDiff(path string, before, after []byte, created, deleted bool) string {
+	var b strings.Builder
+
+	from, to := "a/"+path, "b/"+path
+	if created {
+		from = "/dev/null"
+	}
+	if deleted {
+		to = "/dev/null"
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+
+	a, c := splitLines(before), splitLines(after)
+	if len(a)+len(c) > maxDiffLines {
+		fmt.Fprintf(&b, "@@ file too large to diff (%d -> %d lines) @@\n", len(a), len(c))
+		return b.String()
+	}
+
+	edits := diffLines(a, c)
+	for _, h := range hunks(edits) {
+		writeHunk(&b, edits, h[0], h[1])
+	}
+	return b.String()
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the shortest edit script from a to b with Myers' algorithm
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+
+	// trace[d] holds v[-d..d] as it was before step d, indexed by k+d
+	var trace [][]int
+	for d := 0; d <= offset; d++ {
+		snapshot := make([]int, 2*d+1)
+		for k := -d; k <= d; k++ {
+			snapshot[k+d] = v[offset+k]
+		}
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // insertion: move down from diagonal k+1
+			} else {
+				x = v[offset+k-1] + 1 // deletion: move right from diagonal k-1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, a, b []string, depth int) []edit {
+	x, y := len(a), len(b)
+	var reversed []edit
+
+	for d := depth; d > 0; d-- {
+		prev := trace[d] // state after step d-1, indexed by k+d
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, edit{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, edit{'+', b[y]})
+		} else {
+			x--
+			reversed = append(reversed, edit{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, edit{' ', a[x]})
+	}
+
+	edits := make([]edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
+
+// hunks groups changes into [start, end) ranges of edits, including surrounding context
+func hunks(edits []edit) [][2]int {
+	var out [][2]int
+	for i, e := range edits {
+		if e.kind == ' ' {
+			continue
+		}
+		start := max(i-contextLines, 0)
+		end := min(i+contextLines+1, len(edits))
+		if len(out) > 0 && start <= out[len(out)-1][1] {
+			out[len(out)-1][1] = end
+			continue
+		}
+		out = append(out, [2]int{start, end})
+	}
+	return out
+}
+
+func writeHunk(b *strings.Builder, edits []edit, start, end int) {
+	// Line numbers are 1-based positions in the old and new file
+	aStart, bStart := 1, 1
+	for _, e := range edits[:start] {
+		if e.kind != '+' {
+			aStart++
+		}
+		if e.kind != '-' {
+			bStart++
+		}
+	}
+
+	aLen, bLen := 0, 0
+	for _, e := range edits[start:end] {
+		if e.kind != '+' {
+			aLen++
+		}
+		if e.kind != '-' {
+			bLen++
+		}
+	}
+	// An empty range refers to the line before it
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, e := range edits[start:end] {
+		b.WriteByte(e.kind)
+		b.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
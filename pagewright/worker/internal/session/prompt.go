@@ -0,0 +1,42 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+)
+
+// Prompt prefixes the current request with the site's earlier turns so follow-ups such as
+// "now make that button blue too" can be resolved. Without history the prompt is unchanged.
+func Prompt(history []types.SessionTurn, prompt string) string {
+	if len(history) == 0 {
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString("This site has been edited in earlier requests. They are listed oldest first ")
+	b.WriteString("for context; only carry out the current request.\n\n")
+
+	for i, turn := range history {
+		fmt.Fprintf(&b, "## Earlier request %d\n\n", i+1)
+		fmt.Fprintf(&b, "Request: %s\n", turn.Prompt)
+		if turn.Status == types.JobResultNoChanges {
+			b.WriteString("Outcome: no changes were made\n")
+		}
+		if turn.Summary != "" {
+			fmt.Fprintf(&b, "Summary: %s\n", turn.Summary)
+		}
+		if len(turn.FilesChanged) > 0 {
+			fmt.Fprintf(&b, "Files changed: %s\n", strings.Join(turn.FilesChanged, ", "))
+		}
+		if turn.Diff != "" {
+			fmt.Fprintf(&b, "\n```diff\n%s```\n", turn.Diff)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Current request\n\n")
+	b.WriteString(prompt)
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestDiffModifiedFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "content/home/index.md", "# Home\n\nWelcome\n\n:::component Hero\ncolor: red\n:::\n")
+
+	before, err := Take(dir)
+	require.NoError(t, err)
+
+	writeFile(t, dir, "content/home/index.md", "# Home\n\nWelcome\n\n:::component Hero\ncolor: blue\n:::\n")
+
+	after, err := Take(dir)
+	require.NoError(t, err)
+
+	expected := `--- a/content/home/index.md
++++ b/content/home/index.md
+@@ -3,5 +3,5 @@
+ Welcome
+ 
+ :::component Hero
+-color: red
++color: blue
+ :::
+`
+	assert.Equal(t, expected, Diff(before, after))
+}
+
+func TestDiffCreatedAndDeletedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "content/old/index.md", "# Old\n")
+
+	before, err := Take(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "content/old/index.md")))
+	writeFile(t, dir, "content/new/index.md", "# New\nPage")
+
+	after, err := Take(dir)
+	require.NoError(t, err)
+
+	diff := Diff(before, after)
+	assert.Contains(t, diff, "--- /dev/null\n+++ b/content/new/index.md\n@@ -0,0 +1,2 @@\n+# New\n+Page\n\\ No newline at end of file\n")
+	assert.Contains(t, diff, "--- a/content/old/index.md\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-# Old\n")
+}
+
+func TestDiffSkipsGeneratedOutput(t *testing.T) {
+	dir := t.TempDir()
+	before, err := Take(dir)
+	require.NoError(t, err)
+
+	writeFile(t, dir, "public/index.html", "<html></html>")
+	writeFile(t, dir, ".pagewright/screenshots/index.png", "png")
+	writeFile(t, dir, "assets/logo.png", "\x89PNG\x00\x01")
+
+	after, err := Take(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Binary file assets/logo.png changed\n", Diff(before, after))
+}
+
+func TestDiffSeparatesDistantChanges(t *testing.T) {
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	a := strings.Join(lines, "\n") + "\n"
+	lines[1], lines[18] = "first", "last"
+	b := strings.Join(lines, "\n") + "\n"
+
+	diff := unifiedDiff("f.md", []byte(a), []byte(b), false, false)
+
+	assert.Equal(t, 2, strings.Count(diff, "@@ -"))
+	assert.Contains(t, diff, "@@ -1,5 +1,5 @@\n line 0\n-line 1\n+first\n")
+	assert.Contains(t, diff, "@@ -16,5 +16,5 @@\n line 15\n line 16\n line 17\n-line 18\n+last\n line 19\n")
+}
+
+func TestTruncate(t *testing.T) {
+	diff := "line one\nline two\nline three\n"
+
+	assert.Equal(t, diff, Truncate(diff, 100))
+	assert.Equal(t, "line one\n... diff truncated (20 more bytes)\n", Truncate(diff, 12))
+}
+
+func TestPrompt(t *testing.T) {
+	assert.Equal(t, "Make it blue", Prompt(nil, "Make it blue"))
+
+	prompt := Prompt([]types.SessionTurn{
+		{
+			Prompt:       "Add a button",
+			Status:       types.JobResultCompleted,
+			Summary:      "Added a red CTA",
+			FilesChanged: []string{"content/home/index.md"},
+			Diff:         "+cta\n",
+		},
+		{Prompt: "Change the title", Status: types.JobResultNoChanges},
+	}, "Now make that button blue too")
+
+	assert.Contains(t, prompt, "## Earlier request 1\n\nRequest: Add a button\nSummary: Added a red CTA\nFiles changed: content/home/index.md\n\n```diff\n+cta\n```\n")
+	assert.Contains(t, prompt, "## Earlier request 2\n\nRequest: Change the title\nOutcome: no changes were made\n")
+	assert.True(t, strings.HasSuffix(prompt, "## Current request\n\nNow make that button blue too"))
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxFileBytes is the largest file whose content is kept for diffing
+const maxFileBytes = 256 * 1024
+
+// skippedDirs are top-level directories that are generated or managed by the worker
+var skippedDirs = map[string]bool{
+	"public":      true,
+	".pagewright": true,
+	".codex":      true,
+	".git":        true,
+}
+
+type fileEntry struct {
+	data []byte // nil for files over maxFileBytes
+	sum  [sha256.Size]byte
+}
+
+// Snapshot records the site sources so changes made by the agent can be diffed
+type Snapshot map[string]fileEntry
+
+// Take snapshots the files under siteDir, skipping generated output
+func Take(siteDir string) (Snapshot, error) {
+	snap := make(Snapshot)
+	err := filepath.WalkDir(siteDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(siteDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if skippedDirs[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+
+		entry := fileEntry{sum: sha256.Sum256(data)}
+		if len(data) <= maxFileBytes {
+			entry.data = data
+		}
+		snap[rel] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot site: %w", err)
+	}
+	return snap, nil
+}
+
+// Diff returns a unified diff of every file that differs between before and after
+func Diff(before, after Snapshot) string {
+	paths := make([]string, 0, len(before)+len(after))
+	for path := range before {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		old, hadOld := before[path]
+		cur, hasCur := after[path]
+		if hadOld && hasCur && old.sum == cur.sum {
+			continue
+		}
+
+		switch {
+		case (hadOld && old.data == nil) || (hasCur && cur.data == nil):
+			fmt.Fprintf(&b, "Large file %s changed\n", path)
+		case isBinary(old.data) || isBinary(cur.data):
+			fmt.Fprintf(&b, "Binary file %s changed\n", path)
+		default:
+			b.WriteString(unifiedDiff(path, old.data, cur.data, !hadOld, !hasCur))
+		}
+	}
+	return b.String()
+}
+
+// Truncate shortens a diff to at most maxBytes, cutting at a line boundary
+func Truncate(diff string, maxBytes int) string {
+	if maxBytes <= 0 || len(diff) <= maxBytes {
+		return diff
+	}
+	cut := diff[:maxBytes]
+	if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+		cut = cut[:i+1]
+	}
+	return cut + fmt.Sprintf("... diff truncated (%d more bytes)\n", len(diff)-len(cut))
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) != -1
+}
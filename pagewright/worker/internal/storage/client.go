@@ -2,16 +2,26 @@ package storage
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/bdobrica/PageWrightCloud/pagewright/worker/internal/types"
 )
 
+// DigestHeader carries the hex SHA-256 of an artifact stream, as a header or trailer
+const DigestHeader = "X-Content-SHA256"
+
+// InstanceDigestHeader is the RFC 3230 digest header, "sha-256=<base64>", which storage sends
+// for artifacts served in their stored format
+const InstanceDigestHeader = "Digest"
+
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
@@ -26,11 +36,19 @@ func NewClient(baseURL string) *Client {
 	}
 }
 
-// FetchArtifact downloads an artifact to the specified destination
-func (c *Client) FetchArtifact(siteID, versionID, destPath string) error {
+// FetchArtifact streams an artifact from storage into unpack without a local archive.
+// The SHA-256 of the stream is verified against the digest sent by storage, if any.
+func (c *Client) FetchArtifact(siteID, versionID string, unpack func(io.Reader) error) error {
 	url := fmt.Sprintf("%s/artifacts/%s/%s", c.baseURL, siteID, versionID)
 
-	resp, err := c.httpClient.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	// Either codec unpacks, so storage serves the archive as stored without transcoding
+	req.Header.Set("Accept-Encoding", "zstd, gzip")
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to fetch artifact: %w", err)
 	}
@@ -40,74 +58,99 @@ func (c *Client) FetchArtifact(siteID, versionID, destPath string) error {
 		return fmt.Errorf("failed to fetch artifact: status %d", resp.StatusCode)
 	}
 
-	// Ensure destination directory exists
-	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	hasher := sha256.New()
+	body := io.TeeReader(resp.Body, hasher)
+
+	if err := unpack(body); err != nil {
+		return err
 	}
 
-	// Create destination file
-	out, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+	// Drain anything the unpacker did not consume so the digest and trailers are complete
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return fmt.Errorf("failed to read artifact: %w", err)
 	}
-	defer out.Close()
 
-	// Copy content
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		return fmt.Errorf("failed to write artifact: %w", err)
+	expected := resp.Header.Get(DigestHeader)
+	if expected == "" {
+		expected = parseInstanceDigest(resp.Header.Get(InstanceDigestHeader))
+	}
+	if expected == "" {
+		expected = resp.Trailer.Get(DigestHeader)
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); expected != "" && expected != actual {
+		return fmt.Errorf("artifact digest mismatch: expected %s, got %s", expected, actual)
 	}
 
 	return nil
 }
 
-// UploadArtifact uploads an artifact file
-func (c *Client) UploadArtifact(siteID, versionID, artifactPath string) error {
-	url := fmt.Sprintf("%s/artifacts/%s/%s", c.baseURL, siteID, versionID)
-
-	// Open the artifact file
-	file, err := os.Open(artifactPath)
-	if err != nil {
-		return fmt.Errorf("failed to open artifact: %w", err)
-	}
-	defer file.Close()
-
-	// Create multipart form
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	part, err := writer.CreateFormFile("artifact", filepath.Base(artifactPath))
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
-	}
-
-	if _, err := io.Copy(part, file); err != nil {
-		return fmt.Errorf("failed to copy file data: %w", err)
+// parseInstanceDigest returns the hex SHA-256 of a Digest header, or "" if it has none
+func parseInstanceDigest(value string) string {
+	for _, part := range strings.Split(value, ",") {
+		algorithm, encoded, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.EqualFold(algorithm, "sha-256") {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sum) != sha256.Size {
+			continue
+		}
+		return hex.EncodeToString(sum)
 	}
+	return ""
+}
 
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close multipart writer: %w", err)
-	}
+// UploadArtifact streams the archive written by pack straight into the upload request body.
+// The SHA-256 of the stream is sent as a trailer so storage can verify it, and returned.
+func (c *Client) UploadArtifact(siteID, versionID, contentType string, pack func(io.Writer) error) (string, error) {
+	url := fmt.Sprintf("%s/artifacts/%s/%s", c.baseURL, siteID, versionID)
 
-	// Create request
-	req, err := http.NewRequest("PUT", url, body)
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("PUT", url, pr)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = -1
+	req.Trailer = http.Header{DigestHeader: nil}
+
+	hasher := sha256.New()
+	var digest string
+	packErr := make(chan error, 1)
+
+	go func() {
+		err := pack(io.MultiWriter(pw, hasher))
+		if err == nil {
+			digest = hex.EncodeToString(hasher.Sum(nil))
+			req.Trailer.Set(DigestHeader, digest)
+		}
+		pw.CloseWithError(err)
+		packErr <- err
+	}()
 
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to upload artifact: %w", err)
+		pr.CloseWithError(err)
+		if perr := <-packErr; perr != nil {
+			return "", fmt.Errorf("failed to pack artifact: %w", perr)
+		}
+		return "", fmt.Errorf("failed to upload artifact: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// The transport has finished with the body, so the packer has stopped
+	pr.Close()
+	if err := <-packErr; err != nil {
+		return "", fmt.Errorf("failed to pack artifact: %w", err)
+	}
+
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to upload artifact: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return "", fmt.Errorf("failed to upload artifact: status %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	return nil
+	return digest, nil
 }
 
 // UploadManifest uploads a manifest JSON file
@@ -171,3 +214,79 @@ func (c *Client) UploadLog(siteID, versionID, logContent string) error {
 
 	return nil
 }
+
+// FetchAttachment streams a file uploaded with the build request into w
+func (c *Client) FetchAttachment(siteID, uploadID, name string, w io.Writer) error {
+	url := fmt.Sprintf("%s/sites/%s/attachments/%s/%s", c.baseURL, siteID, uploadID, name)
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch attachment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to fetch attachment %s: status %d, body: %s", name, resp.StatusCode, string(bodyBytes))
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read attachment %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// FetchSession returns the site's last limit conversation turns, oldest first
+func (c *Client) FetchSession(siteID string, limit int) ([]types.SessionTurn, error) {
+	url := fmt.Sprintf("%s/sites/%s/session?limit=%d", c.baseURL, siteID, limit)
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch session: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var session struct {
+		Turns []types.SessionTurn `json:"turns"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+
+	return session.Turns, nil
+}
+
+// AppendSessionTurn records a finished turn in the site's conversation history
+func (c *Client) AppendSessionTurn(siteID string, turn *types.SessionTurn) error {
+	url := fmt.Sprintf("%s/sites/%s/session", c.baseURL, siteID)
+
+	jsonData, err := json.Marshal(turn)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session turn: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to append session turn: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to append session turn: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
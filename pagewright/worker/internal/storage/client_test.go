@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadArtifactStreamsWithDigestTrailer(t *testing.T) {
+	var received []byte
+	var trailerDigest string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/artifacts/site-1/v2", r.URL.Path)
+		assert.Equal(t, int64(-1), r.ContentLength)
+		assert.Equal(t, "application/zstd", r.Header.Get("Content-Type"))
+		received, _ = io.ReadAll(r.Body)
+		trailerDigest = r.Trailer.Get(DigestHeader)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	digest, err := client.UploadArtifact("site-1", "v2", "application/zstd", func(w io.Writer) error {
+		_, err := w.Write([]byte("packed site"))
+		return err
+	})
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte("packed site"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), digest)
+	assert.Equal(t, digest, trailerDigest)
+	assert.Equal(t, []byte("packed site"), received)
+}
+
+func TestUploadArtifactPackError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.UploadArtifact("site-1", "v2", "application/gzip", func(w io.Writer) error {
+		return assert.AnError
+	})
+	assert.Error(t, err)
+}
+
+func TestFetchArtifactVerifiesDigest(t *testing.T) {
+	content := []byte("artifact bytes")
+	sum := sha256.Sum256(content)
+
+	tests := []struct {
+		name    string
+		digest  string
+		wantErr bool
+	}{
+		{"Matching digest", hex.EncodeToString(sum[:]), false},
+		{"Mismatched digest", "deadbeef", true},
+		{"No digest", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "zstd, gzip", r.Header.Get("Accept-Encoding"))
+				w.Header().Set("Trailer", DigestHeader)
+				w.Write(content)
+				if tt.digest != "" {
+					w.Header().Set(DigestHeader, tt.digest)
+				}
+			}))
+			defer server.Close()
+
+			var got bytes.Buffer
+			client := NewClient(server.URL)
+			err := client.FetchArtifact("site-1", "v1", func(r io.Reader) error {
+				// Read only part of the stream; the client drains the rest
+				_, err := io.CopyN(&got, r, 4)
+				return err
+			})
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, "arti", got.String())
+		})
+	}
+}
+
+func TestFetchArtifactVerifiesDigestHeader(t *testing.T) {
+	content := []byte("artifact bytes")
+	sum := sha256.Sum256(content)
+	other := sha256.Sum256([]byte("other bytes"))
+
+	for digest, wantErr := range map[string]bool{
+		"sha-256=" + base64.StdEncoding.EncodeToString(sum[:]):   false,
+		"sha-256=" + base64.StdEncoding.EncodeToString(other[:]): true,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(InstanceDigestHeader, digest)
+			w.Write(content)
+		}))
+
+		client := NewClient(server.URL)
+		err := client.FetchArtifact("site-1", "v1", func(r io.Reader) error {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		})
+		server.Close()
+
+		if wantErr {
+			assert.Error(t, err, digest)
+		} else {
+			assert.NoError(t, err, digest)
+		}
+	}
+}
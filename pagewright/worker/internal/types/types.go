@@ -1,40 +1,114 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Job represents a work unit passed from manager
 type Job struct {
-	JobID         string    `json:"job_id"`
-	SiteID        string    `json:"site_id"`
-	Prompt        string    `json:"prompt"`
-	SourceVersion string    `json:"source_version"`
-	TargetVersion string    `json:"target_version"`
-	Status        string    `json:"status"`
-	LockToken     string    `json:"lock_token,omitempty"`
-	FencingToken  int64     `json:"fencing_token"`
-	WorkerID      string    `json:"worker_id,omitempty"`
-	Result        string    `json:"result,omitempty"`
-	ErrorMessage  string    `json:"error_message,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	JobID         string        `json:"job_id"`
+	SiteID        string        `json:"site_id"`
+	Prompt        string        `json:"prompt"`
+	SourceVersion string        `json:"source_version"`
+	TargetVersion string        `json:"target_version"`
+	UploadID      string        `json:"upload_id,omitempty"`   // storage upload holding the attachments
+	Attachments   []string      `json:"attachments,omitempty"` // uploaded files the prompt refers to
+	DryRun        bool          `json:"dry_run,omitempty"`     // build a preview-only draft
+	Status        string        `json:"status"`
+	LockToken     string        `json:"lock_token,omitempty"`
+	FencingToken  int64         `json:"fencing_token"`
+	History       []SessionTurn `json:"history,omitempty"` // earlier turns; fetched from storage when empty
+	WorkerID      string        `json:"worker_id,omitempty"`
+	Result        string        `json:"result,omitempty"`
+	ErrorMessage  string        `json:"error_message,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// SessionTurn is one earlier prompt on the same site and its outcome
+type SessionTurn struct {
+	Timestamp    time.Time `json:"timestamp"`
+	JobID        string    `json:"job_id"`
+	BuildID      string    `json:"build_id,omitempty"`
+	BaseBuildID  string    `json:"base_build_id,omitempty"`
+	Status       string    `json:"status"` // completed, no_changes
+	Prompt       string    `json:"prompt"`
+	Summary      string    `json:"summary,omitempty"`
+	FilesChanged []string  `json:"files_changed,omitempty"`
+	Diff         string    `json:"diff,omitempty"`
 }
 
 // Manifest describes the output artifact
 type Manifest struct {
-	SiteID         string    `json:"site_id"`
-	BuildID        string    `json:"build_id"`
-	BaseBuildID    string    `json:"base_build_id"`
-	FencingToken   int64     `json:"fencing_token"`
-	Prompt         string    `json:"prompt"`
-	CreatedAt      time.Time `json:"created_at"`
-	FileCount      int       `json:"file_count"`
-	TotalSize      int64     `json:"total_size"`
-	Entrypoints    []string  `json:"entrypoints"`
-	Screenshots    []string  `json:"screenshots"`
-	ChecksPassed   bool      `json:"checks_passed"`
-	ConsoleErrors  int       `json:"console_errors"`
-	FilesChanged   []string  `json:"files_changed"`
-	ChangesSummary string    `json:"changes_summary"`
+	SiteID         string          `json:"site_id"`
+	BuildID        string          `json:"build_id"`
+	BaseBuildID    string          `json:"base_build_id"`
+	FencingToken   int64           `json:"fencing_token"`
+	Prompt         string          `json:"prompt"`
+	CreatedAt      time.Time       `json:"created_at"`
+	FileCount      int             `json:"file_count"`
+	TotalSize      int64           `json:"total_size"`
+	ArtifactSHA256 string          `json:"artifact_sha256,omitempty"`
+	Entrypoints    []string        `json:"entrypoints"`
+	Screenshots    []string        `json:"screenshots"`
+	ChecksPassed   bool            `json:"checks_passed"`
+	ConsoleErrors  int             `json:"console_errors"`
+	FilesChanged   []string        `json:"files_changed"`
+	ChangesSummary string          `json:"changes_summary"`
+	RepairAttempts []RepairAttempt `json:"repair_attempts,omitempty"`
+	Checks         *CheckReport    `json:"checks,omitempty"`
+	Draft          bool            `json:"draft,omitempty"` // dry run: may only be activated as preview until approved
+	Error          string          `json:"error,omitempty"` // set when the build failed; no artifact was stored
+}
+
+// CompileError mirrors the compiler's error report for a single source location
+type CompileError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *CompileError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+	if e.File != "" {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return e.Message
+}
+
+// RepairAttempt records one compile check after an agent run
+type RepairAttempt struct {
+	Attempt int            `json:"attempt"` // 0 is the initial run, 1+ are repair runs
+	Errors  []CompileError `json:"errors,omitempty"`
+	Outcome string         `json:"outcome"` // passed, failed
+}
+
+// Check severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// CheckFinding is a single problem found by the render checks
+type CheckFinding struct {
+	Page     string `json:"page"`
+	Check    string `json:"check"`    // http_error, broken_link, missing_alt, heading_order, missing_lang, missing_title, screenshot
+	Severity string `json:"severity"` // error, warning
+	Target   string `json:"target,omitempty"`
+	Message  string `json:"message"`
+}
+
+// CheckReport is the machine-readable result of crawling the compiled site
+type CheckReport struct {
+	PagesChecked int            `json:"pages_checked"`
+	LinksChecked int            `json:"links_checked"`
+	Errors       int            `json:"errors"`
+	Warnings     int            `json:"warnings"`
+	Findings     []CheckFinding `json:"findings"`
 }
 
 // WorkerStatus represents current execution state
@@ -46,12 +120,21 @@ type WorkerStatus struct {
 	Error        string `json:"error,omitempty"`
 }
 
+// Job result statuses reported to the manager
+const (
+	JobResultCompleted = "completed"
+	JobResultFailed    = "failed"
+	JobResultNoChanges = "no_changes" // the agent changed nothing; no version was created
+)
+
 // JobResult is sent back to manager when work completes
 type JobResult struct {
 	JobID         string `json:"job_id"`
-	Status        string `json:"status"` // completed, failed
+	Status        string `json:"status"` // completed, failed, no_changes
 	TargetVersion string `json:"target_version"`
 	Result        string `json:"result"`
 	ErrorMessage  string `json:"error_message,omitempty"`
+	LimitExceeded string `json:"limit_exceeded,omitempty"` // set when the agent was stopped by a resource limit
 	ManifestPath  string `json:"manifest_path,omitempty"`
+	Draft         bool   `json:"draft,omitempty"` // the version was built by a dry run
 }